@@ -1,22 +1,132 @@
 package adapter
 
 import (
+	"fmt"
+
 	emucore "github.com/user-none/eblitui/api"
+	"github.com/user-none/emmd/debugger"
 	"github.com/user-none/emmd/emu"
+	"github.com/user-none/emmd/serial"
 )
 
-// Compile-time interface check.
+// Compile-time interface checks.
 var _ emucore.CoreFactory = (*Factory)(nil)
+var _ Cheater = (*emu.Emulator)(nil)
+var _ Mouser = (*emu.Emulator)(nil)
+var _ LightGunner = (*emu.Emulator)(nil)
+var _ CompactStater = (*emu.Emulator)(nil)
+var _ StateInfoSaver = (*emu.Emulator)(nil)
+var _ Timeliner = (*emu.Emulator)(nil)
+var _ Resetter = (*emu.Emulator)(nil)
+
+// Cheater is implemented by emulators that accept cheat codes. The
+// methods mirror the libretro retro_cheat_set and retro_cheat_reset
+// callbacks so frontends can forward .cht entries directly. eblitui's
+// libretro layer does not forward them yet (its callbacks are empty in
+// v0.2.0), so the libretro core advertises no cheat support.
+type Cheater interface {
+	SetCheat(index int, enabled bool, code string) error
+	ResetCheats()
+}
+
+// Mouser is implemented by emulators with a Sega Mega Mouse. Frontends
+// forward relative pointer motion (libretro RETRO_DEVICE_MOUSE deltas or
+// host cursor movement) once per frame while the "port1" or "port2" core
+// option selects "mouse".
+type Mouser interface {
+	SetMouseInput(player int, dx, dy int, left, right, middle, start bool)
+}
+
+// LightGunner is implemented by emulators with light gun support.
+// Frontends pass the absolute framebuffer pixel under the pointer
+// (libretro RETRO_DEVICE_LIGHTGUN coordinates scaled to the screen) once
+// per frame while the "lightgun" core option selects a gun, and can read
+// the aim back to draw their own crosshair instead of enabling
+// "lightgun_crosshair".
+type LightGunner interface {
+	SetLightGunInput(player int, x, y int, trigger, start, a, b bool)
+	LightGunPosition(player int) (x, y int, onScreen bool)
+}
+
+// CompactStater is implemented by emulators with compressed save states.
+// Frontends that keep many states, such as rewind buffers and state
+// thumbnails, store SerializeDelta output against a periodic Serialize
+// keyframe and rebuild full states with emu.ExpandState. SerializeCompact
+// output loads directly through Deserialize. retro_serialize keeps using
+// the uncompressed Serialize.
+type CompactStater interface {
+	SerializeCompact() ([]byte, error)
+	SerializeDelta(key []byte) ([]byte, error)
+}
+
+// StateInfoSaver is implemented by emulators that can add a thumbnail,
+// timestamp, frame count and the core options to a save state, for save
+// slot browsers to show with emu.ReadStateInfo. Frontends call it for user
+// save slots only; rewind buffers, autosaves and movie checkpoints keep
+// using Serialize, which leaves the metadata out. Frontends without a
+// separate save slot path use Factory.StateInfo instead.
+type StateInfoSaver interface {
+	SerializeWithInfo() ([]byte, error)
+}
+
+// Timeliner is implemented by emulators that report emulated time since
+// power-on, for stamping logs, screenshots and recordings so they can be
+// matched frame and cycle exactly across runs.
+type Timeliner interface {
+	Timeline() emu.Timeline
+}
+
+// Resetter is implemented by emulators with the console's reset button
+// and power switch. SoftReset keeps RAM, which some games check to tell a
+// reset from a power-on; HardReset power cycles with a chosen RAM pattern.
+// The headless runner's input scripts call both. eblitui (v0.2.0) has no
+// reset callback, so its frontends reset by recreating the emulator
+// through CreateEmulator, which is a power cycle with zeroed RAM.
+type Resetter interface {
+	SoftReset()
+	HardReset(init emu.RAMInit)
+}
 
 // Factory implements emucore.CoreFactory for the Genesis emulator.
-type Factory struct{}
+type Factory struct {
+	// TMSSBIOS is an optional user-supplied TMSS boot ROM. When set, it is
+	// loaded into every emulator created and mapped at reset while the
+	// "tmss" core option is enabled.
+	TMSSBIOS []byte
+
+	// GDBAddr, when set, starts a GDB remote serial protocol server on
+	// this address (for example "localhost:2345") and routes every
+	// emulator created through it.
+	GDBAddr string
+
+	// Serial, when set, connects the serial lines of controller port
+	// SerialPort (1-3, default 3 for EXT) of every emulator created to a
+	// stream opened with serial.Open, for example "tcp:localhost:4000".
+	// Emulators of the same ROM share the stream; it is closed when
+	// another ROM is loaded and by Close.
+	Serial     string
+	SerialPort int
+
+	// StateInfo makes the emulators' Serialize add the thumbnail,
+	// timestamp and options of SerializeWithInfo, and sizes SystemInfo's
+	// SerializeSize for it. It is for frontends that save user slots
+	// through Serialize and cannot call StateInfoSaver for those alone,
+	// such as eblitui standalone; their rewind and autosave states then
+	// carry the metadata too. libretro needs fixed, deterministic states
+	// and leaves it unset.
+	StateInfo bool
+
+	gdb       *debugger.GDBServer
+	serial    *serial.Stream
+	serialROM uint32 // CRC32 of the ROM the serial stream belongs to
+}
 
 // SystemInfo returns system metadata for UI configuration.
 func (f *Factory) SystemInfo() emucore.SystemInfo {
 	return emucore.SystemInfo{
 		Name:            "emmd",
 		ConsoleName:     "Sega Genesis",
-		Extensions:      []string{".md", ".bin", ".gen"},
+		Extensions:      []string{".md", ".bin", ".gen", ".sms"},
 		ScreenWidth:     emu.ScreenWidth,
 		MaxScreenHeight: emu.MaxScreenHeight,
 		// NTSC pixel aspect ratio for H40 mode (32:35).
@@ -43,16 +153,100 @@ func (f *Factory) SystemInfo() emucore.SystemInfo {
 			{Name: "Z", ID: 10, DefaultKey: "O", DefaultPad: "R1"},
 			{Name: "Start", ID: 7, DefaultKey: "Enter", DefaultPad: "Start"},
 		},
-		Players: 2,
+		Players: 4,
 		CoreOptions: []emucore.CoreOption{
 			{
-				Key:         "six_button",
-				Label:       "6-Button Controller",
-				Description: "Enable 6-button controller mode",
+				Key:         "port1",
+				Label:       "Port 1 Device",
+				Description: "Device plugged into controller port 1",
+				Type:        emucore.CoreOptionSelect,
+				Default:     "3button",
+				Values:      []string{"3button", "6button", "mouse", "none"},
+				Category:    emucore.CoreOptionCategoryInput,
+			},
+			{
+				Key:         "port2",
+				Label:       "Port 2 Device",
+				Description: "Device plugged into controller port 2; use a 3-button pad for games that break with 6-button pads",
+				Type:        emucore.CoreOptionSelect,
+				Default:     "3button",
+				Values:      []string{"3button", "6button", "mouse", "none"},
+				Category:    emucore.CoreOptionCategoryInput,
+			},
+			{
+				Key:         "multitap",
+				Label:       "Multitap",
+				Description: "Multiplayer adapter for up to 4 players: Sega Team Player in port 2 or EA 4-Way Play",
+				Type:        emucore.CoreOptionSelect,
+				Default:     "none",
+				Values:      []string{"none", "teamplayer", "ea4way"},
+				Category:    emucore.CoreOptionCategoryInput,
+			},
+			{
+				Key:         "lightgun",
+				Label:       "Light Gun",
+				Description: "Plug a Sega Menacer or Konami Justifier into port 2 in place of the pad",
+				Type:        emucore.CoreOptionSelect,
+				Default:     "none",
+				Values:      []string{"none", "menacer", "justifier"},
+				Category:    emucore.CoreOptionCategoryInput,
+			},
+			{
+				Key:         "lightgun_crosshair",
+				Label:       "Light Gun Crosshair",
+				Description: "Draw a crosshair where each light gun is aimed",
 				Type:        emucore.CoreOptionBool,
-				Default:     "false",
+				Default:     "true",
 				Category:    emucore.CoreOptionCategoryInput,
 			},
+			{
+				Key:         "tmss",
+				Label:       "TMSS",
+				Description: "Emulate the Model 1 VA6+ Trademark Security System: lock the VDP until \"SEGA\" is written to $A14000 and boot through the TMSS BIOS if one is supplied",
+				Type:        emucore.CoreOptionBool,
+				Default:     "false",
+				Category:    emucore.CoreOptionCategoryCore,
+			},
+			{
+				Key:         "layer_plane_a",
+				Label:       "Show Plane A",
+				Description: "Draw scroll plane A",
+				Type:        emucore.CoreOptionBool,
+				Default:     "true",
+				Category:    emucore.CoreOptionCategoryVideo,
+			},
+			{
+				Key:         "layer_plane_b",
+				Label:       "Show Plane B",
+				Description: "Draw scroll plane B",
+				Type:        emucore.CoreOptionBool,
+				Default:     "true",
+				Category:    emucore.CoreOptionCategoryVideo,
+			},
+			{
+				Key:         "layer_window",
+				Label:       "Show Window",
+				Description: "Draw the window plane",
+				Type:        emucore.CoreOptionBool,
+				Default:     "true",
+				Category:    emucore.CoreOptionCategoryVideo,
+			},
+			{
+				Key:         "layer_sprites",
+				Label:       "Show Sprites",
+				Description: "Draw sprites",
+				Type:        emucore.CoreOptionBool,
+				Default:     "true",
+				Category:    emucore.CoreOptionCategoryVideo,
+			},
+			{
+				Key:         "layer_backdrop",
+				Label:       "Show Backdrop",
+				Description: "Draw the backdrop color; black when disabled",
+				Type:        emucore.CoreOptionBool,
+				Default:     "true",
+				Category:    emucore.CoreOptionCategoryVideo,
+			},
 		},
 		RDBName:         "Sega - Mega Drive - Genesis",
 		ThumbnailRepo:   "Sega_-_Mega_Drive_-_Genesis",
@@ -60,20 +254,116 @@ func (f *Factory) SystemInfo() emucore.SystemInfo {
 		ConsoleID:       1,
 		CoreName:        emu.Name,
 		CoreVersion:     emu.Version,
-		SerializeSize:   emu.SerializeSize(),
+		SerializeSize:   f.serializeSize(),
 		BigEndianMemory: true,
 	}
 }
 
+// serializeSize returns the largest state the emulators' Serialize makes.
+func (f *Factory) serializeSize() int {
+	if f.StateInfo {
+		return emu.SerializeWithInfoSize()
+	}
+	return emu.SerializeSize()
+}
+
 // CreateEmulator creates a new emulator instance with the given ROM and region.
 func (f *Factory) CreateEmulator(rom []byte, region emucore.Region) (emucore.Emulator, error) {
 	e, err := emu.NewEmulator(rom, region)
 	if err != nil {
 		return nil, err
 	}
+	if f.TMSSBIOS != nil {
+		if err := e.SetTMSSBIOS(f.TMSSBIOS); err != nil {
+			return nil, err
+		}
+	}
+	if f.Serial != "" {
+		if f.serial != nil && f.serialROM != e.GetROMCRC32() {
+			f.closeSerial()
+		}
+		if f.serial == nil {
+			s, err := serial.Open(f.Serial)
+			if err != nil {
+				return nil, err
+			}
+			f.serial, f.serialROM = s, e.GetROMCRC32()
+		}
+		port := f.SerialPort
+		if port == 0 {
+			port = 3
+		}
+		if port < 1 || port > 3 {
+			return nil, fmt.Errorf("invalid serial port %d", port)
+		}
+		e.SetSerialBackend(port, f.serial)
+	}
+	if f.GDBAddr != "" {
+		if f.gdb == nil {
+			gdb, err := debugger.ListenGDB(f.GDBAddr)
+			if err != nil {
+				return nil, err
+			}
+			f.gdb = gdb
+		}
+		f.gdb.Attach(&e)
+	}
+	if f.gdb != nil || f.StateInfo {
+		return &emulator{Emulator: &e, gdb: f.gdb, stateInfo: f.StateInfo}, nil
+	}
 	return &e, nil
 }
 
+// Close closes the serial stream and the GDB server. Emulators created
+// afterwards open them again.
+func (f *Factory) Close() error {
+	err := f.closeSerial()
+	if f.gdb != nil {
+		if gerr := f.gdb.Close(); err == nil {
+			err = gerr
+		}
+		f.gdb = nil
+	}
+	return err
+}
+
+// closeSerial closes the serial stream, if one is open.
+func (f *Factory) closeSerial() error {
+	if f.serial == nil {
+		return nil
+	}
+	err := f.serial.Close()
+	f.serial = nil
+	return err
+}
+
+// emulator applies the factory settings that change how frontends drive
+// an emu.Emulator. All other methods come from emu.Emulator.
+type emulator struct {
+	*emu.Emulator
+	gdb       *debugger.GDBServer // Runs frames through GDB when set
+	stateInfo bool                // Serialize adds the state metadata
+}
+
+// RunFrame executes one frame. With GDB it services the server first and
+// skips the frame while GDB has the target halted.
+func (e *emulator) RunFrame() {
+	if e.gdb != nil {
+		e.gdb.RunFrame()
+		return
+	}
+	e.Emulator.RunFrame()
+}
+
+// Serialize creates a save state, with the metadata of SerializeWithInfo
+// when the factory's StateInfo is set.
+func (e *emulator) Serialize() ([]byte, error) {
+	if e.stateInfo {
+		return e.SerializeWithInfo()
+	}
+	return e.Emulator.Serialize()
+}
+
 // DetectRegion auto-detects the region from ROM header data.
 // The bool return is false since emmd uses header-based detection,
 // not a ROM database lookup.
@@ -0,0 +1,343 @@
+// Command headless runs a ROM without a UI for regression testing. It
+// replays an input script or movie for a fixed number of frames, writes PNGs of
+// selected frames and a WAV of the audio, and prints or checks SHA-256
+// hashes of that output against a golden file.
+package main
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/user-none/emmd/emu"
+	"github.com/user-none/emmd/internal/wav"
+	"github.com/user-none/emmd/movie"
+)
+
+// audioSampleRate is the emulator's fixed output rate.
+const audioSampleRate = 48000
+
+func main() {
+	romPath := flag.String("rom", "", "path to ROM file (required)")
+	regionFlag := flag.String("region", "auto", "region: auto, ntsc, or pal")
+	sixButton := flag.Bool("six-button", true, "enable 6-button controller")
+	port1 := flag.String("port1", "", "port 1 device: 3button, 6button, mouse, or none (default from -six-button)")
+	port2 := flag.String("port2", "", "port 2 device: 3button, 6button, mouse, or none (default: empty)")
+	tmssBIOS := flag.String("tmss-bios", "", "path to TMSS boot ROM (enables TMSS mode)")
+	frames := flag.Int("frames", 600, "number of frames to run")
+	inputPath := flag.String("input", "", "input script to replay")
+	moviePath := flag.String("movie", "", "emmd or Gens .gmv movie to replay (sets -frames to its length unless given)")
+	snapList := flag.String("snap", "", "comma-separated frames to capture (default: last frame)")
+	pngDir := flag.String("png-dir", "", "write captured frames as PNGs to this directory")
+	wavPath := flag.String("wav", "", "write the audio of the whole run to this WAV file")
+	printHash := flag.Bool("hash", false, "print SHA-256 hashes of captured frames and audio")
+	golden := flag.String("golden", "", "compare hashes against this file (as printed by -hash)")
+	timeline := flag.Bool("timeline", false, "print the emulated time at each captured frame")
+	flag.Parse()
+
+	if *romPath == "" || *frames < 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ports := [2]string{"3button", *port2}
+	if *sixButton {
+		ports[0] = "6button"
+	}
+	if *port1 != "" {
+		ports[0] = *port1
+	}
+	e, err := newEmulator(*romPath, *regionFlag, *tmssBIOS, ports)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var player *movie.Player
+	if *moviePath != "" {
+		if *inputPath != "" {
+			log.Fatal("-movie and -input cannot be combined")
+		}
+		m, err := loadMovie(*moviePath)
+		if err != nil {
+			log.Fatalf("%s: %v", *moviePath, err)
+		}
+		if player, err = movie.NewPlayer(e, m); err != nil {
+			log.Fatalf("%s: %v", *moviePath, err)
+		}
+		framesSet := false
+		flag.Visit(func(f *flag.Flag) { framesSet = framesSet || f.Name == "frames" })
+		if !framesSet {
+			*frames = len(m.Frames)
+		}
+	}
+
+	var events []inputEvent
+	if *inputPath != "" {
+		f, err := os.Open(*inputPath)
+		if err != nil {
+			log.Fatal(err)
+		}
+		events, err = parseScript(f)
+		f.Close()
+		if err != nil {
+			log.Fatalf("%s: %v", *inputPath, err)
+		}
+	}
+
+	snaps, err := parseSnaps(*snapList, *frames)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var wavFile *os.File
+	var audio *wav.Writer
+	if *wavPath != "" {
+		if wavFile, err = os.Create(*wavPath); err != nil {
+			log.Fatal(err)
+		}
+		if audio, err = wav.NewWriter(wavFile, audioSampleRate); err != nil {
+			log.Fatal(err)
+		}
+	}
+	if *pngDir != "" {
+		if err := os.MkdirAll(*pngDir, 0o755); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var hashes []string
+	audioHash := sha256.New()
+	next := 0
+	for frame := 1; frame <= *frames; frame++ {
+		switch {
+		case player != nil && !player.Done():
+			if err := player.RunFrame(); err != nil {
+				log.Fatal(err)
+			}
+		default:
+			for ; next < len(events) && events[next].frame <= frame; next++ {
+				events[next].apply(e)
+			}
+			e.RunFrame()
+		}
+
+		samples := e.GetAudioSamples()
+		binary.Write(audioHash, binary.LittleEndian, samples)
+		if audio != nil {
+			if err := audio.Write(samples); err != nil {
+				log.Fatal(err)
+			}
+		}
+
+		if !snaps[frame] {
+			continue
+		}
+		img := frameImage(e)
+		if *pngDir != "" {
+			if err := writePNG(filepath.Join(*pngDir, fmt.Sprintf("frame_%06d.png", frame)), img); err != nil {
+				log.Fatal(err)
+			}
+		}
+		h := sha256.Sum256(img.Pix)
+		hashes = append(hashes, fmt.Sprintf("frame %d %x", frame, h))
+		if *timeline {
+			tl := e.Timeline()
+			fmt.Printf("timeline frame %d scanline %d master %d m68k %d z80 %d\n",
+				tl.Frame, tl.Scanline, tl.MasterCycles, tl.M68KCycles, tl.Z80Cycles)
+		}
+	}
+	hashes = append(hashes, fmt.Sprintf("audio %x", audioHash.Sum(nil)))
+	if e.TMSSLockup() {
+		log.Print("68K locked up: the VDP was accessed before the TMSS unlock")
+	}
+
+	if audio != nil {
+		if err := audio.Close(); err != nil {
+			log.Fatal(err)
+		}
+		if err := wavFile.Close(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *printHash {
+		for _, h := range hashes {
+			fmt.Println(h)
+		}
+	}
+	if *golden != "" {
+		if !checkGolden(*golden, hashes) {
+			os.Exit(1)
+		}
+		fmt.Println("golden: OK")
+	}
+}
+
+// newEmulator loads a ROM and applies the command-line options.
+// ports names the device in ports 1 and 2; an empty name keeps the
+// default.
+func newEmulator(romPath, regionName, biosPath string, ports [2]string) (*emu.Emulator, error) {
+	rom, err := os.ReadFile(romPath)
+	if err != nil {
+		return nil, err
+	}
+
+	var region emu.Region
+	switch strings.ToLower(regionName) {
+	case "auto":
+		region = emu.DetectRegion(rom)
+	case "ntsc":
+		region = emu.RegionNTSC
+	case "pal":
+		region = emu.RegionPAL
+	default:
+		return nil, fmt.Errorf("unknown region %q", regionName)
+	}
+
+	newEmu := emu.NewEmulator
+	if strings.EqualFold(filepath.Ext(romPath), ".sms") {
+		newEmu = emu.NewSMSEmulator
+	}
+	e, err := newEmu(rom, region)
+	if err != nil {
+		return nil, err
+	}
+	if biosPath != "" {
+		bios, err := os.ReadFile(biosPath)
+		if err != nil {
+			return nil, err
+		}
+		if err := e.SetTMSSBIOS(bios); err != nil {
+			return nil, err
+		}
+		e.SetOption("tmss", "true")
+	}
+	for i, name := range ports {
+		if name == "" {
+			continue
+		}
+		t, ok := emu.ParsePeripheralType(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown port %d device %q", i+1, name)
+		}
+		e.SetPeripheral(i, t)
+	}
+	return &e, nil
+}
+
+// loadMovie reads an emmd movie or imports a Gens .gmv file.
+func loadMovie(path string) (*movie.Movie, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(string(data), "Gens Movie") {
+		return movie.ImportGMV(data)
+	}
+	return movie.Decode(data)
+}
+
+// parseSnaps parses the -snap list into a set of frame numbers.
+func parseSnaps(list string, frames int) (map[int]bool, error) {
+	snaps := make(map[int]bool)
+	if list == "" {
+		snaps[frames] = true
+		return snaps, nil
+	}
+	for _, s := range strings.Split(list, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(s))
+		if err != nil || n < 1 || n > frames {
+			return nil, fmt.Errorf("invalid snap frame %q (must be 1-%d)", s, frames)
+		}
+		snaps[n] = true
+	}
+	return snaps, nil
+}
+
+// frameImage copies the active display area of the framebuffer.
+func frameImage(e *emu.Emulator) *image.RGBA {
+	fb := e.GetFramebuffer()
+	stride := e.GetFramebufferStride()
+	height := e.GetActiveHeight()
+
+	img := image.NewRGBA(image.Rect(0, 0, emu.ScreenWidth, height))
+	for y := 0; y < height; y++ {
+		copy(img.Pix[y*img.Stride:(y+1)*img.Stride], fb[y*stride:])
+	}
+	return img
+}
+
+func writePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// checkGolden compares hash lines against a golden file and reports every
+// difference. Lines are keyed by everything before the hash.
+func checkGolden(path string, hashes []string) bool {
+	f, err := os.Open(path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+
+	want := make(map[string]string)
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if i := strings.LastIndexByte(line, ' '); i > 0 {
+			want[line[:i]] = line[i+1:]
+		}
+	}
+	if err := sc.Err(); err != nil {
+		log.Fatal(err)
+	}
+
+	ok := true
+	got := make(map[string]bool)
+	for _, h := range hashes {
+		i := strings.LastIndexByte(h, ' ')
+		key, value := h[:i], h[i+1:]
+		got[key] = true
+		switch w, found := want[key]; {
+		case !found:
+			fmt.Fprintf(os.Stderr, "%s: not in golden file\n", key)
+			ok = false
+		case w != value:
+			fmt.Fprintf(os.Stderr, "%s: mismatch (got %s, want %s)\n", key, value, w)
+			ok = false
+		}
+	}
+	var missing []string
+	for key := range want {
+		if !got[key] {
+			missing = append(missing, key)
+		}
+	}
+	sort.Strings(missing)
+	for _, key := range missing {
+		fmt.Fprintf(os.Stderr, "%s: not captured in this run\n", key)
+		ok = false
+	}
+	return ok
+}
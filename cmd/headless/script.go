@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/user-none/emmd/emu"
+)
+
+// buttonBits maps input script button names to emulator SetInput bits.
+var buttonBits = map[string]uint32{
+	"up":    1 << 0,
+	"down":  1 << 1,
+	"left":  1 << 2,
+	"right": 1 << 3,
+	"a":     1 << 4,
+	"b":     1 << 5,
+	"c":     1 << 6,
+	"start": 1 << 7,
+	"x":     1 << 8,
+	"y":     1 << 9,
+	"z":     1 << 10,
+	"mode":  1 << 11,
+}
+
+// ramInits maps power cycle RAM pattern names to HardReset values.
+var ramInits = map[string]emu.RAMInit{
+	"zero":   emu.RAMInitZero,
+	"ones":   emu.RAMInitOnes,
+	"random": emu.RAMInitRandom,
+}
+
+// inputEvent sets a player's buttons from a frame onward, or presses reset
+// or power cycles the console before the frame runs.
+type inputEvent struct {
+	frame   int // 1-based frame the state applies from
+	player  int // 0 or 1
+	buttons uint32
+	reset   bool // Press the reset button instead
+	power   bool // Power cycle instead, with ramInit in RAM
+	ramInit emu.RAMInit
+}
+
+// apply performs the event on e.
+func (ev inputEvent) apply(e *emu.Emulator) {
+	switch {
+	case ev.reset:
+		e.SoftReset()
+	case ev.power:
+		e.HardReset(ev.ramInit)
+	default:
+		e.SetInput(ev.player, ev.buttons)
+	}
+}
+
+// parseScript reads an input script. Each line holds a frame number, a
+// player (1 or 2) and the buttons held from that frame on, joined with
+// '+' ("Right+A") or "none" to release everything. "reset" in place of
+// the player and buttons presses the reset button, and "power" followed by
+// zero, ones or random power cycles with that pattern in RAM. Blank lines
+// and text after '#' are ignored. Events are returned in frame order.
+func parseScript(r io.Reader) ([]inputEvent, error) {
+	var events []inputEvent
+	sc := bufio.NewScanner(r)
+	for line := 1; sc.Scan(); line++ {
+		text, _, _ := strings.Cut(sc.Text(), "#")
+		fields := strings.Fields(text)
+		if len(fields) == 0 {
+			continue
+		}
+		frame, err := strconv.Atoi(fields[0])
+		if err != nil || frame < 1 {
+			return nil, fmt.Errorf("line %d: invalid frame %q", line, fields[0])
+		}
+		switch {
+		case len(fields) == 2 && strings.EqualFold(fields[1], "reset"):
+			events = append(events, inputEvent{frame: frame, reset: true})
+			continue
+		case len(fields) == 3 && strings.EqualFold(fields[1], "power"):
+			init, ok := ramInits[strings.ToLower(fields[2])]
+			if !ok {
+				return nil, fmt.Errorf("line %d: unknown RAM pattern %q", line, fields[2])
+			}
+			events = append(events, inputEvent{frame: frame, power: true, ramInit: init})
+			continue
+		case len(fields) != 3:
+			return nil, fmt.Errorf("line %d: expected <frame> <player> <buttons>", line)
+		}
+		player, err := strconv.Atoi(fields[1])
+		if err != nil || player < 1 || player > 2 {
+			return nil, fmt.Errorf("line %d: invalid player %q", line, fields[1])
+		}
+		var buttons uint32
+		if !strings.EqualFold(fields[2], "none") {
+			for _, name := range strings.Split(fields[2], "+") {
+				bit, ok := buttonBits[strings.ToLower(name)]
+				if !ok {
+					return nil, fmt.Errorf("line %d: unknown button %q", line, name)
+				}
+				buttons |= bit
+			}
+		}
+		events = append(events, inputEvent{frame: frame, player: player - 1, buttons: buttons})
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].frame < events[j].frame })
+	return events, nil
+}
@@ -5,6 +5,7 @@ package main
 import (
 	"flag"
 	"log"
+	"os"
 
 	"github.com/user-none/eblitui/standalone"
 	"github.com/user-none/emmd/adapter"
@@ -14,24 +15,53 @@ func main() {
 	romPath := flag.String("rom", "", "path to ROM file (opens UI if not provided)")
 	regionFlag := flag.String("region", "auto", "region: auto, ntsc, or pal")
 	sixButton := flag.Bool("six-button", true, "enable 6-button controller")
+	port1 := flag.String("port1", "", "port 1 device: 3button, 6button, mouse, or none (default from -six-button)")
+	port2 := flag.String("port2", "", "port 2 device: 3button, 6button, mouse, or none (default from -six-button)")
+	lightGun := flag.String("lightgun", "none", "light gun in port 2: none, menacer, or justifier")
+	tmssBIOS := flag.String("tmss-bios", "", "path to TMSS boot ROM (enables TMSS mode)")
+	gdbAddr := flag.String("gdb", "", "start a GDB server on this address (e.g. localhost:2345)")
+	serial := flag.String("serial", "", "connect serial lines to tcp:host:port, unix:path, or file:path")
+	serialPort := flag.Int("serial-port", 3, "controller port for -serial: 1, 2, or 3 (EXT)")
 	flag.Parse()
 
-	factory := &adapter.Factory{}
+	// eblitui saves slots, rewind and autosave states through the same
+	// Serialize call, so all of them carry the save slot metadata
+	factory := &adapter.Factory{GDBAddr: *gdbAddr, Serial: *serial, SerialPort: *serialPort, StateInfo: true}
+	if *tmssBIOS != "" {
+		bios, err := os.ReadFile(*tmssBIOS)
+		if err != nil {
+			log.Fatal(err)
+		}
+		factory.TMSSBIOS = bios
+	}
 
 	if *romPath != "" {
-		options := map[string]string{}
+		pad := "3button"
 		if *sixButton {
-			options["six_button"] = "true"
-		} else {
-			options["six_button"] = "false"
+			pad = "6button"
+		}
+		options := map[string]string{"port1": pad, "port2": pad}
+		if *port1 != "" {
+			options["port1"] = *port1
+		}
+		if *port2 != "" {
+			options["port2"] = *port2
+		}
+		options["lightgun"] = *lightGun
+		if *tmssBIOS != "" {
+			options["tmss"] = "true"
 		}
-		if err := standalone.RunDirect(factory, *romPath, *regionFlag, options); err != nil {
+		err := standalone.RunDirect(factory, *romPath, *regionFlag, options)
+		factory.Close()
+		if err != nil {
 			log.Fatal(err)
 		}
 		return
 	}
 
-	if err := standalone.Run(factory); err != nil {
+	err := standalone.Run(factory)
+	factory.Close()
+	if err != nil {
 		log.Fatal(err)
 	}
 }
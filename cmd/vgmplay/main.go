@@ -0,0 +1,82 @@
+// Command vgmplay renders VGM and VGZ files to WAV through the emmd YM2612
+// and SN76489 cores, using the same mixing and Model 1 low-pass filter as
+// the emulator. It gives a reference for auditing FM output against known
+// rips.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/user-none/emmd/emu"
+	"github.com/user-none/emmd/internal/wav"
+)
+
+func main() {
+	out := flag.String("o", "", "output WAV path (default: input name with .wav)")
+	loops := flag.Int("loops", 1, "times to repeat the loop section after the first pass")
+	maxSeconds := flag.Int("max-seconds", 600, "stop rendering after this many seconds")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: vgmplay [flags] file.vgm|file.vgz\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	in := flag.Arg(0)
+	data, err := os.ReadFile(in)
+	if err != nil {
+		log.Fatal(err)
+	}
+	player, err := emu.NewVGMPlayer(data)
+	if err != nil {
+		log.Fatalf("%s: %v", in, err)
+	}
+	player.SetLoops(*loops)
+
+	if *out == "" {
+		*out = strings.TrimSuffix(in, filepath.Ext(in)) + ".wav"
+	}
+	f, err := os.Create(*out)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	frames, err := writeWAV(f, player, *maxSeconds*60)
+	if err != nil {
+		f.Close()
+		log.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal(err)
+	}
+	if !player.Done() {
+		log.Printf("stopped after %d seconds", *maxSeconds)
+	}
+	fmt.Printf("%s: %.2fs\n", *out, float64(frames)/float64(player.SampleRate()))
+}
+
+// writeWAV renders up to maxFrames frames into a WAV file and returns the
+// number of sample frames written.
+func writeWAV(f *os.File, p *emu.VGMPlayer, maxFrames int) (int, error) {
+	w, err := wav.NewWriter(f, p.SampleRate())
+	if err != nil {
+		return 0, err
+	}
+	for i := 0; i < maxFrames && !p.Done(); i++ {
+		if err := w.Write(p.RenderFrame()); err != nil {
+			return 0, err
+		}
+	}
+	if err := w.Close(); err != nil {
+		return 0, err
+	}
+	return w.Frames(), nil
+}
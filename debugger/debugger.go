@@ -0,0 +1,277 @@
+// Package debugger implements an interactive 68000 debugger for the
+// Genesis core: PC breakpoints, read/write watchpoints on 68K bus address
+// ranges, single-stepping, register inspection and disassembly.
+//
+// The debugger drives emulation through emu.Emulator.RunFrame. When a
+// breakpoint, watchpoint or step completes, RunFrame returns part way
+// through the frame and the next Continue or Step resumes from exactly
+// that point, so a debugged run stays cycle-identical to a free run.
+package debugger
+
+import (
+	"sort"
+
+	"github.com/user-none/emmd/emu"
+	"github.com/user-none/go-chip-m68k"
+)
+
+// WatchKind selects which bus accesses trigger a watchpoint.
+type WatchKind int
+
+const (
+	WatchRead   WatchKind = 1 << iota // Trigger on reads
+	WatchWrite                        // Trigger on writes
+	WatchAccess = WatchRead | WatchWrite
+)
+
+// Watchpoint watches an inclusive 68K address range.
+type Watchpoint struct {
+	ID    int
+	Start uint32
+	End   uint32
+	Kind  WatchKind
+}
+
+// StopReason describes why execution returned to the debugger.
+type StopReason int
+
+const (
+	StopFrameEnd   StopReason = iota // Frame completed without a stop
+	StopBreakpoint                   // PC reached a breakpoint
+	StopWatchpoint                   // A watched address was accessed
+	StopStep                         // Single step completed
+)
+
+// Stop reports where and why execution stopped. The access fields are
+// only set for StopWatchpoint.
+type Stop struct {
+	Reason StopReason
+	PC     uint32 // Address of the next instruction to execute
+
+	Watchpoint int       // ID of the watchpoint that triggered
+	Addr       uint32    // Accessed address
+	Size       m68k.Size // Access size
+	Write      bool      // Access was a write
+	Value      uint32    // Value read or written
+}
+
+// Debugger controls execution of an attached emulator.
+type Debugger struct {
+	emu *emu.Emulator
+
+	breakpoints map[uint32]bool
+	watchpoints []Watchpoint
+	nextWatchID int
+
+	stepping bool // Stop before the next instruction
+	resume   bool // The instruction at PC was reported and runs next
+	stop     Stop // Pending stop recorded by the hook
+	stopped  bool
+}
+
+// New attaches a debugger to e. Only one debugger can be attached at a
+// time; attaching replaces any previous debug hook.
+func New(e *emu.Emulator) *Debugger {
+	d := &Debugger{
+		emu:         e,
+		breakpoints: make(map[uint32]bool),
+		nextWatchID: 1,
+	}
+	e.SetDebugHook(hook{d})
+	return d
+}
+
+// Detach removes the debug hook. A frame interrupted by the debugger is
+// completed by the next RunFrame call.
+func (d *Debugger) Detach() {
+	d.emu.SetDebugHook(nil)
+}
+
+// AddBreakpoint sets a breakpoint at a 68K address.
+func (d *Debugger) AddBreakpoint(addr uint32) {
+	d.breakpoints[addr&0xFFFFFF] = true
+}
+
+// RemoveBreakpoint clears the breakpoint at addr.
+func (d *Debugger) RemoveBreakpoint(addr uint32) {
+	delete(d.breakpoints, addr&0xFFFFFF)
+}
+
+// Breakpoints returns all breakpoint addresses in ascending order.
+func (d *Debugger) Breakpoints() []uint32 {
+	out := make([]uint32, 0, len(d.breakpoints))
+	for addr := range d.breakpoints {
+		out = append(out, addr)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i] < out[j] })
+	return out
+}
+
+// AddWatchpoint watches the inclusive range start-end and returns the
+// watchpoint ID.
+func (d *Debugger) AddWatchpoint(start, end uint32, kind WatchKind) int {
+	start &= 0xFFFFFF
+	end &= 0xFFFFFF
+	if end < start {
+		start, end = end, start
+	}
+	id := d.nextWatchID
+	d.nextWatchID++
+	d.watchpoints = append(d.watchpoints, Watchpoint{ID: id, Start: start, End: end, Kind: kind})
+	return id
+}
+
+// RemoveWatchpoint deletes the watchpoint with the given ID.
+func (d *Debugger) RemoveWatchpoint(id int) {
+	for i, w := range d.watchpoints {
+		if w.ID == id {
+			d.watchpoints = append(d.watchpoints[:i], d.watchpoints[i+1:]...)
+			return
+		}
+	}
+}
+
+// Watchpoints returns a copy of the watchpoint list.
+func (d *Debugger) Watchpoints() []Watchpoint {
+	return append([]Watchpoint(nil), d.watchpoints...)
+}
+
+// Stopped reports whether emulation is paused mid-frame.
+func (d *Debugger) Stopped() bool {
+	return d.emu.DebugStopped()
+}
+
+// Continue runs until a breakpoint or watchpoint triggers or the current
+// frame ends. Call it in place of RunFrame while debugging.
+func (d *Debugger) Continue() Stop {
+	d.stepping = false
+	return d.run(1)
+}
+
+// Step executes a single 68K instruction. Stepping over the last
+// instruction of a frame finishes that frame and stops at the first
+// instruction of the next one.
+func (d *Debugger) Step() Stop {
+	d.stepping = true
+	stop := d.run(2)
+	d.stepping = false
+	return stop
+}
+
+// run calls RunFrame until the hook requests a stop, at most frames times.
+func (d *Debugger) run(frames int) Stop {
+	// When resuming from a breakpoint or step, or when stepping, the
+	// instruction at PC has already been reported and must execute before
+	// checking again. A watchpoint stops after the accessing instruction,
+	// so the one at PC has not been seen yet.
+	d.resume = d.stepping || (d.emu.DebugStopped() && d.stop.Reason != StopWatchpoint)
+	d.stop = Stop{}
+	d.stopped = false
+
+	for i := 0; i < frames; i++ {
+		d.emu.RunFrame()
+		if d.emu.DebugStopped() {
+			break
+		}
+	}
+
+	if !d.emu.DebugStopped() {
+		return Stop{Reason: StopFrameEnd, PC: d.emu.M68KRegisters().PC}
+	}
+	stop := d.stop
+	stop.PC = d.emu.M68KRegisters().PC
+	return stop
+}
+
+// Registers returns the 68K registers.
+func (d *Debugger) Registers() m68k.Registers {
+	return d.emu.M68KRegisters()
+}
+
+// SetRegisters overwrites the 68K registers.
+func (d *Debugger) SetRegisters(regs m68k.Registers) error {
+	return d.emu.SetM68KRegisters(regs)
+}
+
+// ReadMemory reads a byte through the 68K bus. Device registers see the
+// access as they would from the CPU; watchpoints do not trigger.
+func (d *Debugger) ReadMemory(addr uint32) byte {
+	return byte(d.emu.DebugRead(m68k.Byte, addr))
+}
+
+// WriteMemory writes a byte through the 68K bus without triggering
+// watchpoints.
+func (d *Debugger) WriteMemory(addr uint32, value byte) {
+	d.emu.DebugWrite(m68k.Byte, addr, uint32(value))
+}
+
+// ReadWord reads a big-endian word without side effects.
+func (d *Debugger) ReadWord(addr uint32) uint16 {
+	return uint16(d.emu.PeekMemory(addr))<<8 | uint16(d.emu.PeekMemory(addr+1))
+}
+
+// Disassemble decodes count instructions starting at addr.
+func (d *Debugger) Disassemble(addr uint32, count int) []Instruction {
+	out := make([]Instruction, 0, count)
+	for i := 0; i < count; i++ {
+		inst := Disassemble(d.ReadWord, addr)
+		out = append(out, inst)
+		addr += uint32(inst.Size)
+	}
+	return out
+}
+
+// hook adapts the Debugger to emu.DebugHook without exporting the
+// callbacks on Debugger itself.
+type hook struct {
+	d *Debugger
+}
+
+func (h hook) BeforeInstruction(pc uint32) bool {
+	d := h.d
+	if d.resume {
+		d.resume = false
+		return false
+	}
+	if d.stepping {
+		d.stop = Stop{Reason: StopStep}
+		return true
+	}
+	if d.breakpoints[pc] {
+		d.stop = Stop{Reason: StopBreakpoint}
+		return true
+	}
+	return false
+}
+
+func (h hook) MemoryAccess(addr uint32, size m68k.Size, write bool, value uint32) bool {
+	d := h.d
+	if len(d.watchpoints) == 0 {
+		return false
+	}
+	kind := WatchRead
+	if write {
+		kind = WatchWrite
+	}
+	last := addr + uint32(size) - 1
+	for _, w := range d.watchpoints {
+		if w.Kind&kind == 0 || last < w.Start || addr > w.End {
+			continue
+		}
+		if !d.stopped {
+			// Report the first access; later ones in the same
+			// instruction still stop but keep the original details.
+			d.stop = Stop{
+				Reason:     StopWatchpoint,
+				Watchpoint: w.ID,
+				Addr:       addr,
+				Size:       size,
+				Write:      write,
+				Value:      value,
+			}
+			d.stopped = true
+		}
+		return true
+	}
+	return false
+}
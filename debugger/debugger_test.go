@@ -0,0 +1,202 @@
+package debugger
+
+import (
+	"testing"
+
+	"github.com/user-none/emmd/emu"
+)
+
+// Test program at $200:
+//
+//	$200  moveq   #5,d0
+//	$202  move.w  d0,($ff0010).l
+//	$208  addq.w  #1,d0
+//	$20a  bra.s   $208
+func makeTestEmulator(t *testing.T) *emu.Emulator {
+	t.Helper()
+	rom := make([]byte, 0x400)
+	// SSP = $00FF0000, PC = $00000200
+	copy(rom[0:], []byte{0x00, 0xFF, 0x00, 0x00, 0x00, 0x00, 0x02, 0x00})
+	copy(rom[0x200:], []byte{
+		0x70, 0x05,
+		0x33, 0xC0, 0x00, 0xFF, 0x00, 0x10,
+		0x52, 0x40,
+		0x60, 0xFC,
+	})
+	e, err := emu.NewEmulator(rom, emu.RegionNTSC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &e
+}
+
+func TestBreakpoint(t *testing.T) {
+	e := makeTestEmulator(t)
+	d := New(e)
+	d.AddBreakpoint(0x208)
+
+	stop := d.Continue()
+	if stop.Reason != StopBreakpoint || stop.PC != 0x208 {
+		t.Fatalf("expected breakpoint at $208, got %+v", stop)
+	}
+	if !d.Stopped() {
+		t.Error("expected emulator to be stopped mid-frame")
+	}
+	if got := d.Registers().D[0] & 0xFFFF; got != 5 {
+		t.Errorf("expected D0=5, got %d", got)
+	}
+
+	// Continuing runs the loop once and hits the same breakpoint again
+	stop = d.Continue()
+	if stop.Reason != StopBreakpoint || stop.PC != 0x208 {
+		t.Fatalf("expected second breakpoint at $208, got %+v", stop)
+	}
+	if got := d.Registers().D[0] & 0xFFFF; got != 6 {
+		t.Errorf("expected D0=6, got %d", got)
+	}
+
+	d.RemoveBreakpoint(0x208)
+	if len(d.Breakpoints()) != 0 {
+		t.Error("expected no breakpoints")
+	}
+	stop = d.Continue()
+	if stop.Reason != StopFrameEnd || d.Stopped() {
+		t.Errorf("expected frame to complete, got %+v", stop)
+	}
+}
+
+func TestStep(t *testing.T) {
+	e := makeTestEmulator(t)
+	d := New(e)
+
+	want := []uint32{0x202, 0x208, 0x20A, 0x208, 0x20A}
+	for i, pc := range want {
+		stop := d.Step()
+		if stop.Reason != StopStep || stop.PC != pc {
+			t.Fatalf("step %d: expected PC $%06X, got %+v", i, pc, stop)
+		}
+	}
+}
+
+func TestWatchpoint(t *testing.T) {
+	e := makeTestEmulator(t)
+	d := New(e)
+
+	// Reads of the range do not trigger a write watchpoint
+	id := d.AddWatchpoint(0xFF0010, 0xFF0011, WatchWrite)
+	stop := d.Continue()
+	if stop.Reason != StopWatchpoint {
+		t.Fatalf("expected watchpoint stop, got %+v", stop)
+	}
+	if stop.Watchpoint != id || stop.Addr != 0xFF0010 || !stop.Write || stop.Value != 5 || stop.Size != 2 {
+		t.Errorf("unexpected watch details %+v", stop)
+	}
+	// Stops after the writing instruction completes
+	if stop.PC != 0x208 {
+		t.Errorf("expected PC $208 after the write, got $%06X", stop.PC)
+	}
+
+	d.RemoveWatchpoint(id)
+	if len(d.Watchpoints()) != 0 {
+		t.Error("expected no watchpoints")
+	}
+	d.AddWatchpoint(0xFF0010, 0xFF0011, WatchRead)
+	if stop := d.Continue(); stop.Reason != StopFrameEnd {
+		t.Errorf("read watchpoint should not trigger, got %+v", stop)
+	}
+}
+
+func TestWatchpointThenStep(t *testing.T) {
+	e := makeTestEmulator(t)
+	d := New(e)
+	d.AddWatchpoint(0xFF0010, 0xFF0011, WatchWrite)
+	if stop := d.Continue(); stop.Reason != StopWatchpoint || stop.PC != 0x208 {
+		t.Fatalf("expected watchpoint stop at $208, got %+v", stop)
+	}
+
+	// The instruction at $208 has not been reported yet, so a breakpoint
+	// on it stops before it runs
+	d.AddBreakpoint(0x208)
+	if stop := d.Continue(); stop.Reason != StopBreakpoint || stop.PC != 0x208 {
+		t.Fatalf("expected breakpoint at $208, got %+v", stop)
+	}
+	if got := d.Registers().D[0] & 0xFFFF; got != 5 {
+		t.Errorf("expected D0=5 before addq runs, got %d", got)
+	}
+	d.RemoveBreakpoint(0x208)
+
+	e2 := makeTestEmulator(t)
+	d = New(e2)
+	d.AddWatchpoint(0xFF0010, 0xFF0011, WatchWrite)
+	d.Continue()
+	stop := d.Step()
+	if stop.Reason != StopStep || stop.PC != 0x20A {
+		t.Fatalf("expected step to $20A, got %+v", stop)
+	}
+	if got := d.Registers().D[0] & 0xFFFF; got != 6 {
+		t.Errorf("expected D0=6 after one step, got %d", got)
+	}
+}
+
+func TestDebuggedRunIsDeterministic(t *testing.T) {
+	free := makeTestEmulator(t)
+	for i := 0; i < 3; i++ {
+		free.RunFrame()
+	}
+
+	e := makeTestEmulator(t)
+	d := New(e)
+	d.AddBreakpoint(0x20A)
+	frames, stops := 0, 0
+	for frames < 3 {
+		stop := d.Continue()
+		if stop.Reason == StopFrameEnd {
+			frames++
+			continue
+		}
+		stops++
+		if stops%100 == 0 {
+			d.Step()
+		}
+	}
+	if stops == 0 {
+		t.Fatal("expected breakpoint stops")
+	}
+
+	a, b := free.M68KRegisters(), e.M68KRegisters()
+	if a != b {
+		t.Errorf("registers differ:\nfree  %+v\ndebug %+v", a, b)
+	}
+	if free.M68KCycles() != e.M68KCycles() {
+		t.Errorf("cycles differ: free %d, debug %d", free.M68KCycles(), e.M68KCycles())
+	}
+}
+
+func TestDetach(t *testing.T) {
+	e := makeTestEmulator(t)
+	d := New(e)
+	d.AddBreakpoint(0x208)
+	if stop := d.Continue(); stop.Reason != StopBreakpoint {
+		t.Fatalf("expected breakpoint, got %+v", stop)
+	}
+	d.Detach()
+	e.RunFrame()
+	if e.DebugStopped() {
+		t.Error("detached emulator should finish the frame")
+	}
+}
+
+func TestDebuggerDisassemble(t *testing.T) {
+	e := makeTestEmulator(t)
+	d := New(e)
+	insts := d.Disassemble(0x200, 4)
+	want := []string{"moveq   #5,d0", "move.w  d0,($ff0010).l", "addq.w  #1,d0", "bra.s   $000208"}
+	for i, inst := range insts {
+		if inst.Text != want[i] {
+			t.Errorf("%d: got %q, want %q", i, inst.Text, want[i])
+		}
+	}
+	if insts[3].Addr != 0x20A {
+		t.Errorf("expected last address $20A, got $%X", insts[3].Addr)
+	}
+}
@@ -0,0 +1,651 @@
+package debugger
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Instruction is a single disassembled 68000 instruction.
+type Instruction struct {
+	Addr uint32   // Address of the first opcode word
+	Size int      // Length in bytes including extension words
+	Text string   // Mnemonic and operands, e.g. "move.w  d0,(a1)+"
+	Raw  []uint16 // Opcode and extension words
+}
+
+// ReadWordFunc reads a big-endian word from the 68K address space.
+type ReadWordFunc func(addr uint32) uint16
+
+var conditionNames = [16]string{
+	"t", "f", "hi", "ls", "cc", "cs", "ne", "eq",
+	"vc", "vs", "pl", "mi", "ge", "lt", "gt", "le",
+}
+
+var sizeSuffix = map[int]string{1: ".b", 2: ".w", 4: ".l"}
+
+// Disassemble decodes the instruction at addr. Invalid opcodes are
+// rendered as "dc.w $xxxx" with a size of 2.
+func Disassemble(read ReadWordFunc, addr uint32) Instruction {
+	d := &decoder{read: read, start: addr & 0xFFFFFF, pc: addr & 0xFFFFFF}
+	op := d.word()
+	mnemonic, operands, ok := d.decode(op)
+	if !ok {
+		d.pc = d.start + 2
+		mnemonic, operands = "dc.w", fmt.Sprintf("$%04x", op)
+	}
+
+	text := mnemonic
+	if operands != "" {
+		text = fmt.Sprintf("%-8s%s", mnemonic, operands)
+	}
+
+	size := int(d.pc - d.start)
+	raw := make([]uint16, size/2)
+	for i := range raw {
+		raw[i] = read(d.start + uint32(i*2))
+	}
+	return Instruction{Addr: d.start, Size: size, Text: text, Raw: raw}
+}
+
+// decoder walks the opcode and extension words of one instruction.
+type decoder struct {
+	read  ReadWordFunc
+	start uint32
+	pc    uint32 // Address of the next extension word
+}
+
+func (d *decoder) word() uint16 {
+	w := d.read(d.pc)
+	d.pc = (d.pc + 2) & 0xFFFFFF
+	return w
+}
+
+func (d *decoder) long() uint32 {
+	hi := uint32(d.word())
+	return hi<<16 | uint32(d.word())
+}
+
+// imm formats an immediate operand of the given size.
+func (d *decoder) imm(size int) string {
+	switch size {
+	case 1:
+		return fmt.Sprintf("#$%x", d.word()&0xFF)
+	case 2:
+		return fmt.Sprintf("#$%x", d.word())
+	}
+	return fmt.Sprintf("#$%x", d.long())
+}
+
+// signedHex formats a displacement as $x or -$x.
+func signedHex(v int32) string {
+	if v < 0 {
+		return fmt.Sprintf("-$%x", -int64(v))
+	}
+	return fmt.Sprintf("$%x", v)
+}
+
+// indexReg decodes the index register of a brief extension word.
+func indexReg(ext uint16) string {
+	kind := "d"
+	if ext&0x8000 != 0 {
+		kind = "a"
+	}
+	size := ".w"
+	if ext&0x0800 != 0 {
+		size = ".l"
+	}
+	return fmt.Sprintf("%s%d%s", kind, (ext>>12)&7, size)
+}
+
+// ea decodes an effective address, consuming any extension words. It
+// returns false for mode 7 registers that do not exist.
+func (d *decoder) ea(mode, reg uint16, size int) (string, bool) {
+	switch mode {
+	case 0:
+		return fmt.Sprintf("d%d", reg), true
+	case 1:
+		return fmt.Sprintf("a%d", reg), true
+	case 2:
+		return fmt.Sprintf("(a%d)", reg), true
+	case 3:
+		return fmt.Sprintf("(a%d)+", reg), true
+	case 4:
+		return fmt.Sprintf("-(a%d)", reg), true
+	case 5:
+		disp := int16(d.word())
+		return fmt.Sprintf("%s(a%d)", signedHex(int32(disp)), reg), true
+	case 6:
+		ext := d.word()
+		return fmt.Sprintf("%s(a%d,%s)", signedHex(int32(int8(ext))), reg, indexReg(ext)), true
+	}
+	switch reg {
+	case 0:
+		return fmt.Sprintf("($%x).w", uint32(int32(int16(d.word())))&0xFFFFFF), true
+	case 1:
+		return fmt.Sprintf("($%x).l", d.long()&0xFFFFFF), true
+	case 2:
+		base := d.pc
+		disp := int16(d.word())
+		return fmt.Sprintf("$%06x(pc)", (base+uint32(int32(disp)))&0xFFFFFF), true
+	case 3:
+		base := d.pc
+		ext := d.word()
+		target := (base + uint32(int32(int8(ext)))) & 0xFFFFFF
+		return fmt.Sprintf("$%06x(pc,%s)", target, indexReg(ext)), true
+	case 4:
+		if size == 0 {
+			return "", false
+		}
+		return d.imm(size), true
+	}
+	return "", false
+}
+
+// eaClass flags which addressing modes an instruction accepts.
+type eaClass int
+
+const (
+	eaData     eaClass = 1 << iota // Dn
+	eaAddr                         // An
+	eaMem                          // (An), (An)+, -(An), d16, d8+Xn, abs
+	eaPC                           // d16(pc), d8(pc,Xn)
+	eaImm                          // #imm
+	eaAlterMem                     // memory modes without pc-relative and #imm
+	eaControl                      // (An), d16, d8+Xn, abs, pc-relative
+	eaAll      = eaData | eaAddr | eaMem | eaPC | eaImm
+	eaDataAll  = eaData | eaMem | eaPC | eaImm
+	eaDataAlt  = eaData | eaMem
+	eaAlt      = eaData | eaAddr | eaMem
+)
+
+// validEA reports whether mode/reg is allowed by class.
+func validEA(mode, reg uint16, class eaClass) bool {
+	switch mode {
+	case 0:
+		return class&eaData != 0
+	case 1:
+		return class&eaAddr != 0
+	case 2, 5, 6:
+		return class&(eaMem|eaAlterMem|eaControl) != 0
+	case 3, 4:
+		return class&(eaMem|eaAlterMem) != 0
+	}
+	switch reg {
+	case 0, 1:
+		return class&(eaMem|eaAlterMem|eaControl) != 0
+	case 2, 3:
+		return class&(eaPC|eaControl) != 0
+	case 4:
+		return class&eaImm != 0
+	}
+	return false
+}
+
+// operand validates and decodes the ea in the low 6 bits of op.
+func (d *decoder) operand(op uint16, size int, class eaClass) (string, bool) {
+	mode, reg := (op>>3)&7, op&7
+	if !validEA(mode, reg, class) {
+		return "", false
+	}
+	return d.ea(mode, reg, size)
+}
+
+// size2 decodes the common 2-bit size field (00=b, 01=w, 10=l).
+func size2(bits uint16) int {
+	switch bits & 3 {
+	case 0:
+		return 1
+	case 1:
+		return 2
+	case 2:
+		return 4
+	}
+	return 0
+}
+
+// regList formats a MOVEM register mask. For -(An) the mask is reversed.
+func regList(mask uint16, predec bool) string {
+	if predec {
+		var r uint16
+		for i := 0; i < 16; i++ {
+			if mask&(1<<i) != 0 {
+				r |= 1 << (15 - i)
+			}
+		}
+		mask = r
+	}
+	var parts []string
+	for bank := 0; bank < 2; bank++ {
+		prefix := "d"
+		if bank == 1 {
+			prefix = "a"
+		}
+		for i := 0; i < 8; {
+			if mask&(1<<(bank*8+i)) == 0 {
+				i++
+				continue
+			}
+			j := i
+			for j+1 < 8 && mask&(1<<(bank*8+j+1)) != 0 {
+				j++
+			}
+			if j == i {
+				parts = append(parts, fmt.Sprintf("%s%d", prefix, i))
+			} else {
+				parts = append(parts, fmt.Sprintf("%s%d-%s%d", prefix, i, prefix, j))
+			}
+			i = j + 1
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func (d *decoder) decode(op uint16) (string, string, bool) {
+	switch op >> 12 {
+	case 0x0:
+		return d.decodeImmediate(op)
+	case 0x1, 0x2, 0x3:
+		return d.decodeMove(op)
+	case 0x4:
+		return d.decodeMisc(op)
+	case 0x5:
+		return d.decodeQuick(op)
+	case 0x6:
+		return d.decodeBranch(op)
+	case 0x7:
+		if op&0x0100 != 0 {
+			return "", "", false
+		}
+		return "moveq", fmt.Sprintf("#%d,d%d", int8(op), (op>>9)&7), true
+	case 0x8:
+		return d.decodeOr(op)
+	case 0x9, 0xD:
+		return d.decodeAddSub(op)
+	case 0xB:
+		return d.decodeCmpEor(op)
+	case 0xC:
+		return d.decodeAnd(op)
+	case 0xE:
+		return d.decodeShift(op)
+	}
+	// Line A and line F emulator traps
+	return "", "", false
+}
+
+func (d *decoder) decodeImmediate(op uint16) (string, string, bool) {
+	reg := (op >> 9) & 7
+	if op&0x0100 != 0 {
+		if (op>>3)&7 == 1 {
+			// MOVEP
+			disp := signedHex(int32(int16(d.word())))
+			sz := ".w"
+			if op&0x0040 != 0 {
+				sz = ".l"
+			}
+			mem := fmt.Sprintf("%s(a%d)", disp, op&7)
+			if op&0x0080 != 0 {
+				return "movep" + sz, fmt.Sprintf("d%d,%s", reg, mem), true
+			}
+			return "movep" + sz, fmt.Sprintf("%s,d%d", mem, reg), true
+		}
+		// Dynamic bit operation
+		name := [4]string{"btst", "bchg", "bclr", "bset"}[(op>>6)&3]
+		class := eaDataAlt
+		if name == "btst" {
+			class = eaDataAll
+		}
+		dst, ok := d.operand(op, 1, class)
+		return name, fmt.Sprintf("d%d,%s", reg, dst), ok
+	}
+
+	if reg == 4 {
+		// Static bit operation
+		name := [4]string{"btst", "bchg", "bclr", "bset"}[(op>>6)&3]
+		bit := d.word() & 0xFF
+		class := eaDataAlt
+		if name == "btst" {
+			class = eaData | eaMem | eaPC
+		}
+		dst, ok := d.operand(op, 1, class)
+		return name, fmt.Sprintf("#%d,%s", bit, dst), ok
+	}
+
+	names := map[uint16]string{0: "ori", 1: "andi", 2: "subi", 3: "addi", 5: "eori", 6: "cmpi"}
+	name, known := names[reg]
+	size := size2(op >> 6)
+	if !known || size == 0 {
+		return "", "", false
+	}
+
+	// ORI/ANDI/EORI to CCR and SR
+	if op&0x3F == 0x3C && (reg == 0 || reg == 1 || reg == 5) {
+		switch size {
+		case 1:
+			return name, fmt.Sprintf("#$%x,ccr", d.word()&0xFF), true
+		case 2:
+			return name, fmt.Sprintf("#$%x,sr", d.word()), true
+		}
+		return "", "", false
+	}
+
+	src := d.imm(size)
+	dst, ok := d.operand(op, size, eaDataAlt)
+	return name + sizeSuffix[size], src + "," + dst, ok
+}
+
+func (d *decoder) decodeMove(op uint16) (string, string, bool) {
+	size := map[uint16]int{1: 1, 3: 2, 2: 4}[op>>12]
+	src, ok := d.operand(op, size, eaAll)
+	if !ok || (size == 1 && (op>>3)&7 == 1) {
+		return "", "", false
+	}
+	dmode, dreg := (op>>6)&7, (op>>9)&7
+	if dmode == 1 {
+		if size == 1 {
+			return "", "", false
+		}
+		return "movea" + sizeSuffix[size], fmt.Sprintf("%s,a%d", src, dreg), true
+	}
+	if !validEA(dmode, dreg, eaDataAlt) {
+		return "", "", false
+	}
+	dst, ok := d.ea(dmode, dreg, size)
+	return "move" + sizeSuffix[size], src + "," + dst, ok
+}
+
+func (d *decoder) decodeMisc(op uint16) (string, string, bool) {
+	switch op {
+	case 0x4AFC:
+		return "illegal", "", true
+	case 0x4E70:
+		return "reset", "", true
+	case 0x4E71:
+		return "nop", "", true
+	case 0x4E72:
+		return "stop", fmt.Sprintf("#$%x", d.word()), true
+	case 0x4E73:
+		return "rte", "", true
+	case 0x4E75:
+		return "rts", "", true
+	case 0x4E76:
+		return "trapv", "", true
+	case 0x4E77:
+		return "rtr", "", true
+	}
+
+	reg := (op >> 9) & 7
+	switch {
+	case op&0xFFF0 == 0x4E40:
+		return "trap", fmt.Sprintf("#%d", op&0xF), true
+	case op&0xFFF8 == 0x4E50:
+		return "link", fmt.Sprintf("a%d,#%s", op&7, signedHex(int32(int16(d.word())))), true
+	case op&0xFFF8 == 0x4E58:
+		return "unlk", fmt.Sprintf("a%d", op&7), true
+	case op&0xFFF8 == 0x4E60:
+		return "move", fmt.Sprintf("a%d,usp", op&7), true
+	case op&0xFFF8 == 0x4E68:
+		return "move", fmt.Sprintf("usp,a%d", op&7), true
+	case op&0xFFC0 == 0x4E80:
+		dst, ok := d.operand(op, 0, eaControl)
+		return "jsr", dst, ok
+	case op&0xFFC0 == 0x4EC0:
+		dst, ok := d.operand(op, 0, eaControl)
+		return "jmp", dst, ok
+	case op&0xF1C0 == 0x41C0:
+		src, ok := d.operand(op, 0, eaControl)
+		return "lea", fmt.Sprintf("%s,a%d", src, reg), ok
+	case op&0xF1C0 == 0x4180:
+		src, ok := d.operand(op, 2, eaDataAll)
+		return "chk.w", fmt.Sprintf("%s,d%d", src, reg), ok
+	case op&0xFFC0 == 0x40C0:
+		dst, ok := d.operand(op, 2, eaDataAlt)
+		return "move", "sr," + dst, ok
+	case op&0xFFC0 == 0x44C0:
+		src, ok := d.operand(op, 2, eaDataAll)
+		return "move", src + ",ccr", ok
+	case op&0xFFC0 == 0x46C0:
+		src, ok := d.operand(op, 2, eaDataAll)
+		return "move", src + ",sr", ok
+	case op&0xFFC0 == 0x4800:
+		dst, ok := d.operand(op, 1, eaDataAlt)
+		return "nbcd", dst, ok
+	case op&0xFFF8 == 0x4840:
+		return "swap", fmt.Sprintf("d%d", op&7), true
+	case op&0xFFC0 == 0x4840:
+		src, ok := d.operand(op, 0, eaControl)
+		return "pea", src, ok
+	case op&0xFFF8 == 0x4880:
+		return "ext.w", fmt.Sprintf("d%d", op&7), true
+	case op&0xFFF8 == 0x48C0:
+		return "ext.l", fmt.Sprintf("d%d", op&7), true
+	case op&0xFF80 == 0x4880, op&0xFF80 == 0x4C80:
+		return d.decodeMovem(op)
+	case op&0xFFC0 == 0x4AC0:
+		dst, ok := d.operand(op, 1, eaDataAlt)
+		return "tas", dst, ok
+	}
+
+	names := map[uint16]string{0x40: "negx", 0x42: "clr", 0x44: "neg", 0x46: "not", 0x4A: "tst"}
+	name, known := names[op>>8]
+	size := size2(op >> 6)
+	if !known || size == 0 {
+		return "", "", false
+	}
+	dst, ok := d.operand(op, size, eaDataAlt)
+	return name + sizeSuffix[size], dst, ok
+}
+
+func (d *decoder) decodeMovem(op uint16) (string, string, bool) {
+	size := 2
+	if op&0x0040 != 0 {
+		size = 4
+	}
+	mask := d.word()
+	mode := (op >> 3) & 7
+	if op&0x0400 != 0 {
+		// Memory to registers
+		src, ok := d.operand(op, 0, eaControl|eaMem)
+		if mode == 4 {
+			ok = false
+		}
+		return "movem" + sizeSuffix[size], src + "," + regList(mask, false), ok
+	}
+	// Registers to memory
+	dst, ok := d.operand(op, 0, eaControl|eaAlterMem)
+	if mode == 3 || (mode == 7 && (op&7) >= 2) {
+		ok = false
+	}
+	return "movem" + sizeSuffix[size], regList(mask, mode == 4) + "," + dst, ok
+}
+
+func (d *decoder) decodeQuick(op uint16) (string, string, bool) {
+	cond := (op >> 8) & 0xF
+	if (op>>6)&3 == 3 {
+		if (op>>3)&7 == 1 {
+			base := d.pc
+			disp := int16(d.word())
+			target := (base + uint32(int32(disp))) & 0xFFFFFF
+			return "db" + conditionNames[cond], fmt.Sprintf("d%d,$%06x", op&7, target), true
+		}
+		dst, ok := d.operand(op, 1, eaDataAlt)
+		return "s" + conditionNames[cond], dst, ok
+	}
+	size := size2(op >> 6)
+	data := (op >> 9) & 7
+	if data == 0 {
+		data = 8
+	}
+	name := "addq"
+	if op&0x0100 != 0 {
+		name = "subq"
+	}
+	if size == 1 && (op>>3)&7 == 1 {
+		return "", "", false
+	}
+	dst, ok := d.operand(op, size, eaAlt)
+	return name + sizeSuffix[size], fmt.Sprintf("#%d,%s", data, dst), ok
+}
+
+func (d *decoder) decodeBranch(op uint16) (string, string, bool) {
+	cond := (op >> 8) & 0xF
+	name := "b" + conditionNames[cond]
+	switch cond {
+	case 0:
+		name = "bra"
+	case 1:
+		name = "bsr"
+	}
+	base := d.pc
+	disp := int32(int8(op))
+	suffix := ".s"
+	if disp == 0 {
+		disp = int32(int16(d.word()))
+		suffix = ".w"
+	}
+	target := (base + uint32(disp)) & 0xFFFFFF
+	return name + suffix, fmt.Sprintf("$%06x", target), true
+}
+
+func (d *decoder) decodeOr(op uint16) (string, string, bool) {
+	reg := (op >> 9) & 7
+	switch {
+	case op&0x01C0 == 0x00C0:
+		src, ok := d.operand(op, 2, eaDataAll)
+		return "divu.w", fmt.Sprintf("%s,d%d", src, reg), ok
+	case op&0x01C0 == 0x01C0:
+		src, ok := d.operand(op, 2, eaDataAll)
+		return "divs.w", fmt.Sprintf("%s,d%d", src, reg), ok
+	case op&0x01F0 == 0x0100:
+		return "sbcd", bcdOperands(op), true
+	}
+	return d.decodeLogic("or", op)
+}
+
+func (d *decoder) decodeAnd(op uint16) (string, string, bool) {
+	reg := (op >> 9) & 7
+	switch {
+	case op&0x01C0 == 0x00C0:
+		src, ok := d.operand(op, 2, eaDataAll)
+		return "mulu.w", fmt.Sprintf("%s,d%d", src, reg), ok
+	case op&0x01C0 == 0x01C0:
+		src, ok := d.operand(op, 2, eaDataAll)
+		return "muls.w", fmt.Sprintf("%s,d%d", src, reg), ok
+	case op&0x01F0 == 0x0100:
+		return "abcd", bcdOperands(op), true
+	case op&0x01F8 == 0x0140:
+		return "exg", fmt.Sprintf("d%d,d%d", reg, op&7), true
+	case op&0x01F8 == 0x0148:
+		return "exg", fmt.Sprintf("a%d,a%d", reg, op&7), true
+	case op&0x01F8 == 0x0188:
+		return "exg", fmt.Sprintf("d%d,a%d", reg, op&7), true
+	}
+	return d.decodeLogic("and", op)
+}
+
+// bcdOperands formats ABCD/SBCD (and ADDX/SUBX) register or predecrement
+// operands.
+func bcdOperands(op uint16) string {
+	if op&0x0008 != 0 {
+		return fmt.Sprintf("-(a%d),-(a%d)", op&7, (op>>9)&7)
+	}
+	return fmt.Sprintf("d%d,d%d", op&7, (op>>9)&7)
+}
+
+// decodeLogic decodes OR and AND in their <ea>,Dn and Dn,<ea> forms.
+func (d *decoder) decodeLogic(name string, op uint16) (string, string, bool) {
+	reg := (op >> 9) & 7
+	size := size2(op >> 6)
+	if size == 0 {
+		return "", "", false
+	}
+	if op&0x0100 != 0 {
+		dst, ok := d.operand(op, size, eaAlterMem)
+		return name + sizeSuffix[size], fmt.Sprintf("d%d,%s", reg, dst), ok
+	}
+	src, ok := d.operand(op, size, eaDataAll)
+	return name + sizeSuffix[size], fmt.Sprintf("%s,d%d", src, reg), ok
+}
+
+func (d *decoder) decodeAddSub(op uint16) (string, string, bool) {
+	name := "add"
+	if op>>12 == 0x9 {
+		name = "sub"
+	}
+	reg := (op >> 9) & 7
+
+	if (op>>6)&3 == 3 {
+		size := 2
+		if op&0x0100 != 0 {
+			size = 4
+		}
+		src, ok := d.operand(op, size, eaAll)
+		return name + "a" + sizeSuffix[size], fmt.Sprintf("%s,a%d", src, reg), ok
+	}
+
+	size := size2(op >> 6)
+	if op&0x0130 == 0x0100 {
+		return name + "x" + sizeSuffix[size], bcdOperands(op), true
+	}
+	if op&0x0100 != 0 {
+		dst, ok := d.operand(op, size, eaAlterMem)
+		return name + sizeSuffix[size], fmt.Sprintf("d%d,%s", reg, dst), ok
+	}
+	if size == 1 && (op>>3)&7 == 1 {
+		return "", "", false
+	}
+	src, ok := d.operand(op, size, eaAll)
+	return name + sizeSuffix[size], fmt.Sprintf("%s,d%d", src, reg), ok
+}
+
+func (d *decoder) decodeCmpEor(op uint16) (string, string, bool) {
+	reg := (op >> 9) & 7
+	if (op>>6)&3 == 3 {
+		size := 2
+		if op&0x0100 != 0 {
+			size = 4
+		}
+		src, ok := d.operand(op, size, eaAll)
+		return "cmpa" + sizeSuffix[size], fmt.Sprintf("%s,a%d", src, reg), ok
+	}
+	size := size2(op >> 6)
+	if op&0x0100 != 0 {
+		if (op>>3)&7 == 1 {
+			return "cmpm" + sizeSuffix[size], fmt.Sprintf("(a%d)+,(a%d)+", op&7, reg), true
+		}
+		dst, ok := d.operand(op, size, eaDataAlt)
+		return "eor" + sizeSuffix[size], fmt.Sprintf("d%d,%s", reg, dst), ok
+	}
+	if size == 1 && (op>>3)&7 == 1 {
+		return "", "", false
+	}
+	src, ok := d.operand(op, size, eaAll)
+	return "cmp" + sizeSuffix[size], fmt.Sprintf("%s,d%d", src, reg), ok
+}
+
+func (d *decoder) decodeShift(op uint16) (string, string, bool) {
+	dir := "r"
+	if op&0x0100 != 0 {
+		dir = "l"
+	}
+	names := [4]string{"as", "ls", "rox", "ro"}
+
+	if (op>>6)&3 == 3 {
+		// Memory shift by one bit
+		if op&0x0800 != 0 {
+			return "", "", false
+		}
+		dst, ok := d.operand(op, 2, eaAlterMem)
+		return names[(op>>9)&3] + dir + ".w", dst, ok
+	}
+
+	size := size2(op >> 6)
+	name := names[(op>>3)&3] + dir + sizeSuffix[size]
+	count := (op >> 9) & 7
+	if op&0x0020 != 0 {
+		return name, fmt.Sprintf("d%d,d%d", count, op&7), true
+	}
+	if count == 0 {
+		count = 8
+	}
+	return name, fmt.Sprintf("#%d,d%d", count, op&7), true
+}
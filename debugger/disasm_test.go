@@ -0,0 +1,101 @@
+package debugger
+
+import "testing"
+
+// wordsReader serves words from a slice starting at address base.
+func wordsReader(base uint32, words ...uint16) ReadWordFunc {
+	return func(addr uint32) uint16 {
+		i := int(addr-base) / 2
+		if addr < base || i >= len(words) {
+			return 0
+		}
+		return words[i]
+	}
+}
+
+func TestDisassemble(t *testing.T) {
+	cases := []struct {
+		words []uint16
+		text  string
+		size  int
+	}{
+		{[]uint16{0x4E71}, "nop", 2},
+		{[]uint16{0x4E75}, "rts", 2},
+		{[]uint16{0x7005}, "moveq   #5,d0", 2},
+		{[]uint16{0x70FF}, "moveq   #-1,d0", 2},
+		{[]uint16{0x33C0, 0x00FF, 0x0010}, "move.w  d0,($ff0010).l", 6},
+		{[]uint16{0x3028, 0xFFFE}, "move.w  -$2(a0),d0", 4},
+		{[]uint16{0x2A3C, 0x1234, 0x5678}, "move.l  #$12345678,d5", 6},
+		{[]uint16{0x12D8}, "move.b  (a0)+,(a1)+", 2},
+		{[]uint16{0x2F00}, "move.l  d0,-(a7)", 2},
+		{[]uint16{0x3440}, "movea.w d0,a2", 2},
+		{[]uint16{0x5240}, "addq.w  #1,d0", 2},
+		{[]uint16{0x5188}, "subq.l  #8,a0", 2},
+		{[]uint16{0x60FC}, "bra.s   $0001fe", 2},
+		{[]uint16{0x6700, 0x0010}, "beq.w   $000212", 4},
+		{[]uint16{0x6100, 0xFFFE}, "bsr.w   $000200", 4},
+		{[]uint16{0x51C8, 0xFFFC}, "dbf     d0,$0001fe", 4},
+		{[]uint16{0x57C0}, "seq     d0", 2},
+		{[]uint16{0x4EB9, 0x0000, 0x1000}, "jsr     ($1000).l", 6},
+		{[]uint16{0x41FA, 0x0010}, "lea     $000212(pc),a0", 4},
+		{[]uint16{0x48E7, 0xC0C0}, "movem.l d0-d1/a0-a1,-(a7)", 4},
+		{[]uint16{0x4CDF, 0x0303}, "movem.l (a7)+,d0-d1/a0-a1", 4},
+		{[]uint16{0x0240, 0x00FF}, "andi.w  #$ff,d0", 4},
+		{[]uint16{0x007C, 0x0700}, "ori     #$700,sr", 4},
+		{[]uint16{0x0839, 0x0007, 0x00A1, 0x0001}, "btst    #7,($a10001).l", 8},
+		{[]uint16{0x0300}, "btst    d1,d0", 2},
+		{[]uint16{0xD041}, "add.w   d1,d0", 2},
+		{[]uint16{0xD1C1}, "adda.l  d1,a0", 2},
+		{[]uint16{0x9181}, "subx.l  d1,d0", 2},
+		{[]uint16{0xB07C, 0x0003}, "cmp.w   #$3,d0", 4},
+		{[]uint16{0xB348}, "cmpm.w  (a0)+,(a1)+", 2},
+		{[]uint16{0xC0C1}, "mulu.w  d1,d0", 2},
+		{[]uint16{0xC141}, "exg     d0,d1", 2},
+		{[]uint16{0x81C1}, "divs.w  d1,d0", 2},
+		{[]uint16{0xE548}, "lsl.w   #2,d0", 2},
+		{[]uint16{0xE2A8}, "lsr.l   d1,d0", 2},
+		{[]uint16{0xE0D0}, "asr.w   (a0)", 2},
+		{[]uint16{0x4840}, "swap    d0", 2},
+		{[]uint16{0x4880}, "ext.w   d0", 2},
+		{[]uint16{0x4A79, 0x00FF, 0x0000}, "tst.w   ($ff0000).l", 6},
+		{[]uint16{0x4E56, 0xFFF8}, "link    a6,#-$8", 4},
+		{[]uint16{0x4E4F}, "trap    #15", 2},
+		{[]uint16{0x46FC, 0x2700}, "move    #$2700,sr", 4},
+		{[]uint16{0x4AFC}, "illegal", 2},
+		{[]uint16{0x0188, 0x0004}, "movep.w d0,$4(a0)", 4},
+		{[]uint16{0x3030, 0x1006}, "move.w  $6(a0,d1.w),d0", 4},
+		{[]uint16{0xA000}, "dc.w    $a000", 2},
+		{[]uint16{0x7100}, "dc.w    $7100", 2},
+		{[]uint16{0x4E7A}, "dc.w    $4e7a", 2},
+	}
+	for _, tc := range cases {
+		inst := Disassemble(wordsReader(0x200, tc.words...), 0x200)
+		if inst.Text != tc.text || inst.Size != tc.size {
+			t.Errorf("%04X: got %q (%d bytes), want %q (%d bytes)",
+				tc.words[0], inst.Text, inst.Size, tc.text, tc.size)
+		}
+		if len(inst.Raw) != tc.size/2 || inst.Raw[0] != tc.words[0] {
+			t.Errorf("%04X: raw words %04X", tc.words[0], inst.Raw)
+		}
+	}
+}
+
+func TestRegList(t *testing.T) {
+	cases := []struct {
+		mask   uint16
+		predec bool
+		want   string
+	}{
+		{0x0001, false, "d0"},
+		{0x00FF, false, "d0-d7"},
+		{0x8001, false, "d0/a7"},
+		{0x0505, false, "d0/d2/a0/a2"},
+		{0x8000, true, "d0"},
+		{0xC0C0, true, "d0-d1/a0-a1"},
+	}
+	for _, tc := range cases {
+		if got := regList(tc.mask, tc.predec); got != tc.want {
+			t.Errorf("regList(%04X, %v) = %q, want %q", tc.mask, tc.predec, got, tc.want)
+		}
+	}
+}
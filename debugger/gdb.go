@@ -0,0 +1,580 @@
+package debugger
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/user-none/emmd/emu"
+	"github.com/user-none/go-chip-m68k"
+)
+
+// gdbPollInterval is how long RunFrame waits for GDB packets while the
+// target is halted before returning control to the frontend.
+const gdbPollInterval = 16 * time.Millisecond
+
+// gdbRegCount is the number of registers in the m68k core feature:
+// d0-d7, a0-a7, ps, pc.
+const gdbRegCount = 18
+
+// gdbTargetXML describes the register layout to GDB.
+const gdbTargetXML = `<?xml version="1.0"?>
+<!DOCTYPE target SYSTEM "gdb-target.dtd">
+<target version="1.0">
+<architecture>m68k</architecture>
+<feature name="org.gnu.gdb.m68k.core">
+<reg name="d0" bitsize="32"/>
+<reg name="d1" bitsize="32"/>
+<reg name="d2" bitsize="32"/>
+<reg name="d3" bitsize="32"/>
+<reg name="d4" bitsize="32"/>
+<reg name="d5" bitsize="32"/>
+<reg name="d6" bitsize="32"/>
+<reg name="d7" bitsize="32"/>
+<reg name="a0" bitsize="32" type="data_ptr"/>
+<reg name="a1" bitsize="32" type="data_ptr"/>
+<reg name="a2" bitsize="32" type="data_ptr"/>
+<reg name="a3" bitsize="32" type="data_ptr"/>
+<reg name="a4" bitsize="32" type="data_ptr"/>
+<reg name="a5" bitsize="32" type="data_ptr"/>
+<reg name="fp" bitsize="32" type="data_ptr"/>
+<reg name="sp" bitsize="32" type="data_ptr"/>
+<reg name="ps" bitsize="32"/>
+<reg name="pc" bitsize="32" type="code_ptr"/>
+</feature>
+</target>
+`
+
+// gdbRequest carries a packet from a connection goroutine to the
+// emulation goroutine. An empty packet with closed set reports a
+// disconnect; interrupt reports a Ctrl-C break.
+type gdbRequest struct {
+	conn      net.Conn
+	packet    string
+	interrupt bool
+	closed    bool
+}
+
+// GDBServer implements the GDB remote serial protocol over TCP so
+// m68k-elf-gdb can debug the running 68K. Packets are received on a
+// background goroutine but executed on the emulation goroutine inside
+// RunFrame, so the emulator is never touched concurrently.
+type GDBServer struct {
+	ln       net.Listener
+	requests chan gdbRequest
+
+	dbg    *Debugger
+	conn   net.Conn // Active GDB connection, nil when detached
+	halted bool     // Target stopped and waiting for GDB
+
+	// Watchpoint IDs keyed by the GDB Z packet that created them
+	watches map[string]int
+}
+
+// ListenGDB starts a GDB server on addr (for example "localhost:2345").
+// Call Attach before RunFrame.
+func ListenGDB(addr string) (*GDBServer, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	s := &GDBServer{
+		ln:       ln,
+		requests: make(chan gdbRequest, 16),
+		watches:  make(map[string]int),
+	}
+	go s.acceptLoop()
+	log.Printf("[gdb] listening on %s", ln.Addr())
+	return s, nil
+}
+
+// Addr returns the listening address.
+func (s *GDBServer) Addr() net.Addr {
+	return s.ln.Addr()
+}
+
+// Close stops listening and drops the active connection.
+func (s *GDBServer) Close() error {
+	if s.conn != nil {
+		s.conn.Close()
+	}
+	if s.dbg != nil {
+		s.dbg.Detach()
+	}
+	return s.ln.Close()
+}
+
+// Attach debugs e, replacing any previously attached emulator. Existing
+// breakpoints are dropped; a connected GDB sees the target halted.
+func (s *GDBServer) Attach(e *emu.Emulator) {
+	if s.dbg != nil {
+		s.dbg.Detach()
+	}
+	s.dbg = New(e)
+	s.watches = make(map[string]int)
+	s.halted = s.conn != nil
+}
+
+// RunFrame replaces Emulator.RunFrame while the server is in use. It
+// services GDB packets, then runs until the end of the frame or until a
+// breakpoint, watchpoint or interrupt halts the target. While halted it
+// waits up to one frame for packets and returns without emulating.
+func (s *GDBServer) RunFrame() {
+	if s.dbg == nil {
+		return
+	}
+	s.drain()
+	if s.halted {
+		timeout := time.NewTimer(gdbPollInterval)
+		defer timeout.Stop()
+		for s.halted {
+			select {
+			case req := <-s.requests:
+				s.handle(req)
+			case <-timeout.C:
+				return
+			}
+		}
+	}
+
+	stop := s.dbg.Continue()
+	if stop.Reason != StopFrameEnd {
+		s.halted = true
+		s.send(s.stopReply(stop))
+	}
+}
+
+// drain handles queued packets without blocking.
+func (s *GDBServer) drain() {
+	for {
+		select {
+		case req := <-s.requests:
+			s.handle(req)
+		default:
+			return
+		}
+	}
+}
+
+func (s *GDBServer) acceptLoop() {
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			return
+		}
+		log.Printf("[gdb] connection from %s", conn.RemoteAddr())
+		go s.readLoop(conn)
+	}
+}
+
+// readLoop splits the byte stream into packets and forwards them.
+func (s *GDBServer) readLoop(conn net.Conn) {
+	defer func() {
+		s.requests <- gdbRequest{conn: conn, closed: true}
+	}()
+	r := bufio.NewReader(conn)
+	for {
+		c, err := r.ReadByte()
+		if err != nil {
+			return
+		}
+		switch c {
+		case 0x03:
+			s.requests <- gdbRequest{conn: conn, interrupt: true}
+		case '$':
+			body, err := r.ReadString('#')
+			if err != nil {
+				return
+			}
+			sum := make([]byte, 2)
+			if _, err := r.Read(sum[:1]); err != nil {
+				return
+			}
+			if _, err := r.Read(sum[1:]); err != nil {
+				return
+			}
+			body = body[:len(body)-1]
+			want, err := strconv.ParseUint(string(sum), 16, 8)
+			if err != nil || byte(want) != checksum(body) {
+				conn.Write([]byte("-"))
+				continue
+			}
+			conn.Write([]byte("+"))
+			s.requests <- gdbRequest{conn: conn, packet: body}
+		}
+		// Acks ('+', '-') from GDB are ignored
+	}
+}
+
+func checksum(s string) byte {
+	var sum byte
+	for i := 0; i < len(s); i++ {
+		sum += s[i]
+	}
+	return sum
+}
+
+// send writes a packet to the active connection.
+func (s *GDBServer) send(body string) {
+	if s.conn == nil {
+		return
+	}
+	var b strings.Builder
+	for i := 0; i < len(body); i++ {
+		c := body[i]
+		if c == '#' || c == '$' || c == '}' || c == '*' {
+			b.WriteByte('}')
+			c ^= 0x20
+		}
+		b.WriteByte(c)
+	}
+	escaped := b.String()
+	fmt.Fprintf(s.conn, "$%s#%02x", escaped, checksum(escaped))
+}
+
+// handle executes one request on the emulation goroutine.
+func (s *GDBServer) handle(req gdbRequest) {
+	if req.closed {
+		if req.conn == s.conn {
+			log.Printf("[gdb] disconnected")
+			s.detach()
+		}
+		return
+	}
+	if s.conn != req.conn {
+		// A new debugger connected; it starts with the target halted
+		if s.conn != nil {
+			s.conn.Close()
+		}
+		s.conn = req.conn
+		s.halted = true
+	}
+	if req.interrupt {
+		if !s.halted {
+			s.halted = true
+			s.send("S02")
+		}
+		return
+	}
+	if reply, ok := s.execute(req.packet); ok {
+		s.send(reply)
+	}
+}
+
+// detach clears GDB state and lets the target run freely.
+func (s *GDBServer) detach() {
+	for _, addr := range s.dbg.Breakpoints() {
+		s.dbg.RemoveBreakpoint(addr)
+	}
+	for _, w := range s.dbg.Watchpoints() {
+		s.dbg.RemoveWatchpoint(w.ID)
+	}
+	s.watches = make(map[string]int)
+	s.conn = nil
+	s.halted = false
+}
+
+// execute runs a packet and returns the reply. ok is false when the
+// reply is deferred (continue) or the connection is gone.
+func (s *GDBServer) execute(p string) (reply string, ok bool) {
+	if p == "" {
+		return "", true
+	}
+	switch p[0] {
+	case '?':
+		return "S05", true
+	case 'g':
+		return s.readRegisters(), true
+	case 'G':
+		return s.writeRegisters(p[1:]), true
+	case 'p':
+		return s.readRegister(p[1:]), true
+	case 'P':
+		return s.writeRegister(p[1:]), true
+	case 'm':
+		return s.readMemory(p[1:]), true
+	case 'M':
+		return s.writeMemory(p[1:]), true
+	case 'c':
+		if err := s.setPC(p[1:]); err != nil {
+			return "E01", true
+		}
+		s.halted = false
+		return "", false
+	case 's':
+		if err := s.setPC(p[1:]); err != nil {
+			return "E01", true
+		}
+		return s.stopReply(s.dbg.Step()), true
+	case 'Z', 'z':
+		return s.breakpoint(p), true
+	case 'D':
+		s.send("OK")
+		s.conn.Close()
+		s.detach()
+		return "", false
+	case 'k':
+		s.conn.Close()
+		s.detach()
+		return "", false
+	case 'H':
+		return "OK", true
+	case 'q':
+		return s.query(p), true
+	}
+	// Unsupported packets get an empty reply
+	return "", true
+}
+
+func (s *GDBServer) query(p string) string {
+	switch {
+	case strings.HasPrefix(p, "qSupported"):
+		return "PacketSize=4000;qXfer:features:read+"
+	case p == "qAttached":
+		return "1"
+	case p == "qC":
+		return "QC1"
+	case p == "qfThreadInfo":
+		return "m1"
+	case p == "qsThreadInfo":
+		return "l"
+	case strings.HasPrefix(p, "qXfer:features:read:target.xml:"):
+		var off, length int
+		if _, err := fmt.Sscanf(p[len("qXfer:features:read:target.xml:"):], "%x,%x", &off, &length); err != nil {
+			return "E01"
+		}
+		if off >= len(gdbTargetXML) {
+			return "l"
+		}
+		end := off + length
+		if end >= len(gdbTargetXML) {
+			return "l" + gdbTargetXML[off:]
+		}
+		return "m" + gdbTargetXML[off:end]
+	}
+	return ""
+}
+
+// regValue returns GDB register n.
+func regValue(r m68k.Registers, n int) uint32 {
+	switch {
+	case n < 8:
+		return r.D[n]
+	case n < 16:
+		return r.A[n-8]
+	case n == 16:
+		return uint32(r.SR)
+	}
+	return r.PC
+}
+
+// setRegValue sets GDB register n.
+func setRegValue(r *m68k.Registers, n int, v uint32) {
+	switch {
+	case n < 8:
+		r.D[n] = v
+	case n < 16:
+		r.A[n-8] = v
+	case n == 16:
+		r.SR = uint16(v)
+	default:
+		r.PC = v & 0xFFFFFF
+	}
+}
+
+func (s *GDBServer) readRegisters() string {
+	r := s.dbg.Registers()
+	var b strings.Builder
+	for n := 0; n < gdbRegCount; n++ {
+		fmt.Fprintf(&b, "%08x", regValue(r, n))
+	}
+	return b.String()
+}
+
+func (s *GDBServer) writeRegisters(data string) string {
+	if len(data) < gdbRegCount*8 {
+		return "E01"
+	}
+	r := s.dbg.Registers()
+	for n := 0; n < gdbRegCount; n++ {
+		v, err := strconv.ParseUint(data[n*8:n*8+8], 16, 32)
+		if err != nil {
+			return "E01"
+		}
+		setRegValue(&r, n, uint32(v))
+	}
+	if err := s.dbg.SetRegisters(r); err != nil {
+		return "E03"
+	}
+	return "OK"
+}
+
+func (s *GDBServer) readRegister(arg string) string {
+	n, err := strconv.ParseUint(arg, 16, 8)
+	if err != nil {
+		return "E01"
+	}
+	if n >= gdbRegCount {
+		// Floating point registers do not exist on the 68000
+		return "E02"
+	}
+	return fmt.Sprintf("%08x", regValue(s.dbg.Registers(), int(n)))
+}
+
+func (s *GDBServer) writeRegister(arg string) string {
+	parts := strings.SplitN(arg, "=", 2)
+	if len(parts) != 2 {
+		return "E01"
+	}
+	n, err := strconv.ParseUint(parts[0], 16, 8)
+	if err != nil || n >= gdbRegCount {
+		return "E01"
+	}
+	v, err := strconv.ParseUint(parts[1], 16, 32)
+	if err != nil {
+		return "E01"
+	}
+	r := s.dbg.Registers()
+	setRegValue(&r, int(n), uint32(v))
+	if err := s.dbg.SetRegisters(r); err != nil {
+		return "E03"
+	}
+	return "OK"
+}
+
+// parseAddrLen parses "addr,length".
+func parseAddrLen(arg string) (uint32, int, error) {
+	parts := strings.SplitN(arg, ",", 2)
+	if len(parts) != 2 {
+		return 0, 0, errors.New("malformed address")
+	}
+	addr, err := strconv.ParseUint(parts[0], 16, 32)
+	if err != nil {
+		return 0, 0, err
+	}
+	length, err := strconv.ParseUint(parts[1], 16, 16)
+	if err != nil {
+		return 0, 0, err
+	}
+	return uint32(addr), int(length), nil
+}
+
+func (s *GDBServer) readMemory(arg string) string {
+	addr, length, err := parseAddrLen(arg)
+	if err != nil {
+		return "E01"
+	}
+	buf := make([]byte, length)
+	for i := range buf {
+		buf[i] = s.dbg.ReadMemory(addr + uint32(i))
+	}
+	return hex.EncodeToString(buf)
+}
+
+func (s *GDBServer) writeMemory(arg string) string {
+	parts := strings.SplitN(arg, ":", 2)
+	if len(parts) != 2 {
+		return "E01"
+	}
+	addr, length, err := parseAddrLen(parts[0])
+	if err != nil {
+		return "E01"
+	}
+	data, err := hex.DecodeString(parts[1])
+	if err != nil || len(data) != length {
+		return "E01"
+	}
+	for i, b := range data {
+		s.dbg.WriteMemory(addr+uint32(i), b)
+	}
+	return "OK"
+}
+
+// setPC handles the optional resume address of c and s packets.
+func (s *GDBServer) setPC(arg string) error {
+	if arg == "" {
+		return nil
+	}
+	pc, err := strconv.ParseUint(arg, 16, 32)
+	if err != nil {
+		return err
+	}
+	r := s.dbg.Registers()
+	r.PC = uint32(pc) & 0xFFFFFF
+	return s.dbg.SetRegisters(r)
+}
+
+// breakpoint handles Z/z packets: type 0/1 are breakpoints, 2 write,
+// 3 read and 4 access watchpoints.
+func (s *GDBServer) breakpoint(p string) string {
+	fields := strings.Split(p[1:], ",")
+	if len(fields) < 3 {
+		return "E01"
+	}
+	addr, err := strconv.ParseUint(fields[1], 16, 32)
+	if err != nil {
+		return "E01"
+	}
+	length, err := strconv.ParseUint(fields[2], 16, 16)
+	if err != nil {
+		return "E01"
+	}
+	insert := p[0] == 'Z'
+
+	var kind WatchKind
+	switch fields[0] {
+	case "0", "1":
+		if insert {
+			s.dbg.AddBreakpoint(uint32(addr))
+		} else {
+			s.dbg.RemoveBreakpoint(uint32(addr))
+		}
+		return "OK"
+	case "2":
+		kind = WatchWrite
+	case "3":
+		kind = WatchRead
+	case "4":
+		kind = WatchAccess
+	default:
+		return ""
+	}
+
+	key := strings.Join(fields[:3], ",")
+	if insert {
+		if length == 0 {
+			length = 1
+		}
+		s.watches[key] = s.dbg.AddWatchpoint(uint32(addr), uint32(addr)+uint32(length)-1, kind)
+	} else if id, ok := s.watches[key]; ok {
+		s.dbg.RemoveWatchpoint(id)
+		delete(s.watches, key)
+	}
+	return "OK"
+}
+
+// stopReply formats a debugger stop as a GDB stop reply packet.
+func (s *GDBServer) stopReply(stop Stop) string {
+	if stop.Reason != StopWatchpoint {
+		return "S05"
+	}
+	kind := "awatch"
+	for _, w := range s.dbg.Watchpoints() {
+		if w.ID != stop.Watchpoint {
+			continue
+		}
+		switch w.Kind {
+		case WatchWrite:
+			kind = "watch"
+		case WatchRead:
+			kind = "rwatch"
+		}
+	}
+	return fmt.Sprintf("T05%s:%x;", kind, stop.Addr)
+}
@@ -0,0 +1,191 @@
+package debugger
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// gdbClient is a minimal RSP client for driving the server in tests.
+type gdbClient struct {
+	t    *testing.T
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func (c *gdbClient) send(body string) {
+	c.t.Helper()
+	fmt.Fprintf(c.conn, "$%s#%02x", body, checksum(body))
+	if ack, err := c.r.ReadByte(); err != nil || ack != '+' {
+		c.t.Fatalf("%s: expected ack, got %q (%v)", body, ack, err)
+	}
+}
+
+func (c *gdbClient) recv() string {
+	c.t.Helper()
+	c.conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := c.r.ReadString('$'); err != nil {
+		c.t.Fatalf("reading reply: %v", err)
+	}
+	body, err := c.r.ReadString('#')
+	if err != nil {
+		c.t.Fatalf("reading reply: %v", err)
+	}
+	c.r.Discard(2)
+	return strings.TrimSuffix(body, "#")
+}
+
+func (c *gdbClient) call(body string) string {
+	c.t.Helper()
+	c.send(body)
+	return c.recv()
+}
+
+func startGDB(t *testing.T) (*gdbClient, func()) {
+	t.Helper()
+	s, err := ListenGDB("127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Attach(makeTestEmulator(t))
+
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				s.RunFrame()
+			}
+		}
+	}()
+
+	conn, err := net.Dial("tcp", s.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &gdbClient{t: t, conn: conn, r: bufio.NewReader(conn)}
+	return c, func() {
+		conn.Close()
+		close(done)
+		<-finished
+		s.Close()
+	}
+}
+
+func TestGDB_Session(t *testing.T) {
+	c, stop := startGDB(t)
+	defer stop()
+
+	if got := c.call("qSupported:multiprocess+"); !strings.Contains(got, "qXfer:features:read+") {
+		t.Errorf("qSupported: %q", got)
+	}
+	if got := c.call("qXfer:features:read:target.xml:0,1000"); !strings.HasPrefix(got, "l<?xml") {
+		t.Errorf("target.xml: %q", got)
+	}
+	if got := c.call("?"); got != "S05" {
+		t.Errorf("?: %q", got)
+	}
+
+	// The target ran freely until GDB connected; restart the program
+	if regs := c.call("g"); len(regs) != gdbRegCount*8 {
+		t.Fatalf("g: %q", regs)
+	}
+	if got := c.call("P11=00000200"); got != "OK" {
+		t.Fatalf("write pc: %q", got)
+	}
+	if regs := c.call("g"); !strings.HasSuffix(regs, "00000200") {
+		t.Fatalf("g after pc write: %q", regs)
+	}
+
+	if got := c.call("Z0,208,2"); got != "OK" {
+		t.Fatalf("Z0: %q", got)
+	}
+	c.send("c")
+	if got := c.recv(); got != "S05" {
+		t.Fatalf("continue: %q", got)
+	}
+	if got := c.call("p11"); got != "00000208" {
+		t.Errorf("pc after breakpoint: %q", got)
+	}
+	if got := c.call("p0"); got != "00000005" {
+		t.Errorf("d0 after breakpoint: %q", got)
+	}
+
+	if got := c.call("mff0010,2"); got != "0005" {
+		t.Errorf("read memory: %q", got)
+	}
+	if got := c.call("Mff0020,2:abcd"); got != "OK" {
+		t.Errorf("write memory: %q", got)
+	}
+	if got := c.call("mff0020,2"); got != "abcd" {
+		t.Errorf("read back: %q", got)
+	}
+
+	if got := c.call("s"); got != "S05" {
+		t.Errorf("step: %q", got)
+	}
+	if got := c.call("p11"); got != "0000020a" {
+		t.Errorf("pc after step: %q", got)
+	}
+
+	if got := c.call("P0=00000100"); got != "OK" {
+		t.Errorf("write d0: %q", got)
+	}
+	if got := c.call("p0"); got != "00000100" {
+		t.Errorf("d0 after write: %q", got)
+	}
+
+	if got := c.call("z0,208,2"); got != "OK" {
+		t.Fatalf("z0: %q", got)
+	}
+	if got := c.call("Z4,ff0010,2"); got != "OK" {
+		t.Fatalf("Z4: %q", got)
+	}
+	if got := c.call("Z2,ff0010,2"); got != "OK" {
+		t.Fatalf("Z2: %q", got)
+	}
+	if got := c.call("z4,ff0010,2"); got != "OK" {
+		t.Fatalf("z4: %q", got)
+	}
+	// Restart at $202 so the move.w writes the watched word
+	c.send("c202")
+	if got := c.recv(); got != "T05watch:ff0010;" {
+		t.Fatalf("watch: %q", got)
+	}
+
+	if got := c.call("D"); got != "OK" {
+		t.Errorf("detach: %q", got)
+	}
+}
+
+func TestGDB_Interrupt(t *testing.T) {
+	c, stop := startGDB(t)
+	defer stop()
+
+	if got := c.call("?"); got != "S05" {
+		t.Fatalf("?: %q", got)
+	}
+	c.send("c")
+	time.Sleep(20 * time.Millisecond)
+	c.conn.Write([]byte{0x03})
+	if got := c.recv(); got != "S02" {
+		t.Errorf("interrupt: %q", got)
+	}
+}
+
+func TestGDB_BadChecksum(t *testing.T) {
+	c, stop := startGDB(t)
+	defer stop()
+
+	fmt.Fprint(c.conn, "$g#00")
+	if nak, _ := c.r.ReadByte(); nak != '-' {
+		t.Errorf("expected nak, got %q", nak)
+	}
+}
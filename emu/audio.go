@@ -1,6 +1,10 @@
 package emu
 
-import "math"
+import (
+	"math"
+
+	"github.com/user-none/go-chip-sn76489"
+)
 
 const (
 	sampleRate    = 48000
@@ -14,11 +18,24 @@ const (
 var lpfAlpha = 1.0 / (float64(sampleRate)/(2*math.Pi*lpfCutoffHz) + 1)
 
 // mixAudio collects YM2612 and PSG output buffers and mixes them into
-// the emulator's stereo audio buffer. YM2612 produces stereo L/R pairs,
-// PSG produces mono samples that are duplicated to both channels.
+// the emulator's stereo audio buffer, then applies the Model 1 filter.
 func (e *Emulator) mixAudio() {
-	ym2612Samples := e.ym2612.GetBuffer()
-	psgBuf, psgCount := e.psg.GetBuffer()
+	e.audioBuffer = mixChips(e.audioBuffer, e.ym2612, e.psg)
+	e.applyLowPass()
+}
+
+// applyLowPass filters the emulator's audio buffer. Filter state persists
+// across frames.
+func (e *Emulator) applyLowPass() {
+	lowPass(e.audioBuffer, &e.filterPrevL, &e.filterPrevR)
+}
+
+// mixChips drains the YM2612 and PSG output buffers and appends the mix
+// to buf. YM2612 produces stereo L/R pairs, PSG produces mono samples
+// that are duplicated to both channels.
+func mixChips(buf []int16, ym *YM2612, psg *sn76489.SN76489) []int16 {
+	ym2612Samples := ym.GetBuffer()
+	psgBuf, psgCount := psg.GetBuffer()
 
 	ymPairs := len(ym2612Samples) / 2
 	mixCount := ymPairs
@@ -32,35 +49,34 @@ func (e *Emulator) mixAudio() {
 		psgVal := int32(psgBuf[i])
 		mixL := clampInt32(fmL+psgVal, -32768, 32767)
 		mixR := clampInt32(fmR+psgVal, -32768, 32767)
-		e.audioBuffer = append(e.audioBuffer, int16(mixL), int16(mixR))
+		buf = append(buf, int16(mixL), int16(mixR))
 	}
 
 	// Append any remaining YM2612 stereo samples
 	if ymPairs > mixCount {
-		e.audioBuffer = append(e.audioBuffer, ym2612Samples[mixCount*2:]...)
+		buf = append(buf, ym2612Samples[mixCount*2:]...)
 	}
 
 	// Append any remaining PSG samples as stereo (mono duplicated to L/R)
 	for i := mixCount; i < psgCount; i++ {
 		s := int16(psgBuf[i])
-		e.audioBuffer = append(e.audioBuffer, s, s)
+		buf = append(buf, s, s)
 	}
-
-	e.applyLowPass()
+	return buf
 }
 
-// applyLowPass applies a first-order RC low-pass filter to the audio
-// buffer. This emulates the Model 1 VA3 motherboard filter (fc ~= 2840 Hz,
-// 20 dB/decade rolloff). Applied per stereo channel with state persisting
-// across frames.
-func (e *Emulator) applyLowPass() {
-	for i := 0; i < len(e.audioBuffer); i += 2 {
-		inL := float64(e.audioBuffer[i])
-		inR := float64(e.audioBuffer[i+1])
-		e.filterPrevL = lpfAlpha*inL + (1-lpfAlpha)*e.filterPrevL
-		e.filterPrevR = lpfAlpha*inR + (1-lpfAlpha)*e.filterPrevR
-		e.audioBuffer[i] = int16(math.Round(e.filterPrevL))
-		e.audioBuffer[i+1] = int16(math.Round(e.filterPrevR))
+// lowPass applies a first-order RC low-pass filter to a stereo buffer in
+// place. This emulates the Model 1 VA3 motherboard filter (fc ~= 2840 Hz,
+// 20 dB/decade rolloff). prevL and prevR carry the filter state between
+// calls.
+func lowPass(buf []int16, prevL, prevR *float64) {
+	for i := 0; i < len(buf); i += 2 {
+		inL := float64(buf[i])
+		inR := float64(buf[i+1])
+		*prevL = lpfAlpha*inL + (1-lpfAlpha)**prevL
+		*prevR = lpfAlpha*inR + (1-lpfAlpha)**prevR
+		buf[i] = int16(math.Round(*prevL))
+		buf[i+1] = int16(math.Round(*prevR))
 	}
 }
 
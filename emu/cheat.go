@@ -0,0 +1,259 @@
+package emu
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/user-none/go-chip-m68k"
+)
+
+// gameGenieAlphabet maps Genesis Game Genie characters to 5-bit values.
+const gameGenieAlphabet = "ABCDEFGHJKLMNPRSTVWXYZ0123456789"
+
+// CheatType identifies the device format of a cheat code.
+type CheatType int
+
+const (
+	CheatGameGenie       CheatType = iota // ROM patch (ABCD-EFGH)
+	CheatProActionReplay                  // RAM poke or ROM patch (AAAAAA:VVVV)
+)
+
+// CheatPatch is a single decoded address/value pair.
+type CheatPatch struct {
+	Type    CheatType
+	Address uint32 // 24-bit 68K address
+	Value   uint16
+}
+
+// Cheat is one cheat entry. An entry may hold several codes joined with
+// '+', as used by libretro .cht files.
+type Cheat struct {
+	Code    string
+	Enabled bool
+	Patches []CheatPatch
+}
+
+// DecodeCheat decodes a Genesis Game Genie code ("ABCD-EFGH") or a Pro
+// Action Replay code ("AAAAAA:VVVV"). The dash and colon are optional.
+func DecodeCheat(code string) (CheatPatch, error) {
+	c := strings.ToUpper(strings.TrimSpace(code))
+	switch {
+	case len(c) == 9 && c[4] == '-':
+		return decodeGameGenie(c[:4] + c[5:])
+	case len(c) == 11 && c[6] == ':':
+		return decodeActionReplay(c[:6], c[7:])
+	case len(c) == 8:
+		return decodeGameGenie(c)
+	case len(c) == 10:
+		return decodeActionReplay(c[:6], c[6:])
+	}
+	return CheatPatch{}, errors.New("unrecognized cheat code format")
+}
+
+// decodeGameGenie decodes the 8 characters of a Game Genie code. The 40
+// code bits are scrambled across a 24-bit address and a 16-bit value:
+//
+//	code:    ijklm nopIJ KLMNO PABCD EFGHd efgha bcQRS TUVWX
+//	address: ABCDEFGH IJKLMNOP QRSTUVWX
+//	value:   abcdefgh ijklmnop
+func decodeGameGenie(c string) (CheatPatch, error) {
+	var addr uint32
+	var val uint16
+	for i := 0; i < 8; i++ {
+		idx := strings.IndexByte(gameGenieAlphabet, c[i])
+		if idx < 0 {
+			return CheatPatch{}, errors.New("invalid Game Genie character")
+		}
+		n := uint32(idx)
+		switch i {
+		case 0:
+			val |= uint16(n << 3)
+		case 1:
+			val |= uint16(n >> 2)
+			addr |= (n & 0x03) << 14
+		case 2:
+			addr |= n << 9
+		case 3:
+			addr |= (n&0x0F)<<20 | (n>>4)<<8
+		case 4:
+			val |= uint16(n&0x01) << 12
+			addr |= (n >> 1) << 16
+		case 5:
+			val |= uint16(n&0x01)<<15 | uint16(n>>1)<<8
+		case 6:
+			val |= uint16(n>>3) << 13
+			addr |= (n & 0x07) << 5
+		case 7:
+			addr |= n
+		}
+	}
+	return CheatPatch{Type: CheatGameGenie, Address: addr, Value: val}, nil
+}
+
+// decodeActionReplay decodes a hex address and value pair.
+func decodeActionReplay(a, v string) (CheatPatch, error) {
+	addr, err := strconv.ParseUint(a, 16, 24)
+	if err != nil {
+		return CheatPatch{}, errors.New("invalid Action Replay address")
+	}
+	val, err := strconv.ParseUint(v, 16, 16)
+	if err != nil {
+		return CheatPatch{}, errors.New("invalid Action Replay value")
+	}
+	return CheatPatch{Type: CheatProActionReplay, Address: uint32(addr), Value: uint16(val)}, nil
+}
+
+// parseCheat decodes a '+' separated list of codes.
+func parseCheat(code string) ([]CheatPatch, error) {
+	var patches []CheatPatch
+	for _, part := range strings.Split(code, "+") {
+		if strings.TrimSpace(part) == "" {
+			continue
+		}
+		p, err := DecodeCheat(part)
+		if err != nil {
+			return nil, err
+		}
+		if !isCheatROMAddress(p.Address) && !isCheatRAMAddress(p.Address) {
+			return nil, errors.New("cheat address outside ROM and RAM")
+		}
+		if p.Type == CheatGameGenie && !isCheatROMAddress(p.Address) {
+			return nil, errors.New("Game Genie codes can only patch ROM")
+		}
+		if isCheatROMAddress(p.Address) && p.Address&1 != 0 {
+			return nil, errors.New("ROM patch address must be even")
+		}
+		patches = append(patches, p)
+	}
+	if len(patches) == 0 {
+		return nil, errors.New("empty cheat code")
+	}
+	return patches, nil
+}
+
+func isCheatROMAddress(addr uint32) bool { return addr < maxROMSize }
+func isCheatRAMAddress(addr uint32) bool { return addr >= 0xE00000 && addr <= 0xFFFFFF }
+
+// AddCheat decodes and appends an enabled cheat, returning its index.
+func (e *Emulator) AddCheat(code string) (int, error) {
+	patches, err := parseCheat(code)
+	if err != nil {
+		return -1, err
+	}
+	e.cheats = append(e.cheats, Cheat{Code: code, Enabled: true, Patches: patches})
+	e.rebuildROMPatches()
+	return len(e.cheats) - 1, nil
+}
+
+// SetCheat stores a cheat at index, growing the list as needed. This
+// mirrors the libretro retro_cheat_set callback.
+func (e *Emulator) SetCheat(index int, enabled bool, code string) error {
+	if index < 0 {
+		return errors.New("invalid cheat index")
+	}
+	patches, err := parseCheat(code)
+	if err != nil {
+		return err
+	}
+	for len(e.cheats) <= index {
+		e.cheats = append(e.cheats, Cheat{})
+	}
+	e.cheats[index] = Cheat{Code: code, Enabled: enabled, Patches: patches}
+	e.rebuildROMPatches()
+	return nil
+}
+
+// EnableCheat enables or disables the cheat at index.
+func (e *Emulator) EnableCheat(index int, enabled bool) error {
+	if index < 0 || index >= len(e.cheats) {
+		return errors.New("invalid cheat index")
+	}
+	e.cheats[index].Enabled = enabled
+	e.rebuildROMPatches()
+	return nil
+}
+
+// RemoveCheat deletes the cheat at index. Later cheats shift down by one.
+func (e *Emulator) RemoveCheat(index int) error {
+	if index < 0 || index >= len(e.cheats) {
+		return errors.New("invalid cheat index")
+	}
+	e.cheats = append(e.cheats[:index], e.cheats[index+1:]...)
+	e.rebuildROMPatches()
+	return nil
+}
+
+// ResetCheats removes all cheats. This mirrors the libretro
+// retro_cheat_reset callback.
+func (e *Emulator) ResetCheats() {
+	e.cheats = nil
+	e.bus.romPatches = nil
+}
+
+// Cheats returns a copy of the cheat list.
+func (e *Emulator) Cheats() []Cheat {
+	out := make([]Cheat, len(e.cheats))
+	for i, c := range e.cheats {
+		out[i] = c
+		out[i].Patches = append([]CheatPatch(nil), c.Patches...)
+	}
+	return out
+}
+
+// rebuildROMPatches collects the ROM patches of all enabled cheats into
+// the bus lookup table. ROM patches always replace a whole word. Later
+// cheats win when addresses overlap.
+func (e *Emulator) rebuildROMPatches() {
+	var patches map[uint32]uint16
+	for _, c := range e.cheats {
+		if !c.Enabled {
+			continue
+		}
+		for _, p := range c.Patches {
+			if !isCheatROMAddress(p.Address) {
+				continue
+			}
+			if patches == nil {
+				patches = make(map[uint32]uint16)
+			}
+			patches[p.Address] = p.Value
+		}
+	}
+	e.bus.romPatches = patches
+}
+
+// applyRAMCheats pokes the RAM values of all enabled cheats. Values that
+// fit in a byte are written as bytes; larger values as words.
+func (e *Emulator) applyRAMCheats() {
+	for _, c := range e.cheats {
+		if !c.Enabled {
+			continue
+		}
+		for _, p := range c.Patches {
+			if !isCheatRAMAddress(p.Address) {
+				continue
+			}
+			if p.Value <= 0xFF {
+				e.bus.writeRAM(m68k.Byte, p.Address, uint32(p.Value))
+			} else {
+				e.bus.writeRAM(m68k.Word, p.Address&^1, uint32(p.Value))
+			}
+		}
+	}
+}
+
+// patchROM overlays active ROM patches on a cartridge read.
+func (b *GenesisBus) patchROM(s m68k.Size, addr uint32, value uint32) uint32 {
+	p, ok := b.romPatches[addr&^1]
+	if !ok {
+		return value
+	}
+	if s == m68k.Byte {
+		if addr&1 == 0 {
+			return uint32(p >> 8)
+		}
+		return uint32(p & 0xFF)
+	}
+	return uint32(p)
+}
@@ -0,0 +1,204 @@
+package emu
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/user-none/go-chip-m68k"
+)
+
+// encodeGameGenie builds a Game Genie code from an address and value
+// using the documented bit layout, independent of the decoder.
+func encodeGameGenie(addr uint32, val uint16) string {
+	const layout = "ijklmnopIJKLMNOPABCDEFGHdefghabcQRSTUVWX"
+	bit := func(ch byte) uint32 {
+		if ch >= 'A' && ch <= 'X' {
+			return (addr >> (23 - uint(ch-'A'))) & 1
+		}
+		return uint32(val>>(15-uint(ch-'a'))) & 1
+	}
+	var sb strings.Builder
+	for i := 0; i < 8; i++ {
+		if i == 4 {
+			sb.WriteByte('-')
+		}
+		var n uint32
+		for j := 0; j < 5; j++ {
+			n = n<<1 | bit(layout[i*5+j])
+		}
+		sb.WriteByte(gameGenieAlphabet[n])
+	}
+	return sb.String()
+}
+
+func TestDecodeCheat_GameGenieRoundTrip(t *testing.T) {
+	cases := []struct {
+		addr uint32
+		val  uint16
+	}{
+		{0x000000, 0x0000},
+		{0x000200, 0x4E71},
+		{0x01A3F6, 0x6002},
+		{0x3FFFFE, 0xFFFF},
+		{0x123456, 0xBEEF},
+	}
+	for _, tc := range cases {
+		code := encodeGameGenie(tc.addr, tc.val)
+		p, err := DecodeCheat(code)
+		if err != nil {
+			t.Fatalf("%s: %v", code, err)
+		}
+		if p.Type != CheatGameGenie || p.Address != tc.addr || p.Value != tc.val {
+			t.Errorf("%s: got %06X:%04X, want %06X:%04X", code, p.Address, p.Value, tc.addr, tc.val)
+		}
+	}
+}
+
+func TestDecodeCheat_GameGenieNoDash(t *testing.T) {
+	code := encodeGameGenie(0x000200, 0x4E71)
+	p, err := DecodeCheat(strings.ToLower(strings.Replace(code, "-", "", 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Address != 0x000200 || p.Value != 0x4E71 {
+		t.Errorf("got %06X:%04X", p.Address, p.Value)
+	}
+}
+
+func TestDecodeCheat_ActionReplay(t *testing.T) {
+	for _, code := range []string{"FFA123:0063", "ffa1230063"} {
+		p, err := DecodeCheat(code)
+		if err != nil {
+			t.Fatalf("%s: %v", code, err)
+		}
+		if p.Type != CheatProActionReplay || p.Address != 0xFFA123 || p.Value != 0x0063 {
+			t.Errorf("%s: got %+v", code, p)
+		}
+	}
+}
+
+func TestDecodeCheat_Invalid(t *testing.T) {
+	for _, code := range []string{"", "ABCD-EFGI", "ZZZZZZ:0000", "FF0000:XYZW", "ABC"} {
+		if _, err := DecodeCheat(code); err == nil {
+			t.Errorf("%q: expected error", code)
+		}
+	}
+}
+
+func TestAddCheat_RejectsBadAddresses(t *testing.T) {
+	e := createTestEmulator()
+	// I/O area is neither ROM nor RAM
+	if _, err := e.AddCheat("A10000:0000"); err == nil {
+		t.Error("expected error for I/O address")
+	}
+	// Odd ROM address
+	if _, err := e.AddCheat("000201:0000"); err == nil {
+		t.Error("expected error for odd ROM address")
+	}
+	if len(e.Cheats()) != 0 {
+		t.Error("rejected cheats should not be added")
+	}
+}
+
+func TestGameGenie_PatchesROMReads(t *testing.T) {
+	e := createTestEmulator()
+	orig := e.bus.Read(m68k.Word, 0x200)
+
+	idx, err := e.AddCheat(encodeGameGenie(0x000200, 0x1234))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := e.bus.Read(m68k.Word, 0x200); got != 0x1234 {
+		t.Errorf("word read: expected 0x1234, got 0x%04X", got)
+	}
+	if got := e.bus.Read(m68k.Byte, 0x200); got != 0x12 {
+		t.Errorf("even byte read: expected 0x12, got 0x%02X", got)
+	}
+	if got := e.bus.Read(m68k.Byte, 0x201); got != 0x34 {
+		t.Errorf("odd byte read: expected 0x34, got 0x%02X", got)
+	}
+	if got := e.bus.Read(m68k.Long, 0x1FE); got&0xFFFF != 0x1234 {
+		t.Errorf("long read: expected low word 0x1234, got 0x%08X", got)
+	}
+
+	if err := e.EnableCheat(idx, false); err != nil {
+		t.Fatal(err)
+	}
+	if got := e.bus.Read(m68k.Word, 0x200); got != orig {
+		t.Errorf("disabled: expected 0x%04X, got 0x%04X", orig, got)
+	}
+
+	if err := e.EnableCheat(idx, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.RemoveCheat(idx); err != nil {
+		t.Fatal(err)
+	}
+	if got := e.bus.Read(m68k.Word, 0x200); got != orig {
+		t.Errorf("removed: expected 0x%04X, got 0x%04X", orig, got)
+	}
+}
+
+func TestActionReplay_PokesRAMEachFrame(t *testing.T) {
+	e := createTestEmulator()
+	if _, err := e.AddCheat("FF1000:0063+FF2000:BEEF"); err != nil {
+		t.Fatal(err)
+	}
+	e.bus.ram[0x1000] = 0
+	e.bus.ram[0x1001] = 0xAA
+	e.RunFrame()
+
+	if e.bus.ram[0x1000] != 0x63 {
+		t.Errorf("byte poke: expected 0x63, got 0x%02X", e.bus.ram[0x1000])
+	}
+	if e.bus.ram[0x1001] != 0xAA {
+		t.Errorf("byte poke should not touch next byte, got 0x%02X", e.bus.ram[0x1001])
+	}
+	if e.bus.ram[0x2000] != 0xBE || e.bus.ram[0x2001] != 0xEF {
+		t.Errorf("word poke: got %02X%02X", e.bus.ram[0x2000], e.bus.ram[0x2001])
+	}
+
+	e.bus.ram[0x1000] = 0x05
+	e.RunFrame()
+	if e.bus.ram[0x1000] != 0x63 {
+		t.Errorf("poke should reapply each frame, got 0x%02X", e.bus.ram[0x1000])
+	}
+}
+
+func TestSetCheat_LibretroSemantics(t *testing.T) {
+	e := createTestEmulator()
+	if err := e.SetCheat(2, false, "FF0000:0001"); err != nil {
+		t.Fatal(err)
+	}
+	cheats := e.Cheats()
+	if len(cheats) != 3 {
+		t.Fatalf("expected list grown to 3, got %d", len(cheats))
+	}
+	if cheats[2].Enabled || cheats[2].Code != "FF0000:0001" {
+		t.Errorf("unexpected cheat %+v", cheats[2])
+	}
+
+	e.bus.ram[0] = 0
+	e.RunFrame()
+	if e.bus.ram[0] != 0 {
+		t.Error("disabled cheat should not poke RAM")
+	}
+
+	e.ResetCheats()
+	if len(e.Cheats()) != 0 {
+		t.Error("expected no cheats after reset")
+	}
+	if err := e.SetCheat(-1, true, "FF0000:0001"); err == nil {
+		t.Error("expected error for negative index")
+	}
+}
+
+func TestCheat_DMAFromROMSeesPatch(t *testing.T) {
+	e := createTestEmulator()
+	if _, err := e.AddCheat(encodeGameGenie(0x000100, 0xCAFE)); err != nil {
+		t.Fatal(err)
+	}
+	if got := e.bus.ReadWord(0x000100); got != 0xCAFE {
+		t.Errorf("expected 0xCAFE, got 0x%04X", got)
+	}
+}
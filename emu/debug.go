@@ -0,0 +1,169 @@
+package emu
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+
+	"github.com/user-none/go-chip-m68k"
+)
+
+// DebugHook receives 68K execution and bus events while attached with
+// SetDebugHook. Either method can stop RunFrame part way through a
+// scanline; the next RunFrame call resumes from exactly that point.
+type DebugHook interface {
+	// BeforeInstruction is called before the 68K executes the
+	// instruction at pc. Returning true stops RunFrame before it runs.
+	BeforeInstruction(pc uint32) bool
+
+	// MemoryAccess is called for every access on the 68K bus, including
+	// DMA and Z80 bank window accesses. For reads, value is the data
+	// returned. Returning true stops RunFrame at the next 68K
+	// instruction boundary.
+	MemoryAccess(addr uint32, size m68k.Size, write bool, value uint32) bool
+}
+
+// frameState tracks progress through a frame so RunFrame can return early
+// when a debug hook requests a stop and pick up where it left off.
+type frameState struct {
+	stopped      bool // RunFrame returned mid-scanline
+	line         int  // Scanline being executed
+	budget       int  // 68K cycles remaining in the scanline
+	activeHeight int  // Active display height latched at frame start
+	gunCycle     int  // Cycle in the scanline a light gun fires, or -1
+}
+
+// SetDebugHook attaches a debug hook, or detaches it when h is nil.
+// Without a hook the 68K runs with no per-instruction overhead.
+func (e *Emulator) SetDebugHook(h DebugHook) {
+	e.debugHook = h
+	e.bus.debugHook = h
+	e.bus.debugBreak = false
+}
+
+// DebugStopped reports whether the last RunFrame call returned before the
+// end of the frame because the debug hook requested a stop.
+func (e *Emulator) DebugStopped() bool {
+	return e.frame.stopped
+}
+
+// DebugScanline returns the scanline currently being executed. While
+// stopped this is the scanline the 68K was interrupted in.
+func (e *Emulator) DebugScanline() int {
+	return e.frame.line
+}
+
+// M68KRegisters returns a snapshot of the 68K registers.
+func (e *Emulator) M68KRegisters() m68k.Registers {
+	return e.m68k.Registers()
+}
+
+// M68KCycles returns the 68K cycle counter.
+func (e *Emulator) M68KCycles() uint64 {
+	return e.m68k.Cycles()
+}
+
+// m68kStateVersion is the go-chip-m68k Serialize layout SetM68KRegisters
+// patches: version(1), then a register block of D(32) + A(32) + PC(4) +
+// SR(2) + USP(4) + SSP(4).
+const (
+	m68kStateVersion  = 1
+	m68kRegisterBlock = 78
+)
+
+var errM68KStateLayout = errors.New("unsupported 68K state layout")
+
+// SetM68KRegisters overwrites the 68K registers. A[7] is written to the
+// stack pointer selected by the S bit of SR. The cycle counter, a pending
+// interrupt, the STOP and halted states and any cycle deficit are kept.
+// If go-chip-m68k's state layout is not the one expected, the CPU is left
+// unchanged and an error is returned.
+func (e *Emulator) SetM68KRegisters(regs m68k.Registers) error {
+	if regs.SR&0x2000 != 0 {
+		regs.SSP = regs.A[7]
+	} else {
+		regs.USP = regs.A[7]
+	}
+	// SetState would reset everything but the registers, so patch the
+	// register block at the start of the CPU's serialized state instead.
+	// The block must hold the current registers, so a layout change in
+	// go-chip-m68k fails here rather than corrupting the CPU.
+	var buf [m68k.SerializeSize]byte
+	if err := e.m68k.Serialize(buf[:]); err != nil {
+		return err
+	}
+	var current [m68kRegisterBlock]byte
+	putM68KRegisters(current[:], e.m68k.Registers())
+	if buf[0] != m68kStateVersion || !bytes.Equal(buf[1:1+m68kRegisterBlock], current[:]) {
+		return errM68KStateLayout
+	}
+	putM68KRegisters(buf[1:], regs)
+	return e.m68k.Deserialize(buf[:])
+}
+
+// putM68KRegisters writes the register block of the 68K's serialized
+// state.
+func putM68KRegisters(buf []byte, regs m68k.Registers) {
+	be := binary.BigEndian
+	off := 0
+	for _, v := range regs.D {
+		be.PutUint32(buf[off:], v)
+		off += 4
+	}
+	for _, v := range regs.A {
+		be.PutUint32(buf[off:], v)
+		off += 4
+	}
+	be.PutUint32(buf[off:], regs.PC)
+	be.PutUint16(buf[off+4:], regs.SR)
+	be.PutUint32(buf[off+6:], regs.USP)
+	be.PutUint32(buf[off+10:], regs.SSP)
+}
+
+// DebugRead performs a 68K bus read through GenesisBus.ReadCycle without
+// notifying the debug hook. Device reads keep their side effects.
+func (e *Emulator) DebugRead(size m68k.Size, addr uint32) uint32 {
+	return e.bus.readCycle(e.m68k.Cycles(), size, addr)
+}
+
+// DebugWrite performs a 68K bus write through GenesisBus.WriteCycle
+// without notifying the debug hook. ROM is read-only, as on hardware.
+func (e *Emulator) DebugWrite(size m68k.Size, addr uint32, value uint32) {
+	hook := e.bus.debugHook
+	e.bus.debugHook = nil
+	e.bus.WriteCycle(e.m68k.Cycles(), size, addr, value)
+	e.bus.debugHook = hook
+}
+
+// PeekMemory reads a byte from the 68K address space without side
+// effects and without triggering debug hooks. Only cartridge ROM (with
+// the TMSS boot ROM and cheat patches applied) and main RAM are
+// readable; other regions return 0.
+func (e *Emulator) PeekMemory(addr uint32) byte {
+	addr &= 0xFFFFFF
+	switch {
+	case addr < 0x400000:
+		if e.bus.tmss.biosMapped {
+			return byte(e.bus.readBIOS(m68k.Byte, addr))
+		}
+		return byte(e.bus.readCartROM(m68k.Byte, addr))
+	case addr >= 0xE00000:
+		return e.bus.ram[addr&0xFFFF]
+	}
+	return 0
+}
+
+// debugStop reports whether the debug hook wants the 68K stopped before
+// its next step. Breakpoints are only checked when a new instruction is
+// about to be fetched, not while an instruction's cycle deficit is being
+// paid down.
+func (e *Emulator) debugStop() bool {
+	if e.bus.debugBreak {
+		e.bus.debugBreak = false
+		return true
+	}
+	if e.m68k.Deficit() > 0 {
+		return false
+	}
+	return e.debugHook.BeforeInstruction(e.m68k.Registers().PC)
+}
@@ -0,0 +1,109 @@
+package emu
+
+import (
+	"testing"
+
+	"github.com/user-none/go-chip-m68k"
+)
+
+// stopAt is a DebugHook that stops before the instruction at pc.
+type stopAt struct {
+	pc   uint32
+	hits int
+}
+
+func (s *stopAt) BeforeInstruction(pc uint32) bool {
+	if pc == s.pc {
+		s.hits++
+		return true
+	}
+	return false
+}
+
+func (s *stopAt) MemoryAccess(addr uint32, size m68k.Size, write bool, value uint32) bool {
+	return false
+}
+
+func TestDebugHook_StopsAndResumes(t *testing.T) {
+	e := createTestEmulator()
+	h := &stopAt{pc: 0x200}
+	e.SetDebugHook(h)
+
+	e.RunFrame()
+	if !e.DebugStopped() {
+		t.Fatal("expected RunFrame to stop at the hook")
+	}
+	if e.M68KRegisters().PC != 0x200 {
+		t.Errorf("expected PC 0x200, got 0x%06X", e.M68KRegisters().PC)
+	}
+	line := e.DebugScanline()
+
+	// Detach so the resumed frame runs through
+	e.SetDebugHook(nil)
+	e.RunFrame()
+	if e.DebugStopped() {
+		t.Error("expected frame to complete after resume")
+	}
+	if line != 0 {
+		t.Errorf("expected stop on scanline 0, got %d", line)
+	}
+}
+
+func TestDebugHook_DeserializeClearsStop(t *testing.T) {
+	e := createTestEmulator()
+	state, err := e.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.SetDebugHook(&stopAt{pc: 0x200})
+	e.RunFrame()
+	if !e.DebugStopped() {
+		t.Fatal("expected stop")
+	}
+	if err := e.Deserialize(state); err != nil {
+		t.Fatal(err)
+	}
+	if e.DebugStopped() {
+		t.Error("loading a state should discard a mid-frame stop")
+	}
+}
+
+func TestSetM68KRegisters_KeepsPendingInterrupt(t *testing.T) {
+	e := createTestEmulator()
+	e.bus.rom[0x70], e.bus.rom[0x71], e.bus.rom[0x72], e.bus.rom[0x73] = 0, 0, 0x03, 0x00 // Level 4 autovector
+	e.m68k.RequestInterrupt(4, nil)
+	cycles := e.M68KCycles()
+
+	regs := e.M68KRegisters()
+	regs.D[3] = 0x12345678
+	regs.SR = 0x2000 // Unmask interrupts
+	if err := e.SetM68KRegisters(regs); err != nil {
+		t.Fatalf("SetM68KRegisters failed: %v", err)
+	}
+
+	if e.M68KCycles() != cycles {
+		t.Errorf("cycle counter changed from %d to %d", cycles, e.M68KCycles())
+	}
+	if got := e.M68KRegisters().D[3]; got != 0x12345678 {
+		t.Errorf("D3: expected 0x12345678, got 0x%08X", got)
+	}
+	e.m68k.Step()
+	if sr := e.M68KRegisters().SR; sr&0x0700 != 0x0400 {
+		t.Errorf("expected the pending interrupt to be taken, SR=0x%04X", sr)
+	}
+}
+
+func TestPeekMemory(t *testing.T) {
+	e := createTestEmulator()
+	e.bus.ram[0x1234] = 0xAB
+	if got := e.PeekMemory(0xFF1234); got != 0xAB {
+		t.Errorf("RAM peek: expected 0xAB, got 0x%02X", got)
+	}
+	if got := e.PeekMemory(0x000001); got != 0xFF {
+		t.Errorf("ROM peek: expected 0xFF, got 0x%02X", got)
+	}
+	// Peeking the VDP must not touch its state
+	if got := e.PeekMemory(0xC00004); got != 0 {
+		t.Errorf("VDP peek: expected 0, got 0x%02X", got)
+	}
+}
@@ -0,0 +1,376 @@
+package emu
+
+import (
+	"bytes"
+	"encoding/binary"
+)
+
+// eepromSerializeSize is the fixed size of the EEPROM protocol state in
+// save states. The memory contents are stored in the SRAM slot.
+// present(1) + scl(1) + sda(1) + sdaOut(1) + state(1) + bitCount(1) +
+// shift(1) + wordAddr(2) + reserved(3)
+const eepromSerializeSize = 12
+
+// eepromChip describes a 24Cxx serial EEPROM part.
+type eepromChip struct {
+	size     int    // Capacity in bytes
+	pageMask uint16 // Page write wrap mask
+	mode     int    // Addressing mode: 1 = X24C01, 2 = 24C02-24C16, 3 = 24C32-24C65
+}
+
+var (
+	chip24C01 = eepromChip{size: 128, pageMask: 0x03, mode: 1}
+	chip24C02 = eepromChip{size: 256, pageMask: 0x07, mode: 2}
+	chip24C08 = eepromChip{size: 1024, pageMask: 0x0F, mode: 2}
+	chip24C16 = eepromChip{size: 2048, pageMask: 0x0F, mode: 2}
+	chip24C65 = eepromChip{size: 8192, pageMask: 0x3F, mode: 3}
+)
+
+// eepromBoard describes how a cartridge wires the EEPROM SDA/SCL lines
+// onto the 68K bus. SDA is split into an input line (EEPROM -> 68K) and
+// an output line (68K -> EEPROM) because several boards use different
+// addresses or bits for each direction.
+type eepromBoard struct {
+	sdaInAddr  uint32
+	sdaInBit   uint8
+	sdaOutAddr uint32
+	sdaOutBit  uint8
+	sclAddr    uint32
+	sclBit     uint8
+}
+
+var (
+	// Sega: Wonder Boy in Monster World, Evander Holyfield, Wily Wars
+	boardSega = eepromBoard{0x200001, 0, 0x200001, 0, 0x200001, 1}
+	// Electronic Arts: NHLPA 93, Rings of Power, Madden 93
+	boardEA = eepromBoard{0x200001, 7, 0x200001, 7, 0x200001, 6}
+	// Acclaim 16Mbit boards: NBA Jam
+	boardAcclaim16M = eepromBoard{0x200001, 1, 0x200000, 0, 0x200000, 1}
+	// Acclaim 32Mbit boards: NBA Jam TE, NFL Quarterback Club
+	boardAcclaim32M = eepromBoard{0x200001, 0, 0x200001, 0, 0x200000, 0}
+)
+
+// eepromGame maps a header product code to its board and chip.
+type eepromGame struct {
+	serial string
+	board  eepromBoard
+	chip   eepromChip
+}
+
+// eepromDatabase lists cartridges whose EEPROM is not described by the
+// generic "RA" $E8 header, or whose board differs from the Sega wiring.
+var eepromDatabase = []eepromGame{
+	{"T-081326", boardAcclaim16M, chip24C02}, // NBA Jam (UE)
+	{"T-81033", boardAcclaim16M, chip24C02},  // NBA Jam (J)
+	{"T-81406", boardAcclaim32M, chip24C02},  // NBA Jam Tournament Edition
+	{"T-081276", boardAcclaim32M, chip24C02}, // NFL Quarterback Club
+	{"T-081586", boardAcclaim32M, chip24C16}, // NFL Quarterback Club 96
+	{"T-81576", boardAcclaim32M, chip24C65},  // College Slam
+	{"T-81476", boardAcclaim32M, chip24C65},  // Frank Thomas Big Hurt Baseball
+	{"T-50176", boardEA, chip24C01},          // Rings of Power
+	{"T-50396", boardEA, chip24C01},          // NHLPA Hockey 93
+	{"T-50446", boardEA, chip24C01},          // John Madden Football 93
+	{"T-50516", boardEA, chip24C01},          // John Madden Football 93 Championship Edition
+	{"T-50606", boardEA, chip24C01},          // Bill Walsh College Football
+	{"MK-1215", boardSega, chip24C01},        // Evander Holyfield's Real Deal Boxing
+	{"G-4060", boardSega, chip24C01},         // Wonder Boy in Monster World (J)
+	{"T-12046", boardSega, chip24C01},        // Mega Man - The Wily Wars
+}
+
+// I2C protocol states.
+const (
+	eepromStandby   = iota // Waiting for a start condition
+	eepromDevice           // Receiving device select (or X24C01 address) byte
+	eepromAddrHigh         // Receiving word address high byte (24C32+)
+	eepromAddrLow          // Receiving word address low byte
+	eepromWrite            // Receiving data bytes to write
+	eepromReadSetup        // Acknowledging a read request before transmitting
+	eepromRead             // Transmitting data bytes
+)
+
+// EEPROM emulates a 24Cxx I2C serial EEPROM wired to the cartridge bus.
+// The 68K bit-bangs SCL and SDA through the board's mapped addresses.
+type EEPROM struct {
+	mem   []byte
+	chip  eepromChip
+	board eepromBoard
+
+	scl    bool // Clock line as driven by the 68K
+	sda    bool // Data line as driven by the 68K
+	sdaOut bool // Data line as driven by the EEPROM (true = released)
+
+	state    int
+	bitCount uint8  // Clocks in the current byte (1-8 data, 9 = ACK)
+	shift    uint8  // Receive/transmit shift register
+	wordAddr uint16 // Current memory address
+}
+
+// newEEPROM creates an EEPROM with the given chip and board wiring.
+// Memory is initialized to 0xFF as on an erased part.
+func newEEPROM(chip eepromChip, board eepromBoard) *EEPROM {
+	mem := make([]byte, chip.size)
+	for i := range mem {
+		mem[i] = 0xFF
+	}
+	e := &EEPROM{mem: mem, chip: chip, board: board}
+	e.reset()
+	return e
+}
+
+// reset returns the protocol to idle with both lines released. The memory
+// contents are kept.
+func (e *EEPROM) reset() {
+	*e = EEPROM{
+		mem:    e.mem,
+		chip:   e.chip,
+		board:  e.board,
+		scl:    true,
+		sda:    true,
+		sdaOut: true,
+	}
+}
+
+// detectEEPROM returns an EEPROM for cartridges that save through a
+// serial EEPROM, or nil. Known product codes at $180-$18D are matched
+// first; otherwise an "RA" header with type byte $E8 at $1B2 selects a
+// 24C01 on the Sega board.
+func detectEEPROM(rom []byte) *EEPROM {
+	if len(rom) < 0x1BC {
+		return nil
+	}
+	product := rom[0x180:0x18E]
+	for _, g := range eepromDatabase {
+		if bytes.Contains(product, []byte(g.serial)) {
+			return newEEPROM(g.chip, g.board)
+		}
+	}
+	if rom[0x1B0] == 'R' && rom[0x1B1] == 'A' && rom[0x1B2] == 0xE8 {
+		return newEEPROM(chip24C01, boardSega)
+	}
+	return nil
+}
+
+// Handles reports whether addr is one of the board's EEPROM line addresses.
+func (e *EEPROM) Handles(addr uint32) bool {
+	return addr == e.board.sdaInAddr || addr == e.board.sdaOutAddr || addr == e.board.sclAddr
+}
+
+// ReadsAt reports whether reads of addr return the SDA input line. The
+// other line addresses are write-only and read as the cartridge.
+func (e *EEPROM) ReadsAt(addr uint32) bool {
+	return addr == e.board.sdaInAddr
+}
+
+// Read returns the byte seen by the 68K at addr. Only the SDA input
+// bit carries data; the combined line is low if either side pulls it low.
+func (e *EEPROM) Read(addr uint32) uint8 {
+	if addr != e.board.sdaInAddr {
+		return 0
+	}
+	if e.sdaOut && e.sda {
+		return 1 << e.board.sdaInBit
+	}
+	return 0
+}
+
+// Write latches line levels from a byte written to addr and
+// advances the I2C state machine.
+func (e *EEPROM) Write(addr uint32, val uint8) {
+	scl, sda := e.latch(addr, val, e.scl, e.sda)
+	e.update(scl, sda)
+}
+
+// WriteWord latches line levels from a word written to addr and addr+1.
+// Both bytes are applied before the state machine advances, so boards
+// that place SCL and SDA on different bytes see them change together.
+func (e *EEPROM) WriteWord(addr uint32, val uint16) {
+	scl, sda := e.latch(addr, uint8(val>>8), e.scl, e.sda)
+	scl, sda = e.latch(addr+1, uint8(val), scl, sda)
+	e.update(scl, sda)
+}
+
+// latch returns the SCL/SDA levels after a byte write to addr.
+func (e *EEPROM) latch(addr uint32, val uint8, scl, sda bool) (bool, bool) {
+	if addr == e.board.sclAddr {
+		scl = val&(1<<e.board.sclBit) != 0
+	}
+	if addr == e.board.sdaOutAddr {
+		sda = val&(1<<e.board.sdaOutBit) != 0
+	}
+	return scl, sda
+}
+
+// update processes a change of the SCL/SDA lines.
+func (e *EEPROM) update(scl, sda bool) {
+	oldSCL, oldSDA := e.scl, e.sda
+	e.scl, e.sda = scl, sda
+
+	if oldSCL && scl {
+		// SDA changes while SCL is high are start/stop conditions
+		if oldSDA && !sda {
+			e.start()
+		} else if !oldSDA && sda {
+			e.state = eepromStandby
+			e.sdaOut = true
+		}
+		return
+	}
+
+	if !oldSCL && scl {
+		e.clockRise()
+	} else if oldSCL && !scl {
+		e.clockFall()
+	}
+}
+
+// start handles a start (or repeated start) condition.
+func (e *EEPROM) start() {
+	e.state = eepromDevice
+	e.bitCount = 0
+	e.shift = 0
+	e.sdaOut = true
+}
+
+// clockRise samples SDA on the rising edge of SCL. bitCount counts
+// clocked bits: 1-8 for data, 9 for the acknowledge clock.
+func (e *EEPROM) clockRise() {
+	switch e.state {
+	case eepromDevice, eepromAddrHigh, eepromAddrLow, eepromWrite, eepromReadSetup:
+		if e.bitCount < 8 {
+			e.shift <<= 1
+			if e.sda {
+				e.shift |= 1
+			}
+		}
+		e.bitCount++
+	case eepromRead:
+		e.bitCount++
+		// Master acknowledge: a high SDA (NACK) ends the read
+		if e.bitCount == 9 && e.sda {
+			e.state = eepromStandby
+			e.sdaOut = true
+		}
+	}
+}
+
+// clockFall drives the EEPROM's side of SDA for the next clock on the
+// falling edge of SCL.
+func (e *EEPROM) clockFall() {
+	switch e.state {
+	case eepromDevice, eepromAddrHigh, eepromAddrLow, eepromWrite, eepromReadSetup:
+		switch e.bitCount {
+		case 8:
+			// Byte complete: acknowledge by pulling SDA low
+			e.sdaOut = !e.receiveByte(e.shift)
+		case 9:
+			// End of ACK clock
+			e.bitCount = 0
+			e.shift = 0
+			e.sdaOut = true
+			if e.state == eepromReadSetup {
+				e.state = eepromRead
+				e.loadReadByte()
+			}
+		}
+	case eepromRead:
+		switch {
+		case e.bitCount == 9:
+			// End of master ACK clock: advance and send the next byte
+			e.wordAddr = e.maskAddr(e.wordAddr + 1)
+			e.loadReadByte()
+		case e.bitCount == 8:
+			// Release SDA for the master acknowledge
+			e.sdaOut = true
+		case e.bitCount > 0:
+			e.sdaOut = e.shift&(0x80>>e.bitCount) != 0
+		}
+	}
+}
+
+// loadReadByte latches the byte at wordAddr and drives its first bit.
+func (e *EEPROM) loadReadByte() {
+	e.bitCount = 0
+	e.shift = e.mem[e.wordAddr]
+	e.sdaOut = e.shift&0x80 != 0
+}
+
+// receiveByte handles a complete byte received from the master.
+// Returns false if the byte is not acknowledged.
+func (e *EEPROM) receiveByte(b uint8) bool {
+	switch e.state {
+	case eepromDevice:
+		read := b&0x01 != 0
+		switch e.chip.mode {
+		case 1:
+			// X24C01: 7-bit word address followed by R/W
+			e.wordAddr = uint16(b >> 1)
+		default:
+			if b>>4 != 0x0A {
+				e.state = eepromStandby
+				return false
+			}
+			if e.chip.mode == 2 {
+				// Device select bits A2-A0 extend the word address
+				e.wordAddr = e.maskAddr(uint16(b>>1&0x07)<<8 | e.wordAddr&0xFF)
+			}
+		}
+		switch {
+		case read:
+			e.state = eepromReadSetup
+		case e.chip.mode == 1:
+			e.state = eepromWrite
+		case e.chip.mode == 3:
+			e.state = eepromAddrHigh
+		default:
+			e.state = eepromAddrLow
+		}
+	case eepromAddrHigh:
+		e.wordAddr = e.maskAddr(uint16(b)<<8 | e.wordAddr&0xFF)
+		e.state = eepromAddrLow
+	case eepromAddrLow:
+		e.wordAddr = e.maskAddr(e.wordAddr&0xFF00 | uint16(b))
+		e.state = eepromWrite
+	case eepromWrite:
+		e.mem[e.wordAddr] = b
+		// Sequential writes wrap within the current page
+		page := e.wordAddr &^ e.chip.pageMask
+		e.wordAddr = page | (e.wordAddr+1)&e.chip.pageMask
+	}
+	return true
+}
+
+// maskAddr wraps an address to the chip capacity.
+func (e *EEPROM) maskAddr(addr uint16) uint16 {
+	return addr & uint16(e.chip.size-1)
+}
+
+// Size returns the EEPROM capacity in bytes.
+func (e *EEPROM) Size() int {
+	return len(e.mem)
+}
+
+// serialize writes the protocol state to buf (eepromSerializeSize bytes).
+func (e *EEPROM) serialize(buf []byte) {
+	buf[0] = 1
+	buf[1] = boolByte(e.scl)
+	buf[2] = boolByte(e.sda)
+	buf[3] = boolByte(e.sdaOut)
+	buf[4] = uint8(e.state)
+	buf[5] = e.bitCount
+	buf[6] = e.shift
+	binary.LittleEndian.PutUint16(buf[7:], e.wordAddr)
+}
+
+// deserialize reads the protocol state from buf (eepromSerializeSize bytes).
+func (e *EEPROM) deserialize(buf []byte) {
+	if buf[0] == 0 {
+		return
+	}
+	e.scl = buf[1] != 0
+	e.sda = buf[2] != 0
+	e.sdaOut = buf[3] != 0
+	e.state = int(buf[4])
+	e.bitCount = buf[5]
+	e.shift = buf[6]
+	e.wordAddr = e.maskAddr(binary.LittleEndian.Uint16(buf[7:]))
+}
@@ -0,0 +1,305 @@
+package emu
+
+import (
+	"testing"
+
+	"github.com/user-none/go-chip-m68k"
+)
+
+// i2cHost bit-bangs the I2C protocol through the 68K bus using the
+// board wiring of the attached EEPROM.
+type i2cHost struct {
+	bus   *GenesisBus
+	board eepromBoard
+	scl   bool
+	sda   bool
+}
+
+func newI2CHost(bus *GenesisBus) *i2cHost {
+	return &i2cHost{bus: bus, board: bus.eeprom.board, scl: true, sda: true}
+}
+
+// set drives both lines. When SCL and SDA share an address they are
+// written together; otherwise a word write updates them at once.
+func (h *i2cHost) set(scl, sda bool) {
+	h.scl, h.sda = scl, sda
+	b := h.board
+	var hi, lo uint8
+	put := func(addr uint32, bit uint8, level bool) {
+		if !level {
+			return
+		}
+		if addr&1 == 0 {
+			hi |= 1 << bit
+		} else {
+			lo |= 1 << bit
+		}
+	}
+	put(b.sclAddr, b.sclBit, scl)
+	put(b.sdaOutAddr, b.sdaOutBit, sda)
+	h.bus.WriteCycle(0, m68k.Word, b.sclAddr&^1, uint32(hi)<<8|uint32(lo))
+}
+
+func (h *i2cHost) readSDA() bool {
+	val := h.bus.ReadCycle(0, m68k.Byte, h.board.sdaInAddr)
+	return val&(1<<h.board.sdaInBit) != 0
+}
+
+func (h *i2cHost) start() {
+	h.set(true, true)
+	h.set(true, false)
+	h.set(false, false)
+}
+
+func (h *i2cHost) stop() {
+	h.set(false, false)
+	h.set(true, false)
+	h.set(true, true)
+}
+
+// writeByte sends a byte and returns true if the EEPROM acknowledged.
+func (h *i2cHost) writeByte(b uint8) bool {
+	for i := 7; i >= 0; i-- {
+		bit := b&(1<<i) != 0
+		h.set(false, bit)
+		h.set(true, bit)
+		h.set(false, bit)
+	}
+	// ACK clock: release SDA and sample
+	h.set(false, true)
+	h.set(true, true)
+	ack := !h.readSDA()
+	h.set(false, true)
+	return ack
+}
+
+// readByte receives a byte and sends ACK (more=true) or NACK.
+func (h *i2cHost) readByte(more bool) uint8 {
+	var b uint8
+	h.set(false, true)
+	for i := 0; i < 8; i++ {
+		h.set(true, true)
+		b <<= 1
+		if h.readSDA() {
+			b |= 1
+		}
+		h.set(false, true)
+	}
+	h.set(false, !more)
+	h.set(true, !more)
+	h.set(false, !more)
+	h.set(false, true)
+	return b
+}
+
+func makeEEPROMBus(serial string) *GenesisBus {
+	rom := make([]byte, 0x1000)
+	copy(rom[0x100:], "SEGA GENESIS    ")
+	copy(rom[0x180:], "GM "+serial+" -00")
+	return makeTestBusWithROM(rom)
+}
+
+func TestDetectEEPROM_Database(t *testing.T) {
+	bus := makeEEPROMBus("T-081326")
+	if bus.eeprom == nil {
+		t.Fatal("expected EEPROM for NBA Jam serial")
+	}
+	if bus.eeprom.board != boardAcclaim16M || bus.eeprom.chip != chip24C02 {
+		t.Errorf("unexpected board/chip: %+v %+v", bus.eeprom.board, bus.eeprom.chip)
+	}
+}
+
+func TestDetectEEPROM_Header(t *testing.T) {
+	rom := make([]byte, 0x1000)
+	copy(rom[0x1B0:], []byte{'R', 'A', 0xE8, 0x40, 0x00, 0x20, 0x00, 0x01, 0x00, 0x20, 0x00, 0x01})
+	bus := makeTestBusWithROM(rom)
+	if bus.eeprom == nil {
+		t.Fatal("expected EEPROM from RA $E8 header")
+	}
+	if bus.sram != nil {
+		t.Error("byte SRAM should not be allocated for an EEPROM cartridge")
+	}
+	if !bus.HasSRAM() || bus.SRAMSize() != 128 {
+		t.Errorf("expected 128-byte battery save, got %d", bus.SRAMSize())
+	}
+}
+
+func TestDetectEEPROM_None(t *testing.T) {
+	bus := makeTestBus()
+	if bus.eeprom != nil {
+		t.Error("expected no EEPROM")
+	}
+}
+
+func TestEEPROM_24C02WriteRead(t *testing.T) {
+	bus := makeEEPROMBus("T-081326")
+	h := newI2CHost(bus)
+
+	// Byte write: device, word address, data
+	h.start()
+	if !h.writeByte(0xA0) {
+		t.Fatal("device select not acknowledged")
+	}
+	h.writeByte(0x10)
+	h.writeByte(0x5A)
+	h.writeByte(0xC3)
+	h.stop()
+
+	if bus.eeprom.mem[0x10] != 0x5A || bus.eeprom.mem[0x11] != 0xC3 {
+		t.Fatalf("mem[0x10..0x11] = %02X %02X", bus.eeprom.mem[0x10], bus.eeprom.mem[0x11])
+	}
+
+	// Random read: dummy write to set address, repeated start, read
+	h.start()
+	h.writeByte(0xA0)
+	h.writeByte(0x10)
+	h.start()
+	h.writeByte(0xA1)
+	b0 := h.readByte(true)
+	b1 := h.readByte(false)
+	h.stop()
+
+	if b0 != 0x5A || b1 != 0xC3 {
+		t.Errorf("read %02X %02X, expected 5A C3", b0, b1)
+	}
+}
+
+func TestEEPROM_BadDeviceNotAcknowledged(t *testing.T) {
+	bus := makeEEPROMBus("T-081326")
+	h := newI2CHost(bus)
+	h.start()
+	if h.writeByte(0x50) {
+		t.Error("non-EEPROM device select should not be acknowledged")
+	}
+	h.stop()
+}
+
+func TestEEPROM_X24C01WriteRead(t *testing.T) {
+	bus := makeEEPROMBus("MK-1215")
+	h := newI2CHost(bus)
+
+	// X24C01: 7-bit address + R/W in the first byte
+	h.start()
+	h.writeByte(0x05<<1 | 0)
+	h.writeByte(0x99)
+	h.stop()
+
+	if bus.eeprom.mem[0x05] != 0x99 {
+		t.Fatalf("mem[5] = %02X, expected 99", bus.eeprom.mem[0x05])
+	}
+
+	h.start()
+	h.writeByte(0x05<<1 | 1)
+	if got := h.readByte(false); got != 0x99 {
+		t.Errorf("read %02X, expected 99", got)
+	}
+	h.stop()
+}
+
+func TestEEPROM_EABoard(t *testing.T) {
+	bus := makeEEPROMBus("T-50396")
+	h := newI2CHost(bus)
+	h.start()
+	h.writeByte(0x7F<<1 | 0)
+	h.writeByte(0x42)
+	h.stop()
+	if bus.eeprom.mem[0x7F] != 0x42 {
+		t.Errorf("mem[0x7F] = %02X, expected 42", bus.eeprom.mem[0x7F])
+	}
+}
+
+func TestEEPROM_Acclaim32MBoard(t *testing.T) {
+	bus := makeEEPROMBus("T-81406")
+	h := newI2CHost(bus)
+	h.start()
+	h.writeByte(0xA0)
+	h.writeByte(0xFF)
+	h.writeByte(0x11)
+	h.stop()
+	if bus.eeprom.mem[0xFF] != 0x11 {
+		t.Errorf("mem[0xFF] = %02X, expected 11", bus.eeprom.mem[0xFF])
+	}
+}
+
+func TestEEPROM_SCLAddressReadsROM(t *testing.T) {
+	// Acclaim 32M boards put SCL at $200000, inside the ROM
+	rom := make([]byte, 0x200002)
+	copy(rom[0x100:], "SEGA GENESIS    ")
+	copy(rom[0x180:], "GM T-81406 -00")
+	rom[0x200000] = 0x5A
+	bus := makeTestBusWithROM(rom)
+
+	if got := bus.ReadCycle(0, m68k.Byte, 0x200000); got != 0x5A {
+		t.Errorf("SCL address: expected ROM byte 5A, got %02X", got)
+	}
+	if got := bus.ReadCycle(0, m68k.Word, 0x200000); got != 0x5A01 {
+		t.Errorf("word read: expected ROM byte and SDA high (5A01), got %04X", got)
+	}
+}
+
+func TestEEPROM_24C65TwoByteAddress(t *testing.T) {
+	bus := makeEEPROMBus("T-81576")
+	h := newI2CHost(bus)
+	h.start()
+	h.writeByte(0xA0)
+	h.writeByte(0x1F)
+	h.writeByte(0x40)
+	h.writeByte(0x77)
+	h.stop()
+	if bus.eeprom.mem[0x1F40] != 0x77 {
+		t.Errorf("mem[0x1F40] = %02X, expected 77", bus.eeprom.mem[0x1F40])
+	}
+}
+
+func TestEEPROM_PageWriteWraps(t *testing.T) {
+	bus := makeEEPROMBus("T-081326")
+	h := newI2CHost(bus)
+	h.start()
+	h.writeByte(0xA0)
+	h.writeByte(0x07) // last byte of an 8-byte page
+	h.writeByte(0x01)
+	h.writeByte(0x02)
+	h.stop()
+	if bus.eeprom.mem[0x07] != 0x01 || bus.eeprom.mem[0x00] != 0x02 {
+		t.Errorf("page wrap failed: mem[7]=%02X mem[0]=%02X", bus.eeprom.mem[0x07], bus.eeprom.mem[0x00])
+	}
+}
+
+func TestEEPROM_SRAMInterface(t *testing.T) {
+	bus := makeEEPROMBus("T-081326")
+	data := make([]byte, 256)
+	data[3] = 0xAB
+	bus.SetSRAM(data)
+	got := bus.GetSRAM()
+	if len(got) != 256 || got[3] != 0xAB {
+		t.Errorf("GetSRAM mismatch: len=%d [3]=%02X", len(got), got[3])
+	}
+}
+
+func TestEEPROM_SerializeRoundTrip(t *testing.T) {
+	rom := make([]byte, 0x1000)
+	copy(rom[0:8], []byte{0x00, 0xFF, 0x00, 0x00, 0x00, 0x00, 0x02, 0x00})
+	copy(rom[0x180:], "GM T-081326 -00")
+	e, err := NewEmulator(rom, RegionNTSC)
+	if err != nil {
+		t.Fatalf("NewEmulator failed: %v", err)
+	}
+	e.bus.eeprom.mem[0x20] = 0x3C
+	e.bus.eeprom.wordAddr = 0x21
+
+	state, err := e.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	e.bus.eeprom.mem[0x20] = 0x00
+	e.bus.eeprom.wordAddr = 0
+	if err := e.Deserialize(state); err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	if e.bus.eeprom.mem[0x20] != 0x3C {
+		t.Errorf("EEPROM contents not restored")
+	}
+	if e.bus.eeprom.wordAddr != 0x21 {
+		t.Errorf("EEPROM address not restored: %02X", e.bus.eeprom.wordAddr)
+	}
+}
@@ -1,6 +1,8 @@
 package emu
 
 import (
+	"compress/flate"
+
 	emucore "github.com/user-none/eblitui/api"
 	"github.com/user-none/go-chip-m68k"
 	"github.com/user-none/go-chip-sn76489"
@@ -14,19 +16,55 @@ var _ emucore.BatterySaver = (*Emulator)(nil)
 var _ emucore.MemoryInspector = (*Emulator)(nil)
 var _ emucore.MemoryMapper = (*Emulator)(nil)
 
-// Flat address boundaries for ReadMemory.
+// Flat address bases for ReadMemory.
 const (
 	mainRAMStart = 0x000000
-	mainRAMEnd   = 0x00FFFF
 	z80RAMStart  = 0x010000
-	z80RAMEnd    = 0x011FFF
+	vramStart    = 0x020000
+	cramStart    = 0x030000
+	vsramStart   = 0x030080
+	ymRegStart   = 0x030100
+	romStart     = 0x1000000
+)
+
+// Memory region types beyond the emucore ones. Values above 0xFF follow
+// the libretro convention for core-specific memory IDs.
+const (
+	MemoryROM = 0x100 + iota
+	MemoryVRAM
+	MemoryCRAM
+	MemoryVSRAM
+	MemoryYM2612
 )
 
+// Libretro memory descriptor flags (RETRO_MEMDESC_*).
+const (
+	MemDescConst     = 1 << 0
+	MemDescBigEndian = 1 << 1
+	MemDescSystemRAM = 1 << 2
+	MemDescSaveRAM   = 1 << 3
+	MemDescVideoRAM  = 1 << 4
+)
+
+// MemoryDescriptor describes a memory region in the form of a libretro
+// retro_memory_descriptor. The region's MemoryMapper buffer backs it from
+// offset 0; Start is its address in AddrSpace (empty for the CPU bus) and
+// Flat its address for ReadMemory. Save RAM has no flat address.
+type MemoryDescriptor struct {
+	Region    int
+	Flags     uint64
+	Start     uint32
+	Len       uint32
+	AddrSpace string
+	Flat      uint32
+}
+
 // Emulator contains fields shared by all platform implementations
 type Emulator struct {
 	m68k   *m68k.CPU
 	z80    *z80.CPU
 	z80Mem *Z80Memory
+	sms    *SMSMemory // Master System mode bus, nil in Genesis mode
 	bus    *GenesisBus
 	vdp    *VDP
 	psg    *sn76489.SN76489
@@ -55,11 +93,57 @@ type Emulator struct {
 	// Low-pass filter state (Model 1 RC filter, persists across frames)
 	filterPrevL float64
 	filterPrevR float64
+
+	// Game Genie and Pro Action Replay cheats
+	cheats []Cheat
+
+	// Debugger support
+	debugHook DebugHook
+	frame     frameState
+
+	// VGM music logger, nil when not recording
+	vgm *vgmRecorder
+
+	// CPU cycle counters at the start of the scanline, for timing VGM
+	// writes within it
+	lineM68K  uint64
+	lineZ80   uint64
+	z80OnLine bool // The Z80 is running its part of the scanline
+
+	// Draw light gun crosshairs over the frame
+	showCrosshair bool
+
+	// Reused compressor for SerializeCompact and SerializeDelta
+	compactWriter *flate.Writer
+
+	// Emulated time since power-on, see Timeline
+	frameCount   uint64
+	lineCount    uint64
+	masterCycles uint64
+	m68kCycles   uint64 // 68K cycles run on completed scanlines
+	z80Cycles    uint64
+
+	// Core option values set through SetOption
+	options map[string]string
 }
 
 // NewEmulator creates and initializes the shared emulator components.
+// ROMs with a Master System header and no Genesis header run in SMS mode.
 func NewEmulator(rom []byte, region Region) (Emulator, error) {
+	return newEmulator(rom, region, IsSMSROM(rom))
+}
+
+// NewSMSEmulator creates an emulator in Master System mode regardless of
+// the ROM header, for .sms files without a "TMR SEGA" header.
+func NewSMSEmulator(rom []byte, region Region) (Emulator, error) {
+	return newEmulator(rom, region, true)
+}
+
+func newEmulator(rom []byte, region Region, smsMode bool) (Emulator, error) {
 	consoleRegion := DetectConsoleRegion(rom)
+	if smsMode {
+		consoleRegion = smsConsoleRegion(rom)
+	}
 	vdp := NewVDP(region == RegionPAL)
 	timing := GetTimingForRegion(region)
 
@@ -67,6 +151,7 @@ func NewEmulator(rom []byte, region Region) (Emulator, error) {
 	psg := sn76489.New(timing.Z80ClockHz, sampleRate, psgBufferSize, sn76489.Sega)
 	psg.SetGain(psgGain)
 	io := NewIO(vdp, psg, ym2612, consoleRegion)
+	io.setClock(timing.M68KClockHz)
 
 	bus := NewGenesisBus(rom, vdp, io, psg, ym2612)
 	vdp.SetBus(bus)
@@ -77,6 +162,13 @@ func NewEmulator(rom []byte, region Region) (Emulator, error) {
 	z80Mem := NewZ80Memory(bus)
 	z80CPU := z80.New(z80Mem)
 
+	var sms *SMSMemory
+	if smsMode {
+		sms = NewSMSMemory(bus)
+		z80CPU = z80.New(sms)
+		vdp.SetSMSMode(true)
+	}
+
 	m68kCyclesPerFrame := timing.M68KClockHz / timing.FPS
 	m68kCyclesPerScanline := m68kCyclesPerFrame / timing.Scanlines
 	z80CyclesPerScanline := (timing.Z80ClockHz / timing.FPS) / timing.Scanlines
@@ -85,6 +177,7 @@ func NewEmulator(rom []byte, region Region) (Emulator, error) {
 		m68k:                  cpu,
 		z80:                   z80CPU,
 		z80Mem:                z80Mem,
+		sms:                   sms,
 		bus:                   bus,
 		vdp:                   vdp,
 		psg:                   psg,
@@ -101,42 +194,61 @@ func NewEmulator(rom []byte, region Region) (Emulator, error) {
 }
 
 // RunFrame executes one frame of emulation.
+// With a debug hook attached, RunFrame may return part way through the
+// frame (see DebugStopped); the next call resumes from the same point.
 func (e *Emulator) RunFrame() {
-	e.audioBuffer = e.audioBuffer[:0]
-	e.psg.ResetBuffer()
-
-	activeHeight := e.vdp.ActiveHeight()
-
-	for i := 0; i < e.scanlines; i++ {
-		// Clear HBlank at the start of each scanline
-		e.vdp.SetHBlank(false)
+	if e.sms != nil {
+		e.runFrameSMS()
+		return
+	}
+	resume := e.frame.stopped
+	e.frame.stopped = false
+	if !resume {
+		e.audioBuffer = e.audioBuffer[:0]
+		e.psg.ResetBuffer()
+		e.applyRAMCheats()
+		e.frame.line = 0
+		e.frame.activeHeight = e.vdp.ActiveHeight()
+	}
 
-		// Update VDP scanline state and check for interrupts
-		vInt, hInt := e.vdp.StartScanline(i)
-		if vInt {
-			e.m68k.RequestInterrupt(6, nil)
-			e.vdp.AcknowledgeVInt()
-		}
-		if hInt {
-			e.m68k.RequestInterrupt(4, nil)
-		}
+	activeHeight := e.frame.activeHeight
 
-		// Z80 V-blank interrupt: independent of VDP V-int enable.
-		// On real hardware the Z80 INT is tied to the VDP V-blank output.
-		// Mark as pending at V-blank start; INT stays asserted until the
-		// Z80 acknowledges it during execution below.
-		if i == activeHeight {
-			e.z80IntPending = true
-			e.z80.INT(true, 0xFF)
+	for ; e.frame.line < e.scanlines; e.frame.line++ {
+		i := e.frame.line
+		budget := e.m68kCyclesPerScanline
+		if resume {
+			// Continue the 68K where the debugger stopped it
+			budget = e.frame.budget
+			resume = false
+		} else {
+			e.startScanline(i, activeHeight)
 		}
 
-		// Initialize VDP scanline cycle tracking before M68K runs
-		e.vdp.BeginScanline(e.m68k.Cycles(), e.m68kCyclesPerScanline)
-
 		// Run M68K for this scanline using budget-based execution
-		budget := e.m68kCyclesPerScanline
 		for budget > 0 {
-			consumed := e.m68k.StepCycles(budget)
+			if e.bus.tmss.lockup {
+				// VDP touched before TMSS unlock: the 68K waits forever
+				// for DTACK while the rest of the system keeps running.
+				e.m68k.AddCycles(uint64(budget))
+				budget = 0
+				break
+			}
+			if e.debugHook != nil && e.debugStop() {
+				e.frame.budget = budget
+				e.frame.stopped = true
+				return
+			}
+			step := budget
+			if e.frame.gunCycle >= 0 {
+				// Stop the 68K where the beam reaches the light gun's aim
+				elapsed := e.m68kCyclesPerScanline - budget
+				if elapsed >= e.frame.gunCycle {
+					e.fireLightGun()
+				} else {
+					step = min(budget, e.frame.gunCycle-elapsed)
+				}
+			}
+			consumed := e.m68k.StepCycles(step)
 			if consumed == 0 {
 				break // CPU halted (double bus fault)
 			}
@@ -170,6 +282,8 @@ func (e *Emulator) RunFrame() {
 		// This is critical: the 68K often deasserts Z80 reset before releasing
 		// the bus, so the Z80 must not start executing until the bus is free.
 		if e.bus.z80Reset && !e.bus.z80BusRequested {
+			e.lineZ80 = e.z80.Cycles()
+			e.z80OnLine = true
 			budget := e.z80CyclesPerScanline
 			for budget > 0 {
 				// While INT is pending, check each step for acknowledgment
@@ -190,6 +304,8 @@ func (e *Emulator) RunFrame() {
 					e.z80.INT(false, 0xFF)
 				}
 			}
+			e.z80OnLine = false
+			e.z80Cycles += e.z80.Cycles() - e.lineZ80
 		}
 
 		// Render active scanlines
@@ -200,12 +316,66 @@ func (e *Emulator) RunFrame() {
 		// Generate audio for this scanline
 		e.ym2612.GenerateSamples(e.m68kCyclesPerScanline)
 		e.psg.Run(e.z80CyclesPerScanline)
+		e.endScanline()
+	}
+
+	if e.showCrosshair {
+		e.io.drawCrosshairs(e.vdp.GetFramebuffer(), e.vdp.GetStride(), activeHeight)
 	}
 
 	e.mixAudio()
+	e.frameCount++
+}
+
+// fireLightGun pulses the light gun's TH line at the current 68K cycle
+// and raises the external interrupt if the game enabled it.
+func (e *Emulator) fireLightGun() {
+	e.frame.gunCycle = -1
+	if e.io.fireLightGun(e.m68k.Cycles()) {
+		e.m68k.RequestInterrupt(2, nil)
+	}
+}
+
+// startScanline raises the scanline's VDP and Z80 interrupts and begins
+// VDP cycle tracking ahead of the 68K.
+func (e *Emulator) startScanline(i, activeHeight int) {
+	// Clear HBlank at the start of each scanline
+	e.vdp.SetHBlank(false)
+
+	// Update VDP scanline state and check for interrupts
+	vInt, hInt := e.vdp.StartScanline(i)
+	if vInt {
+		e.m68k.RequestInterrupt(6, nil)
+		e.vdp.AcknowledgeVInt()
+	}
+	if hInt {
+		e.m68k.RequestInterrupt(4, nil)
+	}
+
+	// Z80 V-blank interrupt: independent of VDP V-int enable.
+	// On real hardware the Z80 INT is tied to the VDP V-blank output.
+	// Mark as pending at V-blank start; INT stays asserted until the
+	// Z80 acknowledges it during execution in RunFrame.
+	if i == activeHeight {
+		e.z80IntPending = true
+		e.z80.INT(true, 0xFF)
+	}
+
+	// Serial receive and TH falling edges on the controller ports
+	if e.io.tickPorts(e.m68k.Cycles()) {
+		e.m68k.RequestInterrupt(2, nil)
+	}
+
+	// Initialize VDP scanline cycle tracking before M68K runs
+	e.vdp.BeginScanline(e.m68k.Cycles(), e.m68kCyclesPerScanline)
+	e.lineM68K = e.m68k.Cycles()
+
+	// Light gun aimed at this line fires when the beam reaches its X
+	e.frame.gunCycle = e.io.lightGunCycle(i, activeHeight, e.m68kCyclesPerScanline)
 }
 
-// SetInput unpacks a button bitmask and sets controller state for the given player.
+// SetInput unpacks a button bitmask and sets controller state for the given
+// player (0-3). Players 3 and 4 are only read through a multitap.
 func (e *Emulator) SetInput(player int, buttons uint32) {
 	up := buttons&(1<<emucore.ButtonUp) != 0
 	down := buttons&(1<<emucore.ButtonDown) != 0
@@ -225,6 +395,10 @@ func (e *Emulator) SetInput(player int, buttons uint32) {
 		e.io.InputP1.Set(up, down, left, right, btnA, btnB, btnC, start, btnX, btnY, btnZ, btnMode)
 	case 1:
 		e.io.InputP2.Set(up, down, left, right, btnA, btnB, btnC, start, btnX, btnY, btnZ, btnMode)
+	case 2:
+		e.io.InputP3.Set(up, down, left, right, btnA, btnB, btnC, start, btnX, btnY, btnZ, btnMode)
+	case 3:
+		e.io.InputP4.Set(up, down, left, right, btnA, btnB, btnC, start, btnX, btnY, btnZ, btnMode)
 	}
 }
 
@@ -234,12 +408,102 @@ func (e *Emulator) SetP2Connected(connected bool) {
 	e.io.InputP2.Connected = connected
 }
 
-// SetSixButton enables or disables 6-button controllers on both ports.
-// When disabled, controllers behave as 3-button pads and games
-// will not detect 6-button support.
-func (e *Emulator) SetSixButton(enabled bool) {
-	e.io.InputP1.SixButton = enabled
-	e.io.InputP2.SixButton = enabled
+// SetMultitap selects the multiplayer adapter: the Team Player in port 2
+// or the EA 4-Way Play across both ports.
+func (e *Emulator) SetMultitap(m Multitap) {
+	e.io.SetMultitap(m)
+}
+
+// Multitap returns the selected multiplayer adapter.
+func (e *Emulator) Multitap() Multitap {
+	return e.io.Multitap()
+}
+
+// SetPeripheral selects the device plugged into the port of the given
+// player (0-1): no device, a 3- or 6-button pad, or a Mega Mouse. Players
+// 2 and 3 are the extra pads behind a multitap and take the pad types.
+func (e *Emulator) SetPeripheral(player int, t PeripheralType) {
+	switch player {
+	case 0, 1:
+		e.io.SetPeripheral(player+1, t)
+	case 2, 3:
+		e.io.setTapPad(player, t)
+	}
+}
+
+// Peripheral returns the device plugged in for the given player (0-3).
+func (e *Emulator) Peripheral(player int) PeripheralType {
+	switch player {
+	case 0, 1:
+		return e.io.PeripheralType(player + 1)
+	case 2, 3:
+		return e.io.tapPadPeripheral(player)
+	}
+	return PeripheralNone
+}
+
+// SetSerialBackend connects the serial lines of controller port 1, 2 or
+// 3 (EXT) to a backend, or disconnects them when b is nil. The backend is
+// not part of save states.
+func (e *Emulator) SetSerialBackend(port int, b SerialBackend) {
+	e.io.SetSerialBackend(port, b)
+}
+
+// SetMouseInput feeds relative pointer motion in screen pixels (positive Y
+// is down) and button state to the Mega Mouse of the given player (0-1).
+// Motion accumulates until the game reads the mouse, so frontends can call
+// this once per host event or once per frame.
+func (e *Emulator) SetMouseInput(player int, dx, dy int, left, right, middle, start bool) {
+	switch player {
+	case 0:
+		e.io.MouseP1.Move(dx, dy, left, right, middle, start)
+	case 1:
+		e.io.MouseP2.Move(dx, dy, left, right, middle, start)
+	}
+}
+
+// SetLightGun plugs a Menacer or Justifier into port 2 in place of the
+// pad, or unplugs it with LightGunNone.
+func (e *Emulator) SetLightGun(t LightGunType) {
+	e.io.SetLightGun(t)
+}
+
+// LightGun returns the light gun plugged into port 2.
+func (e *Emulator) LightGun() LightGunType {
+	return e.io.LightGun()
+}
+
+// SetLightGunInput aims the light gun of the given player (0-1) at
+// framebuffer pixel (x, y) and sets its buttons. Player 0 is the Menacer
+// or the Justifier's blue gun, player 1 the Justifier's pink gun.
+// Coordinates outside the active display aim off screen.
+func (e *Emulator) SetLightGunInput(player int, x, y int, trigger, start, a, b bool) {
+	switch player {
+	case 0:
+		e.io.GunP1.Set(x, y, trigger, start, a, b)
+	case 1:
+		e.io.GunP2.Set(x, y, trigger, start, a, b)
+	}
+}
+
+// LightGunPosition returns where the light gun of the given player (0-1)
+// is aimed, for frontends that draw their own crosshair. onScreen is
+// false when no gun is plugged in for the player or it is aimed away.
+func (e *Emulator) LightGunPosition(player int) (x, y int, onScreen bool) {
+	switch {
+	case e.io.lightGun == LightGunNone:
+		return 0, 0, false
+	case player == 0:
+		return e.io.GunP1.Position()
+	case player == 1 && e.io.lightGun == LightGunJustifier:
+		return e.io.GunP2.Position()
+	}
+	return 0, 0, false
+}
+
+// SetCrosshair enables drawing light gun crosshairs into the framebuffer.
+func (e *Emulator) SetCrosshair(enabled bool) {
+	e.showCrosshair = enabled
 }
 
 // GetFramebuffer returns raw RGBA pixel data for current frame.
@@ -279,9 +543,17 @@ func (e *Emulator) SetRegion(region Region) {
 	e.m68kCyclesPerFrame = e.timing.M68KClockHz / e.timing.FPS
 	e.m68kCyclesPerScanline = e.m68kCyclesPerFrame / e.timing.Scanlines
 	e.z80CyclesPerScanline = (e.timing.Z80ClockHz / e.timing.FPS) / e.timing.Scanlines
+	e.io.setClock(e.timing.M68KClockHz)
+}
+
+// GetROMCRC32 returns the CRC32 of the loaded ROM.
+func (e *Emulator) GetROMCRC32() uint32 {
+	return e.bus.GetROMCRC32()
 }
 
-// HasSRAM returns true if the loaded ROM declares battery-backed SRAM.
+// HasSRAM returns true if the loaded ROM declares battery-backed SRAM
+// or saves through a serial EEPROM. In Master System mode it is true once
+// the game has enabled its cartridge RAM, or SetSRAM has loaded it.
 func (e *Emulator) HasSRAM() bool {
 	return e.bus.HasSRAM()
 }
@@ -293,6 +565,9 @@ func (e *Emulator) GetSRAM() []byte {
 
 // SetSRAM loads SRAM contents from a save file.
 func (e *Emulator) SetSRAM(data []byte) {
+	if e.sms != nil && len(data) > 0 {
+		e.sms.allocCartRAM()
+	}
 	e.bus.SetSRAM(data)
 }
 
@@ -324,7 +599,7 @@ func (e *Emulator) SetMainRAM(data []byte) {
 
 // GetSRAMSize returns the size of the SRAM in bytes, or 0 if none.
 func (e *Emulator) GetSRAMSize() int {
-	return len(e.bus.sram)
+	return e.bus.SRAMSize()
 }
 
 // Close releases any resources held by the emulator.
@@ -332,65 +607,220 @@ func (e *Emulator) Close() {}
 
 // SetOption applies a core option change identified by key.
 func (e *Emulator) SetOption(key string, value string) {
+	if e.options == nil {
+		e.options = make(map[string]string)
+	}
+	e.options[key] = value
+
 	switch key {
-	case "six_button":
-		e.SetSixButton(value == "true")
+	case "port1":
+		if t, ok := ParsePeripheralType(value); ok {
+			e.SetPeripheral(0, t)
+		}
+	case "port2":
+		if t, ok := ParsePeripheralType(value); ok {
+			e.SetPeripheral(1, t)
+		}
+	case "tmss":
+		e.SetTMSS(value == "true")
+	case "multitap":
+		switch value {
+		case "teamplayer":
+			e.SetMultitap(MultitapTeamPlayer)
+		case "ea4way":
+			e.SetMultitap(MultitapEA4Way)
+		default:
+			e.SetMultitap(MultitapNone)
+		}
+	case "lightgun":
+		switch value {
+		case "menacer":
+			e.SetLightGun(LightGunMenacer)
+		case "justifier":
+			e.SetLightGun(LightGunJustifier)
+		default:
+			e.SetLightGun(LightGunNone)
+		}
+	case "lightgun_crosshair":
+		e.SetCrosshair(value == "true")
+	case "layer_plane_a":
+		e.SetLayerEnabled(LayerPlaneA, value == "true")
+	case "layer_plane_b":
+		e.SetLayerEnabled(LayerPlaneB, value == "true")
+	case "layer_window":
+		e.SetLayerEnabled(LayerWindow, value == "true")
+	case "layer_sprites":
+		e.SetLayerEnabled(LayerSprites, value == "true")
+	case "layer_backdrop":
+		e.SetLayerEnabled(LayerBackdrop, value == "true")
+	}
+}
+
+// memoryArea is one region of the flat address space and MemoryMap.
+type memoryArea struct {
+	region int    // MemoryMapper region type, or -1 for flat access only
+	flat   uint32 // ReadMemory base address
+	data   []byte // Live backing memory
+	desc   MemoryDescriptor
+}
+
+// memoryAreas returns the flat address space layout for the current mode.
+// Save RAM is not part of it; it is handled by ReadRegion and WriteRegion.
+func (e *Emulator) memoryAreas() []memoryArea {
+	vdp := e.vdp
+	if e.sms != nil {
+		return []memoryArea{
+			{-1, mainRAMStart, e.bus.ram[:], MemoryDescriptor{}},
+			{emucore.MemorySystemRAM, z80RAMStart, e.bus.z80RAM[:],
+				MemoryDescriptor{Flags: MemDescSystemRAM, Start: 0xC000}},
+			{MemoryVRAM, vramStart, vdp.vram[:smsVRAMMask+1],
+				MemoryDescriptor{Flags: MemDescVideoRAM, AddrSpace: "VRAM"}},
+			{MemoryCRAM, cramStart, vdp.smsCRAM[:],
+				MemoryDescriptor{AddrSpace: "CRAM"}},
+			{MemoryROM, romStart, e.bus.rom,
+				MemoryDescriptor{Flags: MemDescConst}},
+		}
+	}
+	return []memoryArea{
+		{emucore.MemorySystemRAM, mainRAMStart, e.bus.ram[:],
+			MemoryDescriptor{Flags: MemDescSystemRAM | MemDescBigEndian, Start: 0xFF0000}},
+		{-1, z80RAMStart, e.bus.z80RAM[:], MemoryDescriptor{}},
+		{MemoryVRAM, vramStart, vdp.vram[:],
+			MemoryDescriptor{Flags: MemDescVideoRAM | MemDescBigEndian, AddrSpace: "VRAM"}},
+		{MemoryCRAM, cramStart, vdp.cram[:],
+			MemoryDescriptor{Flags: MemDescBigEndian, AddrSpace: "CRAM"}},
+		{MemoryVSRAM, vsramStart, vdp.vsram[:],
+			MemoryDescriptor{Flags: MemDescBigEndian, AddrSpace: "VSRAM"}},
+		{MemoryYM2612, ymRegStart, e.ym2612.regFile[:],
+			MemoryDescriptor{AddrSpace: "YM2612"}},
+		{MemoryROM, romStart, e.bus.rom,
+			MemoryDescriptor{Flags: MemDescConst | MemDescBigEndian}},
 	}
 }
 
 // ReadMemory reads from a flat address into buf and returns the number
-// of bytes read.
+// of bytes read. Reading stops at the first address outside every area.
+//
+//	0x000000-0x00FFFF  68K main RAM
+//	0x010000-0x011FFF  Z80 RAM
+//	0x020000-0x02FFFF  VRAM (16KB in SMS mode)
+//	0x030000-0x03007F  CRAM (32 bytes in SMS mode)
+//	0x030080-0x0300CF  VSRAM
+//	0x030100-0x0302FF  YM2612 registers (Part I, then Part II)
+//	0x1000000-         Cartridge ROM
 func (e *Emulator) ReadMemory(addr uint32, buf []byte) uint32 {
+	areas := e.memoryAreas()
 	var count uint32
 	for i := range buf {
 		cur := addr + uint32(i)
-		var b byte
-		switch {
-		case cur >= mainRAMStart && cur <= mainRAMEnd:
-			b = e.ReadMainRAM(uint16(cur - mainRAMStart))
-		case cur >= z80RAMStart && cur <= z80RAMEnd:
-			b = e.ReadZ80RAM(uint16(cur - z80RAMStart))
-		default:
+		found := false
+		for _, a := range areas {
+			if cur >= a.flat && cur-a.flat < uint32(len(a.data)) {
+				buf[i] = a.data[cur-a.flat]
+				found = true
+				break
+			}
+		}
+		if !found {
 			return count
 		}
-		buf[i] = b
 		count++
 	}
 	return count
 }
 
 // MemoryMap returns a list of available memory regions with sizes.
+// In SMS mode system RAM is the 8KB Z80 work RAM.
 func (e *Emulator) MemoryMap() []emucore.MemoryRegion {
-	regions := []emucore.MemoryRegion{
-		{Type: emucore.MemorySystemRAM, Size: mainRAMSize},
-	}
-	if sramSize := e.GetSRAMSize(); sramSize > 0 {
-		regions = append(regions, emucore.MemoryRegion{
-			Type: emucore.MemorySaveRAM,
-			Size: sramSize,
-		})
+	var regions []emucore.MemoryRegion
+	for _, a := range e.memoryAreas() {
+		if a.region < 0 {
+			continue
+		}
+		regions = append(regions, emucore.MemoryRegion{Type: a.region, Size: len(a.data)})
+		if a.region == emucore.MemorySystemRAM {
+			if sramSize := e.GetSRAMSize(); sramSize > 0 {
+				regions = append(regions, emucore.MemoryRegion{
+					Type: emucore.MemorySaveRAM,
+					Size: sramSize,
+				})
+			}
+		}
 	}
 	return regions
 }
 
+// MemoryDescriptors returns a libretro memory descriptor for each region
+// in MemoryMap, in the same order.
+func (e *Emulator) MemoryDescriptors() []MemoryDescriptor {
+	var descs []MemoryDescriptor
+	for _, a := range e.memoryAreas() {
+		if a.region < 0 {
+			continue
+		}
+		d := a.desc
+		d.Region = a.region
+		d.Len = uint32(len(a.data))
+		d.Flat = a.flat
+		descs = append(descs, d)
+		if a.region == emucore.MemorySystemRAM {
+			if sramSize := e.GetSRAMSize(); sramSize > 0 {
+				descs = append(descs, MemoryDescriptor{
+					Region:    emucore.MemorySaveRAM,
+					Flags:     MemDescSaveRAM,
+					Len:       uint32(sramSize),
+					AddrSpace: "SRAM",
+				})
+			}
+		}
+	}
+	return descs
+}
+
+// memoryArea returns the area backing a MemoryMapper region type.
+func (e *Emulator) memoryArea(regionType int) (memoryArea, bool) {
+	for _, a := range e.memoryAreas() {
+		if a.region == regionType && a.region >= 0 {
+			return a, true
+		}
+	}
+	return memoryArea{}, false
+}
+
 // ReadRegion returns a copy of the specified memory region.
 func (e *Emulator) ReadRegion(regionType int) []byte {
-	switch regionType {
-	case emucore.MemorySystemRAM:
-		return e.GetMainRAM()
-	case emucore.MemorySaveRAM:
+	if regionType == emucore.MemorySaveRAM {
 		return e.GetSRAM()
-	default:
+	}
+	a, ok := e.memoryArea(regionType)
+	if !ok {
 		return nil
 	}
+	out := make([]byte, len(a.data))
+	copy(out, a.data)
+	return out
 }
 
-// WriteRegion writes data to the specified memory region.
+// WriteRegion writes data to the specified memory region. YM2612
+// registers are written through the chip, and only when their value
+// changes, so unchanged key-on or timer registers are not re-triggered.
 func (e *Emulator) WriteRegion(regionType int, data []byte) {
 	switch regionType {
-	case emucore.MemorySystemRAM:
-		e.SetMainRAM(data)
 	case emucore.MemorySaveRAM:
 		e.SetSRAM(data)
+		return
+	case MemoryYM2612:
+		if e.sms != nil {
+			return
+		}
+		for i, val := range data {
+			if i < len(e.ym2612.regFile) && e.ym2612.regFile[i] != val {
+				e.ym2612.PokeRegister(i>>8, uint8(i), val)
+			}
+		}
+		return
+	}
+	if a, ok := e.memoryArea(regionType); ok {
+		copy(a.data, data)
 	}
 }
@@ -0,0 +1,139 @@
+package emu
+
+import (
+	"testing"
+
+	emucore "github.com/user-none/eblitui/api"
+)
+
+func TestEmulator_MemoryMapRegions(t *testing.T) {
+	e := createTestEmulator()
+	sizes := make(map[int]int)
+	for _, r := range e.MemoryMap() {
+		sizes[r.Type] = r.Size
+	}
+	want := map[int]int{
+		emucore.MemorySystemRAM: mainRAMSize,
+		MemoryROM:               1024,
+		MemoryVRAM:              0x10000,
+		MemoryCRAM:              128,
+		MemoryVSRAM:             80,
+		MemoryYM2612:            512,
+	}
+	for typ, size := range want {
+		if sizes[typ] != size {
+			t.Errorf("region 0x%X: expected size %d, got %d", typ, size, sizes[typ])
+		}
+	}
+
+	descs := e.MemoryDescriptors()
+	if len(descs) != len(e.MemoryMap()) {
+		t.Fatalf("expected a descriptor per region, got %d", len(descs))
+	}
+	for _, d := range descs {
+		switch d.Region {
+		case emucore.MemorySystemRAM:
+			if d.Start != 0xFF0000 || d.Flags&MemDescSystemRAM == 0 {
+				t.Errorf("unexpected main RAM descriptor %+v", d)
+			}
+		case MemoryROM:
+			if d.Flags&MemDescConst == 0 || d.Flat != romStart {
+				t.Errorf("unexpected ROM descriptor %+v", d)
+			}
+		case MemoryVRAM:
+			if d.AddrSpace != "VRAM" || d.Flags&MemDescVideoRAM == 0 {
+				t.Errorf("unexpected VRAM descriptor %+v", d)
+			}
+		}
+	}
+}
+
+func TestEmulator_ReadMemoryFlat(t *testing.T) {
+	e := createTestEmulator()
+	e.bus.ram[0x10] = 0x11
+	e.bus.z80RAM[0x20] = 0x22
+	e.vdp.vram[0x1234] = 0x33
+	e.vdp.cram[5] = 0x44
+	e.vdp.vsram[6] = 0x55
+	e.ym2612.WritePort(2, 0xB4)
+	e.ym2612.WritePort(3, 0xC0)
+
+	tests := []struct {
+		addr uint32
+		want byte
+	}{
+		{0x000010, 0x11},
+		{0x010020, 0x22},
+		{0x021234, 0x33},
+		{0x030005, 0x44},
+		{0x030086, 0x55},
+		{0x030100 + 0x100 + 0xB4, 0xC0},
+		{romStart + 6, 0x02}, // Reset PC high byte
+	}
+	for _, tt := range tests {
+		var buf [1]byte
+		if n := e.ReadMemory(tt.addr, buf[:]); n != 1 || buf[0] != tt.want {
+			t.Errorf("0x%06X: expected 0x%02X, got 0x%02X (n=%d)", tt.addr, tt.want, buf[0], n)
+		}
+	}
+
+	// Reads stop at the end of an area
+	buf := make([]byte, 4)
+	if n := e.ReadMemory(0x011FFE, buf); n != 2 {
+		t.Errorf("expected 2 bytes before the Z80 RAM gap, got %d", n)
+	}
+}
+
+func TestEmulator_WriteRegion(t *testing.T) {
+	e := createTestEmulator()
+
+	cram := e.ReadRegion(MemoryCRAM)
+	cram[2], cram[3] = 0x0E, 0xEE
+	e.WriteRegion(MemoryCRAM, cram)
+	if e.vdp.cram[3] != 0xEE {
+		t.Error("CRAM write should reach the VDP")
+	}
+
+	vram := make([]byte, 0x10000)
+	vram[0xFFFF] = 0x99
+	e.WriteRegion(MemoryVRAM, vram)
+	if e.vdp.vram[0xFFFF] != 0x99 {
+		t.Error("VRAM write should reach the VDP")
+	}
+
+	rom := e.ReadRegion(MemoryROM)
+	rom[0x200] = 0x4E
+	e.WriteRegion(MemoryROM, rom)
+	if e.bus.rom[0x200] != 0x4E {
+		t.Error("ROM write should patch the cartridge")
+	}
+
+	// YM2612 pokes go through the register handlers
+	regs := e.ReadRegion(MemoryYM2612)
+	regs[0x22] = 0x0B // LFO on, frequency 3
+	e.WriteRegion(MemoryYM2612, regs)
+	if !e.ym2612.lfoEnable || e.ym2612.lfoFreq != 3 {
+		t.Error("YM2612 register poke should update the LFO")
+	}
+	if got := e.ReadRegion(MemoryYM2612)[0x22]; got != 0x0B {
+		t.Errorf("expected register readback 0x0B, got 0x%02X", got)
+	}
+}
+
+func TestEmulator_MemoryMapSMS(t *testing.T) {
+	rom := makeSMSROM()
+	e, err := NewSMSEmulator(rom, RegionNTSC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sizes := make(map[int]int)
+	for _, r := range e.MemoryMap() {
+		sizes[r.Type] = r.Size
+	}
+	if sizes[emucore.MemorySystemRAM] != z80RAMSize || sizes[MemoryVRAM] != 0x4000 || sizes[MemoryCRAM] != 32 {
+		t.Errorf("unexpected SMS regions %v", sizes)
+	}
+	if _, ok := sizes[MemoryYM2612]; ok {
+		t.Error("YM2612 is unreachable in SMS mode and should not be listed")
+	}
+}
@@ -40,7 +40,14 @@ const sixButtonTimeoutCycles uint64 = 11506
 type IO struct {
 	InputP1       Input
 	InputP2       Input
+	InputP3       Input // Players 3 and 4 are only reachable through a multitap
+	InputP4       Input
+	MouseP1       Mouse    // Mega Mouse for port 1, read when plugged in with SetPeripheral
+	MouseP2       Mouse    // Mega Mouse for port 2
+	GunP1         LightGun // Menacer, or the Justifier's blue gun
+	GunP2         LightGun // The Justifier's pink gun
 	consoleRegion ConsoleRegion
+	version       uint8 // Hardware version (bits 3-0), 1 on TMSS consoles
 	vdp           *VDP
 	psg           *sn76489.SN76489
 	ym2612        *YM2612
@@ -49,28 +56,73 @@ type IO struct {
 	p1Ctrl byte // Port 1 ctrl register (1=output, 0=input)
 	p2Data byte // Port 2 data register
 	p2Ctrl byte // Port 2 ctrl register
-
-	// 6-button controller state machine (only active when SixButton is true)
-	p1THState    uint8  // Current state counter (0-7)
-	p1LastTHHigh bool   // Previous TH value for edge detection
-	p1LastCycle  uint64 // M68K cycle of last TH transition
-
-	p2THState    uint8  // P2 state counter (0-7)
-	p2LastTHHigh bool   // P2 previous TH value for edge detection
-	p2LastCycle  uint64 // P2 M68K cycle of last TH transition
+	p3Data byte // Port 3 (EXT) data register
+	p3Ctrl byte // Port 3 (EXT) ctrl register
+
+	// Devices plugged into ports 1, 2 and 3 (EXT)
+	port1 Peripheral
+	port2 Peripheral
+	port3 Peripheral
+	pad1  *Pad // Control pad reading InputP1
+	pad2  *Pad // Control pad reading InputP2
+
+	// Multiplayer adapter
+	multitap   Multitap
+	tapLines   uint8 // Team Player: TH/TR levels driven on port 2
+	tapCounter uint8 // Team Player: reads since TH went low
+	eaSelect   uint8 // EA 4-Way Play: pad selected through port 2
+
+	// Light gun in port 2
+	lightGun LightGunType
+	gunSense bool // TH pulled low: the beam passed the aim this scanline
+
+	// Serial mode and TH interrupts of ports 1-3
+	serial  [3]serialPort
+	clockHz int // M68K clock for serial frame timing
 }
 
-// NewIO creates a new I/O controller.
+// NewIO creates a new I/O controller with a pad in port 1 and ports 2
+// and 3 empty.
 func NewIO(vdp *VDP, psg *sn76489.SN76489, ym2612 *YM2612, consoleRegion ConsoleRegion) *IO {
-	return &IO{
+	io := &IO{
 		InputP1:       Input{Connected: true},
 		InputP2:       Input{},
 		consoleRegion: consoleRegion,
 		vdp:           vdp,
 		psg:           psg,
 		ym2612:        ym2612,
-		p1LastTHHigh:  true, // TH pulled high at power-on
-		p2LastTHHigh:  true, // TH pulled high at power-on
+		clockHz:       NTSCTiming.M68KClockHz,
+	}
+	io.pad1 = newPad(&io.InputP1)
+	io.pad2 = newPad(&io.InputP2)
+	io.port1 = io.pad1
+	io.port2 = io.pad2
+	io.port3 = emptyPort{}
+	io.reset()
+	return io
+}
+
+// reset returns the port registers, controller protocols and serial
+// registers to their power-on state. Plugged-in devices, their inputs and
+// serial backends are kept.
+func (io *IO) reset() {
+	io.p1Data, io.p1Ctrl = 0, 0
+	io.p2Data, io.p2Ctrl = 0, 0
+	io.p3Data, io.p3Ctrl = 0, 0
+	*io.pad1 = *newPad(&io.InputP1)
+	*io.pad2 = *newPad(&io.InputP2)
+	io.MouseP1.reset()
+	io.MouseP2.reset()
+	io.tapLines = 0x60
+	io.tapCounter = 0
+	io.eaSelect = 0
+	io.gunSense = false
+	for i := range io.serial {
+		io.serial[i] = serialPort{
+			tx:      0xFF,
+			thHigh:  true, // TH pulled high at power-on
+			backend: io.serial[i].backend,
+		}
 	}
 }
 
@@ -83,23 +135,55 @@ func (io *IO) ReadRegister(cycle uint64, addr uint32) byte {
 		// bit 5 = no expansion (1), bits 3-0 = hardware version
 		switch io.consoleRegion {
 		case ConsoleJapan:
-			return 0x20
+			return 0x20 | io.version
 		case ConsoleEurope:
-			return 0xE0
+			return 0xE0 | io.version
 		default:
-			return 0xA0
+			return 0xA0 | io.version
 		}
 	case 0xA10003:
-		return io.readPort1(cycle)
+		return io.serialPins(1, io.readPort1(cycle))
 	case 0xA10005:
-		return io.readPort2(cycle)
+		return io.serialPins(2, io.readPort2(cycle))
+	case 0xA10007:
+		return io.serialPins(3, (io.p3Data&io.p3Ctrl)|(io.port3.Read(cycle, io.p3Data, io.p3Ctrl) & ^io.p3Ctrl))
 	case 0xA10009:
 		return io.p1Ctrl
 	case 0xA1000B:
 		return io.p2Ctrl
-	default:
-		return 0x00
+	case 0xA1000D:
+		return io.p3Ctrl
+	}
+	if addr >= 0xA1000F && addr <= 0xA1001F && addr&1 != 0 {
+		// TxData, RxData and S-Ctrl for ports 1-3
+		idx := int(addr-0xA1000F) / 2
+		return io.readSerial(cycle, idx/3+1, idx%3)
+	}
+	return 0x00
+}
+
+// readPort1 reads port 1 through the EA 4-Way Play or the plugged-in
+// peripheral.
+func (io *IO) readPort1(cycle uint64) byte {
+	if io.multitap == MultitapEA4Way {
+		return io.readEA4WayPort1()
 	}
+	return (io.p1Data & io.p1Ctrl) | (io.port1.Read(cycle, io.p1Data, io.p1Ctrl) & ^io.p1Ctrl)
+}
+
+// readPort2 reads port 2 through a light gun, a multitap or the plugged-in
+// peripheral.
+func (io *IO) readPort2(cycle uint64) byte {
+	if io.lightGun != LightGunNone {
+		return io.readLightGun()
+	}
+	switch io.multitap {
+	case MultitapTeamPlayer:
+		return io.readTeamPlayer()
+	case MultitapEA4Way:
+		return (io.p2Data & io.p2Ctrl) | (0x7F & ^io.p2Ctrl)
+	}
+	return (io.p2Data & io.p2Ctrl) | (io.port2.Read(cycle, io.p2Data, io.p2Ctrl) & ^io.p2Ctrl)
 }
 
 // WriteRegister writes an I/O register by address.
@@ -108,368 +192,33 @@ func (io *IO) WriteRegister(cycle uint64, addr uint32, val byte) {
 	switch addr {
 	case 0xA10003:
 		io.p1Data = val
-
-		// 6-button state machine: track TH edges to advance state counter
-		if io.InputP1.SixButton && io.InputP1.Connected {
-			var newTH bool
-			if io.p1Ctrl&0x40 != 0 {
-				newTH = val&0x40 != 0
-			} else {
-				newTH = true // TH pulled high when configured as input
-			}
-
-			if newTH != io.p1LastTHHigh {
-				// Check timeout: reset counter and TH tracking if enough
-				// time has elapsed. Resetting p1LastTHHigh to true
-				// (idle/pulled-high) ensures the first TH=1 write after
-				// timeout is not an edge, keeping state 0 aligned with
-				// TH=1 reads.
-				if cycle > 0 && io.p1LastCycle > 0 && cycle-io.p1LastCycle >= sixButtonTimeoutCycles {
-					io.p1THState = 0
-					io.p1LastTHHigh = true
-				}
-				// Re-check after potential reset: only advance if still an edge
-				if newTH != io.p1LastTHHigh {
-					io.p1THState = (io.p1THState + 1) & 0x07
-					io.p1LastTHHigh = newTH
-					io.p1LastCycle = cycle
-				}
-			}
-		}
+		io.port1.WriteData(cycle, io.p1Data, io.p1Ctrl)
 	case 0xA10005:
 		io.p2Data = val
-
-		// 6-button state machine: track TH edges to advance state counter
-		if io.InputP2.SixButton && io.InputP2.Connected {
-			var newTH bool
-			if io.p2Ctrl&0x40 != 0 {
-				newTH = val&0x40 != 0
-			} else {
-				newTH = true // TH pulled high when configured as input
-			}
-
-			if newTH != io.p2LastTHHigh {
-				if cycle > 0 && io.p2LastCycle > 0 && cycle-io.p2LastCycle >= sixButtonTimeoutCycles {
-					io.p2THState = 0
-					io.p2LastTHHigh = true
-				}
-				if newTH != io.p2LastTHHigh {
-					io.p2THState = (io.p2THState + 1) & 0x07
-					io.p2LastTHHigh = newTH
-					io.p2LastCycle = cycle
-				}
-			}
-		}
+		io.port2.WriteData(cycle, io.p2Data, io.p2Ctrl)
 	case 0xA10009:
 		io.p1Ctrl = val
+		io.port1.WriteCtrl(cycle, io.p1Data, io.p1Ctrl)
 	case 0xA1000B:
 		io.p2Ctrl = val
-	}
-}
-
-// readPort1 reads Player 1 controller data, combining output pins from the
-// data register with input pins from the controller peripheral.
-//
-// The 6-button controller uses an 8-state machine driven by TH transitions:
-//
-//	State 0,2,4 (TH=1): C, B, Right, Left, Down, Up
-//	State 1,3   (TH=0): Start, A, 0, 0, Down, Up
-//	State 5     (TH=0): Start, A, 0, 0, 0, 0  (detection: bits 3-0 all zero)
-//	State 6     (TH=1): C, B, Mode, X, Y, Z    (extra buttons)
-//	State 7     (TH=0): Start, A, 1, 1, 1, 1   (end marker)
-func (io *IO) readPort1(cycle uint64) byte {
-	// No controller: all peripheral pins float high
-	if !io.InputP1.Connected {
-		return (io.p1Data & io.p1Ctrl) | (0xFF & ^io.p1Ctrl)
-	}
-
-	// Determine TH: if output (ctrl bit 6=1), use data register; else pulled high
-	var th bool
-	if io.p1Ctrl&0x40 != 0 {
-		th = io.p1Data&0x40 != 0
-	} else {
-		th = true
-	}
-
-	// Controller response (active low: 0=pressed, 1=released)
-	var peripheral byte = 0xC0 // Bits 7,6 pulled high
-
-	if !io.InputP1.SixButton {
-		// 3-button controller: no state machine, just TH level
-		if th {
-			// TH=1: C, B, Right, Left, Down, Up
-			peripheral |= 0x3F
-			if io.InputP1.up {
-				peripheral &^= 0x01
-			}
-			if io.InputP1.down {
-				peripheral &^= 0x02
-			}
-			if io.InputP1.left {
-				peripheral &^= 0x04
-			}
-			if io.InputP1.right {
-				peripheral &^= 0x08
-			}
-			if io.InputP1.btnB {
-				peripheral &^= 0x10
-			}
-			if io.InputP1.btnC {
-				peripheral &^= 0x20
-			}
-		} else {
-			// TH=0: Start, A, 0, 0, Down, Up
-			peripheral |= 0x33
-			if io.InputP1.up {
-				peripheral &^= 0x01
-			}
-			if io.InputP1.down {
-				peripheral &^= 0x02
-			}
-			if io.InputP1.btnA {
-				peripheral &^= 0x10
-			}
-			if io.InputP1.start {
-				peripheral &^= 0x20
-			}
-		}
-		return (io.p1Data & io.p1Ctrl) | (peripheral & ^io.p1Ctrl)
-	}
-
-	// 6-button controller: state-machine driven
-	// Check timeout: reset state if too much time has elapsed since last TH edge
-	if cycle > 0 && io.p1LastCycle > 0 && cycle-io.p1LastCycle >= sixButtonTimeoutCycles {
-		io.p1THState = 0
-		io.p1LastTHHigh = true
-	}
-
-	switch io.p1THState {
-	case 0, 2, 4:
-		// TH=1: C, B, Right, Left, Down, Up
-		peripheral |= 0x3F
-		if io.InputP1.up {
-			peripheral &^= 0x01
-		}
-		if io.InputP1.down {
-			peripheral &^= 0x02
-		}
-		if io.InputP1.left {
-			peripheral &^= 0x04
-		}
-		if io.InputP1.right {
-			peripheral &^= 0x08
-		}
-		if io.InputP1.btnB {
-			peripheral &^= 0x10
-		}
-		if io.InputP1.btnC {
-			peripheral &^= 0x20
-		}
-	case 1, 3:
-		// TH=0: Start, A, 0, 0, Down, Up
-		peripheral |= 0x33
-		if io.InputP1.up {
-			peripheral &^= 0x01
-		}
-		if io.InputP1.down {
-			peripheral &^= 0x02
-		}
-		if io.InputP1.btnA {
-			peripheral &^= 0x10
-		}
-		if io.InputP1.start {
-			peripheral &^= 0x20
-		}
-	case 5:
-		// TH=0, detection: Start, A, 0, 0, 0, 0 (bits 3-0 all zero)
-		peripheral |= 0x30
-		if io.InputP1.btnA {
-			peripheral &^= 0x10
-		}
-		if io.InputP1.start {
-			peripheral &^= 0x20
-		}
-	case 6:
-		// TH=1, extra buttons: C, B, Mode, X, Y, Z
-		peripheral |= 0x3F
-		if io.InputP1.btnZ {
-			peripheral &^= 0x01
-		}
-		if io.InputP1.btnY {
-			peripheral &^= 0x02
-		}
-		if io.InputP1.btnX {
-			peripheral &^= 0x04
-		}
-		if io.InputP1.btnMode {
-			peripheral &^= 0x08
-		}
-		if io.InputP1.btnB {
-			peripheral &^= 0x10
-		}
-		if io.InputP1.btnC {
-			peripheral &^= 0x20
-		}
-	case 7:
-		// TH=0, end marker: Start, A, 1, 1, 1, 1 (bits 3-0 all one)
-		peripheral |= 0x3F
-		if io.InputP1.btnA {
-			peripheral &^= 0x10
-		}
-		if io.InputP1.start {
-			peripheral &^= 0x20
-		}
-	}
-
-	return (io.p1Data & io.p1Ctrl) | (peripheral & ^io.p1Ctrl)
-}
-
-// readPort2 reads Player 2 controller data, combining output pins from the
-// data register with input pins from the controller peripheral.
-// Uses the same 3/6-button state machine as readPort1.
-func (io *IO) readPort2(cycle uint64) byte {
-	// No controller: all peripheral pins float high
-	if !io.InputP2.Connected {
-		return (io.p2Data & io.p2Ctrl) | (0xFF & ^io.p2Ctrl)
-	}
-
-	// Determine TH: if output (ctrl bit 6=1), use data register; else pulled high
-	var th bool
-	if io.p2Ctrl&0x40 != 0 {
-		th = io.p2Data&0x40 != 0
-	} else {
-		th = true
-	}
-
-	// Controller response (active low: 0=pressed, 1=released)
-	var peripheral byte = 0xC0 // Bits 7,6 pulled high
-
-	if !io.InputP2.SixButton {
-		// 3-button controller: no state machine, just TH level
-		if th {
-			// TH=1: C, B, Right, Left, Down, Up
-			peripheral |= 0x3F
-			if io.InputP2.up {
-				peripheral &^= 0x01
-			}
-			if io.InputP2.down {
-				peripheral &^= 0x02
-			}
-			if io.InputP2.left {
-				peripheral &^= 0x04
-			}
-			if io.InputP2.right {
-				peripheral &^= 0x08
-			}
-			if io.InputP2.btnB {
-				peripheral &^= 0x10
-			}
-			if io.InputP2.btnC {
-				peripheral &^= 0x20
-			}
-		} else {
-			// TH=0: Start, A, 0, 0, Down, Up
-			peripheral |= 0x33
-			if io.InputP2.up {
-				peripheral &^= 0x01
-			}
-			if io.InputP2.down {
-				peripheral &^= 0x02
-			}
-			if io.InputP2.btnA {
-				peripheral &^= 0x10
-			}
-			if io.InputP2.start {
-				peripheral &^= 0x20
-			}
+		io.port2.WriteCtrl(cycle, io.p2Data, io.p2Ctrl)
+	case 0xA10007:
+		io.p3Data = val
+		io.port3.WriteData(cycle, io.p3Data, io.p3Ctrl)
+	case 0xA1000D:
+		io.p3Ctrl = val
+		io.port3.WriteCtrl(cycle, io.p3Data, io.p3Ctrl)
+	default:
+		if addr >= 0xA1000F && addr <= 0xA1001F && addr&1 != 0 {
+			idx := int(addr-0xA1000F) / 2
+			io.writeSerial(cycle, idx/3+1, idx%3, val)
 		}
-		return (io.p2Data & io.p2Ctrl) | (peripheral & ^io.p2Ctrl)
 	}
 
-	// 6-button controller: state-machine driven
-	// Check timeout: reset state if too much time has elapsed since last TH edge
-	if cycle > 0 && io.p2LastCycle > 0 && cycle-io.p2LastCycle >= sixButtonTimeoutCycles {
-		io.p2THState = 0
-		io.p2LastTHHigh = true
+	switch io.multitap {
+	case MultitapTeamPlayer:
+		io.updateTeamPlayer()
+	case MultitapEA4Way:
+		io.updateEA4Way()
 	}
-
-	switch io.p2THState {
-	case 0, 2, 4:
-		// TH=1: C, B, Right, Left, Down, Up
-		peripheral |= 0x3F
-		if io.InputP2.up {
-			peripheral &^= 0x01
-		}
-		if io.InputP2.down {
-			peripheral &^= 0x02
-		}
-		if io.InputP2.left {
-			peripheral &^= 0x04
-		}
-		if io.InputP2.right {
-			peripheral &^= 0x08
-		}
-		if io.InputP2.btnB {
-			peripheral &^= 0x10
-		}
-		if io.InputP2.btnC {
-			peripheral &^= 0x20
-		}
-	case 1, 3:
-		// TH=0: Start, A, 0, 0, Down, Up
-		peripheral |= 0x33
-		if io.InputP2.up {
-			peripheral &^= 0x01
-		}
-		if io.InputP2.down {
-			peripheral &^= 0x02
-		}
-		if io.InputP2.btnA {
-			peripheral &^= 0x10
-		}
-		if io.InputP2.start {
-			peripheral &^= 0x20
-		}
-	case 5:
-		// TH=0, detection: Start, A, 0, 0, 0, 0 (bits 3-0 all zero)
-		peripheral |= 0x30
-		if io.InputP2.btnA {
-			peripheral &^= 0x10
-		}
-		if io.InputP2.start {
-			peripheral &^= 0x20
-		}
-	case 6:
-		// TH=1, extra buttons: C, B, Mode, X, Y, Z
-		peripheral |= 0x3F
-		if io.InputP2.btnZ {
-			peripheral &^= 0x01
-		}
-		if io.InputP2.btnY {
-			peripheral &^= 0x02
-		}
-		if io.InputP2.btnX {
-			peripheral &^= 0x04
-		}
-		if io.InputP2.btnMode {
-			peripheral &^= 0x08
-		}
-		if io.InputP2.btnB {
-			peripheral &^= 0x10
-		}
-		if io.InputP2.btnC {
-			peripheral &^= 0x20
-		}
-	case 7:
-		// TH=0, end marker: Start, A, 1, 1, 1, 1 (bits 3-0 all one)
-		peripheral |= 0x3F
-		if io.InputP2.btnA {
-			peripheral &^= 0x10
-		}
-		if io.InputP2.start {
-			peripheral &^= 0x20
-		}
-	}
-
-	return (io.p2Data & io.p2Ctrl) | (peripheral & ^io.p2Ctrl)
 }
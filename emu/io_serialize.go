@@ -1,19 +1,23 @@
 package emu
 
-import (
-	"encoding/binary"
-	"errors"
-)
+import "errors"
 
 const (
-	ioSerializeVersion = 1
+	ioSerializeVersion = 2
 	// IOSerializeSize is the total bytes needed for IO serialization.
 	// version(1) + p1Data(1) + p1Ctrl(1) + p2Data(1) + p2Ctrl(1) +
-	// p1THState(1) + p1LastTHHigh(1) + p1LastCycle(8) +
-	// p2THState(1) + p2LastTHHigh(1) + p2LastCycle(8) +
+	// p3Data(1) + p3Ctrl(1) +
+	// port1 type(1) + port1 state(peripheralStateSize) +
+	// port2 type(1) + port2 state(peripheralStateSize) +
+	// port3 type(1) + port3 state(peripheralStateSize) +
 	// InputP1.Connected(1) + InputP1.SixButton(1) +
-	// InputP2.Connected(1) + InputP2.SixButton(1)
-	IOSerializeSize = 29
+	// InputP2.Connected(1) + InputP2.SixButton(1) +
+	// InputP3.Connected(1) + InputP3.SixButton(1) +
+	// InputP4.Connected(1) + InputP4.SixButton(1) +
+	// multitap(1) + tapLines(1) + tapCounter(1) + eaSelect(1) +
+	// lightGun(1) + gunSense(1) +
+	// serial ports(3*serialSerializeSize)
+	IOSerializeSize = 24 + 3*peripheralStateSize + 3*serialSerializeSize
 )
 
 // Serialize writes IO state to buf. buf must be at least IOSerializeSize bytes.
@@ -37,22 +41,15 @@ func (io *IO) Serialize(buf []byte) error {
 	offset++
 	buf[offset] = io.p2Ctrl
 	offset++
-
-	// Player 1 six-button state machine
-	buf[offset] = io.p1THState
+	buf[offset] = io.p3Data
 	offset++
-	buf[offset] = boolByte(io.p1LastTHHigh)
+	buf[offset] = io.p3Ctrl
 	offset++
-	binary.LittleEndian.PutUint64(buf[offset:], io.p1LastCycle)
-	offset += 8
 
-	// Player 2 six-button state machine
-	buf[offset] = io.p2THState
-	offset++
-	buf[offset] = boolByte(io.p2LastTHHigh)
-	offset++
-	binary.LittleEndian.PutUint64(buf[offset:], io.p2LastCycle)
-	offset += 8
+	// Port peripherals
+	offset = io.serializePort(buf, offset, 1)
+	offset = io.serializePort(buf, offset, 2)
+	offset = io.serializePort(buf, offset, 3)
 
 	// Input configuration
 	buf[offset] = boolByte(io.InputP1.Connected)
@@ -63,6 +60,36 @@ func (io *IO) Serialize(buf []byte) error {
 	offset++
 	buf[offset] = boolByte(io.InputP2.SixButton)
 	offset++
+	buf[offset] = boolByte(io.InputP3.Connected)
+	offset++
+	buf[offset] = boolByte(io.InputP3.SixButton)
+	offset++
+	buf[offset] = boolByte(io.InputP4.Connected)
+	offset++
+	buf[offset] = boolByte(io.InputP4.SixButton)
+	offset++
+
+	// Multitap
+	buf[offset] = uint8(io.multitap)
+	offset++
+	buf[offset] = io.tapLines
+	offset++
+	buf[offset] = io.tapCounter
+	offset++
+	buf[offset] = io.eaSelect
+	offset++
+
+	// Light gun
+	buf[offset] = uint8(io.lightGun)
+	offset++
+	buf[offset] = boolByte(io.gunSense)
+	offset++
+
+	// Serial ports
+	for i := range io.serial {
+		io.serial[i].serialize(buf[offset:])
+		offset += serialSerializeSize
+	}
 
 	return nil
 }
@@ -91,22 +118,16 @@ func (io *IO) Deserialize(buf []byte) error {
 	offset++
 	io.p2Ctrl = buf[offset]
 	offset++
-
-	// Player 1 six-button state machine
-	io.p1THState = buf[offset]
+	io.p3Data = buf[offset]
 	offset++
-	io.p1LastTHHigh = buf[offset] != 0
+	io.p3Ctrl = buf[offset]
 	offset++
-	io.p1LastCycle = binary.LittleEndian.Uint64(buf[offset:])
-	offset += 8
 
-	// Player 2 six-button state machine
-	io.p2THState = buf[offset]
-	offset++
-	io.p2LastTHHigh = buf[offset] != 0
-	offset++
-	io.p2LastCycle = binary.LittleEndian.Uint64(buf[offset:])
-	offset += 8
+	// Port peripherals. The pad configuration is restored below from the
+	// Input fields, which PeripheralType reads back.
+	offset = io.deserializePort(buf, offset, 1)
+	offset = io.deserializePort(buf, offset, 2)
+	offset = io.deserializePort(buf, offset, 3)
 
 	// Input configuration
 	io.InputP1.Connected = buf[offset] != 0
@@ -117,6 +138,73 @@ func (io *IO) Deserialize(buf []byte) error {
 	offset++
 	io.InputP2.SixButton = buf[offset] != 0
 	offset++
+	io.InputP3.Connected = buf[offset] != 0
+	offset++
+	io.InputP3.SixButton = buf[offset] != 0
+	offset++
+	io.InputP4.Connected = buf[offset] != 0
+	offset++
+	io.InputP4.SixButton = buf[offset] != 0
+	offset++
+
+	// Multitap
+	io.multitap = Multitap(buf[offset])
+	offset++
+	io.tapLines = buf[offset]
+	offset++
+	io.tapCounter = buf[offset]
+	offset++
+	io.eaSelect = buf[offset]
+	offset++
+
+	// Light gun
+	io.lightGun = LightGunType(buf[offset])
+	offset++
+	io.gunSense = buf[offset] != 0
+	offset++
+
+	// Serial ports
+	for i := range io.serial {
+		io.serial[i].deserialize(buf[offset:])
+		offset += serialSerializeSize
+	}
 
 	return nil
 }
+
+// serializePort writes the type and state of the peripheral in port 1, 2
+// or 3.
+func (io *IO) serializePort(buf []byte, offset, port int) int {
+	_, _, slot := io.port(port)
+	buf[offset] = uint8(io.PeripheralType(port))
+	offset++
+	state := buf[offset : offset+peripheralStateSize]
+	clear(state)
+	if p, ok := (*slot).(PeripheralState); ok {
+		p.SerializeState(state)
+	}
+	return offset + peripheralStateSize
+}
+
+// deserializePort plugs the saved peripheral back into port 1, 2 or 3 and
+// restores its state. A device attached with AttachPeripheral stays in
+// place and only gets its state back.
+func (io *IO) deserializePort(buf []byte, offset, port int) int {
+	pad, mouse, slot := io.port(port)
+	switch t := PeripheralType(buf[offset]); {
+	case pad == nil:
+		// The EXT port has no built-in devices
+		if t == PeripheralNone {
+			*slot = emptyPort{}
+		}
+	case t == PeripheralMouse:
+		*slot = mouse
+	case t == PeripheralNone, t == Peripheral3Button, t == Peripheral6Button:
+		*slot = pad
+	}
+	offset++
+	if p, ok := (*slot).(PeripheralState); ok {
+		p.DeserializeState(buf[offset : offset+peripheralStateSize])
+	}
+	return offset + peripheralStateSize
+}
@@ -65,7 +65,7 @@ func TestIO_ReadRegister_Unknown(t *testing.T) {
 	ym := NewYM2612(7670454, 48000)
 	io := NewIO(vdp, psg, ym, ConsoleUSA)
 
-	val := io.ReadRegister(0, 0xA10007)
+	val := io.ReadRegister(0, 0xA10021)
 	if val != 0x00 {
 		t.Errorf("expected 0x00 for unknown register, got 0x%02X", val)
 	}
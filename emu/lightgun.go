@@ -0,0 +1,182 @@
+package emu
+
+// LightGunType selects a light gun plugged into port 2.
+//
+// A light gun pulls TH of port 2 low when its photodiode sees the beam
+// pass the aimed pixel. With TH interrupts enabled in the port 2 ctrl
+// register (bit 7) the pulse latches the VDP HV counter, when latching
+// is enabled in register 0, and raises the level 2 external interrupt
+// when register 11 enables it. Games read the latched counter to find
+// where the gun points.
+//
+// The Sega Menacer reports its buttons as active-high bits on D3-D0:
+// Start, Trigger, B, A. TL and TR read 0.
+//
+// The Konami Justifier takes two guns. The host drives TH, TR and TL:
+// with TH=1 the port reads $30 so the game can detect the Justifier; with
+// TH=0, TR=1 and TL=0 the blue gun (player 1) is selected and with TH=0,
+// TR=0 and TL=1 the pink gun (player 2). Only the selected gun senses
+// light. Its Trigger and Start are active low on D0 and D1.
+type LightGunType uint8
+
+const (
+	LightGunNone LightGunType = iota
+	LightGunMenacer
+	LightGunJustifier
+)
+
+// LightGun holds where a light gun is aimed and its buttons.
+type LightGun struct {
+	x, y                 int  // Aimed framebuffer pixel
+	onScreen             bool // false when aimed away from the screen
+	trigger, start, a, b bool
+}
+
+// Set aims the gun at framebuffer pixel (x, y) and sets the buttons.
+// Coordinates outside the active display aim the gun off screen, which
+// is how games expect a reload.
+func (g *LightGun) Set(x, y int, trigger, start, a, b bool) {
+	g.x = x
+	g.y = y
+	g.onScreen = x >= 0 && x < ScreenWidth && y >= 0 && y < MaxScreenHeight
+	g.trigger = trigger
+	g.start = start
+	g.a = a
+	g.b = b
+}
+
+// Position returns the aimed framebuffer pixel and whether it is on
+// screen, for frontends that draw their own crosshair.
+func (g *LightGun) Position() (x, y int, onScreen bool) {
+	return g.x, g.y, g.onScreen
+}
+
+// SetLightGun plugs a light gun into port 2 in place of the pad, or
+// unplugs it with LightGunNone.
+func (io *IO) SetLightGun(t LightGunType) {
+	io.lightGun = t
+	io.gunSense = false
+}
+
+// LightGun returns the light gun plugged into port 2.
+func (io *IO) LightGun() LightGunType {
+	return io.lightGun
+}
+
+// activeGun returns the gun that currently senses light, or nil.
+func (io *IO) activeGun() *LightGun {
+	switch io.lightGun {
+	case LightGunMenacer:
+		return &io.GunP1
+	case LightGunJustifier:
+		lines := (io.p2Data&io.p2Ctrl | ^io.p2Ctrl) & 0x70
+		switch lines {
+		case 0x20:
+			return &io.GunP1
+		case 0x10:
+			return &io.GunP2
+		}
+	}
+	return nil
+}
+
+// lightGunCycle returns the 68K cycle within scanline line at which the
+// beam passes the active gun's aim, or -1 if it does not on this line.
+// The active display spans the first ~73% of the scanline, as in
+// cycleToPixel.
+func (io *IO) lightGunCycle(line, activeHeight, totalCycles int) int {
+	io.gunSense = false
+	gun := io.activeGun()
+	if gun == nil || !gun.onScreen || gun.y != line || line >= activeHeight {
+		return -1
+	}
+	activeEnd := (totalCycles * 73) / 100
+	return (gun.x * activeEnd) / ScreenWidth
+}
+
+// fireLightGun pulls TH low at the given 68K cycle as the beam reaches
+// the aimed pixel. It returns true if the level 2 interrupt should be
+// raised.
+func (io *IO) fireLightGun(cycle uint64) bool {
+	io.gunSense = true
+	if io.p2Ctrl&0x80 == 0 {
+		return false
+	}
+	io.vdp.LatchHVCounterAtCycle(cycle)
+	return io.vdp.externalIntEnabled()
+}
+
+// readLightGun returns the light gun response on port 2.
+func (io *IO) readLightGun() byte {
+	var peripheral byte
+	switch io.lightGun {
+	case LightGunMenacer:
+		gun := &io.GunP1
+		peripheral = 0x80
+		if !io.gunSense {
+			peripheral |= 0x40
+		}
+		if gun.a {
+			peripheral |= 0x01
+		}
+		if gun.b {
+			peripheral |= 0x02
+		}
+		if gun.trigger {
+			peripheral |= 0x04
+		}
+		if gun.start {
+			peripheral |= 0x08
+		}
+	case LightGunJustifier:
+		peripheral = 0xF0
+		if io.p2Ctrl&0x40 != 0 && io.p2Data&0x40 == 0 {
+			peripheral |= 0x03
+			if gun := io.activeGun(); gun != nil {
+				if gun.trigger {
+					peripheral &^= 0x01
+				}
+				if gun.start {
+					peripheral &^= 0x02
+				}
+			}
+		}
+	}
+	return (io.p2Data & io.p2Ctrl) | (peripheral & ^io.p2Ctrl)
+}
+
+// drawCrosshairs marks where each connected gun aims on the framebuffer.
+func (io *IO) drawCrosshairs(fb []byte, stride, height int) {
+	guns := []*LightGun{&io.GunP1}
+	colors := [][3]byte{{0x40, 0x80, 0xFF}, {0xFF, 0x60, 0xC0}}
+	switch io.lightGun {
+	case LightGunNone:
+		return
+	case LightGunJustifier:
+		guns = append(guns, &io.GunP2)
+	}
+	for i, gun := range guns {
+		if !gun.onScreen {
+			continue
+		}
+		for d := -crosshairSize; d <= crosshairSize; d++ {
+			plotCrosshair(fb, stride, height, gun.x+d, gun.y, colors[i])
+			plotCrosshair(fb, stride, height, gun.x, gun.y+d, colors[i])
+		}
+	}
+}
+
+// crosshairSize is the arm length of the crosshair in pixels.
+const crosshairSize = 3
+
+// plotCrosshair sets one crosshair pixel, clipped to the framebuffer.
+func plotCrosshair(fb []byte, stride, height, x, y int, c [3]byte) {
+	if x < 0 || x >= ScreenWidth || y < 0 || y >= height {
+		return
+	}
+	off := y*stride + x*4
+	fb[off] = c[0]
+	fb[off+1] = c[1]
+	fb[off+2] = c[2]
+	fb[off+3] = 0xFF
+}
@@ -0,0 +1,130 @@
+package emu
+
+import "testing"
+
+func TestLightGun_MenacerButtons(t *testing.T) {
+	io := newTestIO()
+	io.SetLightGun(LightGunMenacer)
+	io.GunP1.Set(100, 50, true, false, false, true)
+
+	if got := io.ReadRegister(0, 0xA10005); got != 0xC6 {
+		t.Errorf("expected TH high with Trigger and B (0xC6), got 0x%02X", got)
+	}
+	io.fireLightGun(0)
+	if got := io.ReadRegister(0, 0xA10005) & 0x40; got != 0 {
+		t.Error("TH should read low after the beam passes the aim")
+	}
+}
+
+func TestLightGun_JustifierSelect(t *testing.T) {
+	io := newTestIO()
+	io.SetLightGun(LightGunJustifier)
+	io.WriteRegister(0, 0xA1000B, 0x70) // TH, TR, TL outputs
+	io.GunP1.Set(10, 10, true, false, false, false)
+	io.GunP2.Set(20, 20, false, true, false, false)
+
+	io.WriteRegister(0, 0xA10005, 0x60)
+	if got := io.ReadRegister(0, 0xA10005) & 0x0F; got != 0x00 {
+		t.Errorf("TH=1: expected detection nibble 0, got 0x%X", got)
+	}
+
+	io.WriteRegister(0, 0xA10005, 0x20) // Blue gun
+	if got := io.ReadRegister(0, 0xA10005) & 0x03; got != 0x02 {
+		t.Errorf("blue gun: expected Trigger pressed (0x2), got 0x%X", got)
+	}
+	if io.activeGun() != &io.GunP1 {
+		t.Error("TR=1, TL=0 should select the blue gun")
+	}
+
+	io.WriteRegister(0, 0xA10005, 0x10) // Pink gun
+	if got := io.ReadRegister(0, 0xA10005) & 0x03; got != 0x01 {
+		t.Errorf("pink gun: expected Start pressed (0x1), got 0x%X", got)
+	}
+	if io.activeGun() != &io.GunP2 {
+		t.Error("TR=0, TL=1 should select the pink gun")
+	}
+}
+
+func TestLightGun_CycleOnAimedLine(t *testing.T) {
+	io := newTestIO()
+	io.SetLightGun(LightGunMenacer)
+	io.GunP1.Set(ScreenWidth/2, 40, false, false, false, false)
+
+	if c := io.lightGunCycle(39, 224, 488); c != -1 {
+		t.Errorf("line 39: expected no shot, got cycle %d", c)
+	}
+	activeEnd := (488 * 73) / 100
+	if c := io.lightGunCycle(40, 224, 488); c != activeEnd/2 {
+		t.Errorf("line 40: expected cycle %d, got %d", activeEnd/2, c)
+	}
+
+	io.GunP1.Set(-1, 40, false, false, false, false)
+	if c := io.lightGunCycle(40, 224, 488); c != -1 {
+		t.Errorf("off screen: expected no shot, got cycle %d", c)
+	}
+}
+
+func TestLightGun_FireLatchesAndInterrupts(t *testing.T) {
+	io := newTestIO()
+	io.SetLightGun(LightGunMenacer)
+
+	if io.fireLightGun(0) || io.vdp.hvLatched {
+		t.Fatal("without TH interrupts enabled the gun should not latch")
+	}
+
+	io.WriteRegister(0, 0xA1000B, 0x80)
+	io.vdp.writeRegister(0, 0x02)
+	if io.fireLightGun(0) {
+		t.Error("external interrupt should stay off until register 11 enables it")
+	}
+	if !io.vdp.hvLatched {
+		t.Error("HV counter should be latched")
+	}
+
+	io.vdp.writeRegister(11, 0x08)
+	if !io.fireLightGun(0) {
+		t.Error("expected the level 2 interrupt to be requested")
+	}
+}
+
+func TestEmulator_LightGunLatchesAimedLine(t *testing.T) {
+	e := createTestEmulator()
+	e.SetOption("lightgun", "menacer")
+	e.io.WriteRegister(0, 0xA1000B, 0x80)
+	e.vdp.writeRegister(0, 0x02)
+	e.SetLightGunInput(0, 160, 100, false, false, false, false)
+
+	e.RunFrame()
+	if !e.vdp.hvLatched {
+		t.Fatal("HV counter should be latched by the light gun")
+	}
+	if v := e.vdp.hvLatchValue >> 8; v != 100 {
+		t.Errorf("expected latched V counter 100, got %d", v)
+	}
+	h := int(e.vdp.hvLatchValue & 0xFF)
+	activeEnd, _ := e.vdp.hCounterRanges()
+	if h < activeEnd/2-4 || h > activeEnd/2+4 {
+		t.Errorf("expected latched H counter near 0x%02X, got 0x%02X", activeEnd/2, h)
+	}
+
+	if x, y, on := e.LightGunPosition(0); !on || x != 160 || y != 100 {
+		t.Errorf("LightGunPosition: got (%d, %d, %v)", x, y, on)
+	}
+	if _, _, on := e.LightGunPosition(1); on {
+		t.Error("the Menacer has no second gun")
+	}
+}
+
+func TestEmulator_Crosshair(t *testing.T) {
+	e := createTestEmulator()
+	e.SetOption("lightgun", "menacer")
+	e.SetOption("lightgun_crosshair", "true")
+	e.SetLightGunInput(0, 50, 60, false, false, false, false)
+	e.RunFrame()
+
+	fb := e.GetFramebuffer()
+	off := 60*e.GetFramebufferStride() + 50*4
+	if fb[off] != 0x40 || fb[off+1] != 0x80 || fb[off+2] != 0xFF {
+		t.Errorf("expected crosshair pixel at the aim, got %v", fb[off:off+4])
+	}
+}
@@ -0,0 +1,141 @@
+package emu
+
+import "bytes"
+
+const (
+	// maxMappedROMSize is the largest ROM image a banked mapper can address.
+	// The SSF2 bank registers hold 6-bit bank numbers of 512KB each.
+	maxMappedROMSize = 64 * ssf2BankSize
+
+	ssf2BankSize  = 0x80000 // 512KB per bank
+	ssf2BankCount = 8       // 8 x 512KB windows cover $000000-$3FFFFF
+
+	// mapperSerializeSize is the fixed size reserved for mapper state in
+	// save states: type(1) + bank registers(8).
+	mapperSerializeSize = 1 + ssf2BankCount
+)
+
+// MapperType identifies the cartridge banking hardware.
+type MapperType int
+
+const (
+	MapperNone MapperType = iota // Flat ROM up to 4MB
+	MapperSSF2                   // Sega 315-5779 bank switching (Super Street Fighter II)
+)
+
+// Mapper translates 68K cartridge addresses ($000000-$3FFFFF) into ROM
+// offsets and handles the bank registers at $A130F2-$A130FF.
+type Mapper interface {
+	// Type returns the mapper identifier.
+	Type() MapperType
+
+	// MapAddress returns the ROM offset for a cartridge address.
+	MapAddress(addr uint32) uint32
+
+	// WriteRegister handles a byte write to the $A130F2-$A130FF range.
+	// $A130F1 (SRAM control) is handled by the bus, not the mapper.
+	WriteRegister(addr uint32, val uint8)
+
+	// Reset restores the power-on bank configuration.
+	Reset()
+
+	// Serialize writes mapper state to buf (mapperSerializeSize bytes).
+	Serialize(buf []byte)
+
+	// Deserialize reads mapper state from buf (mapperSerializeSize bytes).
+	Deserialize(buf []byte)
+}
+
+// NewMapper creates a mapper of the given type.
+func NewMapper(t MapperType) Mapper {
+	switch t {
+	case MapperSSF2:
+		m := &ssf2Mapper{}
+		m.Reset()
+		return m
+	default:
+		return flatMapper{}
+	}
+}
+
+// DetectMapper inspects the ROM header and size to select the cartridge
+// mapper. SSF2 banking is selected when:
+//
+//   - the ROM is larger than 4MB (no other board can address it),
+//   - the system type at $100 is "SEGA SSF" (homebrew convention), or
+//   - the domestic ($120) or overseas ($150) title is Super Street Fighter II.
+func DetectMapper(rom []byte) MapperType {
+	if len(rom) > maxROMSize {
+		return MapperSSF2
+	}
+	if len(rom) < 0x190 {
+		return MapperNone
+	}
+	if bytes.HasPrefix(rom[0x100:0x110], []byte("SEGA SSF")) {
+		return MapperSSF2
+	}
+	const ssf2Title = "SUPER STREET FIGHTER2"
+	if bytes.HasPrefix(rom[0x120:0x150], []byte(ssf2Title)) ||
+		bytes.HasPrefix(rom[0x150:0x180], []byte(ssf2Title)) {
+		return MapperSSF2
+	}
+	return MapperNone
+}
+
+// flatMapper maps the cartridge area directly onto the ROM image.
+type flatMapper struct{}
+
+func (flatMapper) Type() MapperType                     { return MapperNone }
+func (flatMapper) MapAddress(addr uint32) uint32        { return addr }
+func (flatMapper) WriteRegister(addr uint32, val uint8) {}
+func (flatMapper) Reset()                               {}
+func (flatMapper) Serialize(buf []byte)                 { buf[0] = byte(MapperNone) }
+func (flatMapper) Deserialize(buf []byte)               {}
+
+// ssf2Mapper implements the Sega 315-5779 mapper. The cartridge area is
+// divided into eight 512KB windows. Window 0 ($000000-$07FFFF) is fixed to
+// ROM bank 0; windows 1-7 are selected by the odd registers $A130F3-$A130FF.
+type ssf2Mapper struct {
+	banks [ssf2BankCount]uint8
+}
+
+func (m *ssf2Mapper) Type() MapperType {
+	return MapperSSF2
+}
+
+func (m *ssf2Mapper) MapAddress(addr uint32) uint32 {
+	window := (addr >> 19) & 0x07
+	return uint32(m.banks[window])*ssf2BankSize | addr&(ssf2BankSize-1)
+}
+
+func (m *ssf2Mapper) WriteRegister(addr uint32, val uint8) {
+	// $A130F3 -> window 1, $A130F5 -> window 2, ... $A130FF -> window 7
+	if addr&1 == 0 || addr < 0xA130F3 {
+		return
+	}
+	window := (addr - 0xA130F1) >> 1
+	m.banks[window] = val & 0x3F
+}
+
+// Reset maps each window to the matching bank, so the first 4MB of the
+// ROM appears linearly as on a flat cartridge.
+func (m *ssf2Mapper) Reset() {
+	for i := range m.banks {
+		m.banks[i] = uint8(i)
+	}
+}
+
+func (m *ssf2Mapper) Serialize(buf []byte) {
+	buf[0] = byte(MapperSSF2)
+	copy(buf[1:1+ssf2BankCount], m.banks[:])
+}
+
+func (m *ssf2Mapper) Deserialize(buf []byte) {
+	if MapperType(buf[0]) != MapperSSF2 {
+		m.Reset()
+		return
+	}
+	copy(m.banks[:], buf[1:1+ssf2BankCount])
+	// Window 0 is hard-wired to bank 0 regardless of saved contents.
+	m.banks[0] = 0
+}
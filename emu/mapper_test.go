@@ -0,0 +1,158 @@
+package emu
+
+import (
+	"testing"
+
+	"github.com/user-none/go-chip-m68k"
+	"github.com/user-none/go-chip-sn76489"
+)
+
+// makeBankedROM creates a ROM of the given number of 512KB banks where
+// the first word of each bank holds the bank number.
+func makeBankedROM(banks int) []byte {
+	rom := make([]byte, banks*ssf2BankSize)
+	for i := 0; i < banks; i++ {
+		rom[i*ssf2BankSize] = 0xB0
+		rom[i*ssf2BankSize+1] = byte(i)
+	}
+	return rom
+}
+
+func makeTestBusWithROM(rom []byte) *GenesisBus {
+	vdp := NewVDP(false)
+	psg := sn76489.New(3579545, 48000, psgBufferSize, sn76489.Sega)
+	ym := NewYM2612(7670454, 48000)
+	io := NewIO(vdp, psg, ym, ConsoleUSA)
+	return NewGenesisBus(rom, vdp, io, psg, ym)
+}
+
+func TestDetectMapper_Flat(t *testing.T) {
+	rom := make([]byte, 0x400)
+	copy(rom[0x100:], "SEGA GENESIS    ")
+	if got := DetectMapper(rom); got != MapperNone {
+		t.Errorf("expected MapperNone, got %d", got)
+	}
+}
+
+func TestDetectMapper_LargeROM(t *testing.T) {
+	rom := make([]byte, maxROMSize+ssf2BankSize)
+	if got := DetectMapper(rom); got != MapperSSF2 {
+		t.Errorf("expected MapperSSF2 for >4MB ROM, got %d", got)
+	}
+}
+
+func TestDetectMapper_SSFSystemType(t *testing.T) {
+	rom := make([]byte, 0x400)
+	copy(rom[0x100:], "SEGA SSF        ")
+	if got := DetectMapper(rom); got != MapperSSF2 {
+		t.Errorf("expected MapperSSF2 for SEGA SSF header, got %d", got)
+	}
+}
+
+func TestDetectMapper_SSF2Title(t *testing.T) {
+	rom := make([]byte, 0x400)
+	copy(rom[0x100:], "SEGA GENESIS    ")
+	copy(rom[0x150:], "SUPER STREET FIGHTER2 The New Challengers")
+	if got := DetectMapper(rom); got != MapperSSF2 {
+		t.Errorf("expected MapperSSF2 for SSF2 title, got %d", got)
+	}
+}
+
+func TestGenesisBus_LargeROMNotTruncated(t *testing.T) {
+	bus := makeTestBusWithROM(makeBankedROM(10))
+	if len(bus.rom) != 10*ssf2BankSize {
+		t.Errorf("expected ROM length 0x%X, got 0x%X", 10*ssf2BankSize, len(bus.rom))
+	}
+	if bus.GetMapperType() != MapperSSF2 {
+		t.Errorf("expected SSF2 mapper")
+	}
+}
+
+func TestSSF2_PowerOnLinear(t *testing.T) {
+	bus := makeTestBusWithROM(makeBankedROM(10))
+	for i := uint32(0); i < ssf2BankCount; i++ {
+		got := bus.ReadCycle(0, m68k.Word, i*ssf2BankSize)
+		if got != 0xB000|i {
+			t.Errorf("window %d: expected 0x%04X, got 0x%04X", i, 0xB000|i, got)
+		}
+	}
+}
+
+func TestSSF2_BankSwitch(t *testing.T) {
+	bus := makeTestBusWithROM(makeBankedROM(10))
+
+	// Map bank 9 into window 7 ($380000-$3FFFFF)
+	bus.WriteCycle(0, m68k.Byte, 0xA130FF, 0x0909)
+	if got := bus.ReadCycle(0, m68k.Word, 0x380000); got != 0xB009 {
+		t.Errorf("window 7: expected 0xB009, got 0x%04X", got)
+	}
+
+	// Map bank 8 into window 1 via a word write to the even address
+	bus.WriteCycle(0, m68k.Word, 0xA130F2, 0x0008)
+	if got := bus.ReadCycle(0, m68k.Word, 0x080000); got != 0xB008 {
+		t.Errorf("window 1: expected 0xB008, got 0x%04X", got)
+	}
+
+	// Window 0 is fixed
+	if got := bus.ReadCycle(0, m68k.Word, 0x000000); got != 0xB000 {
+		t.Errorf("window 0: expected 0xB000, got 0x%04X", got)
+	}
+}
+
+func TestSSF2_EvenByteWriteIgnored(t *testing.T) {
+	bus := makeTestBusWithROM(makeBankedROM(10))
+	bus.WriteCycle(0, m68k.Byte, 0xA130F2, 0x0909)
+	if got := bus.ReadCycle(0, m68k.Word, 0x080000); got != 0xB001 {
+		t.Errorf("even byte write should not switch banks, got 0x%04X", got)
+	}
+}
+
+func TestSSF2_LongReadAcrossBanks(t *testing.T) {
+	rom := makeBankedROM(10)
+	// Last word of bank 0 and first word of bank 9
+	rom[ssf2BankSize-2] = 0x12
+	rom[ssf2BankSize-1] = 0x34
+	bus := makeTestBusWithROM(rom)
+	bus.WriteCycle(0, m68k.Byte, 0xA130F3, 0x09)
+
+	got := bus.ReadCycle(0, m68k.Long, ssf2BankSize-2)
+	if got != 0x1234B009 {
+		t.Errorf("expected 0x1234B009, got 0x%08X", got)
+	}
+}
+
+func TestSSF2_FlatMapperIgnoresBankWrites(t *testing.T) {
+	bus := makeTestBus()
+	bus.WriteCycle(0, m68k.Byte, 0xA130F3, 0x05)
+	if got := bus.ReadCycle(0, m68k.Long, 0); got != 0x00FF0000 {
+		t.Errorf("flat mapper should not bank, got 0x%08X", got)
+	}
+}
+
+func TestSSF2_SerializeRoundTrip(t *testing.T) {
+	rom := makeBankedROM(10)
+	// Reset vectors: SSP and PC
+	copy(rom[0:8], []byte{0x00, 0xFF, 0x00, 0x00, 0x00, 0x00, 0x02, 0x00})
+	rom[0x200] = 0x4E
+	rom[0x201] = 0x71
+
+	e, err := NewEmulator(rom, RegionNTSC)
+	if err != nil {
+		t.Fatalf("NewEmulator failed: %v", err)
+	}
+	e.bus.WriteCycle(0, m68k.Byte, 0xA130FD, 0x08)
+
+	state, err := e.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+
+	e.bus.WriteCycle(0, m68k.Byte, 0xA130FD, 0x02)
+	if err := e.Deserialize(state); err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+
+	if got := e.bus.ReadCycle(0, m68k.Word, 0x300000); got != 0xB008 {
+		t.Errorf("window 6 after restore: expected 0xB008, got 0x%04X", got)
+	}
+}
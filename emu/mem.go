@@ -10,24 +10,28 @@ import (
 const (
 	mainRAMSize = 0x10000  // 64KB main 68K RAM
 	z80RAMSize  = 0x2000   // 8KB Z80 RAM
-	maxROMSize  = 0x400000 // 4MB max ROM
+	maxROMSize  = 0x400000 // 4MB max ROM without a banking mapper
 )
 
 // GenesisBus implements m68k.Bus with the full Genesis memory map.
 //
 // Address map (M68K view, 24-bit):
 //
-//	0x000000-0x3FFFFF  ROM (up to 4MB, read-only)
+//	0x000000-0x3FFFFF  ROM (up to 4MB, read-only; banked by mapper for larger ROMs)
 //	0x200000-0x3FFFFF  SRAM (when enabled via $A130F1, overlays ROM)
 //	0xA00000-0xA0FFFF  Z80 address space (0xA00000-0xA01FFF = 8KB Z80 RAM)
 //	0xA10000-0xA1001F  I/O registers
 //	0xA11100-0xA11101  Z80 bus request
 //	0xA11200-0xA11201  Z80 reset
 //	0xA130F1           SRAM control register
+//	0xA130F3-0xA130FF  Mapper bank registers (SSF2)
+//	0xA14000-0xA14003  TMSS unlock register ("SEGA")
+//	0xA14101           TMSS boot ROM / cartridge select
 //	0xC00000-0xC00003  VDP data port
 //	0xC00004-0xC00007  VDP control port
 //	0xC00008-0xC0000F  VDP HV counter, PSG, debug
 //	0xC00011           PSG write port
+//	0xC0001C-0xC0001F  VDP debug register
 //	0xE00000-0xFFFFFF  68K main RAM (64KB physical, mirrored every $10000)
 type GenesisBus struct {
 	rom    []byte
@@ -39,6 +43,19 @@ type GenesisBus struct {
 	psg    *sn76489.SN76489
 	ym2612 *YM2612
 
+	// Cartridge mapper translating $000000-$3FFFFF to ROM offsets
+	mapper Mapper
+
+	// Active cheat ROM patches keyed by even 68K address; nil when none
+	romPatches map[uint32]uint16
+
+	// Debugger bus watch; debugBreak is set when the hook asks to stop
+	debugHook  DebugHook
+	debugBreak bool
+
+	// VGM logger observing PSG writes, nil when not recording
+	vgm *vgmRecorder
+
 	// SRAM fields
 	sram         []byte // Battery-backed SRAM
 	sramStart    uint32 // SRAM start address from ROM header
@@ -46,6 +63,13 @@ type GenesisBus struct {
 	sramEnabled  bool   // SRAM mapped into address space (vs ROM)
 	sramWritable bool   // SRAM is writable (vs read-only)
 
+	// Serial EEPROM (nil for cartridges without one). When present it
+	// replaces byte SRAM as the battery-backed save.
+	eeprom *EEPROM
+
+	// Trademark Security System (VDP lock and boot ROM)
+	tmss tmss
+
 	z80BusRequested bool
 	z80Reset        bool
 	z80PendingReset bool // Set when Z80 reset transitions from asserted to deasserted
@@ -55,9 +79,15 @@ type GenesisBus struct {
 }
 
 // NewGenesisBus creates a new GenesisBus with the given ROM, VDP, IO, PSG, and YM2612.
+// The cartridge mapper is selected from the ROM header via DetectMapper.
 func NewGenesisBus(rom []byte, vdp *VDP, io *IO, psg *sn76489.SN76489, ym2612 *YM2612) *GenesisBus {
-	if len(rom) > maxROMSize {
-		rom = rom[:maxROMSize]
+	mapperType := DetectMapper(rom)
+	limit := maxROMSize
+	if mapperType != MapperNone {
+		limit = maxMappedROMSize
+	}
+	if len(rom) > limit {
+		rom = rom[:limit]
 	}
 
 	bus := &GenesisBus{
@@ -67,8 +97,13 @@ func NewGenesisBus(rom []byte, vdp *VDP, io *IO, psg *sn76489.SN76489, ym2612 *Y
 		io:     io,
 		psg:    psg,
 		ym2612: ym2612,
+		mapper: NewMapper(mapperType),
+		eeprom: detectEEPROM(rom),
+		tmss:   tmss{unlocked: true},
+	}
+	if bus.eeprom == nil {
+		bus.parseSRAMHeader()
 	}
-	bus.parseSRAMHeader()
 	return bus
 }
 
@@ -124,14 +159,32 @@ func (b *GenesisBus) Read(s m68k.Size, addr uint32) uint32 {
 
 // ReadCycle implements m68k.CycleBus.
 func (b *GenesisBus) ReadCycle(cycle uint64, s m68k.Size, addr uint32) uint32 {
+	if b.debugHook == nil {
+		return b.readCycle(cycle, s, addr)
+	}
+	value := b.readCycle(cycle, s, addr)
+	if b.debugHook.MemoryAccess(addr&0xFFFFFF, s, false, value) {
+		b.debugBreak = true
+	}
+	return value
+}
+
+// readCycle decodes a 68K bus read.
+func (b *GenesisBus) readCycle(cycle uint64, s m68k.Size, addr uint32) uint32 {
 	addr &= 0xFFFFFF // 24-bit address bus
 
 	switch {
 	case addr < 0x400000:
+		if b.tmss.biosMapped {
+			return b.readBIOS(s, addr)
+		}
+		if b.eeprom != nil && b.readsEEPROM(s, addr) {
+			return b.readEEPROM(s, addr)
+		}
 		if b.sramEnabled && b.sram != nil && addr >= b.sramStart && addr <= b.sramEnd {
 			return b.readSRAM(s, addr)
 		}
-		return b.readROM(s, addr)
+		return b.readCartROM(s, addr)
 	case addr >= 0xA00000 && addr <= 0xA0FFFF:
 		return b.readZ80(s, addr)
 	case addr >= 0xA10000 && addr <= 0xA1001F:
@@ -148,6 +201,9 @@ func (b *GenesisBus) ReadCycle(cycle uint64, s m68k.Size, addr uint32) uint32 {
 		// Z80 reset
 		return b.readSized(s, 0x00, 0x00)
 	case addr >= 0xC00000 && addr <= 0xDFFFFF:
+		if !b.vdpAccessAllowed() {
+			return 0
+		}
 		// VDP is mirrored every 32 bytes in this range.
 		// 68K byte reads: even addr -> high byte, odd addr -> low byte.
 		port := addr & 0x1F
@@ -230,8 +286,15 @@ func (b *GenesisBus) WriteCycle(cycle uint64, s m68k.Size, addr uint32, value ui
 
 	addr &= 0xFFFFFF // 24-bit address bus
 
+	if b.debugHook != nil && b.debugHook.MemoryAccess(addr, s, true, value) {
+		b.debugBreak = true
+	}
+
 	switch {
 	case addr < 0x400000:
+		if b.eeprom != nil {
+			b.writeEEPROM(s, addr, value)
+		}
 		if b.sramWritable && b.sram != nil && addr >= b.sramStart && addr <= b.sramEnd {
 			b.writeSRAM(s, addr, value)
 		}
@@ -266,6 +329,9 @@ func (b *GenesisBus) WriteCycle(cycle uint64, s m68k.Size, addr uint32, value ui
 		}
 		b.z80Reset = newReset
 	case addr >= 0xC00000 && addr <= 0xDFFFFF:
+		if !b.vdpAccessAllowed() {
+			return
+		}
 		// VDP is mirrored every 32 bytes in this range
 		port := addr & 0x1F
 		switch {
@@ -285,7 +351,13 @@ func (b *GenesisBus) WriteCycle(cycle uint64, s m68k.Size, addr uint32, value ui
 			}
 		case port >= 0x10 && port < 0x18:
 			// PSG write port ($C00011, but responds to $10-$17 range)
-			b.psg.Write(byte(value))
+			b.writePSG(byte(value))
+		case port >= 0x1C:
+			// Debug register ($C0001C, mirrored at $1E)
+			if s == m68k.Long {
+				b.vdp.WriteDebug(uint16(value >> 16))
+			}
+			b.vdp.WriteDebug(uint16(value))
 		}
 	case addr >= 0xA130F0 && addr <= 0xA130FF:
 		if addr == 0xA130F1 {
@@ -297,7 +369,13 @@ func (b *GenesisBus) WriteCycle(cycle uint64, s m68k.Size, addr uint32, value ui
 			}
 			b.sramEnabled = v&0x01 != 0
 			b.sramWritable = v&0x02 != 0
+		} else if s != m68k.Byte || addr&1 != 0 {
+			// Bank registers sit on odd addresses; word writes to the
+			// even address carry the register value in the low byte.
+			b.mapper.WriteRegister(addr|1, uint8(value))
 		}
+	case addr >= 0xA14000 && addr <= 0xA14003, addr == 0xA14100, addr == 0xA14101:
+		b.writeTMSS(s, addr, value)
 	case addr >= 0xE00000:
 		b.writeRAM(s, addr, value)
 	}
@@ -314,7 +392,30 @@ func (b *GenesisBus) GetROMCRC32() uint32 {
 	return b.romCRC
 }
 
+// GetMapperType returns the cartridge mapper in use.
+func (b *GenesisBus) GetMapperType() MapperType {
+	return b.mapper.Type()
+}
+
+// readCartROM reads from the cartridge ROM area through the mapper and
+// overlays active cheat patches. Long reads are split into two word
+// accesses so each half is banked independently, matching the two bus
+// cycles the 68K performs.
+func (b *GenesisBus) readCartROM(s m68k.Size, addr uint32) uint32 {
+	if s == m68k.Long {
+		hi := b.readCartROM(m68k.Word, addr)
+		lo := b.readCartROM(m68k.Word, addr+2)
+		return hi<<16 | lo
+	}
+	value := b.readROM(s, b.mapper.MapAddress(addr))
+	if b.romPatches != nil {
+		value = b.patchROM(s, addr, value)
+	}
+	return value
+}
+
 // readROM reads from ROM with big-endian byte order.
+// addr is an offset into the ROM image, already translated by the mapper.
 func (b *GenesisBus) readROM(s m68k.Size, addr uint32) uint32 {
 	romLen := uint32(len(b.rom))
 	switch s {
@@ -419,27 +520,89 @@ func (b *GenesisBus) writeSRAM(s m68k.Size, addr uint32, value uint32) {
 	}
 }
 
-// HasSRAM returns true if the ROM declares battery-backed SRAM.
+// readsEEPROM reports whether an access covers the EEPROM SDA input.
+func (b *GenesisBus) readsEEPROM(s m68k.Size, addr uint32) bool {
+	for i := uint32(0); i < uint32(s); i++ { // Size is the byte count
+		if b.eeprom.ReadsAt(addr + i) {
+			return true
+		}
+	}
+	return false
+}
+
+// readEEPROM reads an access covering the SDA input. That byte comes from
+// the EEPROM and the rest of the access reads the cartridge ROM.
+func (b *GenesisBus) readEEPROM(s m68k.Size, addr uint32) uint32 {
+	var value uint32
+	for i := uint32(0); i < uint32(s); i++ {
+		a := addr + i
+		if b.eeprom.ReadsAt(a) {
+			value = value<<8 | uint32(b.eeprom.Read(a))
+		} else {
+			value = value<<8 | b.readCartROM(m68k.Byte, a)
+		}
+	}
+	return value
+}
+
+// writeEEPROM forwards writes that touch the EEPROM line addresses.
+func (b *GenesisBus) writeEEPROM(s m68k.Size, addr uint32, value uint32) {
+	switch s {
+	case m68k.Byte:
+		if b.eeprom.Handles(addr) {
+			b.eeprom.Write(addr, uint8(value))
+		}
+	case m68k.Word:
+		if b.eeprom.Handles(addr) || b.eeprom.Handles(addr+1) {
+			b.eeprom.WriteWord(addr, uint16(value))
+		}
+	case m68k.Long:
+		if b.eeprom.Handles(addr) || b.eeprom.Handles(addr+1) {
+			b.eeprom.WriteWord(addr, uint16(value>>16))
+		}
+		if b.eeprom.Handles(addr+2) || b.eeprom.Handles(addr+3) {
+			b.eeprom.WriteWord(addr+2, uint16(value))
+		}
+	}
+}
+
+// saveRAM returns the live battery-backed memory: EEPROM contents when
+// the cartridge has one, otherwise byte SRAM (nil if neither).
+func (b *GenesisBus) saveRAM() []byte {
+	if b.eeprom != nil {
+		return b.eeprom.mem
+	}
+	return b.sram
+}
+
+// HasSRAM returns true if the ROM declares battery-backed SRAM or EEPROM.
 func (b *GenesisBus) HasSRAM() bool {
-	return b.sram != nil
+	return b.saveRAM() != nil
 }
 
-// GetSRAM returns a copy of the SRAM contents.
+// GetSRAM returns a copy of the SRAM or EEPROM contents.
 func (b *GenesisBus) GetSRAM() []byte {
-	if b.sram == nil {
+	mem := b.saveRAM()
+	if mem == nil {
 		return nil
 	}
-	out := make([]byte, len(b.sram))
-	copy(out, b.sram)
+	out := make([]byte, len(mem))
+	copy(out, mem)
 	return out
 }
 
-// SetSRAM loads SRAM contents (e.g. from a save file).
+// SetSRAM loads SRAM or EEPROM contents (e.g. from a save file).
 func (b *GenesisBus) SetSRAM(data []byte) {
-	if b.sram == nil {
+	mem := b.saveRAM()
+	if mem == nil {
 		return
 	}
-	copy(b.sram, data)
+	copy(mem, data)
+}
+
+// SRAMSize returns the size of the battery-backed memory in bytes, or 0.
+func (b *GenesisBus) SRAMSize() int {
+	return len(b.saveRAM())
 }
 
 // readZ80 reads from Z80 address space.
@@ -522,6 +685,15 @@ func (b *GenesisBus) writeZ80(s m68k.Size, addr uint32, value uint32) {
 	}
 }
 
+// writePSG writes a byte to the PSG from either CPU, recording it when a
+// VGM log is active.
+func (b *GenesisBus) writePSG(val uint8) {
+	if b.vgm != nil {
+		b.vgm.psgWrite(val)
+	}
+	b.psg.Write(val)
+}
+
 // readIO reads from I/O register space. For word/long reads, the value
 // is built from consecutive byte registers.
 func (b *GenesisBus) readIO(cycle uint64, s m68k.Size, addr uint32) uint32 {
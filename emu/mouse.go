@@ -0,0 +1,184 @@
+package emu
+
+import "encoding/binary"
+
+// Mouse is a Sega Mega Mouse plugged into a controller port.
+//
+// The host drives TH and TR and the mouse answers on TL and the data
+// nibble. Lowering TH starts an acquisition and latches the motion since
+// the previous one; every TR change then clocks out the next nibble, with
+// TL following TR once the nibble is valid:
+//
+//	Read 0 (TH=1): 0000 (idle)
+//	Read 1:        1011 (ID)
+//	Reads 2-3:     1111
+//	Read 4:        Y overflow, X overflow, Y sign, X sign
+//	Read 5:        Start, Middle, Right, Left (active high)
+//	Reads 6-7:     X delta, high then low nibble
+//	Reads 8-9:     Y delta, high then low nibble
+//
+// Deltas are 9-bit two's complement with the sign in read 4. Y is positive
+// upwards, the inverse of screen coordinates.
+type Mouse struct {
+	dx, dy                     int // Host motion accumulated since the last latch
+	left, right, middle, start bool
+
+	lines   uint8 // TH/TR levels driven by the host
+	counter uint8 // Nibble index of the current acquisition
+	wait    uint8 // Reads left before TL acknowledges a TR change
+	status  uint8 // Overflow and sign nibble of the latched deltas
+	latchX  int16 // X delta latched at the start of the acquisition
+	latchY  int16 // Y delta latched at the start of the acquisition
+}
+
+// mouseMaxDelta is the largest magnitude a 9-bit delta can report;
+// larger motion sets the overflow bit.
+const mouseMaxDelta = 255
+
+// mouseAckDelay is the number of reads TL lags behind a TR change. Some
+// mouse routines (Cannon Fodder, Shanghai II) break if the acknowledge
+// is immediate.
+const mouseAckDelay = 2
+
+// Move adds relative motion in screen coordinates (positive Y is down) and
+// sets the button state. Motion accumulates until the game next reads the
+// mouse.
+func (m *Mouse) Move(dx, dy int, left, right, middle, start bool) {
+	m.dx += dx
+	m.dy -= dy
+	m.left = left
+	m.right = right
+	m.middle = middle
+	m.start = start
+}
+
+// reset returns the protocol to idle with TH and TR high.
+func (m *Mouse) reset() {
+	m.lines = 0x60
+	m.counter = 0
+	m.wait = 0
+	m.status = 0
+	m.latchX = 0
+	m.latchY = 0
+}
+
+// latch captures the accumulated motion for the acquisition that is
+// starting and clears the accumulator.
+func (m *Mouse) latch() {
+	m.status = 0
+	if m.dx < 0 {
+		m.status |= 0x01
+	}
+	if m.dy < 0 {
+		m.status |= 0x02
+	}
+	if m.dx > mouseMaxDelta || m.dx < -mouseMaxDelta {
+		m.status |= 0x04
+	}
+	if m.dy > mouseMaxDelta || m.dy < -mouseMaxDelta {
+		m.status |= 0x08
+	}
+	m.latchX = int16(max(-mouseMaxDelta, min(mouseMaxDelta, m.dx)))
+	m.latchY = int16(max(-mouseMaxDelta, min(mouseMaxDelta, m.dy)))
+	m.dx = 0
+	m.dy = 0
+}
+
+// WriteData tracks the TH/TR lines driven through the port's data register.
+func (m *Mouse) WriteData(cycle uint64, data, ctrl byte) {
+	m.write(data, ctrl)
+}
+
+// WriteCtrl tracks the TH/TR lines when pins switch between input and
+// output.
+func (m *Mouse) WriteCtrl(cycle uint64, data, ctrl byte) {
+	m.write(data, ctrl)
+}
+
+// write tracks the TH/TR lines driven through a port's data and ctrl
+// registers. Input pins read as pulled high.
+func (m *Mouse) write(data, ctrl byte) {
+	lines := (data&ctrl | ^ctrl) & 0x60
+	changed := lines ^ m.lines
+	if changed&0x40 != 0 {
+		if lines&0x40 == 0 {
+			m.counter = 1
+			m.latch()
+		} else {
+			m.counter = 0
+		}
+	}
+	if changed&0x20 != 0 {
+		if m.counter > 0 && m.counter < 9 {
+			m.counter++
+		}
+		m.wait = mouseAckDelay
+	}
+	m.lines = lines
+}
+
+// Read returns the mouse response: TL and the data nibble, with TH and TR
+// pulled high.
+func (m *Mouse) Read(cycle uint64, data, ctrl byte) byte {
+	var nibble byte
+	switch m.counter {
+	case 1:
+		nibble = 0x0B
+	case 2, 3:
+		nibble = 0x0F
+	case 4:
+		nibble = m.status
+	case 5:
+		if m.left {
+			nibble |= 0x01
+		}
+		if m.right {
+			nibble |= 0x02
+		}
+		if m.middle {
+			nibble |= 0x04
+		}
+		if m.start {
+			nibble |= 0x08
+		}
+	case 6:
+		nibble = byte(m.latchX>>4) & 0x0F
+	case 7:
+		nibble = byte(m.latchX) & 0x0F
+	case 8:
+		nibble = byte(m.latchY>>4) & 0x0F
+	case 9:
+		nibble = byte(m.latchY) & 0x0F
+	}
+
+	// TL acknowledges by following TR once the nibble is ready
+	tl := (m.lines & 0x20) >> 1
+	if m.wait > 0 {
+		m.wait--
+		tl ^= 0x10
+	}
+	return 0xE0 | tl | nibble
+}
+
+// SerializeState writes the protocol state: lines(1) + counter(1) +
+// wait(1) + status(1) + latchX(2) + latchY(2)
+func (m *Mouse) SerializeState(buf []byte) {
+	buf[0] = m.lines
+	buf[1] = m.counter
+	buf[2] = m.wait
+	buf[3] = m.status
+	binary.LittleEndian.PutUint16(buf[4:], uint16(m.latchX))
+	binary.LittleEndian.PutUint16(buf[6:], uint16(m.latchY))
+}
+
+// DeserializeState reads the protocol state.
+func (m *Mouse) DeserializeState(buf []byte) {
+	m.lines = buf[0]
+	m.counter = buf[1]
+	m.wait = buf[2]
+	m.status = buf[3]
+	m.latchX = int16(binary.LittleEndian.Uint16(buf[4:]))
+	m.latchY = int16(binary.LittleEndian.Uint16(buf[6:]))
+	m.dx = 0
+	m.dy = 0
+}
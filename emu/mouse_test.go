@@ -0,0 +1,119 @@
+package emu
+
+import "testing"
+
+// mouseNibbles runs a full Mega Mouse acquisition on port 1 and returns
+// the ten data nibbles, checking that TL acknowledges each TR change.
+func mouseNibbles(t *testing.T, io *IO) []byte {
+	t.Helper()
+	io.WriteRegister(0, 0xA10003, 0x60) // Idle: TH=1, TR=1
+	io.WriteRegister(0, 0xA10009, 0x60) // TH and TR outputs
+
+	nibbles := []byte{io.ReadRegister(0, 0xA10003) & 0x0F}
+	io.WriteRegister(0, 0xA10003, 0x20) // TH=0 starts the acquisition
+	nibbles = append(nibbles, io.ReadRegister(0, 0xA10003)&0x0F)
+
+	tr := byte(0x20)
+	for i := 2; i < 10; i++ {
+		tr ^= 0x20
+		io.WriteRegister(0, 0xA10003, tr)
+		var val byte
+		for n := 0; n <= mouseAckDelay; n++ {
+			val = io.ReadRegister(0, 0xA10003)
+		}
+		if (val&0x10)<<1 != tr {
+			t.Fatalf("nibble %d: TL did not follow TR after the ack delay", i)
+		}
+		nibbles = append(nibbles, val&0x0F)
+	}
+
+	io.WriteRegister(0, 0xA10003, 0x60) // TH=1 ends the acquisition
+	return nibbles
+}
+
+func TestMouse_Acquisition(t *testing.T) {
+	io := newTestIO()
+	io.SetPeripheral(1, PeripheralMouse)
+	io.MouseP1.Move(10, -3, true, false, false, true)
+
+	got := mouseNibbles(t, io)
+	want := []byte{0x0, 0xB, 0xF, 0xF, 0x0, 0x9, 0x0, 0xA, 0x0, 0x3}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("nibble %d: expected 0x%X, got 0x%X", i, want[i], got[i])
+		}
+	}
+
+	// Motion was consumed by the acquisition
+	got = mouseNibbles(t, io)
+	if got[6]|got[7]|got[8]|got[9] != 0 {
+		t.Errorf("expected zero deltas on the second read, got %v", got[6:])
+	}
+}
+
+func TestMouse_SignAndOverflow(t *testing.T) {
+	io := newTestIO()
+	io.SetPeripheral(1, PeripheralMouse)
+	io.MouseP1.Move(-300, 0, false, false, false, false)
+	io.MouseP1.Move(0, 2, false, false, false, false)
+
+	got := mouseNibbles(t, io)
+	if got[4] != 0x07 {
+		t.Errorf("status: expected X sign, Y sign and X overflow (0x7), got 0x%X", got[4])
+	}
+	// -255 as 9-bit two's complement is 0x101
+	if got[6] != 0x0 || got[7] != 0x1 {
+		t.Errorf("X: expected 0x01, got 0x%X%X", got[6], got[7])
+	}
+	// -2 is 0x1FE
+	if got[8] != 0xF || got[9] != 0xE {
+		t.Errorf("Y: expected 0xFE, got 0x%X%X", got[8], got[9])
+	}
+}
+
+func TestMouse_UnplugRestoresPad(t *testing.T) {
+	io := newTestIO()
+	io.SetPeripheral(1, PeripheralMouse)
+	io.SetPeripheral(1, Peripheral3Button)
+	if got := io.ReadRegister(0, 0xA10003); got != 0xFF {
+		t.Errorf("expected the pad response 0xFF, got 0x%02X", got)
+	}
+}
+
+func TestMouse_SerializeRoundTrip(t *testing.T) {
+	io := newTestIO()
+	io.SetPeripheral(2, PeripheralMouse)
+	io.MouseP2.Move(5, 5, false, true, false, false)
+	io.WriteRegister(0, 0xA1000B, 0x60)
+	io.WriteRegister(0, 0xA10005, 0x20)
+	io.WriteRegister(0, 0xA10005, 0x00)
+
+	buf := make([]byte, IOSerializeSize)
+	if err := io.Serialize(buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := newTestIO()
+	if err := restored.Deserialize(buf); err != nil {
+		t.Fatal(err)
+	}
+	if restored.PeripheralType(2) != PeripheralMouse {
+		t.Fatal("port 2 should hold the mouse after restore")
+	}
+	m, r := io.MouseP2, restored.MouseP2
+	if r.counter != m.counter || r.lines != m.lines ||
+		r.latchX != m.latchX || r.latchY != m.latchY || r.wait != m.wait {
+		t.Errorf("mouse state not restored: got %+v, want %+v", r, m)
+	}
+}
+
+func TestEmulator_MouseOption(t *testing.T) {
+	e := createTestEmulator()
+	e.SetOption("port2", "mouse")
+	if e.io.PeripheralType(1) == PeripheralMouse || e.io.PeripheralType(2) != PeripheralMouse {
+		t.Fatal("port2 option should plug the mouse into port 2 only")
+	}
+	e.SetMouseInput(1, 4, 0, true, false, false, false)
+	if e.io.MouseP2.dx != 4 || !e.io.MouseP2.left {
+		t.Error("SetMouseInput should reach the port 2 mouse")
+	}
+}
@@ -0,0 +1,197 @@
+package emu
+
+// Multitap selects a multiplayer adapter.
+//
+// The Sega Team Player sits in port 2 and carries players 2-4 (its fourth
+// socket is left empty); player 1 stays on port 1. The host clocks the pad
+// data out a nibble at a time with a TH/TR handshake:
+//
+//	Read 0     (TH=1):       RLDU = 0011
+//	Read 1     (TH=0, TR=1): RLDU = 1111
+//	Reads 2-3:               RLDU = 0000 (acknowledge)
+//	Reads 4-7:               pad type per socket: 0 = 3-button, 1 = 6-button, F = none
+//	Reads 8-:                Right/Left/Down/Up, Start/A/C/B and, for
+//	                         6-button pads, Mode/X/Y/Z per connected pad
+//
+// TL follows TR on every read so the host can tell when the data is valid.
+//
+// The EA 4-Way Play takes both ports: TH, TR and TL of port 2 select which
+// of the four pads port 1 reads, and selecting a value with TH set returns
+// the adapter ID. Its pads are read as 3-button pads.
+type Multitap uint8
+
+const (
+	MultitapNone Multitap = iota
+	MultitapTeamPlayer
+	MultitapEA4Way
+)
+
+// Team Player pad type nibbles.
+const (
+	tapPad3Button = 0x0
+	tapPad6Button = 0x1
+	tapPadNone    = 0xF
+)
+
+// tapPads returns the pads behind the multitap in socket order.
+func (io *IO) tapPads() [4]*Input {
+	if io.multitap == MultitapTeamPlayer {
+		return [4]*Input{&io.InputP2, &io.InputP3, &io.InputP4, nil}
+	}
+	return [4]*Input{&io.InputP1, &io.InputP2, &io.InputP3, &io.InputP4}
+}
+
+// tapPadType returns the Team Player type nibble of a socket.
+func tapPadType(inp *Input) byte {
+	switch {
+	case inp == nil || !inp.Connected:
+		return tapPadNone
+	case inp.SixButton:
+		return tapPad6Button
+	}
+	return tapPad3Button
+}
+
+// teamPlayerNibble returns the active-high data nibble n of the pad
+// sequence that follows the type nibbles, or 0 past its end.
+func (io *IO) teamPlayerNibble(n int) byte {
+	for _, inp := range io.tapPads() {
+		count := 0
+		switch tapPadType(inp) {
+		case tapPad3Button:
+			count = 2
+		case tapPad6Button:
+			count = 3
+		}
+		if n >= count {
+			n -= count
+			continue
+		}
+		var bits [4]bool
+		switch n {
+		case 0:
+			bits = [4]bool{inp.up, inp.down, inp.left, inp.right}
+		case 1:
+			bits = [4]bool{inp.btnB, inp.btnC, inp.btnA, inp.start}
+		default:
+			bits = [4]bool{inp.btnZ, inp.btnY, inp.btnX, inp.btnMode}
+		}
+		var nibble byte
+		for i, b := range bits {
+			if b {
+				nibble |= 1 << i
+			}
+		}
+		return nibble
+	}
+	return 0
+}
+
+// readTeamPlayer returns the Team Player response on port 2.
+func (io *IO) readTeamPlayer() byte {
+	tl := (io.tapLines & 0x20) >> 1 // TL follows TR
+	var peripheral byte
+	switch c := int(io.tapCounter); {
+	case c == 0:
+		peripheral = 0x73
+	case c == 1:
+		peripheral = 0x3F
+	case c <= 3:
+		peripheral = tl
+	case c <= 7:
+		peripheral = tl | tapPadType(io.tapPads()[c-4])
+	default:
+		peripheral = tl | (^io.teamPlayerNibble(c-8) & 0x0F)
+	}
+	return (io.p2Data & io.p2Ctrl) | (peripheral & ^io.p2Ctrl)
+}
+
+// updateTeamPlayer tracks the TH/TR lines driven on port 2. Raising TH
+// restarts the sequence; any other TH or TR change advances it.
+func (io *IO) updateTeamPlayer() {
+	lines := (io.p2Data&io.p2Ctrl | ^io.p2Ctrl) & 0x60
+	if lines == io.tapLines {
+		return
+	}
+	if lines&0x40 != 0 {
+		io.tapCounter = 0
+	} else if io.tapCounter < 0xFF {
+		io.tapCounter++
+	}
+	io.tapLines = lines
+}
+
+// updateEA4Way latches the pad selected by TH, TR and TL of port 2. The
+// selection only changes while all three are outputs.
+func (io *IO) updateEA4Way() {
+	if io.p2Ctrl&0x70 == 0x70 {
+		io.eaSelect = (io.p2Data >> 4) & 0x07
+	}
+}
+
+// readEA4WayPort1 returns the selected pad on port 1, or the adapter ID
+// while TH of port 2 is part of the selection.
+func (io *IO) readEA4WayPort1() byte {
+	peripheral := byte(0x7C)
+	if io.eaSelect&0x04 == 0 {
+		inp := io.tapPads()[io.eaSelect]
+		th := io.p1Ctrl&0x40 == 0 || io.p1Data&0x40 != 0
+		peripheral = 0xFF
+		if inp.Connected {
+			peripheral = 0xC0 | padBits(inp, th)
+		}
+	}
+	return (io.p1Data & io.p1Ctrl) | (peripheral & ^io.p1Ctrl)
+}
+
+// SetMultitap selects the multiplayer adapter. Selecting an adapter
+// connects the pads behind it.
+func (io *IO) SetMultitap(m Multitap) {
+	io.multitap = m
+	io.tapCounter = 0
+	io.tapLines = 0x60
+	io.eaSelect = 0
+	if m != MultitapNone {
+		io.InputP2.Connected = true
+		io.InputP3.Connected = true
+		io.InputP4.Connected = true
+	}
+}
+
+// tapInput returns the Input of player 2 or 3, the pads only reachable
+// through a multitap.
+func (io *IO) tapInput(player int) *Input {
+	if player == 3 {
+		return &io.InputP4
+	}
+	return &io.InputP3
+}
+
+// setTapPad plugs a 3- or 6-button pad in for player 2 or 3 behind the
+// multitap, or unplugs it.
+func (io *IO) setTapPad(player int, t PeripheralType) {
+	inp := io.tapInput(player)
+	switch t {
+	case PeripheralNone:
+		inp.Connected = false
+	case Peripheral3Button, Peripheral6Button:
+		inp.Connected = true
+		inp.SixButton = t == Peripheral6Button
+	}
+}
+
+// tapPadPeripheral returns the pad type of player 2 or 3.
+func (io *IO) tapPadPeripheral(player int) PeripheralType {
+	switch inp := io.tapInput(player); {
+	case !inp.Connected:
+		return PeripheralNone
+	case inp.SixButton:
+		return Peripheral6Button
+	}
+	return Peripheral3Button
+}
+
+// Multitap returns the selected multiplayer adapter.
+func (io *IO) Multitap() Multitap {
+	return io.multitap
+}
@@ -0,0 +1,167 @@
+package emu
+
+import "testing"
+
+// teamPlayerRead drives TH/TR on port 2 and returns the low five bits
+// (TL and the data nibble) of the Team Player response.
+func teamPlayerRead(io *IO, th, tr bool) byte {
+	var data byte
+	if th {
+		data |= 0x40
+	}
+	if tr {
+		data |= 0x20
+	}
+	io.WriteRegister(0, 0xA10005, data)
+	return io.ReadRegister(0, 0xA10005) & 0x1F
+}
+
+func newTeamPlayerIO() *IO {
+	io := newTestIO()
+	io.SetMultitap(MultitapTeamPlayer)
+	io.WriteRegister(0, 0xA1000B, 0x60) // TH and TR outputs
+	return io
+}
+
+func TestTeamPlayer_Handshake(t *testing.T) {
+	io := newTeamPlayerIO()
+	io.InputP3.SixButton = true
+	io.InputP4.Connected = false
+
+	if got := teamPlayerRead(io, true, true); got != 0x13 {
+		t.Errorf("TH=1: expected 0x13, got 0x%02X", got)
+	}
+	if got := teamPlayerRead(io, false, true); got != 0x1F {
+		t.Errorf("start request: expected 0x1F, got 0x%02X", got)
+	}
+	if got := teamPlayerRead(io, false, false); got != 0x00 {
+		t.Errorf("ack (TR=0): expected 0x00, got 0x%02X", got)
+	}
+	if got := teamPlayerRead(io, false, true); got != 0x10 {
+		t.Errorf("ack (TR=1): expected 0x10, got 0x%02X", got)
+	}
+
+	// Pad types: 3-button, 6-button, none, none (fourth socket empty)
+	want := []byte{0x00, 0x11, 0x0F, 0x1F}
+	for i, w := range want {
+		if got := teamPlayerRead(io, false, i%2 != 0); got != w {
+			t.Errorf("type %d: expected 0x%02X, got 0x%02X", i, w, got)
+		}
+	}
+}
+
+func TestTeamPlayer_PadData(t *testing.T) {
+	io := newTeamPlayerIO()
+	io.InputP2.Set(true, false, false, true, false, false, false, true, false, false, false, false)
+	io.InputP3.SixButton = true
+	io.InputP3.Set(false, false, false, false, true, false, false, false, false, false, true, true)
+
+	teamPlayerRead(io, true, true)
+	tr := true
+	next := func() byte {
+		tr = !tr
+		return teamPlayerRead(io, false, tr) & 0x0F
+	}
+	for i := 0; i < 7; i++ {
+		next() // Start request, acknowledge, pad types
+	}
+
+	// Player 2 (3-button): Up+Right, then Start
+	if got := next(); got != 0x06 {
+		t.Errorf("P2 directions: expected 0x06, got 0x%02X", got)
+	}
+	if got := next(); got != 0x07 {
+		t.Errorf("P2 SACB: expected 0x07, got 0x%02X", got)
+	}
+	// Player 3 (6-button): A, then Z and Mode
+	if got := next(); got != 0x0F {
+		t.Errorf("P3 directions: expected 0x0F, got 0x%02X", got)
+	}
+	if got := next(); got != 0x0B {
+		t.Errorf("P3 SACB: expected 0x0B, got 0x%02X", got)
+	}
+	if got := next(); got != 0x06 {
+		t.Errorf("P3 MXYZ: expected 0x06, got 0x%02X", got)
+	}
+
+	// TH high restarts the sequence
+	if got := teamPlayerRead(io, true, true); got != 0x13 {
+		t.Errorf("TH=1 should reset the sequence, got 0x%02X", got)
+	}
+}
+
+func TestEA4Way_SelectsPad(t *testing.T) {
+	io := newTestIO()
+	io.SetMultitap(MultitapEA4Way)
+	io.WriteRegister(0, 0xA10009, 0x40) // Port 1 TH output
+	io.WriteRegister(0, 0xA1000B, 0x70) // Port 2 TH/TR/TL outputs
+	io.InputP3.Set(false, true, false, false, false, false, false, false, false, false, false, false)
+
+	// Detection: TH of port 2 set
+	io.WriteRegister(0, 0xA10005, 0x40)
+	if got := io.ReadRegister(0, 0xA10003) & 0x3F; got != 0x3C {
+		t.Errorf("ID: expected 0x3C, got 0x%02X", got)
+	}
+
+	// Select pad 3 (player 3) and read it with TH=1
+	io.WriteRegister(0, 0xA10005, 0x20)
+	io.WriteRegister(0, 0xA10003, 0x40)
+	if got := io.ReadRegister(0, 0xA10003) & 0x3F; got != 0x3D {
+		t.Errorf("pad 3: expected Down pressed (0x3D), got 0x%02X", got)
+	}
+
+	// Pad 1 has nothing pressed
+	io.WriteRegister(0, 0xA10005, 0x00)
+	if got := io.ReadRegister(0, 0xA10003) & 0x3F; got != 0x3F {
+		t.Errorf("pad 1: expected 0x3F, got 0x%02X", got)
+	}
+}
+
+func TestMultitap_SerializeRoundTrip(t *testing.T) {
+	io := newTeamPlayerIO()
+	teamPlayerRead(io, true, true)
+	teamPlayerRead(io, false, true)
+	teamPlayerRead(io, false, false)
+
+	buf := make([]byte, IOSerializeSize)
+	if err := io.Serialize(buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := newTestIO()
+	if err := restored.Deserialize(buf); err != nil {
+		t.Fatal(err)
+	}
+	if restored.multitap != MultitapTeamPlayer || restored.tapCounter != io.tapCounter ||
+		restored.tapLines != io.tapLines || !restored.InputP4.Connected {
+		t.Errorf("multitap state not restored: %+v", restored)
+	}
+}
+
+func TestEmulator_SetInputPlayers3And4(t *testing.T) {
+	e := createTestEmulator()
+	e.SetOption("multitap", "teamplayer")
+	if e.io.Multitap() != MultitapTeamPlayer {
+		t.Fatal("multitap option should select the Team Player")
+	}
+	e.SetInput(2, 1<<7)
+	e.SetInput(3, 1<<4)
+	if !e.io.InputP3.start || !e.io.InputP4.btnA {
+		t.Error("players 3 and 4 should reach their pads")
+	}
+}
+
+func TestEmulator_SetPeripheralPlayers3And4(t *testing.T) {
+	e := createTestEmulator()
+	e.SetMultitap(MultitapEA4Way)
+	e.SetPeripheral(2, Peripheral6Button)
+	e.SetPeripheral(3, PeripheralNone)
+	if got := e.Peripheral(2); got != Peripheral6Button {
+		t.Errorf("player 3: expected a 6-button pad, got %d", got)
+	}
+	if got := e.Peripheral(3); got != PeripheralNone {
+		t.Errorf("player 4: expected no pad, got %d", got)
+	}
+	if !e.io.InputP3.SixButton || e.io.InputP4.Connected {
+		t.Error("the pads behind the multitap should follow SetPeripheral")
+	}
+}
@@ -0,0 +1,323 @@
+package emu
+
+import "encoding/binary"
+
+// Peripheral is a device plugged into controller port 1 or 2, or the EXT
+// port (3).
+//
+// The port's data register drives the pins configured as outputs in its
+// ctrl register; IO merges those with the device's response on the input
+// pins. Devices only see the register values, so they apply the same rule
+// themselves when they watch host-driven lines: an input pin reads as
+// pulled high.
+//
+// The Team Player, EA 4-Way Play and light guns span both ports or need
+// the VDP, so IO models them directly and they take precedence over the
+// port's peripheral.
+type Peripheral interface {
+	// Read returns the device's levels on the port pins (bits 6-0, plus
+	// bit 7 which reads high on an idle port). Only pins configured as
+	// inputs reach the host. cycle is the current M68K cycle count.
+	Read(cycle uint64, data, ctrl byte) byte
+
+	// WriteData is called after the host writes the port's data register.
+	WriteData(cycle uint64, data, ctrl byte)
+
+	// WriteCtrl is called after the host writes the port's ctrl register.
+	WriteCtrl(cycle uint64, data, ctrl byte)
+}
+
+// Compile-time interface checks.
+var (
+	_ PeripheralState = (*Pad)(nil)
+	_ PeripheralState = (*Mouse)(nil)
+	_ Peripheral      = (*Pad)(nil)
+	_ Peripheral      = (*Mouse)(nil)
+)
+
+// PeripheralState is implemented by peripherals with protocol state that
+// belongs in save states. The state must fit in peripheralStateSize bytes.
+type PeripheralState interface {
+	SerializeState(buf []byte)
+	DeserializeState(buf []byte)
+}
+
+// THDriver is implemented by peripherals that drive TH as an input, such
+// as a link cable on the EXT port. IO samples TH once per scanline while
+// the pin is an input and raises the level 2 external interrupt on a
+// falling edge when the port's ctrl register enables TH interrupts
+// (bit 7).
+type THDriver interface {
+	TH() bool
+}
+
+// peripheralStateSize is the save state slot reserved for each port's
+// peripheral.
+const peripheralStateSize = 16
+
+// PeripheralType identifies the device plugged into a port.
+type PeripheralType uint8
+
+const (
+	PeripheralNone     PeripheralType = iota // Empty port: all pins float high
+	Peripheral3Button                        // 3-button control pad
+	Peripheral6Button                        // 6-button control pad
+	PeripheralMouse                          // Sega Mega Mouse
+	PeripheralExternal                       // Device attached with AttachPeripheral
+)
+
+// ParsePeripheralType maps a core option value to a peripheral type.
+func ParsePeripheralType(value string) (PeripheralType, bool) {
+	switch value {
+	case "none":
+		return PeripheralNone, true
+	case "3button":
+		return Peripheral3Button, true
+	case "6button":
+		return Peripheral6Button, true
+	case "mouse":
+		return PeripheralMouse, true
+	}
+	return PeripheralNone, false
+}
+
+// emptyPort is a port with nothing plugged in: all pins float high.
+type emptyPort struct{}
+
+func (emptyPort) Read(cycle uint64, data, ctrl byte) byte { return 0xFF }
+func (emptyPort) WriteData(cycle uint64, data, ctrl byte) {}
+func (emptyPort) WriteCtrl(cycle uint64, data, ctrl byte) {}
+
+// Pad is a Genesis control pad. Input selects whether it is plugged in and
+// whether it is a 3- or 6-button pad.
+//
+// The 6-button pad uses an 8-state machine driven by TH transitions:
+//
+//	State 0,2,4 (TH=1): C, B, Right, Left, Down, Up
+//	State 1,3   (TH=0): Start, A, 0, 0, Down, Up
+//	State 5     (TH=0): Start, A, 0, 0, 0, 0  (detection: bits 3-0 all zero)
+//	State 6     (TH=1): C, B, Mode, X, Y, Z    (extra buttons)
+//	State 7     (TH=0): Start, A, 1, 1, 1, 1   (end marker)
+type Pad struct {
+	in *Input
+
+	thState    uint8  // Current state counter (0-7)
+	lastTHHigh bool   // Previous TH value for edge detection
+	lastCycle  uint64 // M68K cycle of last TH transition
+}
+
+// newPad creates a pad reading the given input with TH pulled high.
+func newPad(in *Input) *Pad {
+	return &Pad{in: in, lastTHHigh: true}
+}
+
+// Read returns the pad response for the current TH level or 6-button
+// state.
+func (p *Pad) Read(cycle uint64, data, ctrl byte) byte {
+	// No controller: all peripheral pins float high
+	if !p.in.Connected {
+		return 0xFF
+	}
+
+	// Determine TH: if output (ctrl bit 6=1), use data register; else pulled high
+	th := ctrl&0x40 == 0 || data&0x40 != 0
+
+	// Controller response (active low: 0=pressed, 1=released)
+	var peripheral byte = 0xC0 // Bits 7,6 pulled high
+
+	if !p.in.SixButton {
+		// 3-button controller: no state machine, just TH level
+		return peripheral | padBits(p.in, th)
+	}
+
+	// 6-button controller: state-machine driven
+	// Check timeout: reset state if too much time has elapsed since last TH edge
+	if cycle > 0 && p.lastCycle > 0 && cycle-p.lastCycle >= sixButtonTimeoutCycles {
+		p.thState = 0
+		p.lastTHHigh = true
+	}
+
+	switch p.thState {
+	case 0, 2, 4:
+		// TH=1: C, B, Right, Left, Down, Up
+		peripheral |= padBits(p.in, true)
+	case 1, 3:
+		// TH=0: Start, A, 0, 0, Down, Up
+		peripheral |= padBits(p.in, false)
+	case 5:
+		// TH=0, detection: Start, A, 0, 0, 0, 0 (bits 3-0 all zero)
+		peripheral |= padBits(p.in, false) & 0x30
+	case 6:
+		// TH=1, extra buttons: C, B, Mode, X, Y, Z
+		peripheral |= padBits(p.in, true) & 0x30
+		peripheral |= 0x0F
+		if p.in.btnZ {
+			peripheral &^= 0x01
+		}
+		if p.in.btnY {
+			peripheral &^= 0x02
+		}
+		if p.in.btnX {
+			peripheral &^= 0x04
+		}
+		if p.in.btnMode {
+			peripheral &^= 0x08
+		}
+	case 7:
+		// TH=0, end marker: Start, A, 1, 1, 1, 1 (bits 3-0 all one)
+		peripheral |= padBits(p.in, false)&0x30 | 0x0F
+	}
+
+	return peripheral
+}
+
+// WriteData tracks TH edges to advance the 6-button state counter.
+func (p *Pad) WriteData(cycle uint64, data, ctrl byte) {
+	if !p.in.SixButton || !p.in.Connected {
+		return
+	}
+
+	// TH pulled high when configured as input
+	newTH := ctrl&0x40 == 0 || data&0x40 != 0
+	if newTH == p.lastTHHigh {
+		return
+	}
+
+	// Check timeout: reset counter and TH tracking if enough time has
+	// elapsed. Resetting lastTHHigh to true (idle/pulled-high) ensures the
+	// first TH=1 write after timeout is not an edge, keeping state 0
+	// aligned with TH=1 reads.
+	if cycle > 0 && p.lastCycle > 0 && cycle-p.lastCycle >= sixButtonTimeoutCycles {
+		p.thState = 0
+		p.lastTHHigh = true
+	}
+	// Re-check after potential reset: only advance if still an edge
+	if newTH != p.lastTHHigh {
+		p.thState = (p.thState + 1) & 0x07
+		p.lastTHHigh = newTH
+		p.lastCycle = cycle
+	}
+}
+
+// WriteCtrl does nothing: the pad only counts TH edges written through
+// the data register.
+func (p *Pad) WriteCtrl(cycle uint64, data, ctrl byte) {}
+
+// SerializeState writes the 6-button state machine:
+// thState(1) + lastTHHigh(1) + lastCycle(8)
+func (p *Pad) SerializeState(buf []byte) {
+	buf[0] = p.thState
+	buf[1] = boolByte(p.lastTHHigh)
+	binary.LittleEndian.PutUint64(buf[2:], p.lastCycle)
+}
+
+// DeserializeState reads the 6-button state machine.
+func (p *Pad) DeserializeState(buf []byte) {
+	p.thState = buf[0]
+	p.lastTHHigh = buf[1] != 0
+	p.lastCycle = binary.LittleEndian.Uint64(buf[2:])
+}
+
+// padBits returns a 3-button pad's active-low response in bits 5-0 for a
+// TH level: TH=1 C, B, Right, Left, Down, Up; TH=0 Start, A, 0, 0, Down, Up.
+func padBits(inp *Input, th bool) byte {
+	var pressed byte
+	if inp.up {
+		pressed |= 0x01
+	}
+	if inp.down {
+		pressed |= 0x02
+	}
+	if th {
+		if inp.left {
+			pressed |= 0x04
+		}
+		if inp.right {
+			pressed |= 0x08
+		}
+		if inp.btnB {
+			pressed |= 0x10
+		}
+		if inp.btnC {
+			pressed |= 0x20
+		}
+		return 0x3F &^ pressed
+	}
+	if inp.btnA {
+		pressed |= 0x10
+	}
+	if inp.start {
+		pressed |= 0x20
+	}
+	return 0x33 &^ pressed
+}
+
+// port returns the pad, mouse and peripheral slot of port 1, 2 or 3.
+// The EXT port (3) has no pad or mouse.
+func (io *IO) port(port int) (pad *Pad, mouse *Mouse, slot *Peripheral) {
+	switch port {
+	case 2:
+		return io.pad2, &io.MouseP2, &io.port2
+	case 3:
+		return nil, nil, &io.port3
+	}
+	return io.pad1, &io.MouseP1, &io.port1
+}
+
+// SetPeripheral plugs a pad or mouse into port 1 or 2, or empties the
+// port. The pad types also set the port's Input configuration.
+func (io *IO) SetPeripheral(port int, t PeripheralType) {
+	pad, mouse, slot := io.port(port)
+	if pad == nil {
+		if t == PeripheralNone {
+			*slot = emptyPort{}
+		}
+		return
+	}
+	switch t {
+	case PeripheralMouse:
+		mouse.reset()
+		*slot = mouse
+		return
+	case PeripheralNone:
+		pad.in.Connected = false
+	case Peripheral3Button, Peripheral6Button:
+		pad.in.Connected = true
+		pad.in.SixButton = t == Peripheral6Button
+	default:
+		return
+	}
+	*pad = *newPad(pad.in)
+	*slot = pad
+}
+
+// AttachPeripheral plugs a device implemented outside this package into
+// port 1, 2 or 3 (EXT), or empties the port when p is nil. Its state is
+// saved if it implements PeripheralState.
+func (io *IO) AttachPeripheral(port int, p Peripheral) {
+	_, _, slot := io.port(port)
+	if p == nil {
+		p = emptyPort{}
+	}
+	*slot = p
+}
+
+// PeripheralType returns the device plugged into port 1, 2 or 3.
+func (io *IO) PeripheralType(port int) PeripheralType {
+	_, _, slot := io.port(port)
+	switch p := (*slot).(type) {
+	case *Pad:
+		switch {
+		case !p.in.Connected:
+			return PeripheralNone
+		case p.in.SixButton:
+			return Peripheral6Button
+		}
+		return Peripheral3Button
+	case *Mouse:
+		return PeripheralMouse
+	case emptyPort:
+		return PeripheralNone
+	}
+	return PeripheralExternal
+}
@@ -0,0 +1,112 @@
+package emu
+
+import "testing"
+
+func TestPeripheral_MixedPadTypes(t *testing.T) {
+	e := createTestEmulator()
+	e.SetOption("port1", "6button")
+	e.SetOption("port2", "3button")
+
+	if got := e.io.PeripheralType(1); got != Peripheral6Button {
+		t.Errorf("port 1: expected 6-button pad, got %d", got)
+	}
+	if got := e.io.PeripheralType(2); got != Peripheral3Button {
+		t.Errorf("port 2: expected 3-button pad, got %d", got)
+	}
+
+	// Port 1 reaches the 6-button detection state, port 2 stays a plain
+	// 3-button pad for the same TH sequence.
+	io := e.io
+	io.WriteRegister(0, 0xA10009, 0x40)
+	io.WriteRegister(0, 0xA1000B, 0x40)
+	var p1, p2 byte
+	for _, th := range []byte{0x40, 0x00, 0x40, 0x00, 0x40, 0x00} {
+		io.WriteRegister(1, 0xA10003, th)
+		io.WriteRegister(1, 0xA10005, th)
+		p1 = io.ReadRegister(1, 0xA10003)
+		p2 = io.ReadRegister(1, 0xA10005)
+	}
+	if p1&0x0F != 0x00 {
+		t.Errorf("port 1: expected 6-button detection (bits 3-0 zero), got 0x%02X", p1)
+	}
+	if p2&0x0F != 0x03 {
+		t.Errorf("port 2: expected 3-button TH=0 response, got 0x%02X", p2)
+	}
+}
+
+func TestPeripheral_None(t *testing.T) {
+	io := newTestIO()
+	io.InputP1.Set(true, false, false, false, false, false, false, false, false, false, false, false)
+	io.SetPeripheral(1, PeripheralNone)
+	if got := io.ReadRegister(0, 0xA10003); got != 0xFF {
+		t.Errorf("empty port: expected 0xFF, got 0x%02X", got)
+	}
+	if got := io.PeripheralType(1); got != PeripheralNone {
+		t.Errorf("expected PeripheralNone, got %d", got)
+	}
+}
+
+// testDevice is a peripheral defined outside the built-in set.
+type testDevice struct {
+	lastData byte
+	writes   int
+}
+
+func (d *testDevice) Read(cycle uint64, data, ctrl byte) byte { return 0xA5 }
+func (d *testDevice) WriteData(cycle uint64, data, ctrl byte) {
+	d.lastData = data
+	d.writes++
+}
+func (d *testDevice) WriteCtrl(cycle uint64, data, ctrl byte) {}
+func (d *testDevice) SerializeState(buf []byte)               { buf[0] = byte(d.writes) }
+func (d *testDevice) DeserializeState(buf []byte)             { d.writes = int(buf[0]) }
+
+func TestPeripheral_AttachExternal(t *testing.T) {
+	io := newTestIO()
+	dev := &testDevice{}
+	io.AttachPeripheral(2, dev)
+	io.WriteRegister(0, 0xA1000B, 0x0F)
+	io.WriteRegister(0, 0xA10005, 0x0A)
+
+	if got := io.ReadRegister(0, 0xA10005); got != 0xAA {
+		t.Errorf("expected device pins merged with outputs (0xAA), got 0x%02X", got)
+	}
+	if dev.lastData != 0x0A || io.PeripheralType(2) != PeripheralExternal {
+		t.Error("device should see data writes and report as external")
+	}
+
+	buf := make([]byte, IOSerializeSize)
+	if err := io.Serialize(buf); err != nil {
+		t.Fatal(err)
+	}
+	dev.writes = 0
+	if err := io.Deserialize(buf); err != nil {
+		t.Fatal(err)
+	}
+	if dev.writes != 1 {
+		t.Errorf("external device state not restored: writes=%d", dev.writes)
+	}
+}
+
+func TestPeripheral_SerializePadState(t *testing.T) {
+	io := newTestIO()
+	io.SetPeripheral(1, Peripheral6Button)
+	io.WriteRegister(0, 0xA10009, 0x40)
+	io.WriteRegister(100, 0xA10003, 0x00)
+	io.WriteRegister(200, 0xA10003, 0x40)
+
+	buf := make([]byte, IOSerializeSize)
+	if err := io.Serialize(buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := newTestIO()
+	if err := restored.Deserialize(buf); err != nil {
+		t.Fatal(err)
+	}
+	if restored.PeripheralType(1) != Peripheral6Button {
+		t.Error("port 1 should restore as a 6-button pad")
+	}
+	if restored.pad1.thState != 2 || restored.pad1.lastCycle != 200 {
+		t.Errorf("pad state not restored: state=%d cycle=%d", restored.pad1.thState, restored.pad1.lastCycle)
+	}
+}
@@ -62,8 +62,12 @@ const (
 // Character format is checked first. If no character codes are found, the
 // first non-space byte is parsed as a hex digit.
 // For multi-region ROMs, priority is U > J > E.
-// Returns ConsoleUSA for unknown or missing region data.
+// Returns ConsoleUSA for unknown or missing region data. Master System
+// ROMs use the region code of their SMS header instead.
 func DetectConsoleRegion(rom []byte) ConsoleRegion {
+	if IsSMSROM(rom) {
+		return smsConsoleRegion(rom)
+	}
 	if len(rom) < 0x200 {
 		return ConsoleUSA
 	}
@@ -0,0 +1,115 @@
+package emu
+
+// RAMInit selects what main RAM and Z80 RAM hold after HardReset. Real
+// DRAM powers up with no defined contents; some games read it before
+// clearing it, so the pattern can change what they do.
+type RAMInit int
+
+const (
+	RAMInitZero   RAMInit = iota // All bytes 0x00, as NewEmulator leaves them
+	RAMInitOnes                  // All bytes 0xFF
+	RAMInitRandom                // Pseudo-random bytes seeded from the ROM CRC, the same every run
+)
+
+// SoftReset presses the console's reset button. The 68000 restarts from
+// its reset vector, the Z80 is held in reset with the YM2612, and the
+// cartridge mapper and TMSS lock return to their power-on state. RAM, the
+// VDP, the PSG, the I/O ports and SRAM are kept, which is how games tell a
+// reset from a power-on. In Master System mode the Z80 and the Sega mapper
+// are reset.
+func (e *Emulator) SoftReset() {
+	e.frame.stopped = false
+	if e.sms != nil {
+		e.sms.reset()
+		e.resetZ80()
+		return
+	}
+
+	e.bus.mapper.Reset()
+	e.bus.tmss.reset()
+	e.bus.z80Reset = false
+	e.bus.z80BusRequested = false
+	e.bus.z80PendingReset = false
+	e.ym2612.reset()
+	e.resetZ80()
+	e.resetM68K()
+}
+
+// HardReset power cycles the console with init in main RAM and Z80 RAM.
+// Every component returns to its power-on state and the timeline restarts
+// from zero. SRAM and EEPROM contents, which are battery-backed, are kept,
+// as are the region, core options, peripherals, cheats and debug hook.
+func (e *Emulator) HardReset(init RAMInit) {
+	e.fillRAM(init)
+
+	e.bus.sramEnabled = false
+	e.bus.sramWritable = false
+	if e.bus.eeprom != nil {
+		e.bus.eeprom.reset()
+	}
+	e.bus.debugBreak = false
+	e.z80Mem.bankRegister = 0
+	e.vdp.reset()
+	e.psg.Reset()
+	e.io.reset()
+
+	e.z80IntPending = false
+	e.filterPrevL = 0
+	e.filterPrevR = 0
+	e.frameCount = 0
+	e.lineCount = 0
+	e.masterCycles = 0
+	e.m68kCycles = 0
+	e.z80Cycles = 0
+
+	e.SoftReset()
+	e.lineM68K = e.m68k.Cycles()
+}
+
+// resetZ80 resets the Z80 and releases its interrupt line.
+func (e *Emulator) resetZ80() {
+	cycles := e.z80.Cycles()
+	e.z80.Reset()
+	e.z80.AddCycles(cycles)
+	e.z80IntPending = false
+	e.z80.INT(false, 0xFF)
+}
+
+// resetM68K restarts the 68000 from its reset vector. The cycle counter
+// keeps running: the VDP and I/O time events against it.
+func (e *Emulator) resetM68K() {
+	cycles := e.m68k.Cycles()
+	e.m68k.Reset()
+	e.m68k.AddCycles(cycles)
+}
+
+// fillRAM writes the power-on pattern to main RAM and Z80 RAM.
+func (e *Emulator) fillRAM(init RAMInit) {
+	switch init {
+	case RAMInitOnes:
+		for i := range e.bus.ram {
+			e.bus.ram[i] = 0xFF
+		}
+		for i := range e.bus.z80RAM {
+			e.bus.z80RAM[i] = 0xFF
+		}
+	case RAMInitRandom:
+		// xorshift32; the seed must be non-zero
+		x := e.bus.romCRC | 1
+		next := func() byte {
+			x ^= x << 13
+			x ^= x >> 17
+			x ^= x << 5
+			return byte(x)
+		}
+		for i := range e.bus.ram {
+			e.bus.ram[i] = next()
+		}
+		for i := range e.bus.z80RAM {
+			e.bus.z80RAM[i] = next()
+		}
+	default:
+		e.bus.ram = [mainRAMSize]byte{}
+		e.bus.z80RAM = [z80RAMSize]byte{}
+	}
+}
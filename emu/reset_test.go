@@ -0,0 +1,141 @@
+package emu
+
+import (
+	"testing"
+
+	"github.com/user-none/go-chip-m68k"
+)
+
+func TestSoftReset_KeepsRAMAndVDP(t *testing.T) {
+	e := createTestEmulator()
+	e.RunFrame()
+	e.bus.WriteCycle(0, m68k.Byte, 0xFF0000, 0xAB)
+	e.bus.z80RAM[0x10] = 0xCD
+	e.vdp.writeRegister(1, 0x44)
+	e.bus.WriteCycle(0, m68k.Word, 0xA11200, 0x0100) // Release Z80 reset
+	e.ym2612.writeRegister(0, 0x2B, 0x80)            // DAC on
+	timeline := e.Timeline()
+
+	e.SoftReset()
+
+	if got := e.bus.ReadCycle(0, m68k.Byte, 0xFF0000); got != 0xAB {
+		t.Errorf("main RAM: expected 0xAB, got 0x%02X", got)
+	}
+	if e.bus.z80RAM[0x10] != 0xCD {
+		t.Error("Z80 RAM should be kept")
+	}
+	if e.vdp.regs[1] != 0x44 {
+		t.Errorf("VDP register 1: expected 0x44, got 0x%02X", e.vdp.regs[1])
+	}
+	if pc := e.M68KRegisters().PC; pc != 0x200 {
+		t.Errorf("68K PC: expected reset vector 0x200, got 0x%06X", pc)
+	}
+	if e.bus.z80Reset {
+		t.Error("Z80 should be held in reset")
+	}
+	if e.ym2612.dacEnable {
+		t.Error("YM2612 should be reset with the Z80")
+	}
+	if e.Timeline() != timeline {
+		t.Error("a soft reset should not restart the timeline")
+	}
+}
+
+func TestSoftReset_RelocksTMSS(t *testing.T) {
+	e := createTestEmulator()
+	e.SetTMSS(true)
+	e.bus.WriteCycle(0, m68k.Long, 0xA14000, 0x53454741) // "SEGA"
+	if !e.bus.tmss.unlocked {
+		t.Fatal("expected TMSS unlocked")
+	}
+	e.SoftReset()
+	if e.bus.tmss.unlocked {
+		t.Error("reset should lock the VDP until the game writes SEGA again")
+	}
+}
+
+func TestSoftReset_ClearsDebugStop(t *testing.T) {
+	e := createTestEmulator()
+	e.SetDebugHook(&stopAt{pc: 0x202})
+	e.RunFrame()
+	if !e.DebugStopped() {
+		t.Fatal("expected stop")
+	}
+	e.SoftReset()
+	if e.DebugStopped() {
+		t.Error("reset should discard a mid-frame stop")
+	}
+}
+
+func TestSoftReset_SMS(t *testing.T) {
+	e := makeSMSEmulator(t)
+	e.RunFrame()
+	e.sms.Write(0xFFFF, 3)
+	e.sms.Write(0xC100, 0x5A)
+
+	e.SoftReset()
+	if e.sms.Read(0x8000) != 2 {
+		t.Errorf("slot 2 should map bank 2 after reset, read %d", e.sms.Read(0x8000))
+	}
+	if e.sms.Read(0xC100) != 0x5A {
+		t.Error("work RAM should be kept")
+	}
+	if pc := e.z80.Registers().PC; pc != 0 {
+		t.Errorf("Z80 PC: expected 0, got 0x%04X", pc)
+	}
+}
+
+func TestHardReset_PowerOnState(t *testing.T) {
+	e := createTestEmulator()
+	e.bus.sram = []byte{1, 2, 3, 4}
+	e.RunFrame()
+	e.bus.WriteCycle(0, m68k.Byte, 0xFF0000, 0xAB)
+	e.vdp.writeRegister(1, 0x44)
+	e.io.WriteRegister(0, 0xA10009, 0x40)
+
+	e.HardReset(RAMInitZero)
+
+	if got := e.bus.ReadCycle(0, m68k.Byte, 0xFF0000); got != 0 {
+		t.Errorf("main RAM: expected 0, got 0x%02X", got)
+	}
+	if e.vdp.regs[1] != 0 {
+		t.Errorf("VDP register 1: expected 0, got 0x%02X", e.vdp.regs[1])
+	}
+	if got := e.io.ReadRegister(0, 0xA10009); got != 0 {
+		t.Errorf("port 1 ctrl: expected 0, got 0x%02X", got)
+	}
+	if e.bus.sram[2] != 3 {
+		t.Error("battery-backed SRAM should be kept")
+	}
+	if tl := e.Timeline(); tl != (Timeline{}) {
+		t.Errorf("expected the timeline to restart, got %+v", tl)
+	}
+	if pc := e.M68KRegisters().PC; pc != 0x200 {
+		t.Errorf("68K PC: expected 0x200, got 0x%06X", pc)
+	}
+}
+
+func TestHardReset_RAMInit(t *testing.T) {
+	e := createTestEmulator()
+
+	e.HardReset(RAMInitOnes)
+	if e.bus.ram[0] != 0xFF || e.bus.ram[mainRAMSize-1] != 0xFF || e.bus.z80RAM[0] != 0xFF {
+		t.Error("expected RAM filled with 0xFF")
+	}
+
+	e.HardReset(RAMInitRandom)
+	first := e.bus.ram
+	zeros := 0
+	for _, b := range first {
+		if b == 0 {
+			zeros++
+		}
+	}
+	if zeros > mainRAMSize/64 {
+		t.Errorf("random RAM has %d zero bytes", zeros)
+	}
+	e.HardReset(RAMInitRandom)
+	if e.bus.ram != first {
+		t.Error("random RAM should be the same on every power-on")
+	}
+}
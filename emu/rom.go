@@ -15,7 +15,7 @@ func ValidateSystemType(rom []byte) error {
 
 	sysType := strings.TrimRight(string(rom[0x100:0x110]), " ")
 	switch sysType {
-	case "SEGA MEGA DRIVE", "SEGA GENESIS":
+	case "SEGA MEGA DRIVE", "SEGA GENESIS", "SEGA SSF":
 		return nil
 	default:
 		return fmt.Errorf("unrecognized system type: %q", sysType)
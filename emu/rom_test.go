@@ -47,6 +47,13 @@ func TestValidateSystemType_Genesis(t *testing.T) {
 	}
 }
 
+func TestValidateSystemType_SSF(t *testing.T) {
+	rom := makeValidationROM("SEGA SSF")
+	if err := ValidateSystemType(rom); err != nil {
+		t.Errorf("expected valid, got: %v", err)
+	}
+}
+
 func TestValidateSystemType_Invalid(t *testing.T) {
 	rom := makeValidationROM("NOT A GENESIS")
 	if err := ValidateSystemType(rom); err == nil {
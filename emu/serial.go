@@ -0,0 +1,191 @@
+package emu
+
+import "encoding/binary"
+
+// SerialBackend carries the bytes of a port's serial (UART) mode to and
+// from the outside world. Receive must not block: it returns false when
+// no byte is waiting.
+type SerialBackend interface {
+	Transmit(b byte)
+	Receive() (b byte, ok bool)
+}
+
+// Serial control register (S-Ctrl) bits.
+//
+//	Bits 7-6: baud rate (0 = 4800, 1 = 2400, 2 = 1200, 3 = 300)
+//	Bit 5:    SIN, TR is the serial input
+//	Bit 4:    SOUT, TL is the serial output
+//	Bit 3:    Rint, raise the external interrupt when a byte arrives
+//	Bit 2:    RERR, receive error (read only)
+//	Bit 1:    RRDY, received byte waiting in RxData (read only)
+//	Bit 0:    TFUL, TxData still shifting out (read only)
+const (
+	sctrlSIN  = 0x20
+	sctrlSOUT = 0x10
+	sctrlRint = 0x08
+	sctrlRERR = 0x04
+	sctrlRRDY = 0x02
+	sctrlTFUL = 0x01
+)
+
+// serialFrameBits is the length of an 8N1 frame: start, 8 data, stop.
+const serialFrameBits = 10
+
+// serialBaud maps the S-Ctrl baud field to bits per second.
+var serialBaud = [4]int{4800, 2400, 1200, 300}
+
+// serialSerializeSize is the per-port serial state in save states:
+// tx(1) + rx(1) + sctrl(1) + txDoneAt(8) + thHigh(1)
+const serialSerializeSize = 12
+
+// serialPort is the UART and TH interrupt state of one controller port.
+type serialPort struct {
+	tx       byte   // Last byte written to TxData
+	rx       byte   // Last byte received into RxData
+	sctrl    byte   // Writable S-Ctrl bits plus RERR and RRDY
+	txDoneAt uint64 // M68K cycle when the byte in TxData finishes shifting out
+	thHigh   bool   // TH level at the last sample, for falling edges
+
+	backend SerialBackend
+}
+
+// serialPort returns the serial state of port 1, 2 or 3.
+func (io *IO) serialPort(port int) *serialPort {
+	return &io.serial[port-1]
+}
+
+// SetSerialBackend connects the serial lines of port 1, 2 or 3 (EXT) to a
+// backend, or disconnects them when b is nil.
+func (io *IO) SetSerialBackend(port int, b SerialBackend) {
+	io.serialPort(port).backend = b
+}
+
+// setClock sets the M68K clock used to time serial frames.
+func (io *IO) setClock(hz int) {
+	io.clockHz = hz
+}
+
+// byteCycles returns how many M68K cycles one serial frame takes at the
+// port's baud rate.
+func (io *IO) byteCycles(s *serialPort) uint64 {
+	baud := serialBaud[s.sctrl>>6]
+	return uint64(io.clockHz * serialFrameBits / baud)
+}
+
+// readSerial reads TxData, RxData or S-Ctrl of a port. reg is the register
+// index (0-2). Reading RxData takes the byte and clears RRDY and RERR.
+func (io *IO) readSerial(cycle uint64, port, reg int) byte {
+	s := io.serialPort(port)
+	switch reg {
+	case 0:
+		return s.tx
+	case 1:
+		s.sctrl &^= sctrlRRDY | sctrlRERR
+		return s.rx
+	}
+	val := s.sctrl
+	if cycle < s.txDoneAt {
+		val |= sctrlTFUL
+	}
+	return val
+}
+
+// writeSerial writes TxData or S-Ctrl of a port. A TxData write with SOUT
+// enabled hands the byte to the backend and holds TFUL for one frame time;
+// writes while TFUL is set are dropped. RxData is read only.
+func (io *IO) writeSerial(cycle uint64, port, reg int, val byte) {
+	s := io.serialPort(port)
+	switch reg {
+	case 0:
+		if cycle < s.txDoneAt {
+			return
+		}
+		s.tx = val
+		if s.sctrl&sctrlSOUT == 0 {
+			return
+		}
+		s.txDoneAt = cycle + io.byteCycles(s)
+		if s.backend != nil {
+			s.backend.Transmit(val)
+		}
+	case 2:
+		s.sctrl = val&0xF8 | s.sctrl&(sctrlRERR|sctrlRRDY)
+	}
+}
+
+// serialPins overrides the TL and TR pins of a port's data register read
+// while they carry the serial lines, which idle high.
+func (io *IO) serialPins(port int, val byte) byte {
+	s := io.serialPort(port)
+	if s.sctrl&sctrlSOUT != 0 {
+		val |= 0x10
+	}
+	if s.sctrl&sctrlSIN != 0 {
+		val |= 0x20
+	}
+	return val
+}
+
+// tickPorts polls the serial backends and samples TH on each port. It is
+// called once per scanline and returns true when the level 2 external
+// interrupt should be raised: a byte arrived with Rint enabled, or TH fell
+// on a port whose ctrl register enables TH interrupts (bit 7).
+//
+// A byte is only taken from the backend once the previous one has been
+// read, so the backend buffers instead of the receiver overrunning; RERR
+// is never set.
+func (io *IO) tickPorts(cycle uint64) bool {
+	irq := false
+	for port := 1; port <= 3; port++ {
+		s := io.serialPort(port)
+		if s.backend != nil && s.sctrl&(sctrlSIN|sctrlRRDY) == sctrlSIN {
+			if b, ok := s.backend.Receive(); ok {
+				s.rx = b
+				s.sctrl |= sctrlRRDY
+				irq = irq || s.sctrl&sctrlRint != 0
+			}
+		}
+
+		_, ctrl, dev := io.portLines(port)
+		th := true
+		if d, ok := dev.(THDriver); ok && ctrl&0x40 == 0 {
+			th = d.TH()
+		}
+		if s.thHigh && !th && ctrl&0x80 != 0 {
+			irq = true
+		}
+		s.thHigh = th
+	}
+	return irq && io.vdp.externalIntEnabled()
+}
+
+// portLines returns the data and ctrl registers and the peripheral of
+// port 1, 2 or 3.
+func (io *IO) portLines(port int) (data, ctrl byte, dev Peripheral) {
+	switch port {
+	case 1:
+		return io.p1Data, io.p1Ctrl, io.port1
+	case 2:
+		return io.p2Data, io.p2Ctrl, io.port2
+	}
+	return io.p3Data, io.p3Ctrl, io.port3
+}
+
+// serialize writes the port's serial state (serialSerializeSize bytes).
+func (s *serialPort) serialize(buf []byte) {
+	buf[0] = s.tx
+	buf[1] = s.rx
+	buf[2] = s.sctrl
+	binary.LittleEndian.PutUint64(buf[3:], s.txDoneAt)
+	buf[11] = boolByte(s.thHigh)
+}
+
+// deserialize reads the port's serial state (serialSerializeSize bytes).
+// The backend stays connected.
+func (s *serialPort) deserialize(buf []byte) {
+	s.tx = buf[0]
+	s.rx = buf[1]
+	s.sctrl = buf[2]
+	s.txDoneAt = binary.LittleEndian.Uint64(buf[3:])
+	s.thHigh = buf[11] != 0
+}
@@ -0,0 +1,165 @@
+package emu
+
+import (
+	"bytes"
+	"testing"
+)
+
+// loopSerial is a SerialBackend that records transmitted bytes and hands
+// out queued ones.
+type loopSerial struct {
+	sent []byte
+	recv []byte
+}
+
+func (l *loopSerial) Transmit(b byte) { l.sent = append(l.sent, b) }
+
+func (l *loopSerial) Receive() (byte, bool) {
+	if len(l.recv) == 0 {
+		return 0, false
+	}
+	b := l.recv[0]
+	l.recv = l.recv[1:]
+	return b, true
+}
+
+// thLine is an EXT port device that drives TH.
+type thLine struct {
+	emptyPort
+	high bool
+}
+
+func (t *thLine) TH() bool { return t.high }
+
+func TestSerial_PowerOnRegisters(t *testing.T) {
+	io := newTestIO()
+	for _, port := range []uint32{0xA1000F, 0xA10015, 0xA1001B} {
+		if got := io.ReadRegister(0, port); got != 0xFF {
+			t.Errorf("TxData at 0x%06X: expected 0xFF, got 0x%02X", port, got)
+		}
+	}
+	if got := io.ReadRegister(0, 0xA10007); got != 0xFF {
+		t.Errorf("empty EXT port: expected 0xFF, got 0x%02X", got)
+	}
+}
+
+func TestSerial_EXTDataCtrl(t *testing.T) {
+	io := newTestIO()
+	io.WriteRegister(0, 0xA1000D, 0x40)
+	io.WriteRegister(0, 0xA10007, 0x00)
+	if got := io.ReadRegister(0, 0xA1000D); got != 0x40 {
+		t.Errorf("expected EXT ctrl 0x40, got 0x%02X", got)
+	}
+	if got := io.ReadRegister(0, 0xA10007); got != 0xBF {
+		t.Errorf("expected TH driven low (0xBF), got 0x%02X", got)
+	}
+}
+
+func TestSerial_TransmitHoldsTFUL(t *testing.T) {
+	io := newTestIO()
+	l := &loopSerial{}
+	io.SetSerialBackend(2, l)
+	io.WriteRegister(0, 0xA10019, 0xD0) // 300 baud, SOUT
+
+	io.WriteRegister(100, 0xA10015, 'A')
+	io.WriteRegister(110, 0xA10015, 'B') // dropped while TFUL
+	if got := io.ReadRegister(110, 0xA10019); got&sctrlTFUL == 0 {
+		t.Error("TFUL should be set while the byte shifts out")
+	}
+	done := uint64(100 + 7670454*10/300)
+	if got := io.ReadRegister(done, 0xA10019); got&sctrlTFUL != 0 {
+		t.Error("TFUL should clear after one frame time")
+	}
+	io.WriteRegister(done, 0xA10015, 'C')
+	if !bytes.Equal(l.sent, []byte("AC")) {
+		t.Errorf("expected AC transmitted, got %q", l.sent)
+	}
+	if got := io.ReadRegister(done, 0xA10015); got != 'C' {
+		t.Errorf("expected TxData 'C', got 0x%02X", got)
+	}
+}
+
+func TestSerial_TransmitNeedsSOUT(t *testing.T) {
+	io := newTestIO()
+	l := &loopSerial{}
+	io.SetSerialBackend(1, l)
+	io.WriteRegister(0, 0xA1000F, 'X')
+	if len(l.sent) != 0 {
+		t.Errorf("expected nothing sent in parallel mode, got %q", l.sent)
+	}
+}
+
+func TestSerial_ReceiveInterrupt(t *testing.T) {
+	io := newTestIO()
+	l := &loopSerial{recv: []byte("hi")}
+	io.SetSerialBackend(3, l)
+	io.vdp.writeRegister(11, 0x08)
+
+	if io.tickPorts(0) {
+		t.Error("nothing should be received with SIN off")
+	}
+	io.WriteRegister(0, 0xA1001F, sctrlSIN|sctrlRint)
+	if !io.tickPorts(0) {
+		t.Error("expected the external interrupt on receive")
+	}
+	if io.tickPorts(0) {
+		t.Error("the next byte should wait until RxData is read")
+	}
+	if got := io.ReadRegister(0, 0xA1001F); got&sctrlRRDY == 0 {
+		t.Error("RRDY should be set")
+	}
+	if got := io.ReadRegister(0, 0xA1001D); got != 'h' {
+		t.Errorf("expected 'h', got 0x%02X", got)
+	}
+	if got := io.ReadRegister(0, 0xA1001F); got&sctrlRRDY != 0 {
+		t.Error("reading RxData should clear RRDY")
+	}
+	io.tickPorts(0)
+	if got := io.ReadRegister(0, 0xA1001D); got != 'i' {
+		t.Errorf("expected 'i', got 0x%02X", got)
+	}
+}
+
+func TestSerial_THFallingEdgeInterrupt(t *testing.T) {
+	io := newTestIO()
+	dev := &thLine{high: true}
+	io.AttachPeripheral(3, dev)
+	io.vdp.writeRegister(11, 0x08)
+
+	dev.high = false
+	if io.tickPorts(0) {
+		t.Error("TH interrupts are disabled in the ctrl register")
+	}
+	dev.high = true
+	io.tickPorts(0)
+	io.WriteRegister(0, 0xA1000D, 0x80)
+	dev.high = false
+	if !io.tickPorts(0) {
+		t.Error("expected the external interrupt on a TH falling edge")
+	}
+	if io.tickPorts(0) {
+		t.Error("TH held low should not interrupt again")
+	}
+}
+
+func TestSerial_SerializeRoundTrip(t *testing.T) {
+	io := newTestIO()
+	io.WriteRegister(0, 0xA1000D, 0x80)
+	io.WriteRegister(0, 0xA1001F, 0x90)
+	io.WriteRegister(50, 0xA1001B, 0x42)
+	buf := make([]byte, IOSerializeSize)
+	if err := io.Serialize(buf); err != nil {
+		t.Fatal(err)
+	}
+
+	io2 := newTestIO()
+	if err := io2.Deserialize(buf); err != nil {
+		t.Fatal(err)
+	}
+	if io2.p3Ctrl != 0x80 {
+		t.Errorf("expected EXT ctrl 0x80, got 0x%02X", io2.p3Ctrl)
+	}
+	if io2.serial[2] != io.serial[2] {
+		t.Errorf("serial state mismatch: %+v vs %+v", io2.serial[2], io.serial[2])
+	}
+}
@@ -3,6 +3,7 @@ package emu
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"hash/crc32"
 	"math"
 
@@ -12,20 +13,160 @@ import (
 )
 
 // Save state format constants
+//
+// A state is a header followed by tagged, length-prefixed chunks, one per
+// component, ending with an END chunk:
+//
+//	header: magic(12) + version(2) + romCRC(4) + dataCRC(4)
+//	chunk:  tag(4) + version(2) + length(4) + payload(length)
+//
+// Each chunk carries its component's own version. Older payloads are
+// upgraded by the migrations in serialize_migrate.go before the component
+// reads them, and unknown chunks are skipped. dataCRC covers every chunk
+// up to and including END, so trailing padding (libretro hands back the
+// whole SerializeSize buffer) is ignored. Version 1 states, from before
+// chunkedVersion, use the old fixed-offset layout and are split into
+// chunks on load.
 const (
-	stateVersion    = 1
+	stateVersion    = 2
+	chunkedVersion  = 2 // first state version with chunks
 	stateMagic      = "eMMDSState\x00\x00"
 	stateHeaderSize = 22 // magic(12) + version(2) + romCRC(4) + dataCRC(4)
+	chunkHeaderSize = 10 // tag(4) + version(2) + length(4)
+)
+
+// Chunk tags
+const (
+	chunkM68K   = "M68K"
+	chunkZ80    = "Z80 "
+	chunkBus    = "BUS "
+	chunkZ80Mem = "Z80M"
+	chunkVDP    = "VDP "
+	chunkYM2612 = "FM  "
+	chunkPSG    = "PSG "
+	chunkIO     = "IO  "
+	chunkBase   = "EMU "
+	chunkInfo   = "META" // optional, see stateinfo.go
+	chunkEnd    = "END "
+)
+
+// Versions of the chunks without a version byte of their own
+const (
+	busSerializeVersion    = 2 // 1: RAM and SRAM, 2: + mapper, EEPROM and TMSS
+	z80MemSerializeVersion = 2 // 1: bank register, 2: + SMS mapper and I/O
+	baseSerializeVersion   = 2 // 1: Z80 interrupt and audio filter, 2: + frame counter, scanline, master clock and CPU cycles
 )
 
 // Fixed serialization sizes for inline components
 const (
-	maxSRAMSize           = 0x8000                           // 32KB max SRAM per Sega specs
-	busSerializeFixedSize = mainRAMSize + z80RAMSize + 4 + 5 // ram + z80RAM + sramLen + flags
-	z80MemSerializeSize   = 2                                // bankRegister
-	emulatorSerializeSize = 17                               // z80IntPending(1) + filterPrevL(8) + filterPrevR(8)
+	maxSRAMSize = 0x8000 // 32KB max SRAM per Sega specs
+	// ram + z80RAM + sramLen + flags + mapper + eeprom + tmss
+	busSerializeFixedSize = mainRAMSize + z80RAMSize + 4 + 5 +
+		mapperSerializeSize + eepromSerializeSize + tmssSerializeSize
+	z80MemSerializeSize   = 2 + smsSerializeSize // bankRegister + SMS mapper and I/O
+	emulatorSerializeSize = 57                   // z80IntPending(1) + filterPrevL(8) + filterPrevR(8) + frameCount(8) + lineCount(8) + masterCycles(8) + m68kCycles(8) + z80Cycles(8)
 )
 
+// stateChunk describes how one component is saved. size is the largest
+// payload save can write; save returns the bytes actually written. check
+// reports whether load would reject a payload, without changing e, so a
+// state is checked in full before any component is loaded.
+type stateChunk struct {
+	tag     string
+	version uint16
+	size    int
+	save    func(e *Emulator, buf []byte) (int, error)
+	load    func(e *Emulator, buf []byte) error
+	check   func(e *Emulator, buf []byte) error
+}
+
+// stateChunks lists the components in the order they are saved and
+// restored.
+var stateChunks = []stateChunk{
+	{chunkM68K, 1, m68k.SerializeSize,
+		func(e *Emulator, buf []byte) (int, error) { return m68k.SerializeSize, e.m68k.Serialize(buf) },
+		func(e *Emulator, buf []byte) error { return e.m68k.Deserialize(buf) },
+		func(e *Emulator, buf []byte) error {
+			return checkChipState(chunkM68K, buf, m68k.SerializeSize, e.m68k.Serialize)
+		}},
+	{chunkZ80, 1, z80.SerializeSize,
+		func(e *Emulator, buf []byte) (int, error) { return z80.SerializeSize, e.z80.Serialize(buf) },
+		func(e *Emulator, buf []byte) error { return e.z80.Deserialize(buf) },
+		func(e *Emulator, buf []byte) error {
+			return checkChipState(chunkZ80, buf, z80.SerializeSize, e.z80.Serialize)
+		}},
+	{chunkBus, busSerializeVersion, busSerializeFixedSize + maxSRAMSize,
+		func(e *Emulator, buf []byte) (int, error) { return e.serializeBus(buf), nil },
+		func(e *Emulator, buf []byte) error { return e.deserializeBus(buf) },
+		func(e *Emulator, buf []byte) error { return checkBusState(buf) }},
+	{chunkZ80Mem, z80MemSerializeVersion, z80MemSerializeSize,
+		func(e *Emulator, buf []byte) (int, error) { return e.serializeZ80Mem(buf), nil },
+		func(e *Emulator, buf []byte) error { return e.deserializeZ80Mem(buf) },
+		func(e *Emulator, buf []byte) error { return checkStateSize(buf, z80MemSerializeSize) }},
+	{chunkVDP, vdpSerializeVersion, VDPSerializeSize,
+		func(e *Emulator, buf []byte) (int, error) { return VDPSerializeSize, e.vdp.Serialize(buf) },
+		func(e *Emulator, buf []byte) error { return e.vdp.Deserialize(buf) },
+		func(e *Emulator, buf []byte) error { return checkVDPState(buf) }},
+	{chunkYM2612, ym2612SerializeVersion, YM2612SerializeSize,
+		func(e *Emulator, buf []byte) (int, error) { return YM2612SerializeSize, e.ym2612.Serialize(buf) },
+		func(e *Emulator, buf []byte) error { return e.ym2612.Deserialize(buf) },
+		func(e *Emulator, buf []byte) error {
+			return checkVersionedState(buf, YM2612SerializeSize, ym2612SerializeVersion)
+		}},
+	{chunkPSG, 1, sn76489.SerializeSize,
+		func(e *Emulator, buf []byte) (int, error) { return sn76489.SerializeSize, e.psg.Serialize(buf) },
+		func(e *Emulator, buf []byte) error { return e.psg.Deserialize(buf) },
+		func(e *Emulator, buf []byte) error {
+			return checkChipState(chunkPSG, buf, sn76489.SerializeSize, e.psg.Serialize)
+		}},
+	{chunkIO, ioSerializeVersion, IOSerializeSize,
+		func(e *Emulator, buf []byte) (int, error) { return IOSerializeSize, e.io.Serialize(buf) },
+		func(e *Emulator, buf []byte) error { return e.io.Deserialize(buf) },
+		func(e *Emulator, buf []byte) error {
+			return checkVersionedState(buf, IOSerializeSize, ioSerializeVersion)
+		}},
+	{chunkBase, baseSerializeVersion, emulatorSerializeSize,
+		func(e *Emulator, buf []byte) (int, error) { return e.serializeBase(buf), nil },
+		func(e *Emulator, buf []byte) error { return e.deserializeBase(buf) },
+		func(e *Emulator, buf []byte) error { return checkStateSize(buf, emulatorSerializeSize) }},
+}
+
+var errStateCorrupt = errors.New("save state data is corrupted")
+
+// checkStateSize checks that a payload is at least size bytes long.
+func checkStateSize(buf []byte, size int) error {
+	if len(buf) < size {
+		return errStateCorrupt
+	}
+	return nil
+}
+
+// checkVersionedState checks the size of a payload that starts with its
+// own version byte, and that the version is supported.
+func checkVersionedState(buf []byte, size int, version uint8) error {
+	if len(buf) < size || buf[0] > version {
+		return errStateCorrupt
+	}
+	return nil
+}
+
+// checkChipState checks a payload for a chip from another module the way
+// its Deserialize does: it must be size bytes long and start with the
+// version byte the chip saves.
+func checkChipState(tag string, buf []byte, size int, serialize func([]byte) error) error {
+	if len(buf) < size {
+		return errStateCorrupt
+	}
+	live := make([]byte, size)
+	if err := serialize(live); err != nil {
+		return err
+	}
+	if buf[0] != live[0] {
+		return fmt.Errorf("unsupported %q state version %d", tag, buf[0])
+	}
+	return nil
+}
+
 // boolByte converts a bool to a uint8 (0 or 1).
 func boolByte(b bool) uint8 {
 	if b {
@@ -34,23 +175,28 @@ func boolByte(b bool) uint8 {
 	return 0
 }
 
-// SerializeSize returns the total size in bytes needed for a save state.
+// SerializeSize returns the largest size in bytes a state from Serialize
+// can take. States are usually shorter: the SRAM chunk only holds the
+// cartridge's save memory. SerializeWithInfoSize covers the metadata.
 func SerializeSize() int {
-	return stateHeaderSize +
-		m68k.SerializeSize +
-		z80.SerializeSize +
-		busSerializeFixedSize + maxSRAMSize +
-		z80MemSerializeSize +
-		VDPSerializeSize +
-		YM2612SerializeSize +
-		sn76489.SerializeSize +
-		IOSerializeSize +
-		emulatorSerializeSize
+	size := stateHeaderSize + chunkHeaderSize // header + END
+	for _, c := range stateChunks {
+		size += chunkHeaderSize + c.size
+	}
+	return size
 }
 
 // Serialize creates a save state and returns it as a byte slice.
 func (e *Emulator) Serialize() ([]byte, error) {
+	return e.serialize(false)
+}
+
+// serialize creates a save state, with a metadata chunk if info is set.
+func (e *Emulator) serialize(info bool) ([]byte, error) {
 	size := SerializeSize()
+	if info {
+		size = SerializeWithInfoSize()
+	}
 	data := make([]byte, size)
 
 	// Write header
@@ -59,51 +205,23 @@ func (e *Emulator) Serialize() ([]byte, error) {
 	binary.LittleEndian.PutUint32(data[14:18], e.bus.romCRC)
 
 	offset := stateHeaderSize
-
-	// M68K CPU
-	if err := e.m68k.Serialize(data[offset:]); err != nil {
-		return nil, err
+	for _, c := range stateChunks {
+		payload := data[offset+chunkHeaderSize : offset+chunkHeaderSize+c.size]
+		n, err := c.save(e, payload)
+		if err != nil {
+			return nil, err
+		}
+		putChunkHeader(data[offset:], c.tag, c.version, n)
+		offset += chunkHeaderSize + n
 	}
-	offset += m68k.SerializeSize
-
-	// Z80 CPU
-	if err := e.z80.Serialize(data[offset:]); err != nil {
-		return nil, err
-	}
-	offset += z80.SerializeSize
-
-	// GenesisBus
-	offset = e.serializeBus(data, offset)
-
-	// Z80Memory
-	offset = e.serializeZ80Mem(data, offset)
-
-	// VDP
-	if err := e.vdp.Serialize(data[offset:]); err != nil {
-		return nil, err
-	}
-	offset += VDPSerializeSize
-
-	// YM2612
-	if err := e.ym2612.Serialize(data[offset:]); err != nil {
-		return nil, err
-	}
-	offset += YM2612SerializeSize
-
-	// PSG
-	if err := e.psg.Serialize(data[offset:]); err != nil {
-		return nil, err
+	if info {
+		n := e.serializeInfo(data[offset+chunkHeaderSize:])
+		putChunkHeader(data[offset:], chunkInfo, stateInfoVersion, n)
+		offset += chunkHeaderSize + n
 	}
-	offset += sn76489.SerializeSize
-
-	// IO
-	if err := e.io.Serialize(data[offset:]); err != nil {
-		return nil, err
-	}
-	offset += IOSerializeSize
-
-	// Emulator inline state
-	e.serializeBase(data, offset)
+	putChunkHeader(data[offset:], chunkEnd, 1, 0)
+	offset += chunkHeaderSize
+	data = data[:offset]
 
 	// Calculate and write data CRC32 (over everything after header)
 	dataCRC := crc32.ChecksumIEEE(data[stateHeaderSize:])
@@ -114,93 +232,144 @@ func (e *Emulator) Serialize() ([]byte, error) {
 
 // Deserialize restores emulator state from a save state byte slice.
 // Region is NOT restored - the current region setting is preserved.
+// Every chunk is migrated and checked before any component is loaded, so
+// a state that is rejected leaves the emulator unchanged.
 func (e *Emulator) Deserialize(data []byte) error {
-	if err := e.VerifyState(data); err != nil {
+	payloads, err := e.readState(data)
+	if err != nil {
 		return err
 	}
 
-	offset := stateHeaderSize
-
-	// M68K CPU
-	if err := e.m68k.Deserialize(data[offset:]); err != nil {
-		return err
-	}
-	offset += m68k.SerializeSize
-
-	// Z80 CPU
-	if err := e.z80.Deserialize(data[offset:]); err != nil {
-		return err
-	}
-	offset += z80.SerializeSize
-
-	// GenesisBus
-	offset = e.deserializeBus(data, offset)
-
-	// Z80Memory
-	offset = e.deserializeZ80Mem(data, offset)
-
-	// VDP
-	if err := e.vdp.Deserialize(data[offset:]); err != nil {
-		return err
-	}
-	offset += VDPSerializeSize
-
-	// YM2612
-	if err := e.ym2612.Deserialize(data[offset:]); err != nil {
-		return err
+	// Close out the time logged so far before the sample counter is replaced
+	if e.vgm != nil {
+		e.vgm.sync()
 	}
-	offset += YM2612SerializeSize
 
-	// PSG
-	if err := e.psg.Deserialize(data[offset:]); err != nil {
-		return err
+	for _, c := range stateChunks {
+		if err := c.load(e, payloads[c.tag]); err != nil {
+			return err
+		}
 	}
-	offset += sn76489.SerializeSize
 
-	// IO
-	if err := e.io.Deserialize(data[offset:]); err != nil {
-		return err
+	if e.vgm != nil {
+		e.vgm.resync()
 	}
-	offset += IOSerializeSize
-
-	// Emulator inline state
-	e.deserializeBase(data, offset)
 
 	return nil
 }
 
 // VerifyState checks if a save state is valid without loading it.
 func (e *Emulator) VerifyState(data []byte) error {
-	expectedSize := SerializeSize()
-	if len(data) < expectedSize {
-		return errors.New("save state too short")
+	_, err := e.readState(data)
+	return err
+}
+
+// readState validates a save state and returns the payload of every
+// component, migrated to the current chunk versions and checked. Compact
+// states are expanded first.
+func (e *Emulator) readState(data []byte) (map[string][]byte, error) {
+	data, err := ExpandState(data, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < stateHeaderSize {
+		return nil, errors.New("save state too short")
 	}
 
 	if string(data[0:12]) != stateMagic {
-		return errors.New("invalid save state magic")
+		return nil, errors.New("invalid save state magic")
 	}
 
 	version := binary.LittleEndian.Uint16(data[12:14])
-	if version > stateVersion {
-		return errors.New("unsupported save state version")
+	if version > stateVersion || version == 0 {
+		return nil, errors.New("unsupported save state version")
 	}
 
 	romCRC := binary.LittleEndian.Uint32(data[14:18])
 	if romCRC != e.bus.romCRC {
-		return errors.New("save state is for a different ROM")
+		return nil, errors.New("save state is for a different ROM")
+	}
+
+	var chunks map[string]chunk
+	var end int
+	if version < chunkedVersion {
+		chunks, end, err = splitLegacyState(data)
+	} else {
+		chunks, end, err = readChunks(data)
+	}
+	if err != nil {
+		return nil, err
 	}
 
 	expectedCRC := binary.LittleEndian.Uint32(data[18:22])
-	actualCRC := crc32.ChecksumIEEE(data[stateHeaderSize:])
+	actualCRC := crc32.ChecksumIEEE(data[stateHeaderSize:end])
 	if expectedCRC != actualCRC {
-		return errors.New("save state data is corrupted")
+		return nil, errStateCorrupt
 	}
 
-	return nil
+	payloads := make(map[string][]byte, len(stateChunks))
+	for _, c := range stateChunks {
+		ch, ok := chunks[c.tag]
+		if !ok {
+			return nil, fmt.Errorf("save state has no %q chunk", c.tag)
+		}
+		payload, err := migrateChunk(c.tag, ch.version, c.version, ch.payload)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.check(e, payload); err != nil {
+			return nil, err
+		}
+		payloads[c.tag] = payload
+	}
+	return payloads, nil
+}
+
+// chunk is a component's payload as found in a save state.
+type chunk struct {
+	version uint16
+	payload []byte
+}
+
+// putChunkHeader writes a chunk header to buf.
+func putChunkHeader(buf []byte, tag string, version uint16, length int) {
+	copy(buf[0:4], tag)
+	binary.LittleEndian.PutUint16(buf[4:6], version)
+	binary.LittleEndian.PutUint32(buf[6:10], uint32(length))
 }
 
-// serializeBus writes GenesisBus state to the data buffer.
-func (e *Emulator) serializeBus(data []byte, offset int) int {
+// readChunks walks the chunks of a state up to END. It returns them by
+// tag and the offset just past END.
+func readChunks(data []byte) (map[string]chunk, int, error) {
+	chunks := make(map[string]chunk)
+	offset := stateHeaderSize
+	for {
+		if len(data)-offset < chunkHeaderSize {
+			return nil, 0, errStateCorrupt
+		}
+		tag := string(data[offset : offset+4])
+		version := binary.LittleEndian.Uint16(data[offset+4:])
+		length := binary.LittleEndian.Uint32(data[offset+6:])
+		offset += chunkHeaderSize
+		if uint64(length) > uint64(len(data)-offset) {
+			return nil, 0, errStateCorrupt
+		}
+		if tag == chunkEnd {
+			return chunks, offset, nil
+		}
+		if _, dup := chunks[tag]; dup {
+			return nil, 0, errStateCorrupt
+		}
+		chunks[tag] = chunk{version, data[offset : offset+int(length)]}
+		offset += int(length)
+	}
+}
+
+// serializeBus writes GenesisBus state to the data buffer and returns the bytes written.
+func (e *Emulator) serializeBus(data []byte) int {
+	offset := 0
+
 	// Main RAM (64KB)
 	copy(data[offset:], e.bus.ram[:])
 	offset += mainRAMSize
@@ -209,12 +378,13 @@ func (e *Emulator) serializeBus(data []byte, offset int) int {
 	copy(data[offset:], e.bus.z80RAM[:])
 	offset += z80RAMSize
 
-	// SRAM length (4 bytes) + SRAM data
-	sramLen := uint32(len(e.bus.sram))
+	// SRAM length (4 bytes) + SRAM data (EEPROM contents when present)
+	sram := e.bus.saveRAM()
+	sramLen := uint32(len(sram))
 	binary.LittleEndian.PutUint32(data[offset:], sramLen)
 	offset += 4
 	if sramLen > 0 {
-		copy(data[offset:], e.bus.sram)
+		copy(data[offset:], sram)
 		offset += int(sramLen)
 	}
 
@@ -230,11 +400,44 @@ func (e *Emulator) serializeBus(data []byte, offset int) int {
 	data[offset] = boolByte(e.bus.z80PendingReset)
 	offset++
 
+	// Cartridge mapper bank registers
+	e.bus.mapper.Serialize(data[offset : offset+mapperSerializeSize])
+	offset += mapperSerializeSize
+
+	// Serial EEPROM protocol state
+	if e.bus.eeprom != nil {
+		e.bus.eeprom.serialize(data[offset : offset+eepromSerializeSize])
+	}
+	offset += eepromSerializeSize
+
+	// TMSS lock and boot ROM mapping
+	e.bus.tmss.serialize(data[offset : offset+tmssSerializeSize])
+	offset += tmssSerializeSize
+
 	return offset
 }
 
+// checkBusState checks that a bus payload holds its fixed fields and the
+// SRAM length it records.
+func checkBusState(data []byte) error {
+	if len(data) < busSerializeFixedSize {
+		return errors.New("bus state too short")
+	}
+	sramLen := int(binary.LittleEndian.Uint32(data[mainRAMSize+z80RAMSize:]))
+	if sramLen > maxSRAMSize || len(data) < busSerializeFixedSize+sramLen {
+		return errors.New("bus state too short")
+	}
+	return nil
+}
+
 // deserializeBus reads GenesisBus state from the data buffer.
-func (e *Emulator) deserializeBus(data []byte, offset int) int {
+func (e *Emulator) deserializeBus(data []byte) error {
+	if err := checkBusState(data); err != nil {
+		return err
+	}
+	offset := 0
+	sramLen := int(binary.LittleEndian.Uint32(data[mainRAMSize+z80RAMSize:]))
+
 	// Main RAM (64KB)
 	copy(e.bus.ram[:], data[offset:offset+mainRAMSize])
 	offset += mainRAMSize
@@ -244,12 +447,14 @@ func (e *Emulator) deserializeBus(data []byte, offset int) int {
 	offset += z80RAMSize
 
 	// SRAM length + SRAM data
-	sramLen := binary.LittleEndian.Uint32(data[offset:])
 	offset += 4
-	if sramLen > 0 && e.bus.sram != nil {
-		copy(e.bus.sram, data[offset:offset+int(sramLen)])
+	if sramLen > 0 && e.sms != nil {
+		e.sms.allocCartRAM()
+	}
+	if sram := e.bus.saveRAM(); sramLen > 0 && sram != nil {
+		copy(sram, data[offset:offset+sramLen])
 	}
-	offset += int(sramLen)
+	offset += sramLen
 
 	// Flags
 	e.bus.sramEnabled = data[offset] != 0
@@ -263,25 +468,51 @@ func (e *Emulator) deserializeBus(data []byte, offset int) int {
 	e.bus.z80PendingReset = data[offset] != 0
 	offset++
 
-	return offset
+	// Cartridge mapper bank registers
+	e.bus.mapper.Deserialize(data[offset : offset+mapperSerializeSize])
+	offset += mapperSerializeSize
+
+	// Serial EEPROM protocol state
+	if e.bus.eeprom != nil {
+		e.bus.eeprom.deserialize(data[offset : offset+eepromSerializeSize])
+	}
+	offset += eepromSerializeSize
+
+	// TMSS lock and boot ROM mapping
+	e.bus.tmss.deserialize(data[offset : offset+tmssSerializeSize])
+
+	return nil
 }
 
-// serializeZ80Mem writes Z80Memory state to the data buffer.
-func (e *Emulator) serializeZ80Mem(data []byte, offset int) int {
+// serializeZ80Mem writes Z80Memory state to the data buffer and returns the bytes written.
+func (e *Emulator) serializeZ80Mem(data []byte) int {
+	offset := 0
+
 	binary.LittleEndian.PutUint16(data[offset:], e.z80Mem.bankRegister)
 	offset += 2
+	if e.sms != nil {
+		e.sms.serialize(data[offset : offset+smsSerializeSize])
+	}
+	offset += smsSerializeSize
 	return offset
 }
 
 // deserializeZ80Mem reads Z80Memory state from the data buffer.
-func (e *Emulator) deserializeZ80Mem(data []byte, offset int) int {
-	e.z80Mem.bankRegister = binary.LittleEndian.Uint16(data[offset:])
-	offset += 2
-	return offset
+func (e *Emulator) deserializeZ80Mem(data []byte) error {
+	if len(data) < z80MemSerializeSize {
+		return errors.New("Z80 memory state too short")
+	}
+	e.z80Mem.bankRegister = binary.LittleEndian.Uint16(data)
+	if e.sms != nil {
+		e.sms.deserialize(data[2 : 2+smsSerializeSize])
+	}
+	return nil
 }
 
-// serializeBase writes Emulator inline state to the data buffer.
-func (e *Emulator) serializeBase(data []byte, offset int) int {
+// serializeBase writes Emulator inline state to the data buffer and returns the bytes written.
+func (e *Emulator) serializeBase(data []byte) int {
+	offset := 0
+
 	data[offset] = boolByte(e.z80IntPending)
 	offset++
 
@@ -291,19 +522,43 @@ func (e *Emulator) serializeBase(data []byte, offset int) int {
 	binary.LittleEndian.PutUint64(data[offset:], math.Float64bits(e.filterPrevR))
 	offset += 8
 
-	return offset
-}
+	binary.LittleEndian.PutUint64(data[offset:], e.frameCount)
+	offset += 8
 
-// deserializeBase reads Emulator inline state from the data buffer.
-func (e *Emulator) deserializeBase(data []byte, offset int) int {
-	e.z80IntPending = data[offset] != 0
-	offset++
+	binary.LittleEndian.PutUint64(data[offset:], e.lineCount)
+	offset += 8
+
+	binary.LittleEndian.PutUint64(data[offset:], e.masterCycles)
+	offset += 8
 
-	e.filterPrevL = math.Float64frombits(binary.LittleEndian.Uint64(data[offset:]))
+	binary.LittleEndian.PutUint64(data[offset:], e.m68kCycles)
 	offset += 8
 
-	e.filterPrevR = math.Float64frombits(binary.LittleEndian.Uint64(data[offset:]))
+	binary.LittleEndian.PutUint64(data[offset:], e.z80Cycles)
 	offset += 8
 
 	return offset
 }
+
+// deserializeBase reads Emulator inline state from the data buffer.
+func (e *Emulator) deserializeBase(data []byte) error {
+	if len(data) < emulatorSerializeSize {
+		return errors.New("emulator state too short")
+	}
+
+	// States are taken at frame boundaries; drop any mid-frame debug stop
+	// and start the scanline at the restored 68K cycle count
+	e.frame.stopped = false
+	e.lineM68K = e.m68k.Cycles()
+
+	e.z80IntPending = data[0] != 0
+	e.filterPrevL = math.Float64frombits(binary.LittleEndian.Uint64(data[1:]))
+	e.filterPrevR = math.Float64frombits(binary.LittleEndian.Uint64(data[9:]))
+	e.frameCount = binary.LittleEndian.Uint64(data[17:])
+	e.lineCount = binary.LittleEndian.Uint64(data[25:])
+	e.masterCycles = binary.LittleEndian.Uint64(data[33:])
+	e.m68kCycles = binary.LittleEndian.Uint64(data[41:])
+	e.z80Cycles = binary.LittleEndian.Uint64(data[49:])
+
+	return nil
+}
@@ -0,0 +1,126 @@
+package emu
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+)
+
+// Compact save states wrap a full state from Serialize for frontends that
+// keep many of them, such as rewind buffers and state thumbnails:
+//
+//	magic(12) + delta(1) + keyCRC(4) + length(4) + deflate(state XOR key)
+//
+// Without a keyframe delta and keyCRC are 0 and the state is only
+// compressed. With one, the state is XORed against the keyframe first;
+// consecutive frames differ in few bytes, so the delta compresses to a
+// small fraction of the state. The libretro path keeps using the
+// uncompressed Serialize output.
+const (
+	compactMagic      = "eMMDSCmpt\x00\x00\x00"
+	compactHeaderSize = 21 // magic(12) + delta(1) + keyCRC(4) + length(4)
+)
+
+// SerializeCompact returns the current state compressed. Deserialize and
+// VerifyState accept it directly.
+func (e *Emulator) SerializeCompact() ([]byte, error) {
+	return e.SerializeDelta(nil)
+}
+
+// SerializeDelta returns the current state as a compressed delta against
+// key, a full state from Serialize or ExpandState. A nil key compresses
+// the state on its own. Load a delta with ExpandState and the same key.
+func (e *Emulator) SerializeDelta(key []byte) ([]byte, error) {
+	state, err := e.Serialize()
+	if err != nil {
+		return nil, err
+	}
+	return e.compact(state, key)
+}
+
+// compact compresses state, XORed against key when key is not nil. state
+// is modified.
+func (e *Emulator) compact(state, key []byte) ([]byte, error) {
+	var err error
+	var keyCRC uint32
+	if key != nil {
+		keyCRC = crc32.ChecksumIEEE(key)
+		xorState(state, key)
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(compactHeaderSize + len(state)/8)
+	buf.WriteString(compactMagic)
+	buf.WriteByte(boolByte(key != nil))
+	binary.Write(&buf, binary.LittleEndian, keyCRC)
+	binary.Write(&buf, binary.LittleEndian, uint32(len(state)))
+
+	if e.compactWriter == nil {
+		e.compactWriter, err = flate.NewWriter(&buf, flate.BestSpeed)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		e.compactWriter.Reset(&buf)
+	}
+	if _, err := e.compactWriter.Write(state); err != nil {
+		return nil, err
+	}
+	if err := e.compactWriter.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// IsCompactState reports whether data came from SerializeCompact or
+// SerializeDelta.
+func IsCompactState(data []byte) bool {
+	return len(data) >= compactHeaderSize && string(data[:12]) == compactMagic
+}
+
+// ExpandState returns the full state held in a compact state. key must be
+// the keyframe a delta was made against, and is ignored otherwise. States
+// that are not compact are returned unchanged.
+func ExpandState(data, key []byte) ([]byte, error) {
+	if !IsCompactState(data) {
+		return data, nil
+	}
+
+	delta := data[12] != 0
+	keyCRC := binary.LittleEndian.Uint32(data[13:17])
+	length := int(binary.LittleEndian.Uint32(data[17:21]))
+	if delta {
+		if key == nil {
+			return nil, errors.New("save state is a delta and needs its keyframe")
+		}
+		if crc32.ChecksumIEEE(key) != keyCRC {
+			return nil, errors.New("save state delta is for a different keyframe")
+		}
+	}
+	if length > SerializeWithInfoSize() {
+		return nil, errStateCorrupt
+	}
+
+	state := make([]byte, length)
+	zr := flate.NewReader(bytes.NewReader(data[compactHeaderSize:]))
+	defer zr.Close()
+	if _, err := io.ReadFull(zr, state); err != nil {
+		return nil, errStateCorrupt
+	}
+
+	if delta {
+		xorState(state, key)
+	}
+	return state, nil
+}
+
+// xorState XORs key into state over their common length.
+func xorState(state, key []byte) {
+	n := min(len(state), len(key))
+	for i := range state[:n] {
+		state[i] ^= key[i]
+	}
+}
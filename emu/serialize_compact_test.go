@@ -0,0 +1,113 @@
+package emu
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/user-none/go-chip-m68k"
+)
+
+func TestSerializeCompact_RoundTrip(t *testing.T) {
+	base := createTestEmulator()
+	base.bus.WriteCycle(0, m68k.Byte, 0xFF0000, 0xAB)
+
+	full, err := base.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	compact, err := base.SerializeCompact()
+	if err != nil {
+		t.Fatalf("SerializeCompact failed: %v", err)
+	}
+	if !IsCompactState(compact) {
+		t.Fatal("expected a compact state")
+	}
+	if len(compact) >= len(full)/4 {
+		t.Errorf("compact state of %d bytes is not much smaller than %d", len(compact), len(full))
+	}
+
+	expanded, err := ExpandState(compact, nil)
+	if err != nil {
+		t.Fatalf("ExpandState failed: %v", err)
+	}
+	if !bytes.Equal(expanded, full) {
+		t.Error("expanded state differs from Serialize output")
+	}
+
+	base.bus.WriteCycle(0, m68k.Byte, 0xFF0000, 0x00)
+	if err := base.Deserialize(compact); err != nil {
+		t.Fatalf("Deserialize of compact state failed: %v", err)
+	}
+	if val := base.bus.ReadCycle(0, m68k.Byte, 0xFF0000); val != 0xAB {
+		t.Errorf("RAM[0xFF0000]: expected 0xAB, got 0x%02X", val)
+	}
+}
+
+func TestSerializeDelta_RoundTrip(t *testing.T) {
+	base := createTestEmulator()
+	key, err := base.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	compact, err := base.SerializeCompact()
+	if err != nil {
+		t.Fatalf("SerializeCompact failed: %v", err)
+	}
+
+	base.RunFrame()
+	base.bus.WriteCycle(0, m68k.Byte, 0xFF0100, 0x42)
+	full, err := base.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	delta, err := base.SerializeDelta(key)
+	if err != nil {
+		t.Fatalf("SerializeDelta failed: %v", err)
+	}
+	if len(delta) >= len(compact) {
+		t.Errorf("delta of %d bytes should be smaller than the compact state of %d", len(delta), len(compact))
+	}
+
+	expanded, err := ExpandState(delta, key)
+	if err != nil {
+		t.Fatalf("ExpandState failed: %v", err)
+	}
+	if !bytes.Equal(expanded, full) {
+		t.Error("expanded delta differs from Serialize output")
+	}
+	if err := base.Deserialize(expanded); err != nil {
+		t.Errorf("Deserialize of expanded delta failed: %v", err)
+	}
+}
+
+func TestSerializeDelta_NeedsKeyframe(t *testing.T) {
+	base := createTestEmulator()
+	key, err := base.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	delta, err := base.SerializeDelta(key)
+	if err != nil {
+		t.Fatalf("SerializeDelta failed: %v", err)
+	}
+
+	if err := base.Deserialize(delta); err == nil {
+		t.Error("Deserialize should reject a delta without its keyframe")
+	}
+	other := append([]byte(nil), key...)
+	other[stateHeaderSize] ^= 0xFF
+	if _, err := ExpandState(delta, other); err == nil {
+		t.Error("ExpandState should reject the wrong keyframe")
+	}
+}
+
+func TestExpandState_Corrupt(t *testing.T) {
+	base := createTestEmulator()
+	compact, err := base.SerializeCompact()
+	if err != nil {
+		t.Fatalf("SerializeCompact failed: %v", err)
+	}
+	if _, err := ExpandState(compact[:len(compact)/2], nil); err == nil {
+		t.Error("ExpandState should reject a truncated state")
+	}
+}
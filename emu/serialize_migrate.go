@@ -0,0 +1,185 @@
+package emu
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// chunkMigration upgrades a chunk payload of version from, size bytes
+// long, to version from+1. The bus payload size excludes its SRAM.
+//
+// Version 1 of each component is the layout of the first release, which
+// saved the fixed-offset state format. When a component's layout changes
+// after a release, bump its version and add a migration from the
+// previous version here so existing states keep loading.
+type chunkMigration struct {
+	tag     string
+	from    uint16
+	size    int
+	migrate func(old []byte) []byte
+}
+
+var chunkMigrations = []chunkMigration{
+	{chunkBus, 1, mainRAMSize + z80RAMSize + 9, migrateBus},
+
+	// SMS mapper and I/O, unused outside Master System mode
+	{chunkZ80Mem, 1, 2, appendPayload(make([]byte, smsSerializeSize)...)},
+
+	// Write FIFO, Mode 4 CRAM and line interrupt, debug register and FIFO
+	// data, all clear: queued VRAM writes were already in VRAM
+	{chunkVDP, 1, 65810, appendVersioned(make([]byte, 88)...)},
+
+	// Register file: not recorded by older states, reads back as zero
+	{chunkYM2612, 1, 826, appendVersioned(make([]byte, 512)...)},
+
+	// Frame counter, scanline, master clock and CPU cycles: unknown,
+	// restart from 0
+	{chunkBase, 1, 17, appendPayload(make([]byte, 40)...)},
+
+	{chunkIO, 1, 29, migrateIO},
+}
+
+// appendPayload returns a migration that appends extra to the payload.
+func appendPayload(extra ...byte) func([]byte) []byte {
+	return func(old []byte) []byte {
+		return append(append([]byte(nil), old...), extra...)
+	}
+}
+
+// appendVersioned returns a migration that appends extra to a payload that
+// starts with its own version byte, and bumps the version.
+func appendVersioned(extra ...byte) func([]byte) []byte {
+	return func(old []byte) []byte {
+		buf := append(append([]byte(nil), old...), extra...)
+		buf[0]++
+		return buf
+	}
+}
+
+// migrateBus converts bus version 1 to version 2, which adds the SSF2
+// mapper (type 0, no mapper, resets the banks), the EEPROM protocol
+// (present 0 keeps the power-on state) and TMSS (unlocked, the game was
+// already running).
+func migrateBus(old []byte) []byte {
+	buf := append([]byte(nil), old...)
+	buf = append(buf, make([]byte, mapperSerializeSize+eepromSerializeSize)...)
+	return append(buf, 0, 1, 0, 0, 0, 0, 0, 0)
+}
+
+// migrateIO converts IO version 1, which saved the 6-button state of both
+// pads, to version 2, where each port including EXT saves the type and
+// state of its peripheral, followed by players 3 and 4, the multitap, the
+// light gun and the serial registers.
+func migrateIO(old []byte) []byte {
+	buf := make([]byte, 0, IOSerializeSize)
+	buf = append(buf, 2)
+	buf = append(buf, old[1:5]...)
+	buf = append(buf, 0, 0) // EXT data and ctrl
+	for i := 0; i < 2; i++ {
+		t := PeripheralNone
+		switch connected, six := old[25+2*i] != 0, old[26+2*i] != 0; {
+		case connected && six:
+			t = Peripheral6Button
+		case connected:
+			t = Peripheral3Button
+		}
+		state := make([]byte, peripheralStateSize)
+		copy(state, old[5+10*i:15+10*i])
+		buf = append(buf, uint8(t))
+		buf = append(buf, state...)
+	}
+	buf = append(buf, uint8(PeripheralNone))
+	buf = append(buf, make([]byte, peripheralStateSize)...)
+	buf = append(buf, old[25:29]...) // Players 1 and 2
+	buf = append(buf, 0, 0, 0, 0)    // Players 3 and 4
+	// No multitap, with TH and TR idle high, and no light gun
+	buf = append(buf, uint8(MultitapNone), 0x60, 0, 0, uint8(LightGunNone), 0)
+	for i := 0; i < 3; i++ {
+		var s serialPort
+		s.tx = 0xFF
+		s.thHigh = true
+		port := make([]byte, serialSerializeSize)
+		s.serialize(port)
+		buf = append(buf, port...)
+	}
+	return buf
+}
+
+// migrateChunk upgrades a payload from version to the current version.
+func migrateChunk(tag string, version, current uint16, payload []byte) ([]byte, error) {
+	if version > current {
+		return nil, fmt.Errorf("unsupported %q state version %d", tag, version)
+	}
+	for ; version < current; version++ {
+		m, ok := findMigration(tag, version)
+		if !ok {
+			return nil, fmt.Errorf("no migration for %q state version %d", tag, version)
+		}
+		if len(payload) < m.size || tag != chunkBus && len(payload) != m.size {
+			return nil, errStateCorrupt
+		}
+		payload = m.migrate(payload)
+	}
+	return payload, nil
+}
+
+// findMigration returns the migration from a chunk version.
+func findMigration(tag string, from uint16) (chunkMigration, bool) {
+	for _, m := range chunkMigrations {
+		if m.tag == tag && m.from == from {
+			return m, true
+		}
+	}
+	return chunkMigration{}, false
+}
+
+// chunkSize returns the payload size of a chunk version, excluding the
+// bus SRAM.
+func chunkSize(tag string, version uint16) (int, bool) {
+	for _, c := range stateChunks {
+		if c.tag == tag && c.version == version {
+			if tag == chunkBus {
+				return busSerializeFixedSize, true
+			}
+			return c.size, true
+		}
+	}
+	m, ok := findMigration(tag, version)
+	return m.size, ok
+}
+
+// splitLegacyState splits a version 1 state into chunks. Version 1
+// wrote the components back to back at fixed offsets, each in its version
+// 1 layout. It returns the chunks and the end of the data covered by the
+// CRC, which included the unused part of the SRAM slot.
+func splitLegacyState(data []byte) (map[string]chunk, int, error) {
+	chunks := make(map[string]chunk)
+	offset := stateHeaderSize
+	sramLen := 0
+	for _, c := range stateChunks {
+		size, _ := chunkSize(c.tag, 1)
+		if c.tag == chunkBus {
+			lenOffset := offset + mainRAMSize + z80RAMSize
+			if lenOffset+4 > len(data) {
+				return nil, 0, errStateCorrupt
+			}
+			sramLen = int(binary.LittleEndian.Uint32(data[lenOffset:]))
+			if sramLen > maxSRAMSize {
+				return nil, 0, errStateCorrupt
+			}
+			size += sramLen
+		}
+		if offset+size > len(data) {
+			return nil, 0, errStateCorrupt
+		}
+		chunks[c.tag] = chunk{1, data[offset : offset+size]}
+		offset += size
+	}
+
+	// The fixed layout reserved room for the largest SRAM
+	end := offset + maxSRAMSize - sramLen
+	if end > len(data) {
+		return nil, 0, errStateCorrupt
+	}
+	return chunks, end, nil
+}
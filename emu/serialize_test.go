@@ -1,8 +1,11 @@
 package emu
 
 import (
+	"bytes"
+	"compress/gzip"
 	"encoding/binary"
 	"hash/crc32"
+	"os"
 	"testing"
 
 	"github.com/user-none/go-chip-m68k"
@@ -296,3 +299,247 @@ func TestSerialize_StateIntegrity(t *testing.T) {
 		t.Errorf("Data CRC32: expected 0x%08X, got 0x%08X", calculatedCRC, dataCRC)
 	}
 }
+
+// rewriteState rebuilds a chunked state from its chunks with edit applied
+// to the chunk list, and recomputes the data CRC.
+func rewriteState(t *testing.T, state []byte, edit func(tags []string, chunks map[string]chunk) []string) []byte {
+	t.Helper()
+	chunks, _, err := readChunks(state)
+	if err != nil {
+		t.Fatalf("readChunks failed: %v", err)
+	}
+	var tags []string
+	for _, c := range stateChunks {
+		tags = append(tags, c.tag)
+	}
+	tags = edit(tags, chunks)
+
+	out := append([]byte(nil), state[:stateHeaderSize]...)
+	for _, tag := range tags {
+		hdr := make([]byte, chunkHeaderSize)
+		putChunkHeader(hdr, tag, chunks[tag].version, len(chunks[tag].payload))
+		out = append(append(out, hdr...), chunks[tag].payload...)
+	}
+	hdr := make([]byte, chunkHeaderSize)
+	putChunkHeader(hdr, chunkEnd, 1, 0)
+	out = append(out, hdr...)
+	binary.LittleEndian.PutUint32(out[18:22], crc32.ChecksumIEEE(out[stateHeaderSize:]))
+	return out
+}
+
+func TestDeserialize_PaddedState(t *testing.T) {
+	base := createTestEmulator()
+	state, err := base.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if len(state) > SerializeSize() {
+		t.Fatalf("state of %d bytes exceeds SerializeSize %d", len(state), SerializeSize())
+	}
+
+	// libretro hands back the whole SerializeSize buffer
+	padded := make([]byte, SerializeSize())
+	copy(padded, state)
+	if err := base.Deserialize(padded); err != nil {
+		t.Errorf("Deserialize of padded state failed: %v", err)
+	}
+}
+
+func TestDeserialize_SkipsUnknownChunk(t *testing.T) {
+	base := createTestEmulator()
+	state, err := base.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	state = rewriteState(t, state, func(tags []string, chunks map[string]chunk) []string {
+		chunks["XTRA"] = chunk{7, []byte{1, 2, 3}}
+		return append(tags, "XTRA")
+	})
+	if err := base.Deserialize(state); err != nil {
+		t.Errorf("Deserialize should skip unknown chunks: %v", err)
+	}
+}
+
+func TestDeserialize_MissingChunk(t *testing.T) {
+	base := createTestEmulator()
+	state, err := base.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	state = rewriteState(t, state, func(tags []string, chunks map[string]chunk) []string {
+		return tags[:len(tags)-1]
+	})
+	if err := base.VerifyState(state); err == nil {
+		t.Error("VerifyState should reject a state without every component")
+	}
+}
+
+func TestDeserialize_FutureChunkVersion(t *testing.T) {
+	base := createTestEmulator()
+	state, err := base.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	state = rewriteState(t, state, func(tags []string, chunks map[string]chunk) []string {
+		c := chunks[chunkVDP]
+		c.version = vdpSerializeVersion + 1
+		chunks[chunkVDP] = c
+		return tags
+	})
+	if err := base.VerifyState(state); err == nil {
+		t.Error("VerifyState should reject a newer VDP chunk")
+	}
+}
+
+func TestDeserialize_RejectedStateLoadsNothing(t *testing.T) {
+	e := createTestEmulator()
+	e.bus.ram[0x10] = 0x5A
+	e.vdp.fifoCount = 2
+	state, err := e.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	chunks, end, err := readChunks(state)
+	if err != nil {
+		t.Fatalf("readChunks failed: %v", err)
+	}
+	vdp := chunks[chunkVDP].payload
+	if vdp[vdpFIFOCountOffset] != 2 {
+		t.Fatalf("FIFO count not at offset %d", vdpFIFOCountOffset)
+	}
+
+	// The VDP loads after the bus
+	vdp[vdpFIFOCountOffset] = fifoSize + 1
+	binary.LittleEndian.PutUint32(state[18:22], crc32.ChecksumIEEE(state[stateHeaderSize:end]))
+	e.bus.ram[0x10] = 0
+	if err := e.Deserialize(state); err == nil {
+		t.Fatal("expected an invalid FIFO count to be rejected")
+	}
+	if e.bus.ram[0x10] != 0 {
+		t.Error("bus was loaded from a rejected state")
+	}
+}
+
+// baselineStateROM returns the ROM testdata/state_v1.bin.gz was saved
+// with. It writes $12345678 to $FF0000, sets VDP registers 15 and 7 and
+// CRAM entry 0 to $0EEE, then counts up the word at $FF0004.
+func baselineStateROM() []byte {
+	rom := make([]byte, 0x400)
+	copy(rom, []byte{0x00, 0xFF, 0x00, 0x00, 0x00, 0x00, 0x02, 0x00})
+	copy(rom[0x200:], []byte{
+		0x23, 0xFC, 0x12, 0x34, 0x56, 0x78, 0x00, 0xFF, 0x00, 0x00, // move.l #$12345678,($ff0000).l
+		0x33, 0xFC, 0x8F, 0x02, 0x00, 0xC0, 0x00, 0x04, // move.w #$8f02,($c00004).l
+		0x33, 0xFC, 0x87, 0x05, 0x00, 0xC0, 0x00, 0x04, // move.w #$8705,($c00004).l
+		0x23, 0xFC, 0xC0, 0x00, 0x00, 0x00, 0x00, 0xC0, 0x00, 0x04, // move.l #$c0000000,($c00004).l
+		0x33, 0xFC, 0x0E, 0xEE, 0x00, 0xC0, 0x00, 0x00, // move.w #$0eee,($c00000).l
+		0x52, 0x79, 0x00, 0xFF, 0x00, 0x04, // addq.w #1,($ff0004).l
+		0x60, 0xF8, // bra.s *-6
+	})
+	return rom
+}
+
+// TestDeserialize_BaselineVersion1 loads a state saved by the first
+// release after running baselineStateROM for 10 frames.
+func TestDeserialize_BaselineVersion1(t *testing.T) {
+	f, err := os.Open("testdata/state_v1.bin.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var state bytes.Buffer
+	if _, err := state.ReadFrom(zr); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := NewEmulator(baselineStateROM(), RegionNTSC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Deserialize(state.Bytes()); err != nil {
+		t.Fatalf("Deserialize of version 1 state failed: %v", err)
+	}
+	if got := e.bus.ram[0:4]; !bytes.Equal(got, []byte{0x12, 0x34, 0x56, 0x78}) {
+		t.Errorf("RAM[0xFF0000]: expected 12345678, got %X", got)
+	}
+	if e.vdp.regs[7] != 0x05 || e.vdp.cram[0] != 0x0E || e.vdp.cram[1] != 0xEE {
+		t.Errorf("VDP not restored: reg 7 0x%02X, CRAM[0] %X", e.vdp.regs[7], e.vdp.cram[0:2])
+	}
+	count := e.bus.ram[4:6]
+	if !bytes.Equal(count, []byte{0xA6, 0x20}) {
+		t.Errorf("counter: expected A620, got %X", count)
+	}
+
+	// The restored 68K keeps running the counting loop
+	e.RunFrame()
+	if bytes.Equal(e.bus.ram[4:6], []byte{0xA6, 0x20}) {
+		t.Error("counter did not advance after loading")
+	}
+}
+
+func TestChunkMigrations_Chain(t *testing.T) {
+	for _, c := range stateChunks {
+		for v := uint16(1); v < c.version; v++ {
+			m, ok := findMigration(c.tag, v)
+			if !ok {
+				t.Errorf("%q: no migration from version %d", c.tag, v)
+				continue
+			}
+			next, _ := chunkSize(c.tag, v+1)
+			if got := len(m.migrate(make([]byte, m.size))); got != next {
+				t.Errorf("%q %d->%d: migrated size %d, expected %d", c.tag, v, v+1, got, next)
+			}
+		}
+	}
+}
+
+func TestChunkMigrations_VDPFromVersion1(t *testing.T) {
+	payload := make([]byte, 65810)
+	payload[0] = 1
+	payload[1] = 0x77 // VRAM[0]
+	migrated, err := migrateChunk(chunkVDP, 1, vdpSerializeVersion, payload)
+	if err != nil {
+		t.Fatalf("migrateChunk failed: %v", err)
+	}
+	v := NewVDP(false)
+	if err := v.Deserialize(migrated); err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	if v.vram[0] != 0x77 {
+		t.Errorf("VRAM[0]: expected 0x77, got 0x%02X", v.vram[0])
+	}
+}
+
+func TestChunkMigrations_IOFromVersion1(t *testing.T) {
+	old := make([]byte, 29)
+	old[0] = 1
+	old[5] = 3              // Port 1 6-button state
+	old[25], old[26] = 1, 1 // InputP1 connected, six-button
+
+	migrated, err := migrateChunk(chunkIO, 1, ioSerializeVersion, old)
+	if err != nil {
+		t.Fatalf("migrateChunk failed: %v", err)
+	}
+	io := newTestIO()
+	if err := io.Deserialize(migrated); err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	if got := io.PeripheralType(1); got != Peripheral6Button {
+		t.Errorf("port 1: expected 6-button pad, got %d", got)
+	}
+	if io.pad1.thState != 3 {
+		t.Errorf("port 1: expected 6-button state 3, got %d", io.pad1.thState)
+	}
+	if got := io.PeripheralType(2); got != PeripheralNone {
+		t.Errorf("port 2: expected empty, got %d", got)
+	}
+	if got := io.PeripheralType(3); got != PeripheralNone {
+		t.Errorf("EXT port: expected empty, got %d", got)
+	}
+	if got := io.ReadRegister(0, 0xA1000F); got != 0xFF {
+		t.Errorf("TxData: expected 0xFF, got 0x%02X", got)
+	}
+}
@@ -0,0 +1,312 @@
+package emu
+
+// Master System compatibility mode.
+//
+// With a Power Base Converter the Genesis holds the 68000 in reset, hands
+// the bus to the Z80 and switches the VDP to Mode 4. The Z80 then sees the
+// SMS memory and I/O maps below; the YM2612 is not reachable.
+//
+// SMS memory map (Z80 view):
+//
+//	0x0000-0x03FF  ROM (first 1KB, never banked)
+//	0x0400-0x3FFF  ROM slot 0 (bank register $FFFD)
+//	0x4000-0x7FFF  ROM slot 1 (bank register $FFFE)
+//	0x8000-0xBFFF  ROM slot 2 ($FFFF) or cartridge RAM ($FFFC bit 3)
+//	0xC000-0xDFFF  8KB work RAM (the Genesis Z80 RAM)
+//	0xE000-0xFFFF  Work RAM mirror; $FFFC-$FFFF also write the mapper
+const (
+	smsBankSize    = 0x4000
+	smsCartRAMSize = 0x8000 // Two 16KB banks of battery-backed RAM
+)
+
+// smsHeaderOffsets are the locations checked for the "TMR SEGA" header.
+var smsHeaderOffsets = []int{0x7FF0, 0x3FF0, 0x1FF0}
+
+// IsSMSROM reports whether rom is a Master System cartridge: it carries a
+// "TMR SEGA" header and no Genesis system type at $100.
+func IsSMSROM(rom []byte) bool {
+	if ValidateSystemType(rom) == nil {
+		return false
+	}
+	return smsHeader(rom) >= 0
+}
+
+// smsHeader returns the offset of the SMS header, or -1 when there is none.
+func smsHeader(rom []byte) int {
+	for _, off := range smsHeaderOffsets {
+		if len(rom) >= off+16 && string(rom[off:off+8]) == "TMR SEGA" {
+			return off
+		}
+	}
+	return -1
+}
+
+// smsConsoleRegion reads the region code from the SMS header. Code 3 is
+// a Japanese cartridge; everything else is treated as export.
+func smsConsoleRegion(rom []byte) ConsoleRegion {
+	if off := smsHeader(rom); off >= 0 && rom[off+15]>>4 == 3 {
+		return ConsoleJapan
+	}
+	return ConsoleUSA
+}
+
+// SMSMemory implements z80.Bus for Master System mode: the SMS memory map
+// with the Sega mapper and the SMS I/O ports.
+type SMSMemory struct {
+	bus *GenesisBus
+
+	// Mapper registers $FFFC-$FFFF: RAM control, then slots 0-2
+	mapper [4]uint8
+
+	ioControl uint8 // Port $3F: TH pin directions and output levels
+	pauseHeld bool  // Pause (Start) state at the last frame, for edge detection
+}
+
+// NewSMSMemory creates an SMSMemory on the given bus. The cartridge RAM is
+// kept in the bus SRAM so it is saved like Genesis SRAM. SMS headers do not
+// declare it, so it is allocated when the game first enables it and
+// HasSRAM is false for games that never do.
+func NewSMSMemory(bus *GenesisBus) *SMSMemory {
+	bus.eeprom = nil
+	bus.sram = nil
+	bus.sramStart, bus.sramEnd = 0, 0
+	m := &SMSMemory{bus: bus}
+	m.reset()
+	return m
+}
+
+// reset maps banks 0-2 into the slots with cartridge RAM off and all I/O
+// pins as inputs, as at power-on.
+func (m *SMSMemory) reset() {
+	m.mapper = [4]uint8{0, 0, 1, 2}
+	m.ioControl = 0xFF
+	m.pauseHeld = false
+}
+
+// romByte reads the ROM through the bank mapped at slot.
+func (m *SMSMemory) romByte(slot int, addr uint16) uint8 {
+	rom := m.bus.rom
+	if len(rom) == 0 {
+		return 0xFF
+	}
+	off := int(m.mapper[slot+1])*smsBankSize + int(addr&(smsBankSize-1))
+	return rom[off%len(rom)]
+}
+
+// cartRAMMapped reports whether cartridge RAM replaces ROM slot 2.
+func (m *SMSMemory) cartRAMMapped() bool {
+	return m.mapper[0]&0x08 != 0
+}
+
+// allocCartRAM allocates the cartridge RAM the first time it is needed.
+func (m *SMSMemory) allocCartRAM() {
+	if m.bus.sram == nil {
+		m.bus.sram = make([]byte, smsCartRAMSize)
+	}
+}
+
+// cartRAMOffset returns the cartridge RAM offset for a slot 2 address.
+func (m *SMSMemory) cartRAMOffset(addr uint16) int {
+	bank := int(m.mapper[0]>>2) & 1
+	return bank*smsBankSize + int(addr&(smsBankSize-1))
+}
+
+// Fetch reads an opcode byte. The SMS has no M1-specific behavior.
+func (m *SMSMemory) Fetch(addr uint16) uint8 {
+	return m.Read(addr)
+}
+
+// Read reads a byte from the SMS address space.
+func (m *SMSMemory) Read(addr uint16) uint8 {
+	switch {
+	case addr < 0x0400:
+		if int(addr) < len(m.bus.rom) {
+			return m.bus.rom[addr]
+		}
+		return 0xFF
+	case addr < 0x4000:
+		return m.romByte(0, addr)
+	case addr < 0x8000:
+		return m.romByte(1, addr)
+	case addr < 0xC000:
+		if m.cartRAMMapped() {
+			return m.bus.sram[m.cartRAMOffset(addr)]
+		}
+		return m.romByte(2, addr)
+	default:
+		return m.bus.z80RAM[addr&0x1FFF]
+	}
+}
+
+// Write writes a byte to the SMS address space.
+func (m *SMSMemory) Write(addr uint16, val uint8) {
+	switch {
+	case addr < 0x8000:
+		// ROM: ignore writes
+	case addr < 0xC000:
+		if m.cartRAMMapped() {
+			m.bus.sram[m.cartRAMOffset(addr)] = val
+		}
+	default:
+		m.bus.z80RAM[addr&0x1FFF] = val
+		if addr >= 0xFFFC {
+			m.mapper[addr-0xFFFC] = val
+			if m.cartRAMMapped() {
+				m.allocCartRAM()
+			}
+		}
+	}
+}
+
+// In reads from an SMS I/O port. Ports are decoded from A7, A6 and A0.
+func (m *SMSMemory) In(port uint16) uint8 {
+	p := uint8(port)
+	switch p & 0xC1 {
+	case 0x40:
+		return m.bus.vdp.ReadVCounterSMS()
+	case 0x41:
+		return m.bus.vdp.ReadHCounterSMS()
+	case 0x80:
+		return m.bus.vdp.ReadDataSMS()
+	case 0x81:
+		return m.bus.vdp.ReadStatusSMS()
+	case 0xC0:
+		return m.readPortDC()
+	case 0xC1:
+		return m.readPortDD()
+	}
+	// $00-$3F: nothing drives the bus
+	return 0xFF
+}
+
+// Out writes to an SMS I/O port.
+func (m *SMSMemory) Out(port uint16, val uint8) {
+	p := uint8(port)
+	switch p & 0xC1 {
+	case 0x01:
+		m.ioControl = val
+	case 0x40, 0x41:
+		m.bus.writePSG(val)
+	case 0x80:
+		m.bus.vdp.WriteDataSMS(val)
+	case 0x81:
+		m.bus.vdp.WriteControlSMS(val)
+	}
+	// $3E memory control and $C0-$FF writes have no effect on a Genesis
+}
+
+// smsPadBits returns a controller's active-low SMS bits: Up, Down, Left,
+// Right, button 1 (B) and button 2 (C) from bit 0.
+func smsPadBits(inp *Input) uint8 {
+	if !inp.Connected {
+		return 0x3F
+	}
+	var pressed uint8
+	for i, b := range []bool{inp.up, inp.down, inp.left, inp.right, inp.btnB, inp.btnC} {
+		if b {
+			pressed |= 1 << i
+		}
+	}
+	return ^pressed & 0x3F
+}
+
+// readPortDC returns port A: player 1 and player 2 Up/Down.
+func (m *SMSMemory) readPortDC() uint8 {
+	p1 := smsPadBits(&m.bus.io.InputP1)
+	p2 := smsPadBits(&m.bus.io.InputP2)
+	return p1 | (p2&0x03)<<6
+}
+
+// readPortDD returns port B: player 2 Left/Right/buttons, reset (never
+// pressed on a Genesis) and the TH pins. A TH pin set as an output reads
+// back its level on export consoles and inverted on Japanese ones, which
+// games use for region detection.
+func (m *SMSMemory) readPortDD() uint8 {
+	p2 := smsPadBits(&m.bus.io.InputP2)
+	val := p2>>2 | 0x30
+
+	japan := m.bus.io.consoleRegion == ConsoleJapan
+	thA, thB := uint8(0x40), uint8(0x80)
+	if m.ioControl&0x02 == 0 {
+		thA = (m.ioControl << 1) & 0x40
+		if japan {
+			thA ^= 0x40
+		}
+	}
+	if m.ioControl&0x08 == 0 {
+		thB = m.ioControl & 0x80
+		if japan {
+			thB ^= 0x80
+		}
+	}
+	return val | thA | thB
+}
+
+// smsSerializeSize is mapper(4) + ioControl(1) + pauseHeld(1).
+const smsSerializeSize = 6
+
+func (m *SMSMemory) serialize(buf []byte) {
+	copy(buf, m.mapper[:])
+	buf[4] = m.ioControl
+	buf[5] = boolByte(m.pauseHeld)
+}
+
+func (m *SMSMemory) deserialize(buf []byte) {
+	copy(m.mapper[:], buf)
+	if m.cartRAMMapped() {
+		m.allocCartRAM()
+	}
+	m.ioControl = buf[4]
+	m.pauseHeld = buf[5] != 0
+}
+
+// SMSMode reports whether the emulator runs in Master System mode.
+func (e *Emulator) SMSMode() bool {
+	return e.sms != nil
+}
+
+// runFrameSMS executes one frame in Master System mode. The 68000 stays
+// in reset; the Z80 runs every line with its INT line following the VDP.
+func (e *Emulator) runFrameSMS() {
+	e.audioBuffer = e.audioBuffer[:0]
+	e.psg.ResetBuffer()
+
+	// The pause button is wired to NMI and fires on press
+	pause := e.io.InputP1.start
+	if pause && !e.sms.pauseHeld {
+		e.z80.NMI()
+	}
+	e.sms.pauseHeld = pause
+
+	for line := 0; line < e.scanlines; line++ {
+		e.vdp.StartScanlineSMS(line)
+
+		start := e.z80.Cycles()
+		budget := e.z80CyclesPerScanline
+		for budget > 0 {
+			// Port $7F reads the H counter at the Z80's position in the line
+			elapsed := e.z80CyclesPerScanline - budget
+			e.vdp.UpdateHCounter(elapsed*e.m68kCyclesPerScanline/e.z80CyclesPerScanline, e.m68kCyclesPerScanline)
+
+			e.z80.INT(e.vdp.SMSInterrupt(), 0xFF)
+			consumed := e.z80.StepCycles(budget)
+			if consumed == 0 {
+				break
+			}
+			budget -= consumed
+		}
+		e.z80Cycles += e.z80.Cycles() - start
+
+		if line < smsActiveHeight {
+			e.vdp.RenderScanline(line)
+		}
+
+		// The YM2612 is unreachable but keeps the mixer fed with silence
+		e.ym2612.GenerateSamples(e.m68kCyclesPerScanline)
+		e.psg.Run(e.z80CyclesPerScanline)
+		e.endScanline()
+	}
+
+	e.mixAudio()
+	e.frameCount++
+}
@@ -0,0 +1,361 @@
+package emu
+
+import "testing"
+
+// makeSMSROM builds a 64KB SMS ROM with a "TMR SEGA" header. Each 16KB
+// bank is filled with its bank number. The Z80 program:
+//
+//	$0000  di / ld sp,$DFF0 / im 1 / jp $0100
+//	$0038  push af / in a,($BF) / ld hl,$C000 / inc (hl) / pop af / ei / reti
+//	$0066  push af / ld hl,$C001 / inc (hl) / pop af / retn
+//	$0100  reg 1 = $60 (display, frame interrupt)
+//	       CRAM 0 = $30 (blue)
+//	       ei
+//	loop:  in a,($DC) / ld ($C002),a / jr loop
+func makeSMSROM() []byte {
+	rom := make([]byte, 0x10000)
+	for i := range rom {
+		rom[i] = byte(i / smsBankSize)
+	}
+	copy(rom[0x0000:], []byte{0xF3, 0x31, 0xF0, 0xDF, 0xED, 0x56, 0xC3, 0x00, 0x01})
+	copy(rom[0x0038:], []byte{0xF5, 0xDB, 0xBF, 0x21, 0x00, 0xC0, 0x34, 0xF1, 0xFB, 0xED, 0x4D})
+	copy(rom[0x0066:], []byte{0xF5, 0x21, 0x01, 0xC0, 0x34, 0xF1, 0xED, 0x45})
+	copy(rom[0x0100:], []byte{
+		0x3E, 0x60, 0xD3, 0xBF, 0x3E, 0x81, 0xD3, 0xBF,
+		0x3E, 0x00, 0xD3, 0xBF, 0x3E, 0xC0, 0xD3, 0xBF,
+		0x3E, 0x30, 0xD3, 0xBE,
+		0xFB,
+		0xDB, 0xDC, 0x32, 0x02, 0xC0, 0x18, 0xF9,
+	})
+	copy(rom[0x7FF0:], "TMR SEGA")
+	rom[0x7FFF] = 0x4C // Export, 32KB
+	return rom
+}
+
+func makeSMSEmulator(t *testing.T) *Emulator {
+	t.Helper()
+	e, err := NewEmulator(makeSMSROM(), RegionNTSC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !e.SMSMode() {
+		t.Fatal("expected SMS mode from the TMR SEGA header")
+	}
+	return &e
+}
+
+func TestSMS_Detection(t *testing.T) {
+	if !IsSMSROM(makeSMSROM()) {
+		t.Error("SMS ROM not detected")
+	}
+	rom := makeSMSROM()
+	copy(rom[0x100:], "SEGA GENESIS    ")
+	if IsSMSROM(rom) {
+		t.Error("ROM with a Genesis header detected as SMS")
+	}
+
+	rom = makeSMSROM()
+	rom[0x7FFF] = 0x3C
+	if DetectConsoleRegion(rom) != ConsoleJapan {
+		t.Error("expected Japanese console region from SMS header code 3")
+	}
+	if DetectRegion(rom) != RegionNTSC {
+		t.Error("SMS ROMs should default to NTSC")
+	}
+}
+
+func TestSMS_ForcedMode(t *testing.T) {
+	rom := makeSMSROM()
+	copy(rom[0x7FF0:], "NO HEADER")
+	e, err := NewSMSEmulator(rom, RegionNTSC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !e.SMSMode() || !e.vdp.SMSMode() {
+		t.Error("NewSMSEmulator should force SMS mode")
+	}
+}
+
+func TestSMS_Mapper(t *testing.T) {
+	e := makeSMSEmulator(t)
+	m := e.sms
+
+	if m.Read(0x0000) != 0xF3 || m.Read(0x4000) != 1 || m.Read(0x8000) != 2 {
+		t.Fatal("unexpected power-on bank layout")
+	}
+
+	m.Write(0xFFFF, 3)
+	if m.Read(0x8000) != 3 {
+		t.Errorf("slot 2 should map bank 3, read %d", m.Read(0x8000))
+	}
+	m.Write(0xFFFD, 2)
+	if m.Read(0x0000) != 0xF3 {
+		t.Error("first 1KB must not be banked")
+	}
+	if m.Read(0x0400) != 2 {
+		t.Errorf("slot 0 should map bank 2 above $0400, read %d", m.Read(0x0400))
+	}
+	if m.Read(0xFFFF) != 3 {
+		t.Error("mapper writes should also land in RAM")
+	}
+
+	// Bank numbers wrap at the ROM size
+	m.Write(0xFFFE, 5)
+	if m.Read(0x4000) != 1 {
+		t.Errorf("bank 5 of a 4-bank ROM should wrap to 1, read %d", m.Read(0x4000))
+	}
+}
+
+func TestSMS_CartRAM(t *testing.T) {
+	e := makeSMSEmulator(t)
+	m := e.sms
+
+	m.Write(0x8000, 0xAA)
+	if m.Read(0x8000) == 0xAA {
+		t.Fatal("ROM slot 2 should ignore writes")
+	}
+	if e.HasSRAM() {
+		t.Fatal("cartridge RAM should not be reported before the game enables it")
+	}
+	m.Write(0xFFFC, 0x08)
+	m.Write(0x8000, 0xAA)
+	m.Write(0xFFFC, 0x0C)
+	m.Write(0x8000, 0xBB)
+	m.Write(0xFFFC, 0x08)
+	if m.Read(0x8000) != 0xAA {
+		t.Errorf("cart RAM bank 0 = 0x%02X, expected 0xAA", m.Read(0x8000))
+	}
+	m.Write(0xFFFC, 0x00)
+	if m.Read(0x8000) != 2 {
+		t.Error("clearing $FFFC bit 3 should map ROM back in")
+	}
+
+	if !e.HasSRAM() || e.GetSRAMSize() != smsCartRAMSize {
+		t.Fatal("SMS cartridge RAM should be exposed as SRAM")
+	}
+	sram := e.GetSRAM()
+	if sram[0] != 0xAA || sram[smsBankSize] != 0xBB {
+		t.Error("SRAM does not hold the cartridge RAM banks")
+	}
+}
+
+func TestSMS_CartRAMLoaded(t *testing.T) {
+	e := makeSMSEmulator(t)
+	save := make([]byte, smsCartRAMSize)
+	save[0] = 0x5A
+	e.SetSRAM(save)
+	if !e.HasSRAM() {
+		t.Fatal("loading a save should allocate the cartridge RAM")
+	}
+	e.sms.Write(0xFFFC, 0x08)
+	if e.sms.Read(0x8000) != 0x5A {
+		t.Error("cartridge RAM does not hold the loaded save")
+	}
+
+	state, err := e.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	e2 := makeSMSEmulator(t)
+	if err := e2.Deserialize(state); err != nil {
+		t.Fatal(err)
+	}
+	if !e2.HasSRAM() || e2.sms.Read(0x8000) != 0x5A {
+		t.Error("cartridge RAM not restored from the save state")
+	}
+}
+
+func TestSMS_RunFrame(t *testing.T) {
+	e := makeSMSEmulator(t)
+	for i := 0; i < 3; i++ {
+		e.RunFrame()
+	}
+
+	if got := e.bus.z80RAM[0]; got != 3 {
+		t.Errorf("expected 3 frame interrupts, got %d", got)
+	}
+	if e.GetActiveHeight() != smsActiveHeight {
+		t.Errorf("active height = %d, expected %d", e.GetActiveHeight(), smsActiveHeight)
+	}
+	fb := e.GetFramebuffer()
+	if fb[0] != 0 || fb[1] != 0 || fb[2] != 255 {
+		t.Errorf("pixel 0 = %v, expected blue", fb[0:3])
+	}
+	if n := len(e.GetAudioSamples()); n == 0 {
+		t.Error("expected audio samples")
+	}
+}
+
+func TestSMS_HCounter(t *testing.T) {
+	rom := makeSMSROM()
+	// in a,($7F) / ld b,a / nop x10 / in a,($7F) / sub b / ld ($C003),a / jr $
+	copy(rom[0x0100:], []byte{
+		0xDB, 0x7F, 0x47,
+		0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0xDB, 0x7F, 0x90, 0x32, 0x03, 0xC0, 0x18, 0xFE,
+	})
+	e, err := NewEmulator(rom, RegionNTSC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	e.RunFrame()
+
+	// About 55 Z80 cycles pass between the two reads
+	if got := e.bus.z80RAM[3]; got < 0x20 || got > 0x60 {
+		t.Errorf("H counter advanced by 0x%02X between reads", got)
+	}
+}
+
+func TestSMS_Controller(t *testing.T) {
+	e := makeSMSEmulator(t)
+	e.SetInput(0, 1<<0|1<<5) // Up and B (button 1)
+	e.RunFrame()
+	if got := e.bus.z80RAM[2]; got != 0xEE {
+		t.Errorf("port $DC = 0x%02X, expected 0xEE", got)
+	}
+}
+
+func TestSMS_PauseNMI(t *testing.T) {
+	e := makeSMSEmulator(t)
+	e.SetInput(0, 1<<7)
+	e.RunFrame()
+	e.RunFrame()
+	if got := e.bus.z80RAM[1]; got != 1 {
+		t.Fatalf("holding Start should pause once, got %d NMIs", got)
+	}
+	e.SetInput(0, 0)
+	e.RunFrame()
+	e.SetInput(0, 1<<7)
+	e.RunFrame()
+	if got := e.bus.z80RAM[1]; got != 2 {
+		t.Errorf("expected a second NMI, got %d", got)
+	}
+}
+
+func TestSMS_RegionTH(t *testing.T) {
+	e := makeSMSEmulator(t)
+	e.sms.Out(0x3F, 0xF5) // TH-A and TH-B outputs, both high
+	if got := e.sms.In(0xDD) & 0xC0; got != 0xC0 {
+		t.Errorf("export TH = 0x%02X, expected 0xC0", got)
+	}
+	e.io.consoleRegion = ConsoleJapan
+	if got := e.sms.In(0xDD) & 0xC0; got != 0x00 {
+		t.Errorf("Japanese TH = 0x%02X, expected 0x00", got)
+	}
+}
+
+func TestSMS_SerializeRoundTrip(t *testing.T) {
+	e := makeSMSEmulator(t)
+	e.RunFrame()
+	e.sms.Write(0xFFFF, 3)
+	e.vdp.smsCRAM[5] = 0x2A
+	state, err := e.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	e2 := makeSMSEmulator(t)
+	if err := e2.Deserialize(state); err != nil {
+		t.Fatal(err)
+	}
+	if e2.sms.Read(0x8000) != 3 || e2.vdp.smsCRAM[5] != 0x2A {
+		t.Error("SMS mapper or CRAM not restored")
+	}
+}
+
+func TestVDP_Mode4Ports(t *testing.T) {
+	vdp := makeTestVDP()
+	vdp.SetSMSMode(true)
+
+	// VRAM write at $1234, then read back through the prefetch
+	vdp.WriteControlSMS(0x34)
+	vdp.WriteControlSMS(0x52)
+	vdp.WriteDataSMS(0xAB)
+	vdp.WriteDataSMS(0xCD)
+	vdp.WriteControlSMS(0x34)
+	vdp.WriteControlSMS(0x12)
+	if a, b := vdp.ReadDataSMS(), vdp.ReadDataSMS(); a != 0xAB || b != 0xCD {
+		t.Errorf("read back 0x%02X 0x%02X, expected 0xAB 0xCD", a, b)
+	}
+
+	// Register write
+	vdp.WriteControlSMS(0xFF)
+	vdp.WriteControlSMS(0x87)
+	if vdp.regs[7] != 0xFF {
+		t.Errorf("reg 7 = 0x%02X, expected 0xFF", vdp.regs[7])
+	}
+
+	// CRAM write keeps 6 bits
+	vdp.WriteControlSMS(0x11)
+	vdp.WriteControlSMS(0xC0)
+	vdp.WriteDataSMS(0xFF)
+	if vdp.smsCRAM[0x11] != 0x3F {
+		t.Errorf("CRAM 0x11 = 0x%02X, expected 0x3F", vdp.smsCRAM[0x11])
+	}
+}
+
+func TestVDP_Mode4Interrupts(t *testing.T) {
+	vdp := makeTestVDP()
+	vdp.SetSMSMode(true)
+	vdp.regs[0] = 0x10 // Line interrupts
+	vdp.regs[10] = 9
+
+	for line := 193; line < 262; line++ {
+		vdp.StartScanlineSMS(line)
+	}
+	lines := 0
+	for line := 0; line < 192; line++ {
+		vdp.StartScanlineSMS(line)
+		if vdp.SMSInterrupt() {
+			lines++
+			vdp.ReadStatusSMS()
+		}
+	}
+	if lines != 19 {
+		t.Errorf("expected 19 line interrupts with reg 10 = 9, got %d", lines)
+	}
+
+	vdp.StartScanlineSMS(192)
+	if status := vdp.ReadStatusSMS(); status&0x80 == 0 {
+		t.Error("frame interrupt flag should be set at line 192")
+	}
+	if vdp.vCounter != 0xC0 {
+		t.Errorf("V counter = 0x%02X at line 192", vdp.vCounter)
+	}
+	vdp.StartScanlineSMS(0xDB)
+	if vdp.vCounter != 0xD5 {
+		t.Errorf("NTSC V counter should jump to 0xD5, got 0x%02X", vdp.vCounter)
+	}
+}
+
+func TestVDP_Mode4Render(t *testing.T) {
+	vdp := makeTestVDP()
+	vdp.SetSMSMode(true)
+	vdp.regs[1] = 0x40
+	vdp.regs[2] = 0xFF     // Name table at $3800
+	vdp.regs[5] = 0xFF     // SAT at $3F00
+	vdp.smsCRAM[1] = 0x03  // Red
+	vdp.smsCRAM[17] = 0x0C // Green
+
+	// Tile 1: solid color 1; name table entry 0 uses it
+	for i := 0; i < 8; i++ {
+		vdp.vram[32+i*4] = 0xFF
+	}
+	vdp.vram[0x3800] = 1
+	// Sprite 0 at (8, 0) using tile 1, list ends after it
+	vdp.vram[0x3F00] = 0xFF // Y = -1 -> line 0
+	vdp.vram[0x3F01] = 0xD0
+	vdp.vram[0x3F80] = 8
+	vdp.vram[0x3F81] = 1
+
+	vdp.RenderScanline(0)
+	fb := vdp.GetFramebuffer()
+	// Framebuffer x 0 maps to SMS pixel 0 (background tile)
+	if fb[0] != 255 || fb[1] != 0 {
+		t.Errorf("background pixel = %v, expected red", fb[0:3])
+	}
+	// SMS pixel 8 is stretched to x 10
+	if p := 10 * 4; fb[p] != 0 || fb[p+1] != 255 {
+		t.Errorf("sprite pixel = %v, expected green", fb[p:p+3])
+	}
+}
@@ -0,0 +1,212 @@
+package emu
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"sort"
+	"time"
+)
+
+// Save state metadata, stored in an optional META chunk that Deserialize
+// skips:
+//
+//	time(8) + frame(8) + region(1) + version(1+n) +
+//	option count(1) + options(key 1+n, value 1+n) +
+//	thumbWidth(2) + thumbHeight(2) + thumbnail(RGB)
+const (
+	stateInfoVersion   = 1
+	thumbScale         = 4 // Thumbnail pixels are 4x4 framebuffer averages
+	thumbMaxWidth      = ScreenWidth / thumbScale
+	thumbMaxHeight     = MaxScreenHeight / 2 / thumbScale
+	stateInfoMaxOption = 2048 // Bytes of options kept, with their lengths
+	stateInfoMaxSize   = 8 + 8 + 1 + 256 + 1 + stateInfoMaxOption + 4 +
+		thumbMaxWidth*thumbMaxHeight*3
+)
+
+// StateInfo describes a save state for browsing save slots.
+type StateInfo struct {
+	Time    time.Time         // Wall-clock time the state was saved
+	Frame   uint64            // Frames emulated since power-on
+	Region  Region            // Region the game was running in
+	ROMCRC  uint32            // CRC32 of the ROM the state belongs to
+	Version string            // emmd version that saved the state
+	Options map[string]string // Core options set when the state was saved
+
+	// Thumbnail is the frame at save time, downscaled 4x, as RGBA rows of
+	// ThumbWidth pixels.
+	Thumbnail   []byte
+	ThumbWidth  int
+	ThumbHeight int
+}
+
+// SerializeWithInfo creates a save state like Serialize with a StateInfo
+// chunk added, for user save slots. Serialize leaves it out: building the
+// thumbnail costs time on every rewind capture, and the timestamp makes
+// otherwise equal states differ, which matters for rewind deltas and movie
+// checkpoints.
+func (e *Emulator) SerializeWithInfo() ([]byte, error) {
+	return e.serialize(true)
+}
+
+// SerializeWithInfoSize returns the largest size in bytes a state from
+// SerializeWithInfo can take.
+func SerializeWithInfoSize() int {
+	return SerializeSize() + chunkHeaderSize + stateInfoMaxSize
+}
+
+// serializeInfo writes the metadata chunk payload and returns its size.
+func (e *Emulator) serializeInfo(buf []byte) int {
+	binary.LittleEndian.PutUint64(buf[0:], uint64(time.Now().UnixNano()))
+	binary.LittleEndian.PutUint64(buf[8:], e.frameCount)
+	buf[16] = uint8(e.region)
+	offset := 17
+	offset = putShortString(buf, offset, Version)
+
+	keys := make([]string, 0, len(e.options))
+	for k := range e.options {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	countOffset := offset
+	offset++
+	count, used := 0, 0
+	for _, k := range keys {
+		v := e.options[k]
+		size := 2 + len(k) + len(v)
+		if len(k) > 255 || len(v) > 255 || used+size > stateInfoMaxOption || count == 255 {
+			continue
+		}
+		offset = putShortString(buf, offset, k)
+		offset = putShortString(buf, offset, v)
+		used += size
+		count++
+	}
+	buf[countOffset] = uint8(count)
+
+	// Thumbnail, from every other line in interlace mode 2
+	fb := e.vdp.GetFramebuffer()
+	stride := e.vdp.GetStride()
+	lineStep := e.vdp.RenderHeight() / e.vdp.ActiveHeight()
+	w := ScreenWidth / thumbScale
+	h := e.vdp.ActiveHeight() / thumbScale
+	binary.LittleEndian.PutUint16(buf[offset:], uint16(w))
+	binary.LittleEndian.PutUint16(buf[offset+2:], uint16(h))
+	offset += 4
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			var sum [3]int
+			for dy := 0; dy < thumbScale; dy++ {
+				row := (y*thumbScale+dy)*lineStep*stride + x*thumbScale*4
+				for dx := 0; dx < thumbScale; dx++ {
+					for c := 0; c < 3; c++ {
+						sum[c] += int(fb[row+dx*4+c])
+					}
+				}
+			}
+			for c := 0; c < 3; c++ {
+				buf[offset+c] = uint8(sum[c] / (thumbScale * thumbScale))
+			}
+			offset += 3
+		}
+	}
+	return offset
+}
+
+// putShortString writes a length-prefixed string, cut to 255 bytes.
+func putShortString(buf []byte, offset int, s string) int {
+	n := min(len(s), 255)
+	buf[offset] = uint8(n)
+	copy(buf[offset+1:], s[:n])
+	return offset + 1 + n
+}
+
+// ReadStateInfo returns the metadata of a save state without loading it,
+// or nil if the state was saved without metadata. Compact states are
+// accepted; deltas need ExpandState with their keyframe first.
+func ReadStateInfo(data []byte) (*StateInfo, error) {
+	data, err := ExpandState(data, nil)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < stateHeaderSize || string(data[0:12]) != stateMagic {
+		return nil, errors.New("invalid save state magic")
+	}
+	version := binary.LittleEndian.Uint16(data[12:14])
+	if version > stateVersion {
+		return nil, errors.New("unsupported save state version")
+	}
+	if version < chunkedVersion {
+		return nil, nil
+	}
+	chunks, end, err := readChunks(data)
+	if err != nil {
+		return nil, err
+	}
+	if binary.LittleEndian.Uint32(data[18:22]) != crc32.ChecksumIEEE(data[stateHeaderSize:end]) {
+		return nil, errStateCorrupt
+	}
+	c, ok := chunks[chunkInfo]
+	if !ok || c.version > stateInfoVersion {
+		return nil, nil
+	}
+	info, ok := parseStateInfo(c.payload)
+	if !ok {
+		return nil, errStateCorrupt
+	}
+	info.ROMCRC = binary.LittleEndian.Uint32(data[14:18])
+	return info, nil
+}
+
+// parseStateInfo decodes a metadata chunk payload.
+func parseStateInfo(buf []byte) (*StateInfo, bool) {
+	r := infoReader{buf: buf}
+	info := &StateInfo{
+		Time:    time.Unix(0, int64(r.uint64())),
+		Frame:   r.uint64(),
+		Region:  Region(r.byte()),
+		Version: r.string(),
+		Options: make(map[string]string),
+	}
+	for n := int(r.byte()); n > 0; n-- {
+		k := r.string()
+		info.Options[k] = r.string()
+	}
+	info.ThumbWidth = int(r.uint16())
+	info.ThumbHeight = int(r.uint16())
+	if info.ThumbWidth > thumbMaxWidth || info.ThumbHeight > thumbMaxHeight {
+		return nil, false
+	}
+	rgb := r.bytes(info.ThumbWidth * info.ThumbHeight * 3)
+	if r.short {
+		return nil, false
+	}
+	info.Thumbnail = make([]byte, info.ThumbWidth*info.ThumbHeight*4)
+	for i := 0; i < info.ThumbWidth*info.ThumbHeight; i++ {
+		copy(info.Thumbnail[i*4:], rgb[i*3:i*3+3])
+		info.Thumbnail[i*4+3] = 0xFF
+	}
+	return info, true
+}
+
+// infoReader reads metadata fields, recording when the payload runs out.
+type infoReader struct {
+	buf   []byte
+	short bool
+}
+
+func (r *infoReader) bytes(n int) []byte {
+	if n > len(r.buf) {
+		r.short = true
+		r.buf = nil
+		return make([]byte, n)
+	}
+	b := r.buf[:n]
+	r.buf = r.buf[n:]
+	return b
+}
+
+func (r *infoReader) byte() byte     { return r.bytes(1)[0] }
+func (r *infoReader) uint16() uint16 { return binary.LittleEndian.Uint16(r.bytes(2)) }
+func (r *infoReader) uint64() uint64 { return binary.LittleEndian.Uint64(r.bytes(8)) }
+func (r *infoReader) string() string { return string(r.bytes(int(r.byte()))) }
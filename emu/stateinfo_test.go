@@ -0,0 +1,126 @@
+package emu
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestStateInfo_RoundTrip(t *testing.T) {
+	base := createTestEmulator()
+	base.SetOption("region", "auto")
+	base.SetOption("audio_filter", "off")
+	base.RunFrame()
+	base.RunFrame()
+
+	// Fill the top-left 4x4 block so the first thumbnail pixel is known
+	fb := base.vdp.GetFramebuffer()
+	stride := base.vdp.GetStride()
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			copy(fb[y*stride+x*4:], []byte{0x40, 0x80, 0xC0, 0xFF})
+		}
+	}
+
+	before := time.Now()
+	state, err := base.SerializeWithInfo()
+	if err != nil {
+		t.Fatalf("SerializeWithInfo failed: %v", err)
+	}
+	if len(state) > SerializeWithInfoSize() {
+		t.Fatalf("state of %d bytes exceeds SerializeWithInfoSize %d", len(state), SerializeWithInfoSize())
+	}
+
+	info, err := ReadStateInfo(state)
+	if err != nil {
+		t.Fatalf("ReadStateInfo failed: %v", err)
+	}
+	if info == nil {
+		t.Fatal("expected metadata")
+	}
+	if info.Frame != 2 {
+		t.Errorf("Frame: expected 2, got %d", info.Frame)
+	}
+	if info.Time.Before(before.Add(-time.Second)) || info.Time.After(time.Now()) {
+		t.Errorf("Time %v is not the save time", info.Time)
+	}
+	if info.Version != Version {
+		t.Errorf("Version: expected %q, got %q", Version, info.Version)
+	}
+	if info.Region != base.GetRegion() {
+		t.Errorf("Region: expected %v, got %v", base.GetRegion(), info.Region)
+	}
+	if info.ROMCRC != base.bus.romCRC {
+		t.Errorf("ROMCRC: expected 0x%08X, got 0x%08X", base.bus.romCRC, info.ROMCRC)
+	}
+	if info.Options["region"] != "auto" || info.Options["audio_filter"] != "off" {
+		t.Errorf("unexpected options %v", info.Options)
+	}
+	if info.ThumbWidth != ScreenWidth/4 || info.ThumbHeight != base.vdp.ActiveHeight()/4 {
+		t.Errorf("thumbnail size %dx%d", info.ThumbWidth, info.ThumbHeight)
+	}
+	if !bytes.Equal(info.Thumbnail[:4], []byte{0x40, 0x80, 0xC0, 0xFF}) {
+		t.Errorf("first thumbnail pixel: got % X", info.Thumbnail[:4])
+	}
+
+	// The metadata chunk is skipped when loading
+	if err := base.Deserialize(state); err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+}
+
+func TestStateInfo_NotInSerialize(t *testing.T) {
+	base := createTestEmulator()
+	state1, err := base.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	state2, err := base.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	if !bytes.Equal(state1, state2) {
+		t.Error("states of the same frame should be identical")
+	}
+
+	info, err := ReadStateInfo(state1)
+	if err != nil {
+		t.Fatalf("ReadStateInfo failed: %v", err)
+	}
+	if info != nil {
+		t.Error("expected no metadata")
+	}
+}
+
+func TestStateInfo_CompactState(t *testing.T) {
+	base := createTestEmulator()
+	base.RunFrame()
+	state, err := base.SerializeWithInfo()
+	if err != nil {
+		t.Fatalf("SerializeWithInfo failed: %v", err)
+	}
+	compact, err := base.compact(state, nil)
+	if err != nil {
+		t.Fatalf("compact failed: %v", err)
+	}
+
+	info, err := ReadStateInfo(compact)
+	if err != nil {
+		t.Fatalf("ReadStateInfo failed: %v", err)
+	}
+	if info == nil || info.Frame != 1 {
+		t.Errorf("expected metadata for frame 1, got %+v", info)
+	}
+}
+
+func TestStateInfo_Corrupt(t *testing.T) {
+	base := createTestEmulator()
+	state, err := base.SerializeWithInfo()
+	if err != nil {
+		t.Fatalf("SerializeWithInfo failed: %v", err)
+	}
+	state[len(state)-chunkHeaderSize-1] ^= 0xFF
+	if _, err := ReadStateInfo(state); err == nil {
+		t.Error("expected an error for a corrupted state")
+	}
+}
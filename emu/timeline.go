@@ -0,0 +1,57 @@
+package emu
+
+// The master clock drives both CPUs: the 68000 runs at MCLK/7 and the Z80
+// at MCLK/15. A scanline lasts the 68K cycles the emulator schedules for
+// it, so 68K positions in the timeline match the VDP's H counter.
+const (
+	m68kClockDivider = 7
+	z80ClockDivider  = 15
+)
+
+// Timeline is a point in emulated time since power-on. It only depends on
+// the frames run, so the same input gives the same timeline on every run,
+// and it is kept in save states.
+type Timeline struct {
+	Frame        uint64 // Frames completed
+	Scanline     uint64 // Scanlines completed
+	MasterCycles uint64 // Master clock cycles
+	M68KCycles   uint64 // 68000 cycles run, including bus stalls
+	Z80Cycles    uint64 // Z80 cycles run
+}
+
+// Timeline returns the current emulated time. While a debug hook has
+// stopped RunFrame part way through a scanline, it includes the 68K
+// cycles already run on that line.
+//
+// The CPU counts are the cycles each CPU ran rather than the master clock
+// divided down: they include instructions that overshoot the end of a
+// scanline and 68K bus stalls, and leave out time the Z80 spends in reset
+// or off the bus.
+func (e *Emulator) Timeline() Timeline {
+	master := e.masterCycles
+	if e.frame.stopped {
+		master += uint64(e.m68kCyclesPerScanline-e.frame.budget) * m68kClockDivider
+	}
+	return Timeline{
+		Frame:        e.frameCount,
+		Scanline:     e.lineCount,
+		MasterCycles: master,
+		M68KCycles:   e.m68kCycles + e.m68k.Cycles() - e.lineM68K,
+		Z80Cycles:    e.z80Cycles,
+	}
+}
+
+// FrameCount returns the number of frames emulated since power-on.
+func (e *Emulator) FrameCount() uint64 {
+	return e.frameCount
+}
+
+// endScanline advances the timeline past a completed scanline. The
+// YM2612 has been clocked for the whole line, so the VGM logger's
+// position in the line restarts.
+func (e *Emulator) endScanline() {
+	e.lineCount++
+	e.masterCycles += uint64(e.m68kCyclesPerScanline) * m68kClockDivider
+	e.m68kCycles += e.m68k.Cycles() - e.lineM68K
+	e.lineM68K = e.m68k.Cycles()
+}
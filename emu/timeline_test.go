@@ -0,0 +1,105 @@
+package emu
+
+import (
+	"testing"
+
+	"github.com/user-none/go-chip-m68k"
+)
+
+func TestTimeline_AdvancesPerFrame(t *testing.T) {
+	e := createTestEmulator()
+	if tl := e.Timeline(); tl != (Timeline{}) {
+		t.Errorf("expected zero timeline at power-on, got %+v", tl)
+	}
+
+	e.RunFrame()
+	e.RunFrame()
+	tl := e.Timeline()
+	if tl.Frame != 2 {
+		t.Errorf("Frame: expected 2, got %d", tl.Frame)
+	}
+	if tl.Scanline != uint64(2*e.scanlines) {
+		t.Errorf("Scanline: expected %d, got %d", 2*e.scanlines, tl.Scanline)
+	}
+	if want := uint64(2*e.scanlines*e.m68kCyclesPerScanline) * 7; tl.MasterCycles != want {
+		t.Errorf("MasterCycles: expected %d, got %d", want, tl.MasterCycles)
+	}
+	if tl.M68KCycles < tl.MasterCycles/7 {
+		t.Errorf("M68KCycles %d behind the master clock %d", tl.M68KCycles, tl.MasterCycles/7)
+	}
+	if tl.Z80Cycles != 0 {
+		t.Errorf("Z80Cycles: expected 0 while the Z80 is held in reset, got %d", tl.Z80Cycles)
+	}
+}
+
+func TestTimeline_CPUCycles(t *testing.T) {
+	e := createTestEmulator()
+	e.bus.WriteCycle(0, m68k.Word, 0xA11200, 0x0100) // Release Z80 reset
+	m68kStart, z80Start := e.m68k.Cycles(), e.z80.Cycles()
+	e.RunFrame()
+	e.RunFrame()
+
+	tl := e.Timeline()
+	if got, want := tl.M68KCycles, e.m68k.Cycles()-m68kStart; got != want {
+		t.Errorf("M68KCycles: expected the %d cycles run, got %d", want, got)
+	}
+	if got, want := tl.Z80Cycles, e.z80.Cycles()-z80Start; got != want {
+		t.Errorf("Z80Cycles: expected the %d cycles run, got %d", want, got)
+	}
+	if nominal := tl.MasterCycles / 15; tl.Z80Cycles == nominal {
+		t.Errorf("Z80Cycles %d should differ from the nominal %d", tl.Z80Cycles, nominal)
+	}
+}
+
+func TestTimeline_PAL(t *testing.T) {
+	e := createTestEmulator()
+	e.SetRegion(RegionPAL)
+	e.RunFrame()
+	if got := e.Timeline().Scanline; got != 313 {
+		t.Errorf("expected 313 PAL scanlines, got %d", got)
+	}
+}
+
+func TestTimeline_DebugStop(t *testing.T) {
+	e := createTestEmulator()
+	e.SetDebugHook(&stopAt{pc: 0x202}) // After the first NOP
+	e.RunFrame()
+	if !e.DebugStopped() {
+		t.Fatal("expected stop")
+	}
+	tl := e.Timeline()
+	if tl.Frame != 0 || tl.Scanline != 0 {
+		t.Errorf("expected a stop within the first scanline, got %+v", tl)
+	}
+	if tl.M68KCycles == 0 || tl.M68KCycles >= uint64(e.m68kCyclesPerScanline) {
+		t.Errorf("M68KCycles %d should be within the first scanline", tl.M68KCycles)
+	}
+
+	e.SetDebugHook(nil)
+	e.RunFrame()
+	if got := e.Timeline().Scanline; got != uint64(e.scanlines) {
+		t.Errorf("expected %d scanlines after resuming, got %d", e.scanlines, got)
+	}
+}
+
+func TestTimeline_Serialized(t *testing.T) {
+	e := createTestEmulator()
+	for i := 0; i < 3; i++ {
+		e.RunFrame()
+	}
+	want := e.Timeline()
+	state, err := e.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	e.RunFrame()
+	if err := e.Deserialize(state); err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	if got := e.Timeline(); got != want {
+		t.Errorf("expected %+v after loading, got %+v", want, got)
+	}
+	if got := e.FrameCount(); got != 3 {
+		t.Errorf("FrameCount: expected 3, got %d", got)
+	}
+}
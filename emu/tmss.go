@@ -0,0 +1,163 @@
+package emu
+
+import (
+	"errors"
+
+	"github.com/user-none/go-chip-m68k"
+)
+
+const (
+	// tmssSerializeSize is the fixed size of TMSS state in save states.
+	// enabled(1) + unlocked(1) + biosMapped(1) + lockup(1) + reg(4)
+	tmssSerializeSize = 8
+
+	maxTMSSBIOSSize = 0x10000 // Boot ROMs are 2KB; allow headroom for variants
+	tmssVersion     = 0x01    // Version register bits 3-0 on TMSS consoles
+)
+
+// tmssUnlockKey is the value software writes to $A14000 to unlock the VDP.
+var tmssUnlockKey = [4]byte{'S', 'E', 'G', 'A'}
+
+// tmss holds the Trademark Security System state of a Model 1 VA6+
+// console. When enabled, the VDP is locked until "SEGA" is written to
+// $A14000. Touching the VDP while locked stalls the 68K forever because
+// the VDP never asserts DTACK. An optional boot ROM is mapped over the
+// cartridge at reset until bit 0 of $A14101 is set.
+type tmss struct {
+	enabled    bool
+	bios       []byte
+	unlocked   bool    // "SEGA" written to $A14000
+	biosMapped bool    // Boot ROM visible at $000000 instead of the cartridge
+	lockup     bool    // VDP accessed while locked; the 68K is frozen
+	reg        [4]byte // $A14000-$A14003
+}
+
+// reset restores the power-on TMSS state.
+func (t *tmss) reset() {
+	t.unlocked = !t.enabled
+	t.biosMapped = t.enabled && t.bios != nil
+	t.lockup = false
+	t.reg = [4]byte{}
+}
+
+// vdpAccessAllowed reports whether the 68K may access the VDP. A locked
+// access freezes the 68K the way a VA6+ console does; frontends can report
+// it through TMSSLockup.
+func (b *GenesisBus) vdpAccessAllowed() bool {
+	if b.tmss.unlocked {
+		return true
+	}
+	b.tmss.lockup = true
+	return false
+}
+
+// readBIOS reads from the TMSS boot ROM, mirrored across the cartridge area.
+func (b *GenesisBus) readBIOS(s m68k.Size, addr uint32) uint32 {
+	bios := b.tmss.bios
+	n := uint32(len(bios))
+	byteAt := func(a uint32) uint32 {
+		return uint32(bios[a%n])
+	}
+	switch s {
+	case m68k.Byte:
+		return byteAt(addr)
+	case m68k.Word:
+		return byteAt(addr)<<8 | byteAt(addr+1)
+	case m68k.Long:
+		return byteAt(addr)<<24 | byteAt(addr+1)<<16 | byteAt(addr+2)<<8 | byteAt(addr+3)
+	}
+	return 0
+}
+
+// writeTMSS handles writes to the TMSS unlock register ($A14000-$A14003)
+// and the boot ROM mapping register ($A14101).
+func (b *GenesisBus) writeTMSS(s m68k.Size, addr uint32, value uint32) {
+	if !b.tmss.enabled {
+		return
+	}
+	if addr == 0xA14100 || addr == 0xA14101 {
+		// Bit 0: 0 = boot ROM mapped, 1 = cartridge mapped
+		if b.tmss.bios != nil {
+			b.tmss.biosMapped = value&0x01 == 0
+		}
+		return
+	}
+
+	offset := addr - 0xA14000
+	switch s {
+	case m68k.Byte:
+		b.tmss.reg[offset] = byte(value)
+	case m68k.Word:
+		b.tmss.reg[offset&2] = byte(value >> 8)
+		b.tmss.reg[offset&2+1] = byte(value)
+	case m68k.Long:
+		b.tmss.reg = [4]byte{byte(value >> 24), byte(value >> 16), byte(value >> 8), byte(value)}
+	}
+	b.tmss.unlocked = b.tmss.reg == tmssUnlockKey
+}
+
+// SetTMSSBIOS loads a TMSS boot ROM image. It is mapped at reset while
+// TMSS mode is enabled. Pass nil to remove it.
+func (e *Emulator) SetTMSSBIOS(bios []byte) error {
+	if bios != nil && (len(bios) < 8 || len(bios) > maxTMSSBIOSSize) {
+		return errors.New("invalid TMSS BIOS size")
+	}
+	if bios == nil {
+		e.bus.tmss.bios = nil
+	} else {
+		e.bus.tmss.bios = append([]byte(nil), bios...)
+	}
+	if e.bus.tmss.enabled {
+		e.bus.tmss.reset()
+		e.m68k.Reset()
+	}
+	return nil
+}
+
+// SetTMSS enables or disables Trademark Security System emulation.
+// Enabling it locks the VDP until "SEGA" is written to $A14000 and
+// reports hardware version 1 in the version register. When a boot ROM
+// is loaded, the 68K is reset so it starts from the boot ROM (or from
+// the cartridge when disabling).
+func (e *Emulator) SetTMSS(enabled bool) {
+	if e.bus.tmss.enabled == enabled {
+		return
+	}
+	e.bus.tmss.enabled = enabled
+	e.bus.tmss.reset()
+	if enabled {
+		e.io.version = tmssVersion
+	} else {
+		e.io.version = 0
+	}
+	if e.bus.tmss.bios != nil {
+		e.m68k.Reset()
+	}
+}
+
+// TMSSLockup reports whether the 68K is frozen because software touched
+// the VDP before writing the TMSS unlock key.
+func (e *Emulator) TMSSLockup() bool {
+	return e.bus.tmss.lockup
+}
+
+// serialize writes TMSS state to buf (tmssSerializeSize bytes).
+func (t *tmss) serialize(buf []byte) {
+	buf[0] = boolByte(t.enabled)
+	buf[1] = boolByte(t.unlocked)
+	buf[2] = boolByte(t.biosMapped)
+	buf[3] = boolByte(t.lockup)
+	copy(buf[4:8], t.reg[:])
+}
+
+// deserialize reads TMSS state from buf (tmssSerializeSize bytes).
+// The enabled flag is configuration and is not restored.
+func (t *tmss) deserialize(buf []byte) {
+	if !t.enabled {
+		return
+	}
+	t.unlocked = buf[1] != 0
+	t.biosMapped = buf[2] != 0 && t.bios != nil
+	t.lockup = buf[3] != 0
+	copy(t.reg[:], buf[4:8])
+}
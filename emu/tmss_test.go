@@ -0,0 +1,150 @@
+package emu
+
+import (
+	"testing"
+
+	"github.com/user-none/go-chip-m68k"
+)
+
+// makeTMSSBIOS creates a boot ROM whose reset vector points at 0x100
+// with a BRA.S -2 (infinite loop) at that address.
+func makeTMSSBIOS() []byte {
+	bios := make([]byte, 0x800)
+	copy(bios[0:8], []byte{0x00, 0xFF, 0xFE, 0x00, 0x00, 0x00, 0x01, 0x00})
+	bios[0x100] = 0x60
+	bios[0x101] = 0xFE
+	return bios
+}
+
+func TestTMSS_DisabledByDefault(t *testing.T) {
+	e := createTestEmulator()
+	e.bus.WriteCycle(0, m68k.Word, 0xC00004, 0x8144)
+	if e.TMSSLockup() {
+		t.Fatal("VDP access should not lock up with TMSS disabled")
+	}
+	if e.vdp.regs[1] != 0x44 {
+		t.Errorf("VDP register 1 = 0x%02X, expected 0x44", e.vdp.regs[1])
+	}
+	if v := e.io.ReadRegister(0, 0xA10001); v&0x0F != 0 {
+		t.Errorf("version nibble = %d, expected 0", v&0x0F)
+	}
+}
+
+func TestTMSS_VersionRegister(t *testing.T) {
+	e := createTestEmulator()
+	e.SetTMSS(true)
+	if v := e.io.ReadRegister(0, 0xA10001); v != 0xA1 {
+		t.Errorf("version register = 0x%02X, expected 0xA1", v)
+	}
+}
+
+func TestTMSS_LockedVDPAccessLocksUp(t *testing.T) {
+	e := createTestEmulator()
+	e.SetTMSS(true)
+
+	e.bus.WriteCycle(0, m68k.Word, 0xC00004, 0x8144)
+	if !e.TMSSLockup() {
+		t.Fatal("expected lockup after VDP access while locked")
+	}
+	if e.vdp.regs[1] != 0 {
+		t.Error("locked VDP write should be ignored")
+	}
+
+	pc := e.m68k.Registers().PC
+	e.RunFrame()
+	if e.m68k.Registers().PC != pc {
+		t.Errorf("68K should be frozen: PC moved from %06X to %06X", pc, e.m68k.Registers().PC)
+	}
+}
+
+func TestTMSS_UnlockLong(t *testing.T) {
+	e := createTestEmulator()
+	e.SetTMSS(true)
+	e.bus.WriteCycle(0, m68k.Long, 0xA14000, 0x53454741)
+	e.bus.WriteCycle(0, m68k.Word, 0xC00004, 0x8144)
+	if e.TMSSLockup() {
+		t.Fatal("VDP access after unlock should not lock up")
+	}
+	if e.vdp.regs[1] != 0x44 {
+		t.Errorf("VDP register 1 = 0x%02X, expected 0x44", e.vdp.regs[1])
+	}
+}
+
+func TestTMSS_UnlockWords(t *testing.T) {
+	e := createTestEmulator()
+	e.SetTMSS(true)
+	e.bus.WriteCycle(0, m68k.Word, 0xA14000, 0x5345)
+	e.bus.WriteCycle(0, m68k.Word, 0xA14002, 0x4741)
+	if !e.bus.tmss.unlocked {
+		t.Error("expected unlock after two word writes")
+	}
+}
+
+func TestTMSS_WrongKeyStaysLocked(t *testing.T) {
+	e := createTestEmulator()
+	e.SetTMSS(true)
+	e.bus.WriteCycle(0, m68k.Long, 0xA14000, 0x53454742)
+	if e.bus.tmss.unlocked {
+		t.Error("wrong key should not unlock")
+	}
+}
+
+func TestTMSS_BootROMMapping(t *testing.T) {
+	e := createTestEmulator()
+	if err := e.SetTMSSBIOS(makeTMSSBIOS()); err != nil {
+		t.Fatalf("SetTMSSBIOS failed: %v", err)
+	}
+	e.SetTMSS(true)
+
+	if pc := e.m68k.Registers().PC; pc != 0x100 {
+		t.Errorf("PC = %06X, expected boot ROM entry 0x100", pc)
+	}
+	if got := e.bus.ReadCycle(0, m68k.Word, 0x100); got != 0x60FE {
+		t.Errorf("boot ROM read = 0x%04X, expected 0x60FE", got)
+	}
+	// Boot ROM is mirrored across the cartridge area
+	if got := e.bus.ReadCycle(0, m68k.Word, 0x900); got != 0x60FE {
+		t.Errorf("boot ROM mirror read = 0x%04X, expected 0x60FE", got)
+	}
+
+	// Switch to the cartridge
+	e.bus.WriteCycle(0, m68k.Byte, 0xA14101, 0x0101)
+	if got := e.bus.ReadCycle(0, m68k.Long, 4); got != 0x200 {
+		t.Errorf("cartridge reset vector = 0x%08X, expected 0x200", got)
+	}
+}
+
+func TestTMSS_DisableRestoresCartridgeBoot(t *testing.T) {
+	e := createTestEmulator()
+	e.SetTMSSBIOS(makeTMSSBIOS())
+	e.SetTMSS(true)
+	e.SetTMSS(false)
+	if pc := e.m68k.Registers().PC; pc != 0x200 {
+		t.Errorf("PC = %06X, expected cartridge entry 0x200", pc)
+	}
+}
+
+func TestTMSS_InvalidBIOS(t *testing.T) {
+	e := createTestEmulator()
+	if err := e.SetTMSSBIOS([]byte{1, 2, 3}); err == nil {
+		t.Error("expected error for undersized BIOS")
+	}
+}
+
+func TestTMSS_SerializeRoundTrip(t *testing.T) {
+	e := createTestEmulator()
+	e.SetTMSS(true)
+	e.bus.WriteCycle(0, m68k.Long, 0xA14000, 0x53454741)
+
+	state, err := e.Serialize()
+	if err != nil {
+		t.Fatalf("Serialize failed: %v", err)
+	}
+	e.bus.WriteCycle(0, m68k.Long, 0xA14000, 0)
+	if err := e.Deserialize(state); err != nil {
+		t.Fatalf("Deserialize failed: %v", err)
+	}
+	if !e.bus.tmss.unlocked {
+		t.Error("unlock state not restored")
+	}
+}
@@ -50,7 +50,7 @@ type VDP struct {
 	vBlank           bool   // Bit 3: in VBlank
 	hBlank           bool   // Bit 2: in HBlank
 	dmaEndCycle      uint64 // Cycle at which current DMA completes (0 = idle)
-	dmaStallCycles   int    // 68K cycles the CPU is stalled by 68K->VDP DMA (0 = none)
+	dmaStallCycles   int    // 68K cycles the CPU is stalled by DMA or a full FIFO (0 = none)
 	assertedIntLevel uint8  // Interrupt level asserted by register write (0 = none)
 
 	// Counters
@@ -64,12 +64,29 @@ type VDP struct {
 	hIntCounter    int  // Reloaded from reg 10
 	dmaFillPending bool // Waiting for data port write to trigger fill
 
+	// Write FIFO: cycles at which queued data port writes reach memory,
+	// oldest first, and the writes themselves
+	fifoDrain [fifoSize]uint64
+	fifoData  [fifoSize]fifoWrite
+	fifoCount int
+
 	// Interlace
 	oddField bool // Toggles each frame for interlace modes
 
 	// Region
 	isPAL bool
 
+	// Mode 4 (Master System compatibility)
+	smsMode        bool
+	smsCRAM        [32]uint8 // 32 entries of --BBGGRR
+	lineIntPending bool      // Mode 4 line interrupt flag
+
+	// Debug register ($C0001C): layer disable and forced colours
+	debugReg uint16
+
+	// Frontend layer visibility (Layer* bits); not part of the machine state
+	layerMask uint8
+
 	// Bus reference for DMA 68K transfers
 	bus BusReader
 
@@ -94,10 +111,26 @@ type VDP struct {
 func NewVDP(isPAL bool) *VDP {
 	return &VDP{
 		isPAL:       isPAL,
+		layerMask:   LayerAll,
 		framebuffer: image.NewRGBA(image.Rect(0, 0, ScreenWidth, MaxScreenHeight)),
 	}
 }
 
+// reset returns the VDP to its power-on state with a blank framebuffer.
+// The region, Mode 4 selection, layer mask and bus are kept.
+func (v *VDP) reset() {
+	*v = VDP{
+		isPAL:        v.isPAL,
+		smsMode:      v.smsMode,
+		layerMask:    v.layerMask,
+		bus:          v.bus,
+		framebuffer:  v.framebuffer,
+		cramChanges:  v.cramChanges[:0],
+		vsramChanges: v.vsramChanges[:0],
+	}
+	clear(v.framebuffer.Pix)
+}
+
 // SetBus sets the bus reader for DMA transfers.
 // Called after GenesisBus is created due to circular construction dependency.
 func (v *VDP) SetBus(bus BusReader) {
@@ -105,7 +138,7 @@ func (v *VDP) SetBus(bus BusReader) {
 }
 
 // DMAStallCycles returns and clears the 68K stall cycles from a
-// 68K->VDP DMA. Called by the emulator loop after each StepCycles.
+// 68K->VDP DMA or a data port write to a full FIFO. Called by the emulator loop after each StepCycles.
 func (v *VDP) DMAStallCycles() int {
 	n := v.dmaStallCycles
 	v.dmaStallCycles = 0
@@ -165,6 +198,9 @@ func (v *VDP) autoIncrement() uint16 {
 
 // ActiveHeight returns the current active display height based on V30 mode.
 func (v *VDP) ActiveHeight() int {
+	if v.smsMode {
+		return smsActiveHeight
+	}
 	if v.v30Mode() {
 		return 240
 	}
@@ -190,6 +226,7 @@ func (v *VDP) activeWidth() int {
 // BeginScanline snapshots CRAM/VSRAM and resets change tracking for mid-scanline writes.
 // Called at the start of each scanline before M68K runs.
 func (v *VDP) BeginScanline(startCycle uint64, totalCycles int) {
+	v.drainFIFO(startCycle)
 	v.cramSnapshot = v.cram
 	v.cramChanges = v.cramChanges[:0]
 	v.vsramSnapshot = v.vsram
@@ -235,6 +272,12 @@ func (v *VDP) hvCounterLatchEnabled() bool {
 	return v.regs[0]&0x02 != 0
 }
 
+// externalIntEnabled reports whether register 11 enables the level 2
+// external interrupt.
+func (v *VDP) externalIntEnabled() bool {
+	return v.regs[11]&0x08 != 0
+}
+
 func (v *VDP) shadowHighlightMode() bool {
 	return v.regs[12]&0x08 != 0
 }
@@ -321,8 +364,8 @@ func (v *VDP) ReadControl(cycle uint64) uint16 {
 	// Bits 15:10 read as fixed value 011101
 	var status uint16 = 0x7400
 
-	// Bit 9: FIFO empty (always 1, no FIFO emulation)
-	status |= 1 << 9
+	// Bits 9:8: FIFO empty / FIFO full
+	status |= v.fifoStatus(cycle)
 
 	// Bit 7: V-int pending
 	if v.vIntPending {
@@ -406,18 +449,18 @@ func (v *VDP) WriteData(cycle uint64, val uint16) {
 	startFill := v.dmaFillPending
 
 	target := v.code & 0x0F
+
+	// The write reaches memory when the FIFO hands it an access slot
+	writeCycle := cycle
+	if cycle > 0 {
+		writeCycle = v.fifoPush(cycle, target, v.address, val)
+	}
 	switch {
 	case target == 0x01: // VRAM write
-		addr := v.address & 0xFFFF
-		if addr&1 == 0 {
-			// Even address: normal write
-			v.vram[addr] = uint8(val >> 8)
-			v.vram[(addr+1)&0xFFFF] = uint8(val)
-		} else {
-			// Odd address: byte-swap, write to word-aligned address
-			wordAddr := addr & 0xFFFE
-			v.vram[wordAddr] = uint8(val)
-			v.vram[(wordAddr+1)&0xFFFF] = uint8(val >> 8)
+		if cycle == 0 {
+			// Untimed access (Z80) bypasses the FIFO
+			v.commitFIFO()
+			v.writeVRAM(v.address, val)
 		}
 	case target == 0x03: // CRAM write
 		addr := v.address & 0x7F // 128 bytes (mask to 7 bits)
@@ -427,7 +470,7 @@ func (v *VDP) WriteData(cycle uint64, val uint16) {
 		v.cram[(addr&0x7E)+1] = lo
 		// Log the change with pixel position for mid-scanline rendering
 		v.cramChanges = append(v.cramChanges, cramChange{
-			pixelX: v.cycleToPixel(cycle),
+			pixelX: v.cycleToPixel(writeCycle),
 			addr:   uint8(addr & 0x7E),
 			hi:     hi,
 			lo:     lo,
@@ -442,7 +485,7 @@ func (v *VDP) WriteData(cycle uint64, val uint16) {
 				v.vsram[(addr&0x7E)+1] = lo
 			}
 			v.vsramChanges = append(v.vsramChanges, vsramChange{
-				pixelX: v.cycleToPixel(cycle),
+				pixelX: v.cycleToPixel(writeCycle),
 				addr:   int(addr & 0x7E),
 				hi:     hi,
 				lo:     lo,
@@ -458,6 +501,19 @@ func (v *VDP) WriteData(cycle uint64, val uint16) {
 	}
 }
 
+// writeVRAM writes a data port word to VRAM. Odd addresses write the
+// byte-swapped word to the word-aligned address.
+func (v *VDP) writeVRAM(addr, val uint16) {
+	if addr&1 == 0 {
+		v.vram[addr] = uint8(val >> 8)
+		v.vram[addr+1] = uint8(val)
+	} else {
+		wordAddr := addr & 0xFFFE
+		v.vram[wordAddr] = uint8(val)
+		v.vram[wordAddr+1] = uint8(val >> 8)
+	}
+}
+
 // ReadData reads from the VDP data port.
 // Returns the pre-fetched value, then fetches the next value.
 func (v *VDP) ReadData() uint16 {
@@ -469,7 +525,9 @@ func (v *VDP) ReadData() uint16 {
 }
 
 // prefetch reads the next value into readBuffer based on current code and address.
+// Reads wait for the FIFO to write back first.
 func (v *VDP) prefetch() {
+	v.commitFIFO()
 	target := v.code & 0x0F
 	switch {
 	case target == 0x00: // VRAM read
@@ -606,6 +664,15 @@ func (v *VDP) LatchHVCounter() {
 	}
 }
 
+// LatchHVCounterAtCycle captures the HV counter at the given CPU cycle if
+// latch mode is enabled, as when a light gun pulses HL mid-scanline.
+func (v *VDP) LatchHVCounterAtCycle(cycle uint64) {
+	if v.hvCounterLatchEnabled() {
+		v.hvLatchValue = v.formatHVCounter(v.hCounterFromCycle(cycle))
+		v.hvLatched = true
+	}
+}
+
 // vCounterValue maps a scanline number to the V counter value.
 func (v *VDP) vCounterValue(line int) uint16 {
 	if v.isPAL {
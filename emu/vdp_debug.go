@@ -0,0 +1,76 @@
+package emu
+
+// Layer bits for SetLayerEnabled and the VDP layer mask.
+const (
+	LayerPlaneA = 1 << iota
+	LayerPlaneB
+	LayerWindow
+	LayerSprites
+	LayerBackdrop
+
+	LayerAll = LayerPlaneA | LayerPlaneB | LayerWindow | LayerSprites | LayerBackdrop
+)
+
+// Debug register ($C0001C) bits used by the renderer.
+//
+//	Bit 6:    disable all layers; the composited output reads all ones
+//	Bits 8-7: AND the output with the sprite (1), plane A (2) or plane B (3)
+//	          pixel, so bit 6 plus a selection solos that layer
+const (
+	debugDisableLayers = 0x0040
+	debugLayerShift    = 7
+	debugRenderMask    = 0x01C0
+)
+
+// WriteDebug writes the VDP debug register.
+func (v *VDP) WriteDebug(val uint16) {
+	v.debugReg = val
+}
+
+// SetLayerMask sets which layers are drawn (Layer* bits). Hidden planes and
+// sprites are treated as transparent; a hidden backdrop is drawn black.
+// Sprite evaluation still runs so overflow and collision flags are unchanged.
+// The mask applies to the Genesis display modes only.
+func (v *VDP) SetLayerMask(mask uint8) {
+	v.layerMask = mask & LayerAll
+}
+
+// LayerMask returns the visible layers (Layer* bits).
+func (v *VDP) LayerMask() uint8 {
+	return v.layerMask
+}
+
+// debugPixel applies the debug register to a composited pixel and returns
+// the resulting palette and color index.
+func (v *VDP) debugPixel(cpal, cidx uint8, spr layerPixel, aPal, aIdx, bPal, bIdx uint8) (uint8, uint8) {
+	c := cpal<<4 | cidx
+	if v.debugReg&debugDisableLayers != 0 {
+		c = 0x3F
+	}
+	switch (v.debugReg >> debugLayerShift) & 0x03 {
+	case 1:
+		c &= spr.palette<<4 | spr.colorIndex
+	case 2:
+		c &= aPal<<4 | aIdx
+	case 3:
+		c &= bPal<<4 | bIdx
+	}
+	return c >> 4, c & 0x0F
+}
+
+// SetLayerEnabled shows or hides a display layer (one of the Layer*
+// constants). This only affects rendering, not the emulated machine.
+func (e *Emulator) SetLayerEnabled(layer int, enabled bool) {
+	mask := e.vdp.LayerMask()
+	if enabled {
+		mask |= uint8(layer)
+	} else {
+		mask &^= uint8(layer)
+	}
+	e.vdp.SetLayerMask(mask)
+}
+
+// LayerEnabled reports whether a display layer is shown.
+func (e *Emulator) LayerEnabled(layer int) bool {
+	return e.vdp.LayerMask()&uint8(layer) != 0
+}
@@ -0,0 +1,190 @@
+package emu
+
+import (
+	"testing"
+
+	"github.com/user-none/go-chip-m68k"
+)
+
+// makeLayerTestVDP returns an H40 VDP with plane A showing a white tile
+// over a red plane B tile in the first cell, plane B red in the second
+// cell and a blue backdrop.
+func makeLayerTestVDP() *VDP {
+	vdp := makeTestVDP()
+	vdp.regs[1] = 0x44  // Display enabled
+	vdp.regs[12] = 0x81 // H40
+	vdp.regs[16] = 0x01 // 64x32 nametables
+	vdp.regs[2] = 0x30  // Plane A at 0xC000
+	vdp.regs[4] = 0x07  // Plane B at 0xE000
+	vdp.regs[13] = 0x3F // H-scroll at 0xFC00
+	vdp.regs[5] = 0x6C  // Sprites at 0xD800
+	vdp.regs[7] = 0x02  // Backdrop: palette 0, color 2
+
+	vdp.cram[2], vdp.cram[3] = 0x0E, 0xEE // Color 1: white
+	vdp.cram[4], vdp.cram[5] = 0x0E, 0x00 // Color 2: blue
+	vdp.cram[6], vdp.cram[7] = 0x00, 0x0E // Color 3: red
+
+	for i := 0; i < 32; i++ {
+		vdp.vram[32+i] = 0x11 // Tile 1: color 1
+		vdp.vram[64+i] = 0x33 // Tile 2: color 3
+	}
+	vdp.vram[0xC001] = 0x01 // Plane A cell 0: tile 1
+	vdp.vram[0xE001] = 0x02 // Plane B cell 0: tile 2
+	vdp.vram[0xE003] = 0x02 // Plane B cell 1: tile 2
+	return vdp
+}
+
+// pixelAt returns the RGB value of framebuffer pixel (x, y).
+func pixelAt(vdp *VDP, x, y int) [3]uint8 {
+	p := y*vdp.framebuffer.Stride + x*4
+	pix := vdp.framebuffer.Pix
+	return [3]uint8{pix[p], pix[p+1], pix[p+2]}
+}
+
+var (
+	rgbBlack = [3]uint8{0, 0, 0}
+	rgbWhite = [3]uint8{255, 255, 255}
+	rgbRed   = [3]uint8{255, 0, 0}
+	rgbBlue  = [3]uint8{0, 0, 255}
+)
+
+func TestVDP_LayerMask(t *testing.T) {
+	tests := []struct {
+		name    string
+		mask    uint8
+		x       int
+		wantRGB [3]uint8
+	}{
+		{"all layers", LayerAll, 0, rgbWhite},
+		{"plane A hidden", LayerAll &^ LayerPlaneA, 0, rgbRed},
+		{"planes hidden", LayerSprites | LayerWindow | LayerBackdrop, 0, rgbBlue},
+		{"plane B hidden", LayerAll &^ LayerPlaneB, 8, rgbBlue},
+		{"backdrop hidden", LayerAll &^ LayerBackdrop, 16, rgbBlack},
+		{"backdrop hidden under plane", LayerAll &^ LayerBackdrop, 8, rgbRed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vdp := makeLayerTestVDP()
+			vdp.SetLayerMask(tt.mask)
+			vdp.RenderScanline(0)
+			if got := pixelAt(vdp, tt.x, 0); got != tt.wantRGB {
+				t.Errorf("pixel %d: expected %v, got %v", tt.x, tt.wantRGB, got)
+			}
+		})
+	}
+}
+
+func TestVDP_LayerMaskWindow(t *testing.T) {
+	vdp := makeLayerTestVDP()
+	vdp.regs[3] = 0x38  // Window at 0xE000 (same as plane B)
+	vdp.regs[17] = 0x80 // Window right of column 0: whole line
+	vdp.RenderScanline(0)
+	if got := pixelAt(vdp, 8, 0); got != rgbRed {
+		t.Fatalf("expected window pixel red, got %v", got)
+	}
+
+	vdp.SetLayerMask(LayerAll &^ LayerWindow)
+	vdp.RenderScanline(0)
+	if got := pixelAt(vdp, 8, 0); got != rgbRed {
+		t.Errorf("hidden window should reveal plane B, got %v", got)
+	}
+	if got := pixelAt(vdp, 16, 0); got != rgbBlue {
+		t.Errorf("hidden window should reveal backdrop, got %v", got)
+	}
+}
+
+func TestVDP_LayerMaskSpritesKeepsFlags(t *testing.T) {
+	vdp := makeLayerTestVDP()
+	// Two overlapping 1x1 sprites at (144,128) using tile 1
+	sat := 0xD800
+	for i, link := range []uint8{1, 0} {
+		e := sat + i*8
+		vdp.vram[e+0], vdp.vram[e+1] = 0x00, 0x80 // Y = 128
+		vdp.vram[e+2], vdp.vram[e+3] = 0x00, link // 1x1 cell
+		vdp.vram[e+4], vdp.vram[e+5] = 0x00, 0x01 // Tile 1
+		vdp.vram[e+6], vdp.vram[e+7] = 0x00, 0x90 // X = 144
+	}
+	vdp.SetLayerMask(LayerAll &^ LayerSprites)
+	vdp.RenderScanline(0)
+
+	if got := pixelAt(vdp, 16, 0); got != rgbBlue {
+		t.Errorf("hidden sprite should not be drawn, got %v", got)
+	}
+	if !vdp.spriteCollision {
+		t.Error("hiding sprites should not change the collision flag")
+	}
+}
+
+func TestVDP_DebugRegisterSolo(t *testing.T) {
+	vdp := makeLayerTestVDP()
+	vdp.WriteDebug(debugDisableLayers | 3<<debugLayerShift) // Solo plane B
+	vdp.RenderScanline(0)
+
+	// Plane B is drawn even under plane A
+	if got := pixelAt(vdp, 0, 0); got != rgbRed {
+		t.Errorf("solo plane B pixel 0: expected red, got %v", got)
+	}
+	// Transparent plane B pixels show color 0 instead of the backdrop
+	if got := pixelAt(vdp, 16, 0); got != rgbBlack {
+		t.Errorf("solo plane B pixel 16: expected color 0, got %v", got)
+	}
+}
+
+func TestVDP_DebugRegisterForcedColor(t *testing.T) {
+	vdp := makeLayerTestVDP()
+	vdp.cram[126], vdp.cram[127] = 0x00, 0x0E // Color 63: red
+	vdp.WriteDebug(debugDisableLayers)
+	vdp.RenderScanline(0)
+	for _, x := range []int{0, 8, 16} {
+		if got := pixelAt(vdp, x, 0); got != rgbRed {
+			t.Errorf("pixel %d: expected forced color 63, got %v", x, got)
+		}
+	}
+
+	// Without bit 6 the composited pixel is ANDed with the selected layer:
+	// white (color 1) & plane B (color 3) = color 1
+	vdp.WriteDebug(3 << debugLayerShift)
+	vdp.RenderScanline(0)
+	if got := pixelAt(vdp, 0, 0); got != rgbWhite {
+		t.Errorf("pixel 0: expected color 1, got %v", got)
+	}
+	if got := pixelAt(vdp, 16, 0); got != rgbBlack {
+		t.Errorf("pixel 16: expected backdrop & color 0, got %v", got)
+	}
+}
+
+func TestGenesisBus_DebugRegisterWrite(t *testing.T) {
+	bus := makeTestBus()
+	bus.WriteCycle(0, m68k.Word, 0xC0001C, 0x0140)
+	if bus.vdp.debugReg != 0x0140 {
+		t.Errorf("expected debug register 0x0140, got 0x%04X", bus.vdp.debugReg)
+	}
+	// Mirrored every 32 bytes
+	bus.WriteCycle(0, m68k.Word, 0xC0003C, 0)
+	if bus.vdp.debugReg != 0 {
+		t.Errorf("expected mirror write to clear the debug register, got 0x%04X", bus.vdp.debugReg)
+	}
+}
+
+func TestEmulator_LayerOptions(t *testing.T) {
+	e := createTestEmulator()
+	for _, layer := range []int{LayerPlaneA, LayerPlaneB, LayerWindow, LayerSprites, LayerBackdrop} {
+		if !e.LayerEnabled(layer) {
+			t.Errorf("layer %d should be enabled by default", layer)
+		}
+	}
+
+	e.SetOption("layer_plane_b", "false")
+	e.SetOption("layer_sprites", "false")
+	if e.LayerEnabled(LayerPlaneB) || e.LayerEnabled(LayerSprites) {
+		t.Error("layers should be hidden by their options")
+	}
+	if !e.LayerEnabled(LayerPlaneA) {
+		t.Error("other layers should stay visible")
+	}
+
+	e.SetOption("layer_plane_b", "true")
+	if !e.LayerEnabled(LayerPlaneB) {
+		t.Error("plane B should be shown again")
+	}
+}
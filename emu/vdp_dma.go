@@ -114,6 +114,7 @@ func (v *VDP) executeDMA(cycle uint64) {
 	if !v.dmaEnabled() {
 		return
 	}
+	v.commitFIFO()
 
 	mode := v.regs[23] >> 6
 	switch mode {
@@ -226,6 +227,7 @@ func (v *VDP) executeDMA68K(cycle uint64) {
 // executeDMAFill fills VRAM with the high byte of the written value.
 // Called after the initial word has been written through normal WriteData routing.
 func (v *VDP) executeDMAFill(cycle uint64, val uint16) {
+	v.commitFIFO()
 	v.dmaFillPending = false
 
 	// Length from regs 19-20 (0 means 0x10000)
@@ -0,0 +1,128 @@
+package emu
+
+// fifoSize is the depth of the VDP write FIFO.
+const fifoSize = 4
+
+// fifoWrite is the data of a FIFO entry. VRAM writes are held until
+// drainFIFO retires them at their access slot; CRAM and VSRAM writes are
+// logged with their slot's pixel position when made.
+type fifoWrite struct {
+	vram bool // Entry holds a VRAM write not yet committed
+	addr uint16
+	val  uint16
+}
+
+// accessSlotsPerLine returns the number of external access slots the VDP
+// grants per scanline. Each slot moves one byte, so it matches the 68K->VDP
+// DMA throughput: H40 has 18 slots on an active line and 205 in blanking,
+// H32 has 16 and 167.
+func (v *VDP) accessSlotsPerLine() int {
+	return v.dmaBytesPerLine(0, v.vBlank || !v.displayEnabled())
+}
+
+// accessSlotCycle returns the CPU cycle of access slot k, counted from the
+// start of the current scanline. Slots are spread evenly across the line and
+// continue into the following lines at the same rate.
+func (v *VDP) accessSlotCycle(k int) uint64 {
+	slots := v.accessSlotsPerLine()
+	return v.scanlineStartCycle + uint64(k*v.scanlineTotalCycles/slots)
+}
+
+// nextAccessSlots returns the cycle at which a FIFO entry that becomes
+// ready at cycle from has used n access slots.
+func (v *VDP) nextAccessSlots(from uint64, n int) uint64 {
+	if v.scanlineTotalCycles <= 0 || from < v.scanlineStartCycle {
+		return from
+	}
+	slots := v.accessSlotsPerLine()
+	relative := int(from - v.scanlineStartCycle)
+	// First slot at or after from
+	k := (relative*slots + v.scanlineTotalCycles - 1) / v.scanlineTotalCycles
+	return v.accessSlotCycle(k + n)
+}
+
+// drainFIFO retires every FIFO entry written to memory by cycle,
+// committing their VRAM writes.
+func (v *VDP) drainFIFO(cycle uint64) {
+	n := 0
+	for n < v.fifoCount && v.fifoDrain[n] <= cycle {
+		if w := v.fifoData[n]; w.vram {
+			v.writeVRAM(w.addr, w.val)
+		}
+		n++
+	}
+	if n == 0 {
+		return
+	}
+	copy(v.fifoDrain[:], v.fifoDrain[n:v.fifoCount])
+	copy(v.fifoData[:], v.fifoData[n:v.fifoCount])
+	v.fifoCount -= n
+}
+
+// commitFIFO writes every queued VRAM write now, keeping the entries'
+// slot timing for the status flags. It is called before VRAM is read or
+// written other than through the FIFO, so accesses stay in order.
+func (v *VDP) commitFIFO() {
+	for i := 0; i < v.fifoCount; i++ {
+		if w := &v.fifoData[i]; w.vram {
+			v.writeVRAM(w.addr, w.val)
+			w.vram = false
+		}
+	}
+}
+
+// fifoPush queues a data port write of val to addr made at cycle and
+// returns the cycle at which it reaches VDP memory. When the FIFO is full
+// the 68K is stalled until the oldest entry drains. VRAM entries take two
+// slots (one per byte) and are written by drainFIFO; CRAM and VSRAM
+// entries take one.
+func (v *VDP) fifoPush(cycle uint64, target uint8, addr, val uint16) uint64 {
+	// Stall cycles not yet applied by the emulator loop have already
+	// passed for the CPU, e.g. between the two words of a long write.
+	cycle += uint64(v.dmaStallCycles)
+	v.drainFIFO(cycle)
+	if v.fifoCount == fifoSize {
+		v.dmaStallCycles += int(v.fifoDrain[0] - cycle)
+		cycle = v.fifoDrain[0]
+		v.drainFIFO(cycle)
+	}
+
+	start := cycle
+	if v.fifoCount > 0 && v.fifoDrain[v.fifoCount-1] > start {
+		start = v.fifoDrain[v.fifoCount-1]
+	}
+	slots := 1
+	if target == 0x01 {
+		slots = 2
+	}
+	drain := v.nextAccessSlots(start, slots)
+	if drain <= cycle {
+		// No slot timing available (no scanline started): write through
+		if target == 0x01 {
+			v.commitFIFO()
+			v.writeVRAM(addr, val)
+		}
+		return cycle
+	}
+	v.fifoDrain[v.fifoCount] = drain
+	v.fifoData[v.fifoCount] = fifoWrite{vram: target == 0x01, addr: addr, val: val}
+	v.fifoCount++
+	return drain
+}
+
+// fifoStatus returns the FIFO empty (bit 9) and full (bit 8) status bits
+// at cycle.
+func (v *VDP) fifoStatus(cycle uint64) uint16 {
+	if cycle == 0 {
+		// Untimed access (Z80): report the FIFO as drained
+		return 1 << 9
+	}
+	v.drainFIFO(cycle)
+	switch v.fifoCount {
+	case 0:
+		return 1 << 9
+	case fifoSize:
+		return 1 << 8
+	}
+	return 0
+}
@@ -0,0 +1,188 @@
+package emu
+
+import "testing"
+
+// makeFIFOTestVDP returns an H40 VDP with display enabled, set up for VRAM
+// writes on an active scanline starting at cycle 1000.
+func makeFIFOTestVDP() *VDP {
+	vdp := makeTestVDP()
+	vdp.WriteControl(0, 0x8144) // Display enabled
+	vdp.WriteControl(0, 0x8C81) // H40
+	vdp.WriteControl(0, 0x8F02) // Auto-increment 2
+	vdp.WriteControl(0, 0x4000)
+	vdp.WriteControl(0, 0x0000)
+	vdp.BeginScanline(1000, 488)
+	return vdp
+}
+
+func TestVDP_FIFOFullFlag(t *testing.T) {
+	vdp := makeFIFOTestVDP()
+	for i := 0; i < fifoSize; i++ {
+		vdp.WriteData(1001, uint16(i))
+	}
+
+	status := vdp.ReadControl(1001)
+	if status&(1<<8) == 0 {
+		t.Error("FIFO full flag (bit 8) should be set after 4 writes")
+	}
+	if status&(1<<9) != 0 {
+		t.Error("FIFO empty flag (bit 9) should be clear after 4 writes")
+	}
+	if stall := vdp.DMAStallCycles(); stall != 0 {
+		t.Errorf("4 writes should not stall, got %d cycles", stall)
+	}
+
+	// One line later every entry has drained into VRAM
+	status = vdp.ReadControl(1488)
+	if status&(1<<9) == 0 || status&(1<<8) != 0 {
+		t.Errorf("expected empty FIFO after a scanline, status=0x%04X", status)
+	}
+	if vdp.vram[6] != 0x00 || vdp.vram[7] != 0x03 {
+		t.Errorf("expected 0x0003 at VRAM 6, got 0x%02X%02X", vdp.vram[6], vdp.vram[7])
+	}
+}
+
+func TestVDP_FIFOCommitsVRAMAtSlot(t *testing.T) {
+	vdp := makeFIFOTestVDP()
+	vdp.WriteData(1001, 0x1234)
+	if vdp.vram[0] != 0 || vdp.vram[1] != 0 {
+		t.Error("VRAM should not change before the write's access slot")
+	}
+	drain := vdp.fifoDrain[0]
+
+	vdp.ReadControl(drain - 1)
+	if vdp.vram[0] != 0 {
+		t.Error("VRAM should not change one cycle before the slot")
+	}
+	vdp.ReadControl(drain)
+	if vdp.vram[0] != 0x12 || vdp.vram[1] != 0x34 {
+		t.Errorf("expected 0x1234 at VRAM 0, got 0x%02X%02X", vdp.vram[0], vdp.vram[1])
+	}
+}
+
+func TestVDP_FIFOReadWaitsForWrites(t *testing.T) {
+	vdp := makeFIFOTestVDP()
+	vdp.WriteData(1001, 0xABCD)
+	vdp.WriteControl(1002, 0x0000) // VRAM read at 0
+	vdp.WriteControl(1002, 0x0000)
+	if got := vdp.ReadData(); got != 0xABCD {
+		t.Errorf("read should see the queued write, got 0x%04X", got)
+	}
+}
+
+func TestVDP_FIFOSerialized(t *testing.T) {
+	vdp := makeFIFOTestVDP()
+	vdp.WriteData(1001, 0x5678)
+	drain := vdp.fifoDrain[0]
+
+	buf := make([]byte, VDPSerializeSize)
+	if err := vdp.Serialize(buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded := makeTestVDP()
+	if err := loaded.Deserialize(buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded.BeginScanline(1000, 488)
+	loaded.ReadControl(drain)
+	if loaded.vram[0] != 0x56 || loaded.vram[1] != 0x78 {
+		t.Errorf("queued write lost across a save state, VRAM 0 = 0x%02X%02X", loaded.vram[0], loaded.vram[1])
+	}
+}
+
+func TestVDP_FIFOPartialStatus(t *testing.T) {
+	vdp := makeFIFOTestVDP()
+	vdp.WriteData(1001, 0x1234)
+	status := vdp.ReadControl(1001)
+	if status&(3<<8) != 0 {
+		t.Errorf("expected neither empty nor full with 1 entry, status=0x%04X", status)
+	}
+}
+
+func TestVDP_FIFOFullStallsCPU(t *testing.T) {
+	vdp := makeFIFOTestVDP()
+	for i := 0; i < fifoSize; i++ {
+		vdp.WriteData(1001, uint16(i))
+	}
+	oldest := vdp.fifoDrain[0]
+
+	vdp.WriteData(1001, 0xFFFF)
+	stall := vdp.DMAStallCycles()
+	if want := int(oldest - 1001); stall != want {
+		t.Errorf("expected %d stall cycles, got %d", want, stall)
+	}
+
+	// Both words of a long write pass the same cycle; the second stalls
+	// only until the next entry drains
+	vdp = makeFIFOTestVDP()
+	for i := 0; i < fifoSize; i++ {
+		vdp.WriteData(1001, uint16(i))
+	}
+	second := vdp.fifoDrain[1]
+	vdp.WriteData(1001, 0xAAAA)
+	vdp.WriteData(1001, 0xBBBB)
+	if stall, want := vdp.DMAStallCycles(), int(second-1001); stall != want {
+		t.Errorf("expected %d stall cycles for a long write, got %d", want, stall)
+	}
+}
+
+func TestVDP_FIFOBlankingDrainsFaster(t *testing.T) {
+	active := makeFIFOTestVDP()
+	blank := makeFIFOTestVDP()
+	blank.WriteControl(0, 0x8104) // Display disabled
+	blank.WriteControl(0, 0x4000)
+	blank.WriteControl(0, 0x0000)
+
+	for i := 0; i < fifoSize; i++ {
+		active.WriteData(1001, uint16(i))
+		blank.WriteData(1001, uint16(i))
+	}
+	a := active.fifoDrain[fifoSize-1]
+	b := blank.fifoDrain[fifoSize-1]
+	if b >= a {
+		t.Errorf("blanking drain (%d) should finish before active drain (%d)", b, a)
+	}
+}
+
+func TestVDP_FIFOH32HasFewerSlots(t *testing.T) {
+	h40 := makeFIFOTestVDP()
+	h32 := makeFIFOTestVDP()
+	h32.WriteControl(0, 0x8C00)
+	h32.WriteControl(0, 0x4000)
+	h32.WriteControl(0, 0x0000)
+
+	for i := 0; i < fifoSize; i++ {
+		h40.WriteData(1001, uint16(i))
+		h32.WriteData(1001, uint16(i))
+	}
+	if h32.fifoDrain[fifoSize-1] <= h40.fifoDrain[fifoSize-1] {
+		t.Error("H32 FIFO should drain slower than H40")
+	}
+}
+
+func TestVDP_FIFOCRAMSlotCount(t *testing.T) {
+	vram := makeFIFOTestVDP()
+	cram := makeFIFOTestVDP()
+	cram.WriteControl(0, 0xC000)
+	cram.WriteControl(0, 0x0000)
+
+	vram.WriteData(1001, 0x0EEE)
+	cram.WriteData(1001, 0x0EEE)
+	if cram.fifoDrain[0] >= vram.fifoDrain[0] {
+		t.Error("CRAM writes should use one slot, VRAM writes two")
+	}
+	// The CRAM change is logged at the slot where it reached CRAM
+	if got, want := cram.cramChanges[0].pixelX, cram.cycleToPixel(cram.fifoDrain[0]); got != want {
+		t.Errorf("expected CRAM change at pixel %d, got %d", want, got)
+	}
+}
+
+func TestVDP_FIFOUntimedWritesBypass(t *testing.T) {
+	vdp := makeFIFOTestVDP()
+	for i := 0; i < 8; i++ {
+		vdp.WriteData(0, uint16(i))
+	}
+	if vdp.fifoCount != 0 || vdp.DMAStallCycles() != 0 {
+		t.Error("untimed writes should not queue in the FIFO")
+	}
+}
@@ -0,0 +1,300 @@
+package emu
+
+// Mode 4 (Master System compatibility).
+//
+// In SMS mode the VDP is driven through 8-bit ports, addresses the first
+// 16KB of VRAM byte-wise and uses a separate 32-entry palette of 6-bit
+// colors (--BBGGRR). The Genesis VDP only supports the 192-line Mode 4
+// display and has no sprite zoom.
+const (
+	smsActiveHeight = 192
+	smsActiveWidth  = 256
+	smsVRAMMask     = 0x3FFF
+)
+
+// SetSMSMode switches the VDP to Mode 4 with the SMS port interface.
+func (v *VDP) SetSMSMode(enabled bool) {
+	v.smsMode = enabled
+}
+
+// SMSMode reports whether the VDP is in Mode 4.
+func (v *VDP) SMSMode() bool {
+	return v.smsMode
+}
+
+// WriteControlSMS writes the 8-bit control port. The first byte is the
+// low address byte; the second holds the code in bits 7:6 and the high
+// address bits. Code 0 pre-fetches for reads, code 2 writes register
+// (second & 0x0F) with the first byte.
+func (v *VDP) WriteControlSMS(val uint8) {
+	if !v.writePending {
+		v.address = (v.address & 0x3F00) | uint16(val)
+		v.writePending = true
+		return
+	}
+	v.writePending = false
+	v.address = (v.address & 0x00FF) | uint16(val&0x3F)<<8
+	v.code = val >> 6
+	switch v.code {
+	case 0:
+		v.readBuffer = uint16(v.vram[v.address&smsVRAMMask])
+		v.address = (v.address + 1) & smsVRAMMask
+	case 2:
+		if reg := val & 0x0F; reg <= 10 {
+			v.regs[reg] = uint8(v.address)
+		}
+	}
+}
+
+// WriteDataSMS writes a byte to VRAM, or to CRAM after a code 3 command.
+// The written byte also replaces the read buffer.
+func (v *VDP) WriteDataSMS(val uint8) {
+	v.writePending = false
+	if v.code == 3 {
+		v.smsCRAM[v.address&0x1F] = val & 0x3F
+	} else {
+		v.vram[v.address&smsVRAMMask] = val
+	}
+	v.readBuffer = uint16(val)
+	v.address = (v.address + 1) & smsVRAMMask
+}
+
+// ReadDataSMS returns the read buffer and pre-fetches the next VRAM byte.
+func (v *VDP) ReadDataSMS() uint8 {
+	v.writePending = false
+	result := uint8(v.readBuffer)
+	v.readBuffer = uint16(v.vram[v.address&smsVRAMMask])
+	v.address = (v.address + 1) & smsVRAMMask
+	return result
+}
+
+// ReadStatusSMS returns the Mode 4 status: bit 7 frame interrupt, bit 6
+// sprite overflow, bit 5 sprite collision. Reading clears the flags, any
+// pending line interrupt and the control port latch.
+func (v *VDP) ReadStatusSMS() uint8 {
+	status := uint8(0x1F)
+	if v.vIntPending {
+		status |= 0x80
+	}
+	if v.spriteOverflow {
+		status |= 0x40
+	}
+	if v.spriteCollision {
+		status |= 0x20
+	}
+	v.vIntPending = false
+	v.lineIntPending = false
+	v.spriteOverflow = false
+	v.spriteCollision = false
+	v.writePending = false
+	return status
+}
+
+// ReadVCounterSMS returns the Mode 4 V counter.
+func (v *VDP) ReadVCounterSMS() uint8 {
+	return uint8(v.vCounter)
+}
+
+// ReadHCounterSMS returns the H counter.
+func (v *VDP) ReadHCounterSMS() uint8 {
+	return v.hCounter
+}
+
+// SMSInterrupt reports the level of the Z80 interrupt line: a pending
+// frame interrupt with reg 1 bit 5 set, or a pending line interrupt with
+// reg 0 bit 4 set.
+func (v *VDP) SMSInterrupt() bool {
+	return (v.vIntPending && v.regs[1]&0x20 != 0) ||
+		(v.lineIntPending && v.regs[0]&0x10 != 0)
+}
+
+// smsVCounterValue maps a scanline to the 192-line Mode 4 V counter.
+//
+//	NTSC: $00-$DA, then $D5-$FF
+//	PAL:  $00-$F2, then $BA-$FF
+func (v *VDP) smsVCounterValue(line int) uint16 {
+	if v.isPAL {
+		if line <= 0xF2 {
+			return uint16(line)
+		}
+		return uint16(0xBA + line - 0xF3)
+	}
+	if line <= 0xDA {
+		return uint16(line)
+	}
+	return uint16(0xD5 + line - 0xDB)
+}
+
+// StartScanlineSMS updates Mode 4 counters and interrupt flags at the
+// start of a scanline. The line counter (reg 10) counts down on lines
+// 0-192 and is reloaded on the remaining lines; the frame interrupt flag
+// is set on the first line after the active display.
+func (v *VDP) StartScanlineSMS(line int) {
+	v.currentLine = line
+	v.vCounter = v.smsVCounterValue(line)
+
+	if line == 0 {
+		v.vBlank = false
+	}
+	if line <= smsActiveHeight {
+		v.hIntCounter--
+		if v.hIntCounter < 0 {
+			v.hIntCounter = int(v.regs[10])
+			v.lineIntPending = true
+		}
+	} else {
+		v.hIntCounter = int(v.regs[10])
+	}
+	if line == smsActiveHeight {
+		v.vBlank = true
+		v.vIntPending = true
+	}
+}
+
+// smsTilePixel returns the 4-bit color of pixel (x, y) of a Mode 4 tile.
+// Tiles are 32 bytes: each row is four bitplanes, leftmost pixel in bit 7.
+func (v *VDP) smsTilePixel(tile, x, y int) uint8 {
+	addr := (tile*32 + y*4) & smsVRAMMask
+	shift := uint(7 - x)
+	return (v.vram[addr]>>shift)&1 |
+		(v.vram[addr+1]>>shift&1)<<1 |
+		(v.vram[addr+2]>>shift&1)<<2 |
+		(v.vram[addr+3]>>shift&1)<<3
+}
+
+// smsColor converts a 6-bit --BBGGRR color to RGB.
+func smsColor(c uint8) (r, g, b uint8) {
+	return (c & 0x03) * 85, (c >> 2 & 0x03) * 85, (c >> 4 & 0x03) * 85
+}
+
+// renderMode4Scanline renders one 256-pixel Mode 4 line and stretches it
+// across the framebuffer.
+func (v *VDP) renderMode4Scanline(line int) {
+	var colors [smsActiveWidth]uint8 // CRAM index per pixel
+	backdrop := 16 + v.regs[7]&0x0F
+
+	if !v.displayEnabled() {
+		for x := range colors {
+			colors[x] = backdrop
+		}
+		v.writeMode4Line(line, &colors)
+		return
+	}
+
+	// Background: 32x28 name table of 16-bit entries
+	// ---P CVHN NNNN NNNN: priority, palette, flips, tile
+	nameBase := int(v.regs[2]&0x0E) << 10
+	hscroll := int(v.regs[8])
+	if v.regs[0]&0x40 != 0 && line < 16 {
+		hscroll = 0 // Top two rows locked
+	}
+	var bgPri [smsActiveWidth]bool
+	for x := 0; x < smsActiveWidth; x++ {
+		vscroll := int(v.regs[9])
+		if v.regs[0]&0x80 != 0 && x >= 192 {
+			vscroll = 0 // Right eight columns locked
+		}
+		row := (line + vscroll) % 224
+		col := (x - hscroll) & 0xFF
+		addr := nameBase + (row/8)*64 + (col/8)*2
+		entry := int(v.vram[addr&smsVRAMMask]) | int(v.vram[(addr+1)&smsVRAMMask])<<8
+
+		tx, ty := col&7, row&7
+		if entry&0x200 != 0 {
+			tx = 7 - tx
+		}
+		if entry&0x400 != 0 {
+			ty = 7 - ty
+		}
+		pixel := v.smsTilePixel(entry&0x1FF, tx, ty)
+		colors[x] = pixel
+		if entry&0x800 != 0 {
+			colors[x] += 16
+		}
+		bgPri[x] = entry&0x1000 != 0 && pixel != 0
+	}
+
+	// Sprites: up to 8 per line, earlier sprites in front
+	var spr [smsActiveWidth]uint8
+	satBase := int(v.regs[5]&0x7E) << 7
+	height := 8
+	if v.regs[1]&0x02 != 0 {
+		height = 16
+	}
+	patBase := 0
+	if v.regs[6]&0x04 != 0 {
+		patBase = 256
+	}
+	count := 0
+	for i := 0; i < 64; i++ {
+		y := int(v.vram[satBase+i])
+		if y == 0xD0 {
+			break // End of sprite list in 192-line mode
+		}
+		sy := y + 1
+		if sy > 240 {
+			sy -= 256 // Partially visible at the top
+		}
+		if line < sy || line >= sy+height {
+			continue
+		}
+		count++
+		if count > 8 {
+			v.spriteOverflow = true
+			break
+		}
+		sx := int(v.vram[satBase+0x80+i*2])
+		if v.regs[0]&0x08 != 0 {
+			sx -= 8 // Early clock shifts sprites left
+		}
+		tile := int(v.vram[satBase+0x81+i*2])
+		if height == 16 {
+			tile &= 0xFE
+		}
+		row := line - sy
+		tile = patBase + tile + row/8
+		for px := 0; px < 8; px++ {
+			x := sx + px
+			if x < 0 || x >= smsActiveWidth {
+				continue
+			}
+			c := v.smsTilePixel(tile, px, row&7)
+			if c == 0 {
+				continue
+			}
+			if spr[x] != 0 {
+				v.spriteCollision = true
+				continue
+			}
+			spr[x] = 16 + c
+		}
+	}
+
+	for x := 0; x < smsActiveWidth; x++ {
+		if spr[x] != 0 && !bgPri[x] {
+			colors[x] = spr[x]
+		}
+	}
+	if v.regs[0]&0x20 != 0 {
+		for x := 0; x < 8; x++ {
+			colors[x] = backdrop // Left column blank
+		}
+	}
+	v.writeMode4Line(line, &colors)
+}
+
+// writeMode4Line converts CRAM indexes to RGBA and stretches the line to
+// the framebuffer width.
+func (v *VDP) writeMode4Line(line int, colors *[smsActiveWidth]uint8) {
+	pix := v.framebuffer.Pix
+	offset := line * v.framebuffer.Stride
+	for x, c := range colors {
+		r, g, b := smsColor(v.smsCRAM[c&0x1F])
+		p := offset + x*4
+		pix[p] = r
+		pix[p+1] = g
+		pix[p+2] = b
+		pix[p+3] = 0xFF
+	}
+	v.stretchScanline(line, smsActiveWidth)
+}
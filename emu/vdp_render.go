@@ -183,6 +183,14 @@ func (v *VDP) renderMergedRange(line, fbLine, startX, endX int) {
 	hMaskA := ntWidthPxA - 1
 	vMaskA := ntHeightPxA - 1
 
+	// Layer visibility and debug register
+	showA := v.layerMask&LayerPlaneA != 0
+	showB := v.layerMask&LayerPlaneB != 0
+	showW := v.layerMask&LayerWindow != 0
+	showSpr := v.layerMask&LayerSprites != 0
+	showBd := v.layerMask&LayerBackdrop != 0
+	debug := v.debugReg&debugRenderMask != 0
+
 	// Window bounds
 	winStartX, winEndX := v.windowBounds(line, endX)
 
@@ -224,6 +232,9 @@ func (v *VDP) renderMergedRange(line, fbLine, startX, endX int) {
 			(entryB&entryTileMask)*tileSz, pixXB, pixYB,
 			entryB&entryHFlip != 0, entryB&entryVFlip != 0,
 		)
+		if !showB {
+			bColorIdx = 0
+		}
 
 		// --- Plane A / Window pixel ---
 		var aPri bool
@@ -244,6 +255,9 @@ func (v *VDP) renderMergedRange(line, fbLine, startX, endX int) {
 				(entryW&entryTileMask)*tileSz, pixXW, pixYW,
 				entryW&entryHFlip != 0, entryW&entryVFlip != 0,
 			)
+			if !showW {
+				aColorIdx = 0
+			}
 		} else {
 			// Plane A pixel
 			vramXA := (x - hScrollA) & hMaskA
@@ -261,10 +275,16 @@ func (v *VDP) renderMergedRange(line, fbLine, startX, endX int) {
 				(entryA&entryTileMask)*tileSz, pixXA, pixYA,
 				entryA&entryHFlip != 0, entryA&entryVFlip != 0,
 			)
+			if !showA {
+				aColorIdx = 0
+			}
 		}
 
 		// --- Sprite pixel ---
 		spr := v.lineBufSpr[x]
+		if !showSpr {
+			spr = layerPixel{}
+		}
 
 		// --- Priority resolution ---
 		// Priority order (highest to lowest):
@@ -276,6 +296,7 @@ func (v *VDP) renderMergedRange(line, fbLine, startX, endX int) {
 		// 6. Low-priority Plane B (non-transparent)
 		// 7. Backdrop
 		var cpal, cidx uint8
+		backdrop := false
 		switch {
 		case spr.priority && spr.colorIndex != 0:
 			cpal, cidx = spr.palette, spr.colorIndex
@@ -291,12 +312,20 @@ func (v *VDP) renderMergedRange(line, fbLine, startX, endX int) {
 			cpal, cidx = bPal, bColorIdx
 		default:
 			cpal, cidx = bdPal, bdIdx
+			backdrop = true
+		}
+		if debug {
+			cpal, cidx = v.debugPixel(cpal, cidx, spr, aPal, aColorIdx, bPal, bColorIdx)
 		}
 
 		r, g, bv := v.cramColor(cpal*16 + cidx)
 
 		if leftBlank && x < 8 {
 			r, g, bv = v.cramColor(bdPal*16 + bdIdx)
+			backdrop = true
+		}
+		if backdrop && !showBd {
+			r, g, bv = 0, 0, 0
 		}
 
 		p := offset + x*4
@@ -343,6 +372,14 @@ func (v *VDP) renderMergedSHRange(line, fbLine, startX, endX int) {
 	hMaskA := ntWidthPxA - 1
 	vMaskA := ntHeightPxA - 1
 
+	// Layer visibility and debug register
+	showA := v.layerMask&LayerPlaneA != 0
+	showB := v.layerMask&LayerPlaneB != 0
+	showW := v.layerMask&LayerWindow != 0
+	showSpr := v.layerMask&LayerSprites != 0
+	showBd := v.layerMask&LayerBackdrop != 0
+	debug := v.debugReg&debugRenderMask != 0
+
 	// Window bounds
 	winStartX, winEndX := v.windowBounds(line, endX)
 
@@ -382,6 +419,9 @@ func (v *VDP) renderMergedSHRange(line, fbLine, startX, endX int) {
 			(entryB&entryTileMask)*tileSz, pixXB, pixYB,
 			entryB&entryHFlip != 0, entryB&entryVFlip != 0,
 		)
+		if !showB {
+			bColorIdx = 0
+		}
 
 		// --- Plane A / Window pixel ---
 		var aPri bool
@@ -401,6 +441,9 @@ func (v *VDP) renderMergedSHRange(line, fbLine, startX, endX int) {
 				(entryW&entryTileMask)*tileSz, pixXW, pixYW,
 				entryW&entryHFlip != 0, entryW&entryVFlip != 0,
 			)
+			if !showW {
+				aColorIdx = 0
+			}
 		} else {
 			vramXA := (x - hScrollA) & hMaskA
 			vramYA := (line + vScrollA) & vMaskA
@@ -417,10 +460,16 @@ func (v *VDP) renderMergedSHRange(line, fbLine, startX, endX int) {
 				(entryA&entryTileMask)*tileSz, pixXA, pixYA,
 				entryA&entryHFlip != 0, entryA&entryVFlip != 0,
 			)
+			if !showA {
+				aColorIdx = 0
+			}
 		}
 
 		// --- Sprite pixel ---
 		spr := v.lineBufSpr[x]
+		if !showSpr {
+			spr = layerPixel{}
+		}
 
 		// --- Shadow/Highlight priority resolution ---
 		// Same priority order as normal mode. All pixels default to shadow
@@ -430,6 +479,7 @@ func (v *VDP) renderMergedSHRange(line, fbLine, startX, endX int) {
 		brightness := brightnessShadow
 		var cpal, cidx uint8
 		sprIsOperator := false
+		backdrop := false
 
 		// Palette 3 sprite operator check (color 14 = highlight, 15 = shadow)
 		if !spr.priority && spr.colorIndex != 0 && spr.palette == 3 {
@@ -462,6 +512,7 @@ func (v *VDP) renderMergedSHRange(line, fbLine, startX, endX int) {
 					cpal, cidx = bPal, bColorIdx
 				default:
 					cpal, cidx = bdPal, bdIdx
+					backdrop = true
 				}
 				if spr.colorIndex == 14 {
 					brightness = brightnessHighlight
@@ -484,6 +535,10 @@ func (v *VDP) renderMergedSHRange(line, fbLine, startX, endX int) {
 			cpal, cidx = bPal, bColorIdx
 		default:
 			cpal, cidx = bdPal, bdIdx
+			backdrop = true
+		}
+		if debug {
+			cpal, cidx = v.debugPixel(cpal, cidx, spr, aPal, aColorIdx, bPal, bColorIdx)
 		}
 
 		var r, g, bv uint8
@@ -498,6 +553,10 @@ func (v *VDP) renderMergedSHRange(line, fbLine, startX, endX int) {
 
 		if leftBlank && x < 8 {
 			r, g, bv = v.cramColorShadow(bdPal*16 + bdIdx)
+			backdrop = true
+		}
+		if backdrop && !showBd {
+			r, g, bv = 0, 0, 0
 		}
 
 		p := offset + x*4
@@ -579,6 +638,9 @@ func (v *VDP) renderMergedScanline(line, fbLine int) {
 
 // RenderScanline renders a single scanline into the framebuffer.
 func (v *VDP) RenderScanline(line int) {
+	// Writes that reached VRAM during the line are visible to it
+	v.drainFIFO(v.scanlineStartCycle + uint64(v.scanlineTotalCycles))
+
 	// Compute framebuffer row
 	fbLine := line
 	if v.interlaceDoubleRes() {
@@ -589,6 +651,11 @@ func (v *VDP) RenderScanline(line int) {
 		return
 	}
 
+	if v.smsMode {
+		v.renderMode4Scanline(fbLine)
+		return
+	}
+
 	// If display is disabled, fill with backdrop and return
 	if !v.displayEnabled() {
 		v.fillBackdrop(fbLine)
@@ -6,7 +6,7 @@ import (
 )
 
 const (
-	vdpSerializeVersion = 1
+	vdpSerializeVersion = 2
 	// VDPSerializeSize is the total bytes needed for VDP serialization.
 	// version(1) + vram(65536) + cram(128) + vsram(80) + regs(24) +
 	// writePending(1) + code(1) + address(2) + readBuffer(2) +
@@ -15,8 +15,15 @@ const (
 	// vCounter(2) + hCounter(1) + currentLine(4) +
 	// hvLatched(1) + hvLatchValue(2) +
 	// hIntCounter(4) + dmaFillPending(1) +
-	// oddField(1) + isPAL(1)
-	VDPSerializeSize = 65810
+	// oddField(1) + isPAL(1) +
+	// fifoCount(1) + fifoDrain(32) +
+	// smsCRAM(32) + lineIntPending(1) +
+	// debugReg(2) +
+	// fifoData(20)
+	VDPSerializeSize = 65898
+
+	// vdpFIFOCountOffset is where fifoCount is saved.
+	vdpFIFOCountOffset = 65810
 )
 
 // Serialize writes VDP state to buf. buf must be at least VDPSerializeSize bytes.
@@ -99,23 +106,58 @@ func (v *VDP) Serialize(buf []byte) error {
 	buf[offset] = boolByte(v.isPAL)
 	offset++
 
+	// Write FIFO
+	buf[offset] = uint8(v.fifoCount)
+	offset++
+	for _, drain := range v.fifoDrain {
+		binary.LittleEndian.PutUint64(buf[offset:], drain)
+		offset += 8
+	}
+
+	// Mode 4
+	copy(buf[offset:], v.smsCRAM[:])
+	offset += len(v.smsCRAM)
+	buf[offset] = boolByte(v.lineIntPending)
+	offset++
+
+	// Debug register
+	binary.LittleEndian.PutUint16(buf[offset:], v.debugReg)
+	offset += 2
+
+	// VRAM writes waiting in the FIFO
+	for _, w := range v.fifoData {
+		buf[offset] = boolByte(w.vram)
+		binary.LittleEndian.PutUint16(buf[offset+1:], w.addr)
+		binary.LittleEndian.PutUint16(buf[offset+3:], w.val)
+		offset += 5
+	}
+
 	return nil
 }
 
-// Deserialize reads VDP state from buf. buf must be at least VDPSerializeSize bytes.
-func (v *VDP) Deserialize(buf []byte) error {
+// checkVDPState reports whether Deserialize would reject buf, without
+// changing any VDP.
+func checkVDPState(buf []byte) error {
 	if len(buf) < VDPSerializeSize {
 		return errors.New("VDP deserialize buffer too small")
 	}
+	if buf[0] > vdpSerializeVersion {
+		return errors.New("unsupported VDP state version")
+	}
+	if int(buf[vdpFIFOCountOffset]) > fifoSize {
+		return errors.New("invalid VDP FIFO state")
+	}
+	return nil
+}
 
-	offset := 0
+// Deserialize reads VDP state from buf. buf must be at least VDPSerializeSize bytes.
+func (v *VDP) Deserialize(buf []byte) error {
+	if err := checkVDPState(buf); err != nil {
+		return err
+	}
 
 	// Version
-	version := buf[offset]
-	offset++
-	if version > vdpSerializeVersion {
-		return errors.New("unsupported VDP state version")
-	}
+	offset := 1
 
 	// VRAM (64KB)
 	copy(v.vram[:], buf[offset:offset+len(v.vram)])
@@ -185,5 +227,33 @@ func (v *VDP) Deserialize(buf []byte) error {
 	v.isPAL = buf[offset] != 0
 	offset++
 
+	// Write FIFO
+	v.fifoCount = int(buf[offset])
+	offset++
+	for i := range v.fifoDrain {
+		v.fifoDrain[i] = binary.LittleEndian.Uint64(buf[offset:])
+		offset += 8
+	}
+
+	// Mode 4
+	copy(v.smsCRAM[:], buf[offset:offset+len(v.smsCRAM)])
+	offset += len(v.smsCRAM)
+	v.lineIntPending = buf[offset] != 0
+	offset++
+
+	// Debug register
+	v.debugReg = binary.LittleEndian.Uint16(buf[offset:])
+	offset += 2
+
+	// VRAM writes waiting in the FIFO
+	for i := range v.fifoData {
+		v.fifoData[i] = fifoWrite{
+			vram: buf[offset] != 0,
+			addr: binary.LittleEndian.Uint16(buf[offset+1:]),
+			val:  binary.LittleEndian.Uint16(buf[offset+3:]),
+		}
+		offset += 5
+	}
+
 	return nil
 }
@@ -0,0 +1,240 @@
+package emu
+
+import (
+	"image"
+	"image/color"
+)
+
+// VDP memory inspection for tile, nametable, sprite and palette viewers.
+// Everything here reads VRAM, CRAM and VSRAM as they are at the time of the
+// call and has no effect on emulation. Color index 0 is drawn transparent.
+
+// Plane selects a nametable for RenderNametable.
+type Plane int
+
+const (
+	PlaneA Plane = iota
+	PlaneB
+	PlaneWindow
+)
+
+// tileSheetColumns is the width of a tile sheet in tiles.
+const tileSheetColumns = 32
+
+// viewportColor outlines the visible screen area on a rendered nametable.
+var viewportColor = color.RGBA{R: 0xFF, G: 0x00, B: 0xFF, A: 0xFF}
+
+// SpriteInfo is a decoded Sprite Attribute Table entry.
+type SpriteInfo struct {
+	Index    int    // SAT entry number
+	X, Y     int    // Screen position (SAT value - 128)
+	Width    int    // Width in cells (1-4)
+	Height   int    // Height in cells (1-4)
+	Link     int    // Next sprite in the list (0 ends the list)
+	Tile     uint16 // First tile index
+	Palette  uint8  // Palette line (0-3)
+	Priority bool
+	HFlip    bool
+	VFlip    bool
+}
+
+// Palette returns CRAM decoded to RGBA, four lines of 16 colors.
+func (v *VDP) Palette() [64]color.RGBA {
+	var pal [64]color.RGBA
+	for i := range pal {
+		r, g, b := v.cramColor(uint8(i))
+		pal[i] = color.RGBA{R: r, G: g, B: b, A: 0xFF}
+	}
+	return pal
+}
+
+// VScroll returns the 40 VSRAM entries (10-bit vertical scroll values,
+// alternating plane A and plane B per 2-cell column).
+func (v *VDP) VScroll() [40]uint16 {
+	var vs [40]uint16
+	for i := range vs {
+		vs[i] = (uint16(v.vsram[i*2])<<8 | uint16(v.vsram[i*2+1])) & 0x03FF
+	}
+	return vs
+}
+
+// tileColor converts a palette line and color index to RGBA; index 0 is
+// transparent.
+func (v *VDP) tileColor(pal, idx uint8) color.RGBA {
+	if idx == 0 {
+		return color.RGBA{}
+	}
+	r, g, b := v.cramColor(pal*16 + idx)
+	return color.RGBA{R: r, G: g, B: b, A: 0xFF}
+}
+
+// drawTile draws one tile at (x, y) with the given palette line and flips.
+func (v *VDP) drawTile(img *image.RGBA, x, y int, tile uint16, pal uint8, hFlip, vFlip bool) {
+	tileSz := v.tileSize()
+	rows := v.tileRows()
+	addr := tile * tileSz
+	for py := 0; py < rows; py++ {
+		for px := 0; px < 8; px++ {
+			idx := v.decodeTilePixel(addr, px, py, hFlip, vFlip)
+			img.SetRGBA(x+px, y+py, v.tileColor(pal, idx))
+		}
+	}
+}
+
+// TileSheet renders all of VRAM as tiles, 32 per row, using palette line
+// palette (0-3). In interlace mode 2 tiles are 8x16 and the sheet holds
+// 1024 of them instead of 2048.
+func (v *VDP) TileSheet(palette int) *image.RGBA {
+	rows := v.tileRows()
+	count := len(v.vram) / int(v.tileSize())
+	img := image.NewRGBA(image.Rect(0, 0, tileSheetColumns*8, count/tileSheetColumns*rows))
+	for t := 0; t < count; t++ {
+		x := (t % tileSheetColumns) * 8
+		y := (t / tileSheetColumns) * rows
+		v.drawTile(img, x, y, uint16(t), uint8(palette&0x03), false, false)
+	}
+	return img
+}
+
+// nametableLayout returns the base address and size in cells of a plane's
+// nametable. The window is as wide as its H40/H32 layout and 32 rows tall.
+func (v *VDP) nametableLayout(plane Plane) (base uint16, hCells, vCells int) {
+	switch plane {
+	case PlaneB:
+		hCells, vCells = v.nametableSize()
+		return v.planeBNametable(), hCells, vCells
+	case PlaneWindow:
+		return v.windowNametableBase(), v.windowNametableWidth(), 32
+	}
+	hCells, vCells = v.nametableSize()
+	return v.planeANametable(), hCells, vCells
+}
+
+// RenderNametable renders a whole plane nametable. With overlay set the
+// visible screen area is outlined, following the current per-line H-scroll
+// and per-column V-scroll; the outline wraps like the plane does. The window
+// is not scrolled, so its outline is the screen rectangle.
+func (v *VDP) RenderNametable(plane Plane, overlay bool) *image.RGBA {
+	base, hCells, vCells := v.nametableLayout(plane)
+	rows := v.tileRows()
+	img := image.NewRGBA(image.Rect(0, 0, hCells*8, vCells*rows))
+
+	for cy := 0; cy < vCells; cy++ {
+		for cx := 0; cx < hCells; cx++ {
+			addr := (base + uint16(cy*hCells+cx)*2) & 0xFFFF
+			entry := uint16(v.vram[addr])<<8 | uint16(v.vram[(addr+1)&0xFFFF])
+			v.drawTile(img, cx*8, cy*rows, entry&entryTileMask,
+				uint8((entry>>entryPalShift)&entryPalMask),
+				entry&entryHFlip != 0, entry&entryVFlip != 0)
+		}
+	}
+
+	if overlay {
+		v.drawViewport(img, plane)
+	}
+	return img
+}
+
+// drawViewport outlines the screen area on a rendered nametable: the left
+// and right edge of every line plus the top and bottom lines.
+func (v *VDP) drawViewport(img *image.RGBA, plane Plane) {
+	width := v.activeWidth()
+	height := v.ActiveHeight()
+	bounds := img.Bounds()
+	wMask, hMask := bounds.Dx()-1, bounds.Dy()-1
+
+	plot := func(sx, sy int) {
+		if plane == PlaneWindow {
+			if sx <= wMask && sy <= hMask {
+				img.SetRGBA(sx, sy, viewportColor)
+			}
+			return
+		}
+		hA, hB := v.hScrollValues(sy)
+		h := hA
+		if plane == PlaneB {
+			h = hB
+		}
+		vs := v.vScrollValue(sx, plane == PlaneB)
+		img.SetRGBA((sx-h)&wMask, (sy+vs)&hMask, viewportColor)
+	}
+
+	for sy := 0; sy < height; sy++ {
+		plot(0, sy)
+		plot(width-1, sy)
+	}
+	for sx := 0; sx < width; sx++ {
+		plot(sx, 0)
+		plot(sx, height-1)
+	}
+}
+
+// Sprites returns the SAT entries in link order starting from sprite 0,
+// as the VDP walks them. The list ends at a zero link, an out of range
+// link, or after 80 (H40) or 64 (H32) sprites.
+func (v *VDP) Sprites() []SpriteInfo {
+	satMask := uint8(0x7F)
+	maxTotal := 64
+	if v.h40Mode() {
+		satMask = 0x7E
+		maxTotal = 80
+	}
+	satBase := uint16(v.regs[5]&satMask) << 9
+
+	var sprites []SpriteInfo
+	index := 0
+	for len(sprites) < maxTotal {
+		addr := satBase + uint16(index)*8
+		word := func(off uint16) uint16 {
+			a := (addr + off) & 0xFFFF
+			return uint16(v.vram[a])<<8 | uint16(v.vram[(a+1)&0xFFFF])
+		}
+		sizeLink := word(2)
+		attr := word(4)
+		s := SpriteInfo{
+			Index:    index,
+			Y:        int(word(0)&0x03FF) - 128,
+			Width:    int((sizeLink>>10)&0x03) + 1,
+			Height:   int((sizeLink>>8)&0x03) + 1,
+			Link:     int(sizeLink & 0x7F),
+			Tile:     attr & entryTileMask,
+			Palette:  uint8((attr >> entryPalShift) & entryPalMask),
+			Priority: attr&entryPriority != 0,
+			HFlip:    attr&entryHFlip != 0,
+			VFlip:    attr&entryVFlip != 0,
+			X:        int(word(6)&0x01FF) - 128,
+		}
+		sprites = append(sprites, s)
+		if s.Link == 0 || s.Link >= maxTotal {
+			break
+		}
+		index = s.Link
+	}
+	return sprites
+}
+
+// VDPPalette returns CRAM decoded to RGBA.
+func (e *Emulator) VDPPalette() [64]color.RGBA {
+	return e.vdp.Palette()
+}
+
+// VDPVScroll returns the VSRAM vertical scroll entries.
+func (e *Emulator) VDPVScroll() [40]uint16 {
+	return e.vdp.VScroll()
+}
+
+// VDPTileSheet renders VRAM as a tile sheet using palette line palette.
+func (e *Emulator) VDPTileSheet(palette int) *image.RGBA {
+	return e.vdp.TileSheet(palette)
+}
+
+// VDPNametable renders a plane nametable, optionally with the screen
+// viewport outlined.
+func (e *Emulator) VDPNametable(plane Plane, overlay bool) *image.RGBA {
+	return e.vdp.RenderNametable(plane, overlay)
+}
+
+// VDPSprites returns the decoded sprite list in link order.
+func (e *Emulator) VDPSprites() []SpriteInfo {
+	return e.vdp.Sprites()
+}
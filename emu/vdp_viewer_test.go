@@ -0,0 +1,137 @@
+package emu
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestVDP_Palette(t *testing.T) {
+	vdp := makeTestVDP()
+	vdp.cram[2], vdp.cram[3] = 0x00, 0x0E // Entry 1: red
+	vdp.cram[126], vdp.cram[127] = 0x0E, 0x00
+	pal := vdp.Palette()
+	if want := (color.RGBA{R: 255, A: 255}); pal[1] != want {
+		t.Errorf("entry 1: expected %v, got %v", want, pal[1])
+	}
+	if want := (color.RGBA{B: 255, A: 255}); pal[63] != want {
+		t.Errorf("entry 63: expected %v, got %v", want, pal[63])
+	}
+}
+
+func TestVDP_VScroll(t *testing.T) {
+	vdp := makeTestVDP()
+	vdp.vsram[2], vdp.vsram[3] = 0xFC, 0x10 // Plane B, upper bits ignored
+	vs := vdp.VScroll()
+	if vs[1] != 0x0010 {
+		t.Errorf("expected 0x0010, got 0x%04X", vs[1])
+	}
+}
+
+func TestVDP_TileSheet(t *testing.T) {
+	vdp := makeTestVDP()
+	vdp.cram[32+6], vdp.cram[32+7] = 0x00, 0x0E // Palette 1, color 3: red
+	for i := 0; i < 32; i++ {
+		vdp.vram[33*32+i] = 0x33 // Tile 33: second row, second column
+	}
+
+	img := vdp.TileSheet(1)
+	if w, h := img.Bounds().Dx(), img.Bounds().Dy(); w != 256 || h != 512 {
+		t.Fatalf("expected 256x512 sheet, got %dx%d", w, h)
+	}
+	if got := img.RGBAAt(8, 8); got != (color.RGBA{R: 255, A: 255}) {
+		t.Errorf("tile 33: expected red, got %v", got)
+	}
+	if got := img.RGBAAt(0, 0); got.A != 0 {
+		t.Errorf("color 0 should be transparent, got %v", got)
+	}
+
+	// Interlace mode 2: 8x16 tiles
+	vdp.regs[12] = 0x06
+	if h := vdp.TileSheet(0).Bounds().Dy(); h != 512 {
+		t.Errorf("expected 1024 8x16 tiles (512 rows), got height %d", h)
+	}
+}
+
+func TestVDP_RenderNametable(t *testing.T) {
+	vdp := makeLayerTestVDP()
+	vdp.vram[0xE000+2*64*3+2*5] = 0x08 // Plane B cell (5,3): tile 2, H-flip
+	vdp.vram[0xE000+2*64*3+2*5+1] = 0x02
+
+	img := vdp.RenderNametable(PlaneB, false)
+	if w, h := img.Bounds().Dx(), img.Bounds().Dy(); w != 512 || h != 256 {
+		t.Fatalf("expected 512x256 plane, got %dx%d", w, h)
+	}
+	if got := img.RGBAAt(40, 24); got != (color.RGBA{R: 255, A: 255}) {
+		t.Errorf("cell (5,3): expected red, got %v", got)
+	}
+	if got := img.RGBAAt(16, 0); got.A != 0 {
+		t.Errorf("empty cell should be transparent, got %v", got)
+	}
+
+	win := vdp.RenderNametable(PlaneWindow, false)
+	if w, h := win.Bounds().Dx(), win.Bounds().Dy(); w != 512 || h != 256 {
+		t.Errorf("expected 512x256 H40 window, got %dx%d", w, h)
+	}
+}
+
+func TestVDP_RenderNametableOverlay(t *testing.T) {
+	vdp := makeLayerTestVDP()
+	// Plane A scrolled 16 pixels right, 8 down
+	vdp.vram[0xFC00], vdp.vram[0xFC01] = 0x00, 0x10
+	vdp.vsram[0], vdp.vsram[1] = 0x00, 0x08
+
+	img := vdp.RenderNametable(PlaneA, true)
+	// Screen (0,0) maps to plane (-16 & 511, 8)
+	if got := img.RGBAAt(496, 8); got != viewportColor {
+		t.Errorf("expected viewport corner at (496,8), got %v", got)
+	}
+	// Right edge wraps to 319-16 = 303
+	if got := img.RGBAAt(303, 100); got != viewportColor {
+		t.Errorf("expected viewport right edge at (303,100), got %v", got)
+	}
+	// Bottom line: 223+8 = 231
+	if got := img.RGBAAt(100, 231); got != viewportColor {
+		t.Errorf("expected viewport bottom at (100,231), got %v", got)
+	}
+	if got := img.RGBAAt(100, 100); got == viewportColor {
+		t.Error("viewport interior should not be outlined")
+	}
+}
+
+func TestVDP_Sprites(t *testing.T) {
+	vdp := makeLayerTestVDP()
+	sat := 0xD800
+	// Sprite 0 links to 2, sprite 2 ends the list
+	vdp.vram[sat+0], vdp.vram[sat+1] = 0x00, 0x90 // Y = 144
+	vdp.vram[sat+2], vdp.vram[sat+3] = 0x0D, 2    // 4x2 cells, link 2
+	vdp.vram[sat+4], vdp.vram[sat+5] = 0xE8, 0x05 // Priority, palette 3, H-flip, tile 5
+	vdp.vram[sat+6], vdp.vram[sat+7] = 0x00, 0xA0 // X = 160
+	e := sat + 16
+	vdp.vram[e+0], vdp.vram[e+1] = 0x00, 0x80
+	vdp.vram[e+2], vdp.vram[e+3] = 0x00, 0
+	vdp.vram[e+6], vdp.vram[e+7] = 0x01, 0x00
+
+	sprites := vdp.Sprites()
+	if len(sprites) != 2 {
+		t.Fatalf("expected 2 sprites, got %d", len(sprites))
+	}
+	want := SpriteInfo{
+		Index: 0, X: 32, Y: 16, Width: 4, Height: 2, Link: 2,
+		Tile: 5, Palette: 3, Priority: true, HFlip: true,
+	}
+	if sprites[0] != want {
+		t.Errorf("sprite 0: expected %+v, got %+v", want, sprites[0])
+	}
+	if s := sprites[1]; s.Index != 2 || s.X != 128 || s.Y != 0 || s.Width != 1 || s.Height != 1 {
+		t.Errorf("sprite 2: unexpected %+v", s)
+	}
+}
+
+func TestVDP_SpritesLinkLoop(t *testing.T) {
+	vdp := makeLayerTestVDP()
+	vdp.vram[0xD803] = 1 // 0 -> 1
+	vdp.vram[0xD80B] = 1 // 1 -> 1
+	if n := len(vdp.Sprites()); n != 80 {
+		t.Errorf("a link loop should stop at 80 sprites in H40, got %d", n)
+	}
+}
@@ -0,0 +1,321 @@
+package emu
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+
+	"github.com/user-none/go-chip-sn76489"
+)
+
+const (
+	vgmVersion    = 0x171
+	vgmHeaderSize = 0x100
+	vgmSampleRate = 44100
+
+	// VGM commands
+	vgmCmdPSG        = 0x50
+	vgmCmdYM2612P0   = 0x52
+	vgmCmdYM2612P1   = 0x53
+	vgmCmdWait       = 0x61
+	vgmCmdWaitNTSC   = 0x62 // 735 samples
+	vgmCmdWaitPAL    = 0x63 // 882 samples
+	vgmCmdEnd        = 0x66
+	vgmCmdDataBlock  = 0x67
+	vgmCmdWaitShort  = 0x70 // 0x7n: wait n+1 samples
+	vgmCmdDAC        = 0x80 // 0x8n: DAC write from data bank, wait n
+	vgmCmdPCMSeek    = 0xE0
+	vgmDataTypeYMPCM = 0x00
+)
+
+// vgmRecorder captures YM2612 and PSG register writes as a VGM 1.71
+// command stream. Time is taken in 68K clock cycles from the YM2612
+// sample counter plus the cycles the running CPU is into the scanline,
+// and converted to 44.1 kHz VGM samples. YM2612 DAC writes ($2A) are
+// collected into a PCM data block and replayed with 0x8n commands.
+type vgmRecorder struct {
+	ym         *YM2612
+	psg        *sn76489.SN76489
+	lineCycles func() int // Cycles into the scanline not yet clocked into the YM2612
+
+	ymClock  uint32
+	psgClock uint32
+	rate     uint32 // Frame rate written to the header
+
+	cmds []byte
+	pcm  []byte
+
+	lastCycle  uint64 // Latest cycle a command was timed at
+	cycleTotal uint64 // Cycles elapsed since recording started
+	samples    uint64 // VGM samples emitted so far
+	dacCmd     int    // Index of the last 0x8n command, -1 if none
+}
+
+func newVGMRecorder(ym *YM2612, psg *sn76489.SN76489, timing RegionTiming, lineCycles func() int) *vgmRecorder {
+	r := &vgmRecorder{
+		ym:         ym,
+		psg:        psg,
+		lineCycles: lineCycles,
+		ymClock:    uint32(timing.M68KClockHz),
+		psgClock:   uint32(timing.Z80ClockHz),
+		rate:       uint32(timing.FPS),
+		dacCmd:     -1,
+	}
+	r.lastCycle = r.cycle()
+	r.dumpState()
+	return r
+}
+
+// cycle returns the current time in 68K clock cycles.
+func (r *vgmRecorder) cycle() uint64 {
+	return r.ym.nativeSampleCount*144 + uint64(r.ym.cycleAccum) + uint64(r.lineCycles())
+}
+
+// sync emits a wait covering the time since the previous command. The Z80
+// runs after the 68K on each scanline, so its writes can be timed before
+// a 68K write already logged; those keep the later time.
+func (r *vgmRecorder) sync() {
+	now := r.cycle()
+	if now > r.lastCycle {
+		r.cycleTotal += now - r.lastCycle
+		r.lastCycle = now
+	}
+
+	target := r.cycleTotal * vgmSampleRate / uint64(r.ymClock)
+	if target > r.samples {
+		r.wait(target - r.samples)
+		r.samples = target
+	}
+}
+
+// wait appends wait commands for n samples. Short waits directly after a
+// DAC write are folded into its 0x8n command.
+func (r *vgmRecorder) wait(n uint64) {
+	if r.dacCmd >= 0 && r.dacCmd == len(r.cmds)-1 {
+		fold := min(n, 15)
+		r.cmds[r.dacCmd] |= byte(fold)
+		n -= fold
+	}
+	r.dacCmd = -1
+	for n > 0 {
+		switch {
+		case n == 735:
+			r.cmds = append(r.cmds, vgmCmdWaitNTSC)
+			n = 0
+		case n == 882:
+			r.cmds = append(r.cmds, vgmCmdWaitPAL)
+			n = 0
+		case n <= 16:
+			r.cmds = append(r.cmds, vgmCmdWaitShort|byte(n-1))
+			n = 0
+		default:
+			chunk := min(n, 0xFFFF)
+			r.cmds = append(r.cmds, vgmCmdWait, byte(chunk), byte(chunk>>8))
+			n -= chunk
+		}
+	}
+}
+
+// ymWrite records a YM2612 register write to part 0 or 1.
+func (r *vgmRecorder) ymWrite(part int, reg, val uint8) {
+	r.sync()
+	if part == 0 && reg == 0x2A {
+		r.pcm = append(r.pcm, val)
+		r.cmds = append(r.cmds, vgmCmdDAC)
+		r.dacCmd = len(r.cmds) - 1
+		return
+	}
+	r.cmds = append(r.cmds, vgmCmdYM2612P0+byte(part), reg, val)
+}
+
+// psgWrite records a byte written to the PSG port.
+func (r *vgmRecorder) psgWrite(val uint8) {
+	r.sync()
+	r.cmds = append(r.cmds, vgmCmdPSG, val)
+}
+
+// resync continues the log after the chips were restored from a save
+// state. The restored sample counter is taken as the new time base and
+// the restored register state is recorded.
+func (r *vgmRecorder) resync() {
+	r.lastCycle = r.cycle()
+	r.dumpState()
+}
+
+// dumpState writes the current YM2612 and PSG register state so playback
+// starts from the same sound as the emulator.
+func (r *vgmRecorder) dumpState() {
+	y := r.ym
+	ym := func(part int, reg, val uint8) {
+		r.cmds = append(r.cmds, vgmCmdYM2612P0+byte(part), reg, val)
+	}
+
+	lfo := y.lfoFreq
+	if y.lfoEnable {
+		lfo |= 0x08
+	}
+	ym(0, 0x22, lfo)
+	ym(0, 0x24, uint8(y.timerA.period>>2))
+	ym(0, 0x25, uint8(y.timerA.period&0x03))
+	ym(0, 0x26, uint8(y.timerB.period))
+	ctrl := y.ch3Mode << 6
+	if y.timerALoad {
+		ctrl |= 0x01
+	}
+	if y.timerBLoad {
+		ctrl |= 0x02
+	}
+	if y.timerAEnable {
+		ctrl |= 0x04
+	}
+	if y.timerBEnable {
+		ctrl |= 0x08
+	}
+	ym(0, 0x27, ctrl)
+	var dac uint8
+	if y.dacEnable {
+		dac = 0x80
+	}
+	ym(0, 0x2B, dac)
+
+	for chIdx := 0; chIdx < 6; chIdx++ {
+		part, slot := chIdx/3, uint8(chIdx%3)
+		ch := &y.ch[chIdx]
+		for opSlot := 0; opSlot < 4; opSlot++ {
+			op := &ch.op[operatorOrder[opSlot]]
+			base := slot + uint8(opSlot)<<2
+			ym(part, 0x30+base, op.dt<<4|op.mul)
+			ym(part, 0x40+base, op.tl)
+			ym(part, 0x50+base, op.rs<<6|op.ar)
+			d1r := op.d1r
+			if op.am {
+				d1r |= 0x80
+			}
+			ym(part, 0x60+base, d1r)
+			ym(part, 0x70+base, op.d2r)
+			ym(part, 0x80+base, op.d1l<<4|op.rr)
+			ym(part, 0x90+base, op.ssgEG)
+		}
+		ym(part, 0xA4+slot, ch.block<<3|uint8(ch.fNum>>8))
+		ym(part, 0xA0+slot, uint8(ch.fNum))
+		ym(part, 0xB0+slot, ch.feedback<<3|ch.algorithm)
+		pan := ch.ams<<4 | ch.fms
+		if ch.panL {
+			pan |= 0x80
+		}
+		if ch.panR {
+			pan |= 0x40
+		}
+		ym(part, 0xB4+slot, pan)
+	}
+	for slot := 0; slot < 3; slot++ {
+		ym(0, 0xAC+uint8(slot), y.ch3Block[slot]<<3|uint8(y.ch3Freq[slot]>>8))
+		ym(0, 0xA8+uint8(slot), uint8(y.ch3Freq[slot]))
+	}
+	for chIdx := 0; chIdx < 6; chIdx++ {
+		key := uint8(chIdx % 3)
+		if chIdx >= 3 {
+			key |= 0x04
+		}
+		for i := 0; i < 4; i++ {
+			if y.ch[chIdx].op[i].keyOn {
+				key |= 0x10 << uint(i)
+			}
+		}
+		ym(0, 0x28, key)
+	}
+	ym(0, 0x2A, y.dacSample)
+
+	for ch := 0; ch < 3; ch++ {
+		tone := r.psg.GetToneReg(ch)
+		r.cmds = append(r.cmds,
+			vgmCmdPSG, 0x80|byte(ch)<<5|byte(tone&0x0F),
+			vgmCmdPSG, byte(tone>>4)&0x3F)
+	}
+	r.cmds = append(r.cmds, vgmCmdPSG, 0xE0|r.psg.GetNoiseReg())
+	for ch := 0; ch < 4; ch++ {
+		r.cmds = append(r.cmds, vgmCmdPSG, 0x90|byte(ch)<<5|r.psg.GetVolume(ch))
+	}
+	r.dacCmd = -1
+}
+
+// finish returns the complete VGM file.
+func (r *vgmRecorder) finish() []byte {
+	r.sync()
+
+	var body []byte
+	if len(r.pcm) > 0 {
+		body = append(body, vgmCmdDataBlock, 0x66, vgmDataTypeYMPCM)
+		body = binary.LittleEndian.AppendUint32(body, uint32(len(r.pcm)))
+		body = append(body, r.pcm...)
+		body = append(body, vgmCmdPCMSeek, 0, 0, 0, 0)
+	}
+	body = append(body, r.cmds...)
+	body = append(body, vgmCmdEnd)
+
+	hdr := make([]byte, vgmHeaderSize)
+	copy(hdr[0x00:], "Vgm ")
+	binary.LittleEndian.PutUint32(hdr[0x04:], uint32(vgmHeaderSize+len(body)-0x04))
+	binary.LittleEndian.PutUint32(hdr[0x08:], vgmVersion)
+	binary.LittleEndian.PutUint32(hdr[0x0C:], r.psgClock)
+	binary.LittleEndian.PutUint32(hdr[0x18:], uint32(r.samples))
+	binary.LittleEndian.PutUint32(hdr[0x24:], r.rate)
+	binary.LittleEndian.PutUint16(hdr[0x28:], 0x0009) // SN76489 noise feedback (Sega)
+	hdr[0x2A] = 16                                    // SN76489 shift register width
+	binary.LittleEndian.PutUint32(hdr[0x2C:], r.ymClock)
+	binary.LittleEndian.PutUint32(hdr[0x34:], vgmHeaderSize-0x34)
+
+	return append(hdr, body...)
+}
+
+// StartVGMLog begins recording YM2612 and PSG writes. The current chip
+// register state is written first so the log plays from the same sound.
+func (e *Emulator) StartVGMLog() error {
+	if e.vgm != nil {
+		return errors.New("VGM log already active")
+	}
+	e.vgm = newVGMRecorder(e.ym2612, e.psg, e.timing, e.lineCycles)
+	e.ym2612.vgm = e.vgm
+	e.bus.vgm = e.vgm
+	return nil
+}
+
+// StopVGMLog ends recording and returns the VGM 1.71 file.
+func (e *Emulator) StopVGMLog() ([]byte, error) {
+	if e.vgm == nil {
+		return nil, errors.New("VGM log not active")
+	}
+	data := e.vgm.finish()
+	e.vgm = nil
+	e.ym2612.vgm = nil
+	e.bus.vgm = nil
+	return data, nil
+}
+
+// VGMLogging reports whether a VGM log is being recorded.
+func (e *Emulator) VGMLogging() bool {
+	return e.vgm != nil
+}
+
+// lineCycles returns how far the running CPU is into the scanline, in
+// 68K clock cycles. The Z80 runs its part of the line after the 68K.
+func (e *Emulator) lineCycles() int {
+	if e.z80OnLine {
+		return int((e.z80.Cycles() - e.lineZ80) * z80ClockDivider / m68kClockDivider)
+	}
+	return int(e.m68k.Cycles() - e.lineM68K)
+}
+
+// CompressVGZ gzips a VGM file into the .vgz format.
+func CompressVGZ(vgm []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(vgm); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,409 @@
+package emu
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"testing"
+)
+
+// vgmCommand is one decoded command from a VGM stream.
+type vgmCommand struct {
+	op   byte
+	args []byte
+	wait int
+}
+
+// parseVGMCommands walks the command stream of a VGM file.
+func parseVGMCommands(t *testing.T, vgm []byte) []vgmCommand {
+	t.Helper()
+	pos := 0x34 + int(binary.LittleEndian.Uint32(vgm[0x34:]))
+	var cmds []vgmCommand
+	for pos < len(vgm) {
+		op := vgm[pos]
+		c := vgmCommand{op: op}
+		switch {
+		case op == vgmCmdPSG:
+			c.args = vgm[pos+1 : pos+2]
+			pos += 2
+		case op == vgmCmdYM2612P0 || op == vgmCmdYM2612P1:
+			c.args = vgm[pos+1 : pos+3]
+			pos += 3
+		case op == vgmCmdWait:
+			c.wait = int(binary.LittleEndian.Uint16(vgm[pos+1:]))
+			pos += 3
+		case op == vgmCmdWaitNTSC:
+			c.wait = 735
+			pos++
+		case op == vgmCmdWaitPAL:
+			c.wait = 882
+			pos++
+		case op == vgmCmdEnd:
+			return append(cmds, c)
+		case op == vgmCmdDataBlock:
+			size := int(binary.LittleEndian.Uint32(vgm[pos+3:]))
+			c.args = vgm[pos+7 : pos+7+size]
+			pos += 7 + size
+		case op&0xF0 == vgmCmdWaitShort:
+			c.wait = int(op&0x0F) + 1
+			pos++
+		case op&0xF0 == vgmCmdDAC:
+			c.wait = int(op & 0x0F)
+			pos++
+		case op == vgmCmdPCMSeek:
+			c.args = vgm[pos+1 : pos+5]
+			pos += 5
+		default:
+			t.Fatalf("unexpected command 0x%02X at 0x%X", op, pos)
+		}
+		cmds = append(cmds, c)
+	}
+	t.Fatal("missing end of data command")
+	return nil
+}
+
+func TestVGM_Header(t *testing.T) {
+	e := createTestEmulator()
+	if err := e.StartVGMLog(); err != nil {
+		t.Fatal(err)
+	}
+	if !e.VGMLogging() {
+		t.Fatal("expected logging to be active")
+	}
+	if err := e.StartVGMLog(); err == nil {
+		t.Error("expected error starting a second log")
+	}
+	e.RunFrame()
+	vgm, err := e.StopVGMLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.VGMLogging() {
+		t.Error("expected logging to be stopped")
+	}
+
+	if string(vgm[:4]) != "Vgm " {
+		t.Fatalf("bad magic %q", vgm[:4])
+	}
+	le := binary.LittleEndian
+	if got := le.Uint32(vgm[0x04:]); int(got) != len(vgm)-4 {
+		t.Errorf("EOF offset: expected 0x%X, got 0x%X", len(vgm)-4, got)
+	}
+	if got := le.Uint32(vgm[0x08:]); got != 0x171 {
+		t.Errorf("version: expected 0x171, got 0x%X", got)
+	}
+	if got := le.Uint32(vgm[0x0C:]); got != uint32(e.timing.Z80ClockHz) {
+		t.Errorf("SN76489 clock: got %d", got)
+	}
+	if got := le.Uint32(vgm[0x2C:]); got != uint32(e.timing.M68KClockHz) {
+		t.Errorf("YM2612 clock: got %d", got)
+	}
+	if got := le.Uint32(vgm[0x24:]); got != 60 {
+		t.Errorf("rate: expected 60, got %d", got)
+	}
+	if le.Uint16(vgm[0x28:]) != 0x0009 || vgm[0x2A] != 16 {
+		t.Error("bad SN76489 feedback or shift width")
+	}
+	if got := 0x34 + le.Uint32(vgm[0x34:]); got != 0x100 {
+		t.Errorf("data offset: expected 0x100, got 0x%X", got)
+	}
+
+	// One NTSC frame is 735 samples, give or take rounding
+	total := le.Uint32(vgm[0x18:])
+	if total < 730 || total > 740 {
+		t.Errorf("total samples: expected ~735, got %d", total)
+	}
+	waits := 0
+	for _, c := range parseVGMCommands(t, vgm) {
+		waits += c.wait
+	}
+	if waits != int(total) {
+		t.Errorf("waits sum to %d, header says %d", waits, total)
+	}
+}
+
+func TestVGM_NotActive(t *testing.T) {
+	e := createTestEmulator()
+	if _, err := e.StopVGMLog(); err == nil {
+		t.Error("expected error stopping an inactive log")
+	}
+}
+
+// vgmWrites returns the chip write commands of a VGM file.
+func vgmWrites(t *testing.T, vgm []byte) []vgmCommand {
+	t.Helper()
+	var writes []vgmCommand
+	for _, c := range parseVGMCommands(t, vgm) {
+		switch c.op {
+		case vgmCmdYM2612P0, vgmCmdYM2612P1, vgmCmdPSG:
+			writes = append(writes, c)
+		}
+	}
+	return writes
+}
+
+func TestVGM_RecordsWrites(t *testing.T) {
+	e := createTestEmulator()
+	if err := e.StartVGMLog(); err != nil {
+		t.Fatal(err)
+	}
+	e.RunFrame()
+	e.ym2612.WritePort(0, 0xA4)
+	e.ym2612.WritePort(1, 0x22)
+	e.ym2612.WritePort(2, 0xB4)
+	e.ym2612.WritePort(3, 0xC0)
+	e.bus.writePSG(0x9F)
+	e.RunFrame()
+	vgm, err := e.StopVGMLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writes := vgmWrites(t, vgm)
+	tail := writes[len(writes)-3:]
+	if tail[0].op != vgmCmdYM2612P0 || !bytes.Equal(tail[0].args, []byte{0xA4, 0x22}) {
+		t.Errorf("part 0 write: %+v", tail[0])
+	}
+	if tail[1].op != vgmCmdYM2612P1 || !bytes.Equal(tail[1].args, []byte{0xB4, 0xC0}) {
+		t.Errorf("part 1 write: %+v", tail[1])
+	}
+	if tail[2].op != vgmCmdPSG || tail[2].args[0] != 0x9F {
+		t.Errorf("PSG write: %+v", tail[2])
+	}
+}
+
+func TestVGM_TimesWritesWithinScanline(t *testing.T) {
+	e := createTestEmulator()
+	copy(e.bus.rom[0x200:], []byte{
+		0x33, 0xFC, 0xB4, 0xC0, 0x00, 0xA0, 0x40, 0x00, // move.w #$B4C0,($A04000).l
+		0x72, 0x1E, // moveq #30,d1
+		0x51, 0xC9, 0xFF, 0xFE, // dbra d1,*
+		0x33, 0xFC, 0xB5, 0xC0, 0x00, 0xA0, 0x40, 0x00, // move.w #$B5C0,($A04000).l
+		0x60, 0xFE, // bra.s *
+	})
+	if err := e.StartVGMLog(); err != nil {
+		t.Fatal(err)
+	}
+	e.RunFrame()
+	vgm, err := e.StopVGMLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Both writes land in the first scanline, about 330 68K cycles or
+	// two VGM samples apart
+	cmds := parseVGMCommands(t, vgm)
+	first, second := -1, -1
+	for i, c := range cmds {
+		if c.op == vgmCmdYM2612P0 && c.args[0] == 0xB4 && c.args[1] == 0xC0 {
+			first = i
+		}
+		if c.op == vgmCmdYM2612P0 && c.args[0] == 0xB5 && c.args[1] == 0xC0 {
+			second = i
+		}
+	}
+	if first < 0 || second < first {
+		t.Fatalf("writes not found in order: %d, %d", first, second)
+	}
+	wait := 0
+	for _, c := range cmds[first:second] {
+		wait += c.wait
+	}
+	if wait < 1 || wait > 3 {
+		t.Errorf("expected about 2 samples between the writes, got %d", wait)
+	}
+}
+
+func TestVGM_StateDump(t *testing.T) {
+	e := createTestEmulator()
+	y := e.ym2612
+	// Program a voice the recorder has to reproduce
+	for _, w := range [][3]uint8{
+		{0, 0x22, 0x0B}, {0, 0x27, 0x40}, {0, 0x31, 0x71}, {0, 0x45, 0x23},
+		{0, 0x59, 0x5F}, {0, 0x6D, 0x85}, {0, 0x71, 0x02}, {0, 0x85, 0x1A},
+		{0, 0x91, 0x0C}, {0, 0xA5, 0x2A}, {0, 0xA1, 0x69}, {0, 0xB1, 0x3C},
+		{0, 0xB5, 0xB2}, {0, 0xAD, 0x13}, {0, 0xA9, 0x45}, {1, 0xB6, 0x40},
+		{0, 0x28, 0xF1},
+	} {
+		y.WritePort(w[0]*2, w[1])
+		y.WritePort(w[0]*2+1, w[2])
+	}
+	e.bus.writePSG(0xA5)
+	e.bus.writePSG(0x12)
+	e.bus.writePSG(0xB7)
+	e.bus.writePSG(0xE6)
+
+	if err := e.StartVGMLog(); err != nil {
+		t.Fatal(err)
+	}
+	vgm, err := e.StopVGMLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fresh := createTestEmulator()
+	for _, c := range vgmWrites(t, vgm) {
+		switch c.op {
+		case vgmCmdPSG:
+			fresh.psg.Write(c.args[0])
+		default:
+			part := c.op - vgmCmdYM2612P0
+			fresh.ym2612.WritePort(part*2, c.args[0])
+			fresh.ym2612.WritePort(part*2+1, c.args[1])
+		}
+	}
+
+	fy := fresh.ym2612
+	for i := range y.ch {
+		a, b := &y.ch[i], &fy.ch[i]
+		if a.fNum != b.fNum || a.block != b.block || a.algorithm != b.algorithm ||
+			a.feedback != b.feedback || a.panL != b.panL || a.panR != b.panR ||
+			a.ams != b.ams || a.fms != b.fms {
+			t.Errorf("channel %d differs after replay", i)
+		}
+		for j := range a.op {
+			oa, ob := &a.op[j], &b.op[j]
+			if oa.dt != ob.dt || oa.mul != ob.mul || oa.tl != ob.tl || oa.rs != ob.rs ||
+				oa.ar != ob.ar || oa.d1r != ob.d1r || oa.d2r != ob.d2r || oa.d1l != ob.d1l ||
+				oa.rr != ob.rr || oa.am != ob.am || oa.ssgEG != ob.ssgEG || oa.keyOn != ob.keyOn {
+				t.Errorf("channel %d operator %d differs after replay", i, j)
+			}
+		}
+	}
+	if y.lfoEnable != fy.lfoEnable || y.lfoFreq != fy.lfoFreq || y.ch3Mode != fy.ch3Mode ||
+		y.ch3Freq != fy.ch3Freq || y.ch3Block != fy.ch3Block {
+		t.Error("global registers differ after replay")
+	}
+	for ch := 0; ch < 3; ch++ {
+		if e.psg.GetToneReg(ch) != fresh.psg.GetToneReg(ch) {
+			t.Errorf("PSG tone %d differs after replay", ch)
+		}
+	}
+	for ch := 0; ch < 4; ch++ {
+		if e.psg.GetVolume(ch) != fresh.psg.GetVolume(ch) {
+			t.Errorf("PSG volume %d differs after replay", ch)
+		}
+	}
+	if e.psg.GetNoiseReg() != fresh.psg.GetNoiseReg() {
+		t.Error("PSG noise differs after replay")
+	}
+}
+
+func TestVGM_DACStream(t *testing.T) {
+	e := createTestEmulator()
+	if err := e.StartVGMLog(); err != nil {
+		t.Fatal(err)
+	}
+	samples := []byte{0x10, 0x80, 0xF0}
+	for _, s := range samples {
+		e.ym2612.WritePort(0, 0x2A)
+		e.ym2612.WritePort(1, s)
+		e.RunFrame()
+	}
+	vgm, err := e.StopVGMLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cmds := parseVGMCommands(t, vgm)
+	if cmds[0].op != vgmCmdDataBlock || !bytes.Equal(cmds[0].args, samples) {
+		t.Fatalf("expected data block %X first, got %+v", samples, cmds[0])
+	}
+	if cmds[1].op != vgmCmdPCMSeek {
+		t.Errorf("expected PCM seek after data block, got 0x%02X", cmds[1].op)
+	}
+	dac := 0
+	for _, c := range cmds {
+		if c.op&0xF0 == vgmCmdDAC {
+			dac++
+			if c.wait == 0 {
+				t.Error("expected the following wait folded into the DAC command")
+			}
+		}
+		if c.op == vgmCmdYM2612P0 && c.args[0] == 0x2A && dac > 0 {
+			t.Error("DAC writes should not be logged as register writes")
+		}
+	}
+	if dac != len(samples) {
+		t.Errorf("expected %d DAC commands, got %d", len(samples), dac)
+	}
+}
+
+func TestVGM_WaitEncoding(t *testing.T) {
+	tests := []struct {
+		n    uint64
+		want []byte
+	}{
+		{1, []byte{0x70}},
+		{16, []byte{0x7F}},
+		{17, []byte{0x61, 0x11, 0x00}},
+		{735, []byte{0x62}},
+		{882, []byte{0x63}},
+		{70000, []byte{0x61, 0xFF, 0xFF, 0x61, 0x71, 0x11}},
+	}
+	for _, tt := range tests {
+		r := &vgmRecorder{dacCmd: -1}
+		r.wait(tt.n)
+		if !bytes.Equal(r.cmds, tt.want) {
+			t.Errorf("wait(%d): expected %X, got %X", tt.n, tt.want, r.cmds)
+		}
+	}
+}
+
+func TestVGM_Deserialize(t *testing.T) {
+	e := createTestEmulator()
+	e.RunFrame()
+	state, err := e.Serialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.StartVGMLog(); err != nil {
+		t.Fatal(err)
+	}
+	e.RunFrame()
+	e.RunFrame()
+	if err := e.Deserialize(state); err != nil {
+		t.Fatal(err)
+	}
+	e.RunFrame()
+	vgm, err := e.StopVGMLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Loading a state must not rewind or skip the log
+	total := binary.LittleEndian.Uint32(vgm[0x18:])
+	if total < 3*730 || total > 3*740 {
+		t.Errorf("expected ~3 frames of samples, got %d", total)
+	}
+}
+
+func TestCompressVGZ(t *testing.T) {
+	e := createTestEmulator()
+	if err := e.StartVGMLog(); err != nil {
+		t.Fatal(err)
+	}
+	e.RunFrame()
+	vgm, err := e.StopVGMLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vgz, err := CompressVGZ(vgm)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vgz[0] != 0x1F || vgz[1] != 0x8B {
+		t.Fatal("expected gzip magic")
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(vgz))
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, vgm) {
+		t.Error("decompressed VGZ does not match VGM")
+	}
+}
@@ -0,0 +1,445 @@
+package emu
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/user-none/go-chip-sn76489"
+)
+
+// VGM commands only understood by the player
+const (
+	vgmCmdStreamSetup = 0x90
+	vgmCmdStreamData  = 0x91
+	vgmCmdStreamFreq  = 0x92
+	vgmCmdStreamStart = 0x93
+	vgmCmdStreamStop  = 0x94
+	vgmCmdStreamFast  = 0x95
+	vgmChipYM2612     = 0x02 // DAC stream chip type
+	vgmFrameSamples   = vgmSampleRate / 60
+	vgmClockMask      = 0x3FFFFFFF // Clear dual-chip and variant flags
+)
+
+// vgmBlock locates one data block within a data bank.
+type vgmBlock struct {
+	offset int
+	length int
+}
+
+// vgmStream is a DAC stream set up with commands 0x90-0x95. Only streams
+// feeding the YM2612 DAC are played.
+type vgmStream struct {
+	ym       bool   // Target is YM2612 register $2A
+	bank     uint8  // Data bank type
+	stepSize int    // Bytes advanced per write
+	stepBase int    // Offset added to the start position
+	freq     uint64 // Writes per second
+	accum    uint64 // Bresenham accumulator against vgmSampleRate
+	pos      int    // Next byte in the bank
+	left     int    // Writes remaining, -1 to play until stopped
+	start    int    // Restart position for looping
+	count    int    // Restart write count for looping
+	loop     bool
+	active   bool
+}
+
+// VGMPlayer plays VGM and VGZ files through the emulator's YM2612 and
+// SN76489 cores and the same mixing and Model 1 low-pass path used by
+// Emulator, without running any CPUs.
+type VGMPlayer struct {
+	ym  *YM2612
+	psg *sn76489.SN76489
+
+	data      []byte
+	pos       int
+	loopStart int // Command offset to jump to at end of data, 0 for none
+	loopsLeft int
+	done      bool
+
+	ymClock  uint64
+	psgClock uint64
+
+	// Time is tracked in 44.1 kHz VGM samples; chip clocks are issued
+	// from totals so rounding never accumulates.
+	wait      int
+	samples   uint64
+	ymCycles  uint64
+	psgCycles uint64
+
+	// YM2612 PCM data bank (data block type 0) and its read position
+	pcm    []byte
+	blocks []vgmBlock
+	loaded map[int]bool // File offsets of data blocks already loaded
+	pcmPos int
+
+	streams [256]*vgmStream // Indexed by stream ID
+
+	audioBuffer []int16
+	filterPrevL float64
+	filterPrevR float64
+}
+
+// NewVGMPlayer parses a VGM file. Gzipped (.vgz) data is detected and
+// decompressed. The loop section, if any, is played once.
+func NewVGMPlayer(data []byte) (*VGMPlayer, error) {
+	if len(data) >= 2 && data[0] == 0x1F && data[1] == 0x8B {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("vgz: %w", err)
+		}
+		data, err = io.ReadAll(zr)
+		if err != nil {
+			return nil, fmt.Errorf("vgz: %w", err)
+		}
+	}
+	if len(data) < 0x40 || string(data[:4]) != "Vgm " {
+		return nil, errors.New("not a VGM file")
+	}
+
+	le := binary.LittleEndian
+	version := le.Uint32(data[0x08:])
+	psgClock := le.Uint32(data[0x0C:]) & vgmClockMask
+	// Before 1.10 the YM2612 shared the YM2413 clock field
+	ymClock := le.Uint32(data[0x10:]) & vgmClockMask
+	if version >= 0x110 {
+		ymClock = le.Uint32(data[0x2C:]) & vgmClockMask
+	}
+	if ymClock == 0 && psgClock == 0 {
+		return nil, errors.New("VGM has no YM2612 or SN76489")
+	}
+	if ymClock == 0 {
+		ymClock = uint32(NTSCTiming.M68KClockHz)
+	}
+	if psgClock == 0 {
+		psgClock = uint32(NTSCTiming.Z80ClockHz)
+	}
+
+	start := 0x40
+	if version >= 0x150 {
+		if off := le.Uint32(data[0x34:]); off != 0 {
+			start = 0x34 + int(off)
+		}
+	}
+	if start >= len(data) {
+		return nil, errors.New("VGM data offset out of range")
+	}
+	loopStart := 0
+	if off := le.Uint32(data[0x1C:]); off != 0 && 0x1C+int(off) < len(data) {
+		loopStart = 0x1C + int(off)
+	}
+
+	psg := sn76489.New(int(psgClock), sampleRate, psgBufferSize, sn76489.Sega)
+	psg.SetGain(psgGain)
+
+	return &VGMPlayer{
+		ym:          NewYM2612(int(ymClock), sampleRate),
+		psg:         psg,
+		data:        data,
+		pos:         start,
+		loopStart:   loopStart,
+		loopsLeft:   1,
+		ymClock:     uint64(ymClock),
+		psgClock:    uint64(psgClock),
+		loaded:      make(map[int]bool),
+		audioBuffer: make([]int16, 0, 2048),
+	}, nil
+}
+
+// SetLoops sets how many times the loop section is repeated after the
+// first pass. Negative values loop forever.
+func (p *VGMPlayer) SetLoops(n int) {
+	p.loopsLeft = n
+}
+
+// SampleRate returns the output sample rate in Hz.
+func (p *VGMPlayer) SampleRate() int {
+	return sampleRate
+}
+
+// Done reports whether playback reached the end of the data.
+func (p *VGMPlayer) Done() bool {
+	return p.done
+}
+
+// Samples returns the number of 44.1 kHz VGM samples played.
+func (p *VGMPlayer) Samples() uint64 {
+	return p.samples
+}
+
+// RenderFrame plays 1/60 second of the file and returns the filtered
+// 16-bit stereo PCM. The returned slice is reused by the next call. The
+// final frame is shorter when playback ends part way through.
+func (p *VGMPlayer) RenderFrame() []int16 {
+	p.audioBuffer = p.audioBuffer[:0]
+	p.psg.ResetBuffer()
+
+	remaining := vgmFrameSamples
+	for remaining > 0 && !p.done {
+		if p.wait == 0 {
+			p.execute()
+			continue
+		}
+		n := min(p.wait, remaining)
+		p.advance(n)
+		p.wait -= n
+		remaining -= n
+	}
+
+	p.audioBuffer = mixChips(p.audioBuffer, p.ym, p.psg)
+	lowPass(p.audioBuffer, &p.filterPrevL, &p.filterPrevR)
+	return p.audioBuffer
+}
+
+// advance runs the chips for n VGM samples. While a DAC stream is active
+// time moves one sample at a time so stream writes land on time.
+func (p *VGMPlayer) advance(n int) {
+	for n > 0 {
+		step := n
+		if p.streamsActive() {
+			p.stepStreams()
+			step = 1
+		}
+		p.samples += uint64(step)
+		n -= step
+
+		ymTarget := p.samples * p.ymClock / vgmSampleRate
+		p.ym.GenerateSamples(int(ymTarget - p.ymCycles))
+		p.ymCycles = ymTarget
+
+		psgTarget := p.samples * p.psgClock / vgmSampleRate
+		p.psg.Run(int(psgTarget - p.psgCycles))
+		p.psgCycles = psgTarget
+	}
+}
+
+// execute runs the next command. Truncated commands end playback.
+func (p *VGMPlayer) execute() {
+	d := p.data
+	if p.pos >= len(d) {
+		p.done = true
+		return
+	}
+	op := d[p.pos]
+	n := vgmCommandLength(op)
+	if op == vgmCmdDataBlock {
+		if p.pos+7 > len(d) {
+			p.done = true
+			return
+		}
+		n = 7 + int(binary.LittleEndian.Uint32(d[p.pos+3:])&0x7FFFFFFF)
+	}
+	if p.pos+n > len(d) {
+		p.done = true
+		return
+	}
+	at := p.pos
+	args := d[p.pos+1 : p.pos+n]
+	p.pos += n
+
+	switch {
+	case op == vgmCmdPSG:
+		p.psg.Write(args[0])
+	case op == vgmCmdYM2612P0 || op == vgmCmdYM2612P1:
+		part := (op - vgmCmdYM2612P0) * 2
+		p.ym.WritePort(part, args[0])
+		p.ym.WritePort(part+1, args[1])
+	case op == vgmCmdWait:
+		p.wait = int(binary.LittleEndian.Uint16(args))
+	case op == vgmCmdWaitNTSC:
+		p.wait = 735
+	case op == vgmCmdWaitPAL:
+		p.wait = 882
+	case op == vgmCmdEnd:
+		if p.loopStart == 0 || p.loopsLeft == 0 {
+			p.done = true
+			return
+		}
+		if p.loopsLeft > 0 {
+			p.loopsLeft--
+		}
+		p.pos = p.loopStart
+	case op == vgmCmdDataBlock:
+		// Blocks inside the loop section are only loaded once
+		if args[1] == vgmDataTypeYMPCM && !p.loaded[at] {
+			p.loaded[at] = true
+			block := args[6:]
+			p.blocks = append(p.blocks, vgmBlock{offset: len(p.pcm), length: len(block)})
+			p.pcm = append(p.pcm, block...)
+		}
+	case op&0xF0 == vgmCmdWaitShort:
+		p.wait = int(op&0x0F) + 1
+	case op&0xF0 == vgmCmdDAC:
+		if p.pcmPos < len(p.pcm) {
+			p.ym.WritePort(0, 0x2A)
+			p.ym.WritePort(1, p.pcm[p.pcmPos])
+			p.pcmPos++
+		}
+		p.wait = int(op & 0x0F)
+	case op == vgmCmdPCMSeek:
+		p.pcmPos = int(binary.LittleEndian.Uint32(args))
+	case op >= vgmCmdStreamSetup && op <= vgmCmdStreamFast:
+		p.streamCommand(op, args)
+	}
+}
+
+// vgmCommandLength returns the size of a command including its opcode.
+// Commands for chips other than the YM2612 and SN76489 are skipped using
+// the lengths reserved for their ranges. Data blocks are sized separately.
+func vgmCommandLength(op byte) int {
+	switch {
+	case op == 0x4F || op == vgmCmdPSG:
+		return 2
+	case op >= 0x51 && op <= 0x5F:
+		return 3
+	case op == vgmCmdWait:
+		return 3
+	case op == 0x68:
+		return 12
+	case op >= 0x70 && op <= 0x8F:
+		return 1
+	case op == vgmCmdStreamSetup, op == vgmCmdStreamData, op == vgmCmdStreamFast:
+		return 5
+	case op == vgmCmdStreamFreq:
+		return 6
+	case op == vgmCmdStreamStart:
+		return 11
+	case op == vgmCmdStreamStop:
+		return 2
+	case op >= 0x30 && op <= 0x3F:
+		return 2
+	case op >= 0x40 && op <= 0x4E:
+		return 3
+	case op >= 0xA0 && op <= 0xBF:
+		return 3
+	case op >= 0xC0 && op <= 0xDF:
+		return 4
+	case op >= 0xE0:
+		return 5
+	}
+	return 1
+}
+
+// streamCommand handles the DAC stream control commands 0x90-0x95.
+func (p *VGMPlayer) streamCommand(op byte, args []byte) {
+	le := binary.LittleEndian
+	id := args[0]
+	s := p.streams[id]
+	if s == nil {
+		if id == 0xFF {
+			// Stop all streams
+			if op == vgmCmdStreamStop {
+				for _, s := range p.streams {
+					if s != nil {
+						s.active = false
+					}
+				}
+			}
+			return
+		}
+		s = &vgmStream{stepSize: 1}
+		p.streams[id] = s
+	}
+
+	switch op {
+	case vgmCmdStreamSetup:
+		s.ym = args[1]&0x7F == vgmChipYM2612 && args[2] == 0 && args[3] == 0x2A
+	case vgmCmdStreamData:
+		s.bank = args[1]
+		s.stepSize = max(int(args[2]), 1)
+		s.stepBase = int(args[3])
+	case vgmCmdStreamFreq:
+		s.freq = uint64(le.Uint32(args[1:]))
+	case vgmCmdStreamStart:
+		mode := args[5]
+		length := int(le.Uint32(args[6:]))
+		// An offset of all ones continues from the current position
+		s.start = s.pos
+		if off := le.Uint32(args[1:]); off != 0xFFFFFFFF {
+			s.start = int(off) + s.stepBase
+		}
+		switch mode & 0x03 {
+		case 1:
+			s.count = length
+		case 2:
+			s.count = int(uint64(length) * s.freq / 1000)
+		case 3:
+			s.count = -1
+			if bank := p.streamBank(s); s.start < len(bank) {
+				s.count = (len(bank) - s.start + s.stepSize - 1) / s.stepSize
+			}
+		default:
+			s.count = -1
+		}
+		s.loop = mode&0x80 != 0
+		p.restartStream(s)
+	case vgmCmdStreamStop:
+		s.active = false
+	case vgmCmdStreamFast:
+		block := int(le.Uint16(args[1:]))
+		if block >= len(p.blocks) {
+			return
+		}
+		b := p.blocks[block]
+		s.start = b.offset + s.stepBase
+		s.count = b.length / s.stepSize
+		s.loop = args[3]&0x01 != 0
+		p.restartStream(s)
+	}
+}
+
+// restartStream starts a stream from its start position.
+func (p *VGMPlayer) restartStream(s *vgmStream) {
+	s.pos = s.start
+	s.left = s.count
+	s.accum = 0
+	s.active = s.ym && s.freq > 0
+}
+
+// streamBank returns the data a stream reads from.
+func (p *VGMPlayer) streamBank(s *vgmStream) []byte {
+	if s.bank == vgmDataTypeYMPCM {
+		return p.pcm
+	}
+	return nil
+}
+
+func (p *VGMPlayer) streamsActive() bool {
+	for _, s := range p.streams {
+		if s != nil && s.active {
+			return true
+		}
+	}
+	return false
+}
+
+// stepStreams issues the DAC writes due in the current VGM sample.
+func (p *VGMPlayer) stepStreams() {
+	for _, s := range p.streams {
+		if s == nil || !s.active {
+			continue
+		}
+		s.accum += s.freq
+		for s.active && s.accum >= vgmSampleRate {
+			s.accum -= vgmSampleRate
+			bank := p.streamBank(s)
+			if s.left == 0 || s.pos >= len(bank) {
+				if !s.loop || s.count == 0 {
+					s.active = false
+					break
+				}
+				s.pos, s.left = s.start, s.count
+				continue
+			}
+			p.ym.WritePort(0, 0x2A)
+			p.ym.WritePort(1, bank[s.pos])
+			s.pos += s.stepSize
+			if s.left > 0 {
+				s.left--
+			}
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package emu
+
+import (
+	"encoding/binary"
+	"testing"
+)
+
+// buildVGM wraps a command stream in a minimal VGM 1.71 header. loop is
+// the offset of the loop point within cmds, or -1 for none.
+func buildVGM(cmds []byte, loop int) []byte {
+	hdr := make([]byte, vgmHeaderSize)
+	copy(hdr, "Vgm ")
+	le := binary.LittleEndian
+	le.PutUint32(hdr[0x04:], uint32(vgmHeaderSize+len(cmds)-4))
+	le.PutUint32(hdr[0x08:], vgmVersion)
+	le.PutUint32(hdr[0x0C:], uint32(NTSCTiming.Z80ClockHz))
+	le.PutUint32(hdr[0x2C:], uint32(NTSCTiming.M68KClockHz))
+	le.PutUint32(hdr[0x34:], vgmHeaderSize-0x34)
+	if loop >= 0 {
+		le.PutUint32(hdr[0x1C:], uint32(vgmHeaderSize+loop-0x1C))
+	}
+	return append(hdr, cmds...)
+}
+
+// playAll renders a player to completion, failing after limit frames.
+func playAll(t *testing.T, p *VGMPlayer, limit int) []int16 {
+	t.Helper()
+	var out []int16
+	for i := 0; !p.Done(); i++ {
+		if i == limit {
+			t.Fatal("playback did not finish")
+		}
+		out = append(out, p.RenderFrame()...)
+	}
+	return out
+}
+
+func peak(samples []int16) int {
+	m := 0
+	for _, s := range samples {
+		v := int(s)
+		if v < 0 {
+			v = -v
+		}
+		m = max(m, v)
+	}
+	return m
+}
+
+func TestVGMPlayer_Errors(t *testing.T) {
+	if _, err := NewVGMPlayer([]byte("not a vgm file at all, but long enough to have a header......")); err == nil {
+		t.Error("expected error for bad magic")
+	}
+	if _, err := NewVGMPlayer([]byte{0x1F, 0x8B, 0x00}); err == nil {
+		t.Error("expected error for truncated gzip")
+	}
+	vgm := buildVGM([]byte{vgmCmdEnd}, -1)
+	binary.LittleEndian.PutUint32(vgm[0x0C:], 0)
+	binary.LittleEndian.PutUint32(vgm[0x2C:], 0)
+	if _, err := NewVGMPlayer(vgm); err == nil {
+		t.Error("expected error for a file without supported chips")
+	}
+}
+
+func TestVGMPlayer_RecordedRoundTrip(t *testing.T) {
+	e := createTestEmulator()
+	if err := e.StartVGMLog(); err != nil {
+		t.Fatal(err)
+	}
+	// Channel 0, algorithm 7 with only the last operator audible
+	for _, w := range [][2]uint8{
+		{0xB0, 0x07}, {0xB4, 0xC0}, {0x3C, 0x01}, {0x4C, 0x00},
+		{0x5C, 0x1F}, {0x6C, 0x00}, {0x7C, 0x00}, {0x8C, 0x0F},
+		{0x40, 0x7F}, {0x44, 0x7F}, {0x48, 0x7F},
+		{0xA4, 0x22}, {0xA0, 0x69}, {0x28, 0xF0},
+	} {
+		e.ym2612.WritePort(0, w[0])
+		e.ym2612.WritePort(1, w[1])
+	}
+	for i := 0; i < 10; i++ {
+		e.RunFrame()
+	}
+	vgm, err := e.StopVGMLog()
+	if err != nil {
+		t.Fatal(err)
+	}
+	vgz, err := CompressVGZ(vgm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := NewVGMPlayer(vgz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out := playAll(t, p, 20)
+	if peak(out) < 1000 {
+		t.Errorf("expected an audible tone, peak %d", peak(out))
+	}
+	total := binary.LittleEndian.Uint32(vgm[0x18:])
+	if p.Samples() != uint64(total) {
+		t.Errorf("played %d samples, file has %d", p.Samples(), total)
+	}
+	// 10 frames at 48 kHz stereo, within a frame of rounding
+	want := 10 * p.SampleRate() / 60 * 2
+	if len(out) < want-1600 || len(out) > want+1600 {
+		t.Errorf("expected ~%d output values, got %d", want, len(out))
+	}
+}
+
+func TestVGMPlayer_Loops(t *testing.T) {
+	cmds := []byte{
+		vgmCmdWait, 0x00, 0x01, // 256 samples intro
+		vgmCmdWait, 0x00, 0x02, // 512 samples loop
+		vgmCmdEnd,
+	}
+	p, err := NewVGMPlayer(buildVGM(cmds, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetLoops(2)
+	playAll(t, p, 10)
+	if got := p.Samples(); got != 256+3*512 {
+		t.Errorf("expected %d samples, got %d", 256+3*512, got)
+	}
+}
+
+func TestVGMPlayer_SkipsOtherChips(t *testing.T) {
+	cmds := []byte{
+		0xB4, 0x00, 0x00, // NES APU write
+		0xC0, 0x00, 0x00, 0x00, // Sega PCM write
+		0xE1, 0x00, 0x00, 0x00, 0x00, // C352 write
+		vgmCmdYM2612P0, 0x2A, 0x42,
+		vgmCmdWaitShort | 0x0F,
+		vgmCmdEnd,
+	}
+	p, err := NewVGMPlayer(buildVGM(cmds, -1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	playAll(t, p, 2)
+	if p.ym.dacSample != 0x42 {
+		t.Errorf("expected DAC 0x42, got 0x%02X", p.ym.dacSample)
+	}
+	if p.Samples() != 16 {
+		t.Errorf("expected 16 samples, got %d", p.Samples())
+	}
+}
+
+func TestVGMPlayer_DACStream(t *testing.T) {
+	pcm := []byte{0x10, 0x20, 0x30, 0x40}
+	var cmds []byte
+	cmds = append(cmds, vgmCmdDataBlock, 0x66, vgmDataTypeYMPCM)
+	cmds = binary.LittleEndian.AppendUint32(cmds, uint32(len(pcm)))
+	cmds = append(cmds, pcm...)
+	cmds = append(cmds,
+		vgmCmdStreamSetup, 0x00, vgmChipYM2612, 0x00, 0x2A,
+		vgmCmdStreamData, 0x00, vgmDataTypeYMPCM, 0x01, 0x00,
+		vgmCmdStreamFreq, 0x00, 0x44, 0xAC, 0x00, 0x00, // 44100 Hz
+		vgmCmdStreamFast, 0x00, 0x00, 0x00, 0x00,
+		vgmCmdWaitShort|0x01, // 2 samples
+	)
+	p, err := NewVGMPlayer(buildVGM(append(cmds, vgmCmdEnd), -1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	playAll(t, p, 2)
+	if p.ym.dacSample != 0x20 {
+		t.Errorf("after 2 samples: expected DAC 0x20, got 0x%02X", p.ym.dacSample)
+	}
+
+	cmds = append(cmds, vgmCmdWaitShort|0x07, vgmCmdEnd)
+	p, err = NewVGMPlayer(buildVGM(cmds, -1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	playAll(t, p, 2)
+	if p.ym.dacSample != 0x40 {
+		t.Errorf("after the block: expected DAC 0x40, got 0x%02X", p.ym.dacSample)
+	}
+	if p.streamsActive() {
+		t.Error("expected the stream to stop at the end of its block")
+	}
+}
@@ -81,6 +81,10 @@ type YM2612 struct {
 	// Address latches for Part I (ports 0/1) and Part II (ports 2/3)
 	addrLatch [2]uint8
 
+	// Last value written to each register: Part I at 0-255, Part II at
+	// 256-511. The chip has no register readback; this is for memory tools.
+	regFile [512]uint8
+
 	// DAC
 	dacEnable bool
 	dacSample uint8 // 8-bit unsigned DAC sample
@@ -129,6 +133,8 @@ type YM2612 struct {
 	// Status port caching (discrete YM2612: ports 1/3 return last status)
 	lastStatus       uint8  // Last value returned by port 0/2 read
 	lastStatusSample uint64 // Native sample count when lastStatus was set
+
+	vgm *vgmRecorder // VGM logger, nil when not recording
 }
 
 // NewYM2612 creates a new YM2612 FM synthesizer.
@@ -138,6 +144,20 @@ func NewYM2612(clockHz, sampleRate int) *YM2612 {
 		clockHz:     clockHz,
 		nativeClock: clockHz / 144,
 		buffer:      make([]int16, 0, 2048),
+	}
+	y.reset()
+	return y
+}
+
+// reset returns the chip to its power-on state, as the /IC line does. The
+// clock, output buffer and VGM logger are kept.
+func (y *YM2612) reset() {
+	*y = YM2612{
+		sampleRate:  y.sampleRate,
+		clockHz:     y.clockHz,
+		nativeClock: y.nativeClock,
+		buffer:      y.buffer[:0],
+		vgm:         y.vgm,
 		dacSample:   0x80, // Center value: (0x80-128)<<6 = 0, no DC offset
 	}
 	// Initialize all channels with panning enabled (L+R)
@@ -149,7 +169,6 @@ func NewYM2612(clockHz, sampleRate int) *YM2612 {
 			y.ch[ch].op[op].egLevel = 0x3FF // Silent
 		}
 	}
-	return y
 }
 
 // ReadPort reads from a YM2612 port (0-3).
@@ -189,19 +208,32 @@ func (y *YM2612) WritePort(port uint8, val uint8) {
 	case 0:
 		y.addrLatch[0] = val
 	case 1:
+		if y.vgm != nil {
+			y.vgm.ymWrite(0, y.addrLatch[0], val)
+		}
 		y.writeRegister(0, y.addrLatch[0], val)
 		y.busyUntil = y.nativeSampleCount + busyDuration
 	case 2:
 		y.addrLatch[1] = val
 	case 3:
+		if y.vgm != nil {
+			y.vgm.ymWrite(1, y.addrLatch[1], val)
+		}
 		y.writeRegister(1, y.addrLatch[1], val)
 		y.busyUntil = y.nativeSampleCount + busyDuration
 	}
 }
 
+// PokeRegister writes a register as if through the data port, without
+// affecting the busy flag or the VGM log.
+func (y *YM2612) PokeRegister(part int, addr, val uint8) {
+	y.writeRegister(part&1, addr, val)
+}
+
 // writeRegister dispatches a register write to the appropriate handler.
 // part: 0 = Part I (channels 0-2), 1 = Part II (channels 3-5)
 func (y *YM2612) writeRegister(part int, addr, val uint8) {
+	y.regFile[part<<8|int(addr)] = val
 	switch {
 	case addr < 0x20:
 		// Invalid register range (below $20)
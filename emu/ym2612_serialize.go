@@ -6,7 +6,7 @@ import (
 )
 
 const (
-	ym2612SerializeVersion = 1
+	ym2612SerializeVersion = 2
 	// Per-operator serialization size:
 	// dt(1) + mul(1) + tl(1) + rs(1) + ar(1) + d1r(1) + d2r(1) + d1l(1) + rr(1) + am(1) +
 	// ssgEG(1) + ssgInverted(1) + phaseCounter(4) + phaseInc(4) +
@@ -25,8 +25,8 @@ const (
 	// nativeSampleCount(8) + busyUntil(8) + lastStatus(1) + lastStatusSample(8) = 75
 	ymGlobalSerializeSize = 75
 	// YM2612SerializeSize is the total bytes needed for YM2612 serialization.
-	// version(1) + 24 operators * 29 + 6 channels * 9 + global(75) = 826
-	YM2612SerializeSize = 1 + 24*ymOperatorSerializeSize + 6*ymChannelSerializeSize + ymGlobalSerializeSize
+	// version(1) + 24 operators * 29 + 6 channels * 9 + global(75) + regFile(512) = 1338
+	YM2612SerializeSize = 1 + 24*ymOperatorSerializeSize + 6*ymChannelSerializeSize + ymGlobalSerializeSize + 512
 )
 
 // Serialize writes YM2612 state to buf. buf must be at least YM2612SerializeSize bytes.
@@ -143,6 +143,10 @@ func (y *YM2612) Serialize(buf []byte) error {
 	binary.LittleEndian.PutUint64(buf[offset:], y.lastStatusSample)
 	offset += 8
 
+	// Register file
+	copy(buf[offset:], y.regFile[:])
+	offset += len(y.regFile)
+
 	return nil
 }
 
@@ -263,6 +267,10 @@ func (y *YM2612) Deserialize(buf []byte) error {
 	y.lastStatusSample = binary.LittleEndian.Uint64(buf[offset:])
 	offset += 8
 
+	// Register file
+	copy(y.regFile[:], buf[offset:offset+len(y.regFile)])
+	offset += len(y.regFile)
+
 	return nil
 }
 
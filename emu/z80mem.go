@@ -99,7 +99,9 @@ func (m *Z80Memory) Write(addr uint16, val uint8) {
 		case port <= 0x07: // VDP control port
 			m.bus.vdp.WriteControl(0, word)
 		case port >= 0x10 && port < 0x18: // PSG write port
-			m.bus.psg.Write(val)
+			m.bus.writePSG(val)
+		case port >= 0x1C: // VDP debug register
+			m.bus.vdp.WriteDebug(word)
 		}
 	case addr < 0x8000:
 		// Unused (0x6001-0x7EFF) and reserved (0x7F20-0x7FFF): ignore writes
@@ -0,0 +1,75 @@
+// Package wav writes 16-bit stereo PCM WAV files for the command-line
+// tools.
+package wav
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+)
+
+const headerSize = 44
+
+// Writer streams interleaved stereo samples to a WAV file. The header is
+// completed by Close once the data size is known.
+type Writer struct {
+	f    io.WriteSeeker
+	w    *bufio.Writer
+	rate int
+	size int // Bytes of sample data written
+}
+
+// NewWriter reserves space for the header and returns a Writer for
+// samples at rate Hz.
+func NewWriter(f io.WriteSeeker, rate int) (*Writer, error) {
+	if _, err := f.Write(make([]byte, headerSize)); err != nil {
+		return nil, err
+	}
+	return &Writer{f: f, w: bufio.NewWriter(f), rate: rate}, nil
+}
+
+// Write appends interleaved L/R samples.
+func (w *Writer) Write(samples []int16) error {
+	if err := binary.Write(w.w, binary.LittleEndian, samples); err != nil {
+		return err
+	}
+	w.size += len(samples) * 2
+	return nil
+}
+
+// Frames returns the number of stereo sample frames written.
+func (w *Writer) Frames() int {
+	return w.size / 4
+}
+
+// Close flushes buffered samples and writes the header. It does not close
+// the underlying file.
+func (w *Writer) Close() error {
+	if err := w.w.Flush(); err != nil {
+		return err
+	}
+
+	rate := uint32(w.rate)
+	hdr := make([]byte, 0, headerSize)
+	hdr = append(hdr, "RIFF"...)
+	hdr = binary.LittleEndian.AppendUint32(hdr, uint32(headerSize-8+w.size))
+	hdr = append(hdr, "WAVEfmt "...)
+	hdr = binary.LittleEndian.AppendUint32(hdr, 16)     // fmt chunk size
+	hdr = binary.LittleEndian.AppendUint16(hdr, 1)      // PCM
+	hdr = binary.LittleEndian.AppendUint16(hdr, 2)      // Channels
+	hdr = binary.LittleEndian.AppendUint32(hdr, rate)   // Sample rate
+	hdr = binary.LittleEndian.AppendUint32(hdr, rate*4) // Byte rate
+	hdr = binary.LittleEndian.AppendUint16(hdr, 4)      // Block align
+	hdr = binary.LittleEndian.AppendUint16(hdr, 16)     // Bits per sample
+	hdr = append(hdr, "data"...)
+	hdr = binary.LittleEndian.AppendUint32(hdr, uint32(w.size))
+
+	if _, err := w.f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := w.f.Write(hdr); err != nil {
+		return err
+	}
+	_, err := w.f.Seek(0, io.SeekEnd)
+	return err
+}
@@ -0,0 +1,72 @@
+package movie
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+
+	"github.com/user-none/emmd/emu"
+)
+
+// Gens .gmv layout
+const (
+	gmvMagic      = "Gens Movie TEST"
+	gmvHeaderSize = 0x40
+	gmvFlagState  = 0x80 // Movie starts from a Gens save state
+	gmvFlag3P     = 0x40 // Third byte of each frame is player 3
+	gmvFlagPAL    = 0x20
+)
+
+// ImportGMV converts a Gens .gmv movie. Gens save states cannot be loaded,
+// so movies that start from one are rejected, as are 3-player movies.
+// GMV files carry no ROM identity or state checkpoints, so the imported
+// movie plays without desync detection.
+//
+// Each GMV frame is three active-low bytes: players 1 and 2 as Up, Down,
+// Left, Right, A, B, C, Start from bit 0, then X, Y, Z, Mode for player 1
+// in the low nibble and player 2 in the high nibble.
+func ImportGMV(data []byte) (*Movie, error) {
+	if len(data) < gmvHeaderSize || string(data[:len(gmvMagic)]) != gmvMagic {
+		return nil, errors.New("not a Gens movie")
+	}
+	version := data[0x0F]
+	var flags byte
+	// Flags were added in version A
+	if version >= 'A' {
+		flags = data[0x16]
+	}
+	if flags&gmvFlagState != 0 {
+		return nil, errors.New("GMV starts from a Gens save state, which cannot be imported")
+	}
+	if flags&gmvFlag3P != 0 {
+		return nil, errors.New("3-player GMV movies are not supported")
+	}
+
+	m := &Movie{
+		Region:      emu.RegionNTSC,
+		Pads:        [4]emu.PeripheralType{gmvPad(data[0x14]), gmvPad(data[0x15])},
+		Rerecords:   binary.LittleEndian.Uint32(data[0x10:]),
+		Description: strings.TrimRight(string(data[0x18:0x40]), "\x00 "),
+	}
+	if flags&gmvFlagPAL != 0 {
+		m.Region = emu.RegionPAL
+	}
+
+	body := data[gmvHeaderSize:]
+	m.Frames = make([]Frame, len(body)/3)
+	for i := range m.Frames {
+		b := body[i*3 : i*3+3]
+		p1 := uint32(^b[0]) | uint32(^b[2]&0x0F)<<8
+		p2 := uint32(^b[1]) | uint32(^b[2]>>4)<<8
+		m.Frames[i] = Frame{p1, p2}
+	}
+	return m, nil
+}
+
+// gmvPad maps a GMV controller type byte ('3' or '6') to a pad.
+func gmvPad(b byte) emu.PeripheralType {
+	if b == '6' {
+		return emu.Peripheral6Button
+	}
+	return emu.Peripheral3Button
+}
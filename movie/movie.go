@@ -0,0 +1,182 @@
+// Package movie records and plays back deterministic input movies for
+// the Genesis core.
+//
+// A movie holds the SetInput bitmasks of all four players for every
+// frame, the pads and multitap they were recorded with, and a rerecord
+// count. It starts either from power-on or from an embedded save state.
+// While recording, a CRC32 of the full emulator state is stored at
+// regular frame intervals; playback compares against these checkpoints
+// and reports the first frame where the run desyncs.
+package movie
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/user-none/emmd/emu"
+)
+
+const (
+	movieMagic   = "EMMDMOV\x1a"
+	movieVersion = 1
+
+	flagFromState = 1 << 0
+
+	// DefaultHashInterval is the number of frames between state checkpoints.
+	DefaultHashInterval = 60
+)
+
+// Frame is the input for one frame: SetInput bitmasks for players 1-4.
+// Players 3 and 4 are the pads behind a multitap.
+type Frame [4]uint32
+
+// Checkpoint is the emulator state CRC32 after a frame has run. Frame 0
+// is the state before the first frame.
+type Checkpoint struct {
+	Frame int
+	CRC   uint32
+}
+
+// Movie is a recorded input log.
+type Movie struct {
+	ROMCRC      uint32 // CRC32 of the ROM, 0 when unknown (imported movies)
+	Region      emu.Region
+	Multitap    emu.Multitap
+	Pads        [4]emu.PeripheralType // Pad of each player: none, 3- or 6-button
+	StartState  []byte                // Save state to start from, nil for power-on
+	Rerecords   uint32
+	Description string
+
+	Frames      []Frame
+	Checkpoints []Checkpoint
+}
+
+// Encode serializes the movie.
+//
+// Layout (little-endian): magic(8) version(2) flags(2) region(1)
+// multitap(1) pads(4) romCRC(4) rerecords(4) descLen(2) desc
+// stateLen(4) state frameCount(4) frames(frameCount*8)
+// checkpointCount(4) checkpoints(count*8). Each frame stores the four
+// players as 16-bit masks.
+func (m *Movie) Encode() []byte {
+	le := binary.LittleEndian
+	var flags uint16
+	if m.StartState != nil {
+		flags |= flagFromState
+	}
+
+	out := append([]byte(nil), movieMagic...)
+	out = le.AppendUint16(out, movieVersion)
+	out = le.AppendUint16(out, flags)
+	out = append(out, byte(m.Region), byte(m.Multitap))
+	for _, t := range m.Pads {
+		out = append(out, byte(t))
+	}
+	out = le.AppendUint32(out, m.ROMCRC)
+	out = le.AppendUint32(out, m.Rerecords)
+	out = le.AppendUint16(out, uint16(len(m.Description)))
+	out = append(out, m.Description...)
+	out = le.AppendUint32(out, uint32(len(m.StartState)))
+	out = append(out, m.StartState...)
+
+	out = le.AppendUint32(out, uint32(len(m.Frames)))
+	for _, f := range m.Frames {
+		for _, buttons := range f {
+			out = le.AppendUint16(out, uint16(buttons))
+		}
+	}
+	out = le.AppendUint32(out, uint32(len(m.Checkpoints)))
+	for _, c := range m.Checkpoints {
+		out = le.AppendUint32(out, uint32(c.Frame))
+		out = le.AppendUint32(out, c.CRC)
+	}
+	return out
+}
+
+// Decode parses a movie produced by Encode.
+func Decode(data []byte) (*Movie, error) {
+	r := reader{data: data}
+	if string(r.bytes(len(movieMagic))) != movieMagic {
+		return nil, errors.New("not an emmd movie")
+	}
+	if v := r.u16(); v != movieVersion {
+		return nil, fmt.Errorf("unsupported movie version %d", v)
+	}
+	flags := r.u16()
+	m := &Movie{
+		Region:   emu.Region(r.u8()),
+		Multitap: emu.Multitap(r.u8()),
+	}
+	for i := range m.Pads {
+		m.Pads[i] = emu.PeripheralType(r.u8())
+	}
+	m.ROMCRC = r.u32()
+	m.Rerecords = r.u32()
+	m.Description = string(r.bytes(int(r.u16())))
+	state := r.bytes(int(r.u32()))
+	if flags&flagFromState != 0 {
+		m.StartState = append([]byte(nil), state...)
+	}
+
+	n := int(r.u32())
+	if n > len(r.data)/8 {
+		return nil, errors.New("movie truncated")
+	}
+	m.Frames = make([]Frame, n)
+	for i := range m.Frames {
+		for p := range m.Frames[i] {
+			m.Frames[i][p] = uint32(r.u16())
+		}
+	}
+	n = int(r.u32())
+	if n > len(r.data)/8 {
+		return nil, errors.New("movie truncated")
+	}
+	m.Checkpoints = make([]Checkpoint, n)
+	for i := range m.Checkpoints {
+		m.Checkpoints[i] = Checkpoint{Frame: int(r.u32()), CRC: r.u32()}
+	}
+	if r.short {
+		return nil, errors.New("movie truncated")
+	}
+	return m, nil
+}
+
+// reader reads little-endian fields, returning zeros once data runs out.
+type reader struct {
+	data  []byte
+	short bool
+}
+
+func (r *reader) bytes(n int) []byte {
+	if n > len(r.data) {
+		r.short = true
+		r.data = nil
+		return nil
+	}
+	b := r.data[:n]
+	r.data = r.data[n:]
+	return b
+}
+
+func (r *reader) u8() uint8 {
+	if b := r.bytes(1); b != nil {
+		return b[0]
+	}
+	return 0
+}
+
+func (r *reader) u16() uint16 {
+	if b := r.bytes(2); b != nil {
+		return binary.LittleEndian.Uint16(b)
+	}
+	return 0
+}
+
+func (r *reader) u32() uint32 {
+	if b := r.bytes(4); b != nil {
+		return binary.LittleEndian.Uint32(b)
+	}
+	return 0
+}
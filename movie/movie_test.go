@@ -0,0 +1,303 @@
+package movie
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/user-none/emmd/emu"
+)
+
+// Test program at $200 folds controller 1 reads into RAM so input changes
+// the emulator state:
+//
+//	$200  move.b  #$40,($a10009).l   ; TH as output
+//	$208  move.b  #$40,($a10003).l   ; TH high
+//	$210  move.b  ($a10003).l,d0
+//	$216  add.b   d0,d1
+//	$218  move.b  d1,($ff0001).l
+//	$21e  bra.s   $210
+func makeTestEmulator(t *testing.T, rom []byte) *emu.Emulator {
+	t.Helper()
+	if rom == nil {
+		rom = testROM()
+	}
+	e, err := emu.NewEmulator(rom, emu.RegionNTSC)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &e
+}
+
+func testROM() []byte {
+	rom := make([]byte, 0x400)
+	// SSP = $00FF0000, PC = $00000200
+	copy(rom[0:], []byte{0x00, 0xFF, 0x00, 0x00, 0x00, 0x00, 0x02, 0x00})
+	copy(rom[0x200:], []byte{
+		0x13, 0xFC, 0x00, 0x40, 0x00, 0xA1, 0x00, 0x09,
+		0x13, 0xFC, 0x00, 0x40, 0x00, 0xA1, 0x00, 0x03,
+		0x10, 0x39, 0x00, 0xA1, 0x00, 0x03,
+		0xD2, 0x00,
+		0x13, 0xC1, 0x00, 0xFF, 0x00, 0x01,
+		0x60, 0xF0,
+	})
+	return rom
+}
+
+// recordTestMovie records frames of scripted input on e.
+func recordTestMovie(t *testing.T, e *emu.Emulator, opts RecordOptions, frames int) *Recorder {
+	t.Helper()
+	r, err := NewRecorder(e, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < frames; i++ {
+		r.SetInput(0, uint32(i*7)&0xFF)
+		if err := r.RunFrame(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return r
+}
+
+func playToEnd(t *testing.T, p *Player) error {
+	t.Helper()
+	for {
+		err := p.RunFrame()
+		if err == ErrMovieEnd {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func TestRecordAndPlay(t *testing.T) {
+	e := makeTestEmulator(t, nil)
+	r := recordTestMovie(t, e, RecordOptions{HashInterval: 10, Description: "test"}, 100)
+	want, _ := stateCRC(e)
+
+	m, err := Decode(r.Movie().Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, r.Movie()) {
+		t.Fatalf("decoded movie differs:\n%+v\n%+v", m, r.Movie())
+	}
+	if len(m.Checkpoints) != 11 {
+		t.Errorf("expected 11 checkpoints, got %d", len(m.Checkpoints))
+	}
+
+	pe := makeTestEmulator(t, nil)
+	p, err := NewPlayer(pe, m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := playToEnd(t, p); err != nil {
+		t.Fatal(err)
+	}
+	if !p.Done() || p.Frame() != 100 {
+		t.Errorf("expected 100 frames played, got %d", p.Frame())
+	}
+	if got, _ := stateCRC(pe); got != want {
+		t.Error("final state differs from the recording")
+	}
+}
+
+func TestPlayDetectsDesync(t *testing.T) {
+	e := makeTestEmulator(t, nil)
+	r := recordTestMovie(t, e, RecordOptions{HashInterval: 10}, 50)
+	m := r.Movie()
+	m.Frames[23][0] ^= 0x20 // B, read while TH is high
+
+	p, err := NewPlayer(makeTestEmulator(t, nil), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = playToEnd(t, p)
+	var desync *DesyncError
+	if !errors.As(err, &desync) {
+		t.Fatalf("expected desync, got %v", err)
+	}
+	if desync.Frame != 30 {
+		t.Errorf("expected desync reported at frame 30, got %d", desync.Frame)
+	}
+}
+
+func TestRecordFromState(t *testing.T) {
+	e := makeTestEmulator(t, nil)
+	for i := 0; i < 15; i++ {
+		e.SetInput(0, 0x20)
+		e.RunFrame()
+	}
+	r := recordTestMovie(t, e, RecordOptions{FromState: true}, 120)
+	if r.Movie().StartState == nil {
+		t.Fatal("expected an embedded start state")
+	}
+
+	p, err := NewPlayer(makeTestEmulator(t, nil), r.Movie())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := playToEnd(t, p); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPowerOnMovieNeedsFreshEmulator(t *testing.T) {
+	r := recordTestMovie(t, makeTestEmulator(t, nil), RecordOptions{}, 10)
+
+	e := makeTestEmulator(t, nil)
+	e.SetInput(0, 0xFF)
+	e.RunFrame()
+	_, err := NewPlayer(e, r.Movie())
+	var desync *DesyncError
+	if !errors.As(err, &desync) || desync.Frame != 0 {
+		t.Errorf("expected desync at frame 0, got %v", err)
+	}
+}
+
+func TestPlayerRejectsOtherROM(t *testing.T) {
+	r := recordTestMovie(t, makeTestEmulator(t, nil), RecordOptions{}, 10)
+	rom := testROM()
+	rom[0x3FF] = 0x55
+	if _, err := NewPlayer(makeTestEmulator(t, rom), r.Movie()); err == nil {
+		t.Error("expected ROM mismatch error")
+	}
+}
+
+func TestRerecord(t *testing.T) {
+	e := makeTestEmulator(t, nil)
+	r := recordTestMovie(t, e, RecordOptions{HashInterval: 10}, 30)
+	snap, err := r.Snapshot()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 25; i++ {
+		r.SetInput(0, 0x80)
+		if err := r.RunFrame(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := r.Restore(snap); err != nil {
+		t.Fatal(err)
+	}
+	m := r.Movie()
+	if r.Frame() != 30 || m.Rerecords != 1 {
+		t.Fatalf("expected frame 30 with 1 rerecord, got %d with %d", r.Frame(), m.Rerecords)
+	}
+	if last := m.Checkpoints[len(m.Checkpoints)-1]; last.Frame != 30 {
+		t.Errorf("expected checkpoints truncated to frame 30, last is %d", last.Frame)
+	}
+	for i := 0; i < 25; i++ {
+		r.SetInput(0, 0x04)
+		if err := r.RunFrame(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p, err := NewPlayer(makeTestEmulator(t, nil), m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := playToEnd(t, p); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRecordMultitapPads(t *testing.T) {
+	e := makeTestEmulator(t, nil)
+	e.SetMultitap(emu.MultitapTeamPlayer)
+	e.SetPeripheral(0, emu.Peripheral6Button)
+	e.SetPeripheral(3, emu.PeripheralNone)
+	r, err := NewRecorder(e, RecordOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		r.SetInput(i%4, uint32(i))
+		if err := r.RunFrame(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m, err := Decode(r.Movie().Encode())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [4]emu.PeripheralType{emu.Peripheral6Button, emu.Peripheral3Button, emu.Peripheral3Button, emu.PeripheralNone}
+	if m.Multitap != emu.MultitapTeamPlayer || m.Pads != want {
+		t.Fatalf("expected Team Player with pads %v, got %d with %v", want, m.Multitap, m.Pads)
+	}
+	if m.Frames[19] != (Frame{16, 17, 18, 19}) {
+		t.Errorf("expected all four players recorded, got %v", m.Frames[19])
+	}
+
+	pe := makeTestEmulator(t, nil)
+	if _, err := NewPlayer(pe, m); err != nil {
+		t.Fatal(err)
+	}
+	if pe.Multitap() != emu.MultitapTeamPlayer || pe.Peripheral(0) != emu.Peripheral6Button || pe.Peripheral(3) != emu.PeripheralNone {
+		t.Error("player did not plug in the movie's multitap and pads")
+	}
+}
+
+func TestRecordRejectsNonPadDevices(t *testing.T) {
+	e := makeTestEmulator(t, nil)
+	e.SetPeripheral(1, emu.PeripheralMouse)
+	if _, err := NewRecorder(e, RecordOptions{}); err == nil {
+		t.Error("expected error recording with a mouse")
+	}
+
+	e = makeTestEmulator(t, nil)
+	e.SetLightGun(emu.LightGunMenacer)
+	if _, err := NewRecorder(e, RecordOptions{}); err == nil {
+		t.Error("expected error recording with a light gun")
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	if _, err := Decode([]byte("nope")); err == nil {
+		t.Error("expected error for bad magic")
+	}
+	data := (&Movie{Frames: make([]Frame, 10)}).Encode()
+	if _, err := Decode(data[:len(data)-10]); err == nil {
+		t.Error("expected error for truncated movie")
+	}
+}
+
+func TestImportGMV(t *testing.T) {
+	data := make([]byte, gmvHeaderSize, gmvHeaderSize+6)
+	copy(data, "Gens Movie TESTA")
+	data[0x10] = 42
+	data[0x14] = '6'
+	data[0x15] = '3'
+	data[0x16] = gmvFlagPAL
+	copy(data[0x18:], "speedrun")
+	data = append(data,
+		0xFF, 0xFF, 0xFF, // nothing pressed
+		^byte(0x81), ^byte(0x10), ^byte(0x21),
+	)
+
+	m, err := ImportGMV(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pads := [4]emu.PeripheralType{emu.Peripheral6Button, emu.Peripheral3Button}
+	if m.Rerecords != 42 || m.Pads != pads || m.Region != emu.RegionPAL || m.Description != "speedrun" {
+		t.Errorf("header not imported: %+v", m)
+	}
+	if len(m.Frames) != 2 || m.Frames[0] != (Frame{}) {
+		t.Fatalf("unexpected frames %v", m.Frames)
+	}
+	// P1: Up, Start and X; P2: A and Y
+	if want := (Frame{0x81 | 0x100, 0x10 | 0x200}); m.Frames[1] != want {
+		t.Errorf("expected %03X, got %03X", want, m.Frames[1])
+	}
+
+	data[0x16] = gmvFlagState
+	if _, err := ImportGMV(data); err == nil {
+		t.Error("expected error for a savestate movie")
+	}
+}
@@ -0,0 +1,254 @@
+package movie
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+
+	"github.com/user-none/emmd/emu"
+)
+
+// stateCRC hashes the complete emulator state.
+func stateCRC(e *emu.Emulator) (uint32, error) {
+	state, err := e.Serialize()
+	if err != nil {
+		return 0, err
+	}
+	return crc32.ChecksumIEEE(state), nil
+}
+
+// RecordOptions configures a new recording.
+type RecordOptions struct {
+	// FromState embeds the emulator's current state as the starting
+	// point. Otherwise the emulator must be freshly powered on.
+	FromState bool
+
+	// HashInterval is the number of frames between state checkpoints;
+	// 0 uses DefaultHashInterval.
+	HashInterval int
+
+	Description string
+}
+
+// Recorder captures the input of every frame run through it.
+type Recorder struct {
+	e        *emu.Emulator
+	m        *Movie
+	input    Frame
+	interval int
+}
+
+// NewRecorder starts recording on e with the pads and multitap it has
+// plugged in. Movies only hold pad input, so it fails when a mouse,
+// light gun or external device is connected.
+func NewRecorder(e *emu.Emulator, opts RecordOptions) (*Recorder, error) {
+	interval := opts.HashInterval
+	if interval <= 0 {
+		interval = DefaultHashInterval
+	}
+	if e.LightGun() != emu.LightGunNone {
+		return nil, errors.New("movies cannot record a light gun")
+	}
+
+	m := &Movie{
+		ROMCRC:      e.GetROMCRC32(),
+		Region:      e.GetRegion(),
+		Multitap:    e.Multitap(),
+		Description: opts.Description,
+	}
+	for player := range m.Pads {
+		switch t := e.Peripheral(player); t {
+		case emu.PeripheralNone, emu.Peripheral3Button, emu.Peripheral6Button:
+			m.Pads[player] = t
+		default:
+			return nil, fmt.Errorf("movies cannot record the device of player %d, only pads", player+1)
+		}
+	}
+	if opts.FromState {
+		state, err := e.Serialize()
+		if err != nil {
+			return nil, err
+		}
+		m.StartState = state
+	}
+	crc, err := stateCRC(e)
+	if err != nil {
+		return nil, err
+	}
+	m.Checkpoints = []Checkpoint{{Frame: 0, CRC: crc}}
+
+	return &Recorder{e: e, m: m, interval: interval}, nil
+}
+
+// SetInput sets a player's buttons for the following frames and forwards
+// them to the emulator.
+func (r *Recorder) SetInput(player int, buttons uint32) {
+	if player < 0 || player >= len(r.input) {
+		return
+	}
+	r.input[player] = buttons
+	r.e.SetInput(player, buttons)
+}
+
+// RunFrame records the current input and runs one frame.
+func (r *Recorder) RunFrame() error {
+	r.m.Frames = append(r.m.Frames, r.input)
+	r.e.RunFrame()
+	frame := len(r.m.Frames)
+	if frame%r.interval != 0 {
+		return nil
+	}
+	crc, err := stateCRC(r.e)
+	if err != nil {
+		return err
+	}
+	r.m.Checkpoints = append(r.m.Checkpoints, Checkpoint{Frame: frame, CRC: crc})
+	return nil
+}
+
+// Frame returns the number of frames recorded.
+func (r *Recorder) Frame() int {
+	return len(r.m.Frames)
+}
+
+// Snapshot is a save state taken during recording, tagged with the movie
+// frame it belongs to.
+type Snapshot struct {
+	Frame int
+	State []byte
+	input Frame
+}
+
+// Snapshot saves the emulator state at the current frame.
+func (r *Recorder) Snapshot() (Snapshot, error) {
+	state, err := r.e.Serialize()
+	if err != nil {
+		return Snapshot{}, err
+	}
+	return Snapshot{Frame: len(r.m.Frames), State: state, input: r.input}, nil
+}
+
+// Restore loads a snapshot and discards everything recorded after it,
+// counting a rerecord.
+func (r *Recorder) Restore(s Snapshot) error {
+	if s.Frame > len(r.m.Frames) {
+		return fmt.Errorf("snapshot frame %d is past the end of the movie", s.Frame)
+	}
+	if err := r.e.Deserialize(s.State); err != nil {
+		return err
+	}
+	r.m.Frames = r.m.Frames[:s.Frame]
+	cps := r.m.Checkpoints
+	for len(cps) > 0 && cps[len(cps)-1].Frame > s.Frame {
+		cps = cps[:len(cps)-1]
+	}
+	r.m.Checkpoints = cps
+	r.m.Rerecords++
+	for player, buttons := range s.input {
+		r.SetInput(player, buttons)
+	}
+	return nil
+}
+
+// Movie returns the recording so far. The movie is shared with the
+// recorder and keeps growing while recording continues.
+func (r *Recorder) Movie() *Movie {
+	return r.m
+}
+
+// DesyncError reports that playback diverged from the recording.
+type DesyncError struct {
+	Frame int    // Frame after which the state differed
+	Want  uint32 // Recorded state CRC
+	Got   uint32 // Playback state CRC
+}
+
+func (e *DesyncError) Error() string {
+	return fmt.Sprintf("movie desync at frame %d: state CRC %08X, recorded %08X", e.Frame, e.Got, e.Want)
+}
+
+// ErrMovieEnd is returned by Player.RunFrame once every frame has played.
+var ErrMovieEnd = errors.New("end of movie")
+
+// Player replays a movie on an emulator.
+type Player struct {
+	e     *emu.Emulator
+	m     *Movie
+	frame int
+	next  int // Index of the next checkpoint to verify
+}
+
+// NewPlayer prepares e to play m, plugging in the movie's pads and
+// multitap. The emulator must run the same ROM and region. Movies
+// starting from power-on need a freshly created emulator; otherwise the
+// frame 0 checkpoint reports a desync.
+func NewPlayer(e *emu.Emulator, m *Movie) (*Player, error) {
+	if m.ROMCRC != 0 && m.ROMCRC != e.GetROMCRC32() {
+		return nil, fmt.Errorf("movie was recorded with ROM CRC %08X, loaded ROM is %08X", m.ROMCRC, e.GetROMCRC32())
+	}
+	if m.Region != e.GetRegion() {
+		return nil, errors.New("movie was recorded in a different region")
+	}
+	e.SetLightGun(emu.LightGunNone)
+	e.SetMultitap(m.Multitap)
+	for player, t := range m.Pads {
+		e.SetPeripheral(player, t)
+	}
+	if m.StartState != nil {
+		if err := e.Deserialize(m.StartState); err != nil {
+			return nil, err
+		}
+	}
+	p := &Player{e: e, m: m}
+	if err := p.check(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// RunFrame applies the next frame's input and runs it. It returns a
+// *DesyncError when a checkpoint does not match and ErrMovieEnd once the
+// movie is over.
+func (p *Player) RunFrame() error {
+	if p.frame >= len(p.m.Frames) {
+		return ErrMovieEnd
+	}
+	f := p.m.Frames[p.frame]
+	for player, buttons := range f {
+		p.e.SetInput(player, buttons)
+	}
+	p.e.RunFrame()
+	p.frame++
+	return p.check()
+}
+
+// check verifies any checkpoint recorded for the current frame.
+func (p *Player) check() error {
+	cps := p.m.Checkpoints
+	for p.next < len(cps) && cps[p.next].Frame < p.frame {
+		p.next++
+	}
+	if p.next >= len(cps) || cps[p.next].Frame != p.frame {
+		return nil
+	}
+	want := cps[p.next].CRC
+	p.next++
+	got, err := stateCRC(p.e)
+	if err != nil {
+		return err
+	}
+	if got != want {
+		return &DesyncError{Frame: p.frame, Want: want, Got: got}
+	}
+	return nil
+}
+
+// Frame returns the number of frames played.
+func (p *Player) Frame() int {
+	return p.frame
+}
+
+// Done reports whether every frame has been played.
+func (p *Player) Done() bool {
+	return p.frame >= len(p.m.Frames)
+}
@@ -0,0 +1,149 @@
+// Package serial connects the serial lines of a Genesis controller port
+// to a byte stream such as a socket, FIFO or pseudo-terminal. A Stream
+// implements emu.SerialBackend.
+package serial
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"sync"
+)
+
+// rxBuffer is how many received bytes a Stream holds for the console
+// before the reader goroutine waits; txBuffer is how many transmitted
+// bytes wait for the writer goroutine before new ones are dropped.
+const (
+	rxBuffer = 4096
+	txBuffer = 4096
+)
+
+// Stream is an emu.SerialBackend over a byte stream. Goroutines read
+// ahead into a buffer and write from a queue, so neither Receive nor
+// Transmit blocks the emulator.
+type Stream struct {
+	rw        io.ReadWriter
+	rx        chan byte
+	tx        chan byte
+	done      chan struct{} // Closed by Close to stop both goroutines
+	closeOnce sync.Once
+}
+
+// NewStream starts reading rw and returns a backend that writes
+// transmitted bytes to it.
+func NewStream(rw io.ReadWriter) *Stream {
+	s := &Stream{
+		rw:   rw,
+		rx:   make(chan byte, rxBuffer),
+		tx:   make(chan byte, txBuffer),
+		done: make(chan struct{}),
+	}
+	go s.read()
+	go s.write()
+	return s
+}
+
+// Open opens a stream from a spec:
+//
+//	tcp:host:port   connect to a TCP server
+//	unix:path       connect to a Unix socket
+//	file:path       open a FIFO or pseudo-terminal for reading and writing
+func Open(spec string) (*Stream, error) {
+	kind, addr, ok := strings.Cut(spec, ":")
+	if !ok || addr == "" {
+		return nil, fmt.Errorf("invalid serial spec %q", spec)
+	}
+	var rw io.ReadWriter
+	var err error
+	switch kind {
+	case "tcp", "unix":
+		rw, err = net.Dial(kind, addr)
+	case "file":
+		rw, err = os.OpenFile(addr, os.O_RDWR, 0)
+	default:
+		return nil, fmt.Errorf("unknown serial backend %q", kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return NewStream(rw), nil
+}
+
+// read feeds the receive buffer until the stream ends or is closed.
+func (s *Stream) read() {
+	defer close(s.rx)
+	buf := make([]byte, 256)
+	for {
+		n, err := s.rw.Read(buf)
+		for _, b := range buf[:n] {
+			select {
+			case s.rx <- b:
+			case <-s.done:
+				return
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// write sends queued bytes to the stream, batching whatever has queued up
+// during the previous write, until Close.
+func (s *Stream) write() {
+	buf := make([]byte, 0, 256)
+	for {
+		select {
+		case b := <-s.tx:
+			buf = append(buf[:0], b)
+		case <-s.done:
+			return
+		}
+	more:
+		for len(buf) < cap(buf) {
+			select {
+			case b := <-s.tx:
+				buf = append(buf, b)
+			default:
+				break more
+			}
+		}
+		// Write errors drop the bytes, as a disconnected cable would
+		s.rw.Write(buf)
+	}
+}
+
+// Transmit queues a byte for the stream. The byte is dropped when the
+// queue is full because the other end is not reading.
+func (s *Stream) Transmit(b byte) {
+	select {
+	case s.tx <- b:
+	default:
+	}
+}
+
+// Receive returns the next buffered byte, if any.
+func (s *Stream) Receive() (byte, bool) {
+	select {
+	case b, ok := <-s.rx:
+		return b, ok
+	default:
+		return 0, false
+	}
+}
+
+// Close stops both goroutines and closes the stream if it is an
+// io.Closer. Bytes still queued are discarded. Calls after the first do
+// nothing.
+func (s *Stream) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.done)
+		if c, ok := s.rw.(io.Closer); ok {
+			err = c.Close()
+		}
+	})
+	return err
+}
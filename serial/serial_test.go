@@ -0,0 +1,75 @@
+package serial
+
+import (
+	"net"
+	"testing"
+	"time"
+)
+
+func TestStream_TransmitDoesNotBlock(t *testing.T) {
+	console, remote := net.Pipe()
+	defer remote.Close()
+	s := NewStream(console)
+	defer s.Close()
+
+	// net.Pipe is unbuffered: a synchronous write would wait for the read
+	done := make(chan struct{})
+	go func() {
+		s.Transmit(0x5A)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Transmit blocked on a stream nobody reads")
+	}
+
+	buf := make([]byte, 1)
+	remote.SetReadDeadline(time.Now().Add(time.Second))
+	if _, err := remote.Read(buf); err != nil || buf[0] != 0x5A {
+		t.Errorf("expected 0x5A on the stream, got 0x%02X (%v)", buf[0], err)
+	}
+}
+
+// endlessReader never ends and never closes, like a device that keeps
+// sending after the emulator stops reading.
+type endlessReader struct{}
+
+func (endlessReader) Read(p []byte) (int, error)  { return len(p), nil }
+func (endlessReader) Write(p []byte) (int, error) { return len(p), nil }
+
+func TestStream_CloseStopsReaderWithFullBuffer(t *testing.T) {
+	s := NewStream(endlessReader{})
+	// Let the reader fill the receive buffer and wait on it
+	for len(s.rx) < rxBuffer {
+		time.Sleep(time.Millisecond)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The reader closes rx once it returns
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case _, ok := <-s.rx:
+			if !ok {
+				return
+			}
+		case <-deadline:
+			t.Fatal("reader still running after Close")
+		}
+	}
+}
+
+func TestStream_CloseTwice(t *testing.T) {
+	console, remote := net.Pipe()
+	defer remote.Close()
+	s := NewStream(console)
+	if err := s.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Close(); err != nil {
+		t.Errorf("second Close returned %v", err)
+	}
+}